@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+)
+
+// runAccounts runs generate once per account of the config file, one after
+// the other, each as a child process with NB_ACCOUNT set to the account's
+// name. A failing account does not stop the others; the exit code is the
+// highest of the accounts', so a single fatal run fails the whole run.
+func runAccounts(ctx context.Context, config *Config, args []string) int {
+	slog.Info("Generating several accounts", "accounts", len(config.Accounts), "output_dir", config.OutputDir)
+
+	exitCodes := make([]int, len(config.Accounts))
+	highest := exitOK
+	for i, account := range config.Accounts {
+		if ctx.Err() != nil {
+			exitCodes[i] = exitInterrupted
+			highest = exitInterrupted
+			continue
+		}
+
+		slog.Info("Generating account", "account", account.Name, "server_url", account.ServerURL)
+		exitCode, err := runChild(ctx, append([]string{commandGenerate}, args...), []string{"NB_ACCOUNT=" + account.Name})
+		if err != nil {
+			slog.Error("Failed to run account", "account", account.Name, "error", err)
+		}
+		exitCodes[i] = exitCode
+		highest = max(highest, exitCode)
+	}
+
+	fmt.Println("\nAccounts:")
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "  ACCOUNT\tPROVIDER\tDIRECTORY\tRESULT\n")
+	for i, account := range config.Accounts {
+		fmt.Fprintf(table, "  %s\tnetbird.%s\t%s\t%s\n", account.Name, account.Name, filepath.Join(config.OutputDir, account.Name), accountResult(exitCodes[i]))
+	}
+	table.Flush()
+	return highest
+}
+
+// accountResult describes the exit code of an account's run
+func accountResult(exitCode int) string {
+	switch exitCode {
+	case exitOK:
+		return "ok"
+	case exitPartialFailure:
+		return "partial failure"
+	case exitInterrupted:
+		return "interrupted"
+	default:
+		return fmt.Sprintf("failed (exit code %d)", exitCode)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// APIStats collects request statistics per API endpoint, for --stats and
+// report.json. A nil *APIStats records nothing, so probes outside a run need
+// none. It is safe for concurrent use.
+type APIStats struct {
+	mu        sync.Mutex
+	endpoints map[string]*EndpointStats
+}
+
+// EndpointStats are the statistics of one endpoint. Requests counts every
+// HTTP request sent, retries included; Latency is their total duration.
+type EndpointStats struct {
+	Endpoint    string
+	Requests    int
+	Retries     int
+	Errors      int // requests that failed after the last retry
+	CacheHits   int // served from --cache-dir without a request
+	NotModified int // revalidated with a 304 and served from the cache
+	Bytes       int64
+	Latency     time.Duration
+	MaxLatency  time.Duration
+}
+
+func newAPIStats() *APIStats {
+	return &APIStats{endpoints: make(map[string]*EndpointStats)}
+}
+
+// endpoint returns the statistics of an endpoint, without its query. The
+// caller holds the lock.
+func (a *APIStats) endpoint(path string) *EndpointStats {
+	path, _, _ = strings.Cut(path, "?")
+	stats, ok := a.endpoints[path]
+	if !ok {
+		stats = &EndpointStats{Endpoint: path}
+		a.endpoints[path] = stats
+	}
+	return stats
+}
+
+// RecordRequest records one HTTP request and the size of its response body
+func (a *APIStats) RecordRequest(path string, latency time.Duration, bytes int) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stats := a.endpoint(path)
+	stats.Requests++
+	stats.Bytes += int64(bytes)
+	stats.Latency += latency
+	stats.MaxLatency = max(stats.MaxLatency, latency)
+}
+
+// RecordRetry records that a request is sent again after a transient failure
+func (a *APIStats) RecordRetry(path string) {
+	a.record(path, func(stats *EndpointStats) { stats.Retries++ })
+}
+
+// RecordError records a request that failed for good
+func (a *APIStats) RecordError(path string) {
+	a.record(path, func(stats *EndpointStats) { stats.Errors++ })
+}
+
+// RecordCacheHit records a response served from the cache, revalidated with
+// the server if notModified is set
+func (a *APIStats) RecordCacheHit(path string, notModified bool) {
+	a.record(path, func(stats *EndpointStats) {
+		if notModified {
+			stats.NotModified++
+		} else {
+			stats.CacheHits++
+		}
+	})
+}
+
+func (a *APIStats) record(path string, update func(stats *EndpointStats)) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	update(a.endpoint(path))
+}
+
+// Endpoints returns the statistics of every endpoint, slowest first
+func (a *APIStats) Endpoints() []EndpointStats {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	endpoints := make([]EndpointStats, 0, len(a.endpoints))
+	for _, stats := range a.endpoints {
+		endpoints = append(endpoints, *stats)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].Latency != endpoints[j].Latency {
+			return endpoints[i].Latency > endpoints[j].Latency
+		}
+		return endpoints[i].Endpoint < endpoints[j].Endpoint
+	})
+	return endpoints
+}
+
+// printStats writes the --stats tables: the API requests per endpoint and the
+// time spent on the API compared to terraform and the whole run
+func printStats(w io.Writer, s *RunSummary) {
+	endpoints := s.API.Endpoints()
+	var total EndpointStats
+	fmt.Fprintln(w, "\nAPI requests:")
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "  ENDPOINT\tREQUESTS\tRETRIES\tERRORS\tCACHED\t304\tSIZE\tTOTAL\tAVERAGE\tMAX\n")
+	for _, stats := range endpoints {
+		fmt.Fprintf(table, "  %s\t%s\n", stats.Endpoint, formatEndpointStats(stats))
+		total.Requests += stats.Requests
+		total.Retries += stats.Retries
+		total.Errors += stats.Errors
+		total.CacheHits += stats.CacheHits
+		total.NotModified += stats.NotModified
+		total.Bytes += stats.Bytes
+		total.Latency += stats.Latency
+		total.MaxLatency = max(total.MaxLatency, stats.MaxLatency)
+	}
+	fmt.Fprintf(table, "  total\t%s\n", formatEndpointStats(total))
+	table.Flush()
+
+	// Resource types are fetched concurrently, so the request time can exceed
+	// the time the run took
+	var terraform time.Duration
+	for _, phase := range s.Phases {
+		if strings.HasPrefix(phase.Name, "terraform_") || strings.HasPrefix(phase.Name, "tfc_") {
+			terraform += phase.Duration
+		}
+	}
+	fmt.Fprintf(w, "\nTime spent: %s in API requests, %s in terraform, %s in the whole run\n",
+		total.Latency.Round(time.Millisecond), terraform.Round(time.Millisecond), s.FinishedAt.Sub(s.StartedAt).Round(time.Millisecond))
+}
+
+// formatEndpointStats renders the columns of one row of the stats table
+func formatEndpointStats(stats EndpointStats) string {
+	average := time.Duration(0)
+	if stats.Requests > 0 {
+		average = stats.Latency / time.Duration(stats.Requests)
+	}
+	return fmt.Sprintf("%d\t%d\t%d\t%d\t%d\t%s\t%s\t%s\t%s",
+		stats.Requests, stats.Retries, stats.Errors, stats.CacheHits, stats.NotModified, formatBytes(stats.Bytes),
+		stats.Latency.Round(time.Millisecond), average.Round(time.Millisecond), stats.MaxLatency.Round(time.Millisecond))
+}
+
+// formatBytes renders a size in B, KB or MB
+func formatBytes(bytes int64) string {
+	switch {
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(bytes)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", bytes)
+}
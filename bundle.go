@@ -0,0 +1,218 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// defaultBundleFile is where `bundle` writes the archive when no path is given
+const defaultBundleFile = "netbird-bundle.tar.gz"
+
+// bundleManifestFile is the archive member describing the bundle
+const bundleManifestFile = "manifest.json"
+
+// BundleManifest describes an air-gap bundle. The API token is never stored.
+// The bundle holds only the API responses: the provider schema and the
+// templates the configuration is generated from are part of the importer, so
+// a bundle is generated offline only by the importer build that captured it.
+type BundleManifest struct {
+	ImporterVersion string    `json:"importer_version"`
+	ImporterBuild   string    `json:"importer_build"`
+	ProviderVersion string    `json:"provider_version"`
+	ServerURL       string    `json:"server_url"`
+	ServerVersion   string    `json:"server_version,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	Endpoints       []string  `json:"endpoints"`
+}
+
+// BundleAPI serves API responses captured in a bundle, implementing
+// lib.NetBirdAPI for offline generation
+type BundleAPI struct {
+	Manifest  BundleManifest
+	responses map[string][]byte
+}
+
+// runBundle captures the API responses needed for generation into an archive
+// that `generate --from-bundle` can use without network access
+func runBundle(ctx context.Context, config *Config) error {
+	service := newService(config)
+
+	endpoints := importerEndpoints(config.ExcludedTypes)
+
+	responses := make(map[string][]byte)
+	for _, endpoint := range endpoints {
+		slog.Info("Capturing API response", "endpoint", endpoint)
+		body, err := service.GetRaw(ctx, endpoint)
+		if err != nil {
+			return fmt.Errorf("failed to fetch %s: %w", endpoint, err)
+		}
+		responses[endpoint] = body
+	}
+
+	manifest := BundleManifest{
+		ImporterVersion: version,
+		ImporterBuild:   importerBuild(),
+		ProviderVersion: config.ProviderVersion,
+		ServerURL:       config.ServerURL,
+		ServerVersion:   detectServerVersion(ctx, service),
+		CreatedAt:       time.Now().UTC(),
+		Endpoints:       endpoints,
+	}
+
+	err := writeBundle(config.OutputDir, manifest, responses)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nBundle written to %s (%d endpoints)\n", config.OutputDir, len(endpoints))
+	fmt.Printf("Generate offline with: netbird-importer generate --from-bundle %s [output-directory]\n", config.OutputDir)
+	return nil
+}
+
+// writeBundle writes the manifest and captured responses as a gzipped tarball
+func writeBundle(bundlePath string, manifest BundleManifest, responses map[string][]byte) error {
+	file, err := os.Create(bundlePath)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	defer file.Close()
+
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	members := []struct {
+		name string
+		data []byte
+	}{{bundleManifestFile, manifestData}}
+	for _, endpoint := range manifest.Endpoints {
+		members = append(members, struct {
+			name string
+			data []byte
+		}{bundleMemberName(endpoint), responses[endpoint]})
+	}
+
+	for _, member := range members {
+		header := &tar.Header{
+			Name:    member.name,
+			Mode:    0644,
+			Size:    int64(len(member.data)),
+			ModTime: manifest.CreatedAt,
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write bundle: %w", err)
+		}
+		if _, err := tarWriter.Write(member.data); err != nil {
+			return fmt.Errorf("failed to write bundle: %w", err)
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return file.Close()
+}
+
+// OpenBundle reads a bundle written by `bundle`
+func OpenBundle(bundlePath string) (*BundleAPI, error) {
+	file, err := os.Open(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle %s: %w", bundlePath, err)
+	}
+
+	members := make(map[string][]byte)
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle %s: %w", bundlePath, err)
+		}
+
+		data, err := io.ReadAll(tarReader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle %s: %w", bundlePath, err)
+		}
+		members[header.Name] = data
+	}
+
+	bundle := &BundleAPI{responses: make(map[string][]byte)}
+	manifestData, exists := members[bundleManifestFile]
+	if !exists {
+		return nil, fmt.Errorf("bundle %s has no %s", bundlePath, bundleManifestFile)
+	}
+	if err := json.Unmarshal(manifestData, &bundle.Manifest); err != nil {
+		return nil, fmt.Errorf("invalid bundle manifest: %w", err)
+	}
+	build := importerBuild()
+	if bundle.Manifest.ImporterBuild == "" {
+		return nil, fmt.Errorf("bundle %s was captured by importer %s, which does not record its build: capture it again with this importer (%s)", bundlePath, bundle.Manifest.ImporterVersion, build)
+	}
+	if bundle.Manifest.ImporterBuild != build {
+		return nil, fmt.Errorf("bundle %s was captured by importer build %s, this is %s: generate with the same build or capture the bundle again", bundlePath, bundle.Manifest.ImporterBuild, build)
+	}
+
+	for _, endpoint := range bundle.Manifest.Endpoints {
+		data, exists := members[bundleMemberName(endpoint)]
+		if !exists {
+			return nil, fmt.Errorf("bundle %s is missing the response for %s", bundlePath, endpoint)
+		}
+		bundle.responses[endpoint] = data
+	}
+
+	return bundle, nil
+}
+
+// Get decodes a captured API response
+func (b *BundleAPI) Get(ctx context.Context, endpoint string, result interface{}) error {
+	body, exists := b.responses[endpoint]
+	if !exists {
+		return fmt.Errorf("%s was not captured in the bundle", endpoint)
+	}
+
+	_, err := decodeTolerant(adaptResponse(endpoint, b.Manifest.ServerVersion, body), result)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", endpoint, err)
+	}
+	return nil
+}
+
+// bundleMemberName maps an API endpoint to its archive member, /api/setup-keys
+// becomes api/setup-keys.json
+func bundleMemberName(endpoint string) string {
+	return path.Clean(strings.TrimPrefix(endpoint, "/")) + ".json"
+}
+
+// containsString reports whether the list contains the value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
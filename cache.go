@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"netbird-terraformer/lib"
+)
+
+// defaultCacheTTL is how long a cached API response is used before it is
+// fetched again
+const defaultCacheTTL = 15 * time.Minute
+
+// cacheEntry is one cached API response. The raw body is stored as returned by
+// the server, so decoding works the same as for a live response. The ETag, if
+// the server sent one, revalidates the entry once it expired.
+type cacheEntry struct {
+	ServerURL string          `json:"server_url"`
+	Endpoint  string          `json:"endpoint"`
+	FetchedAt time.Time       `json:"fetched_at"`
+	ETag      string          `json:"etag,omitempty"`
+	Body      json.RawMessage `json:"body"`
+}
+
+// CachedAPI serves API responses from an on-disk cache, fetching and storing
+// them when missing or older than the TTL. An expired response with an ETag
+// is fetched conditionally and reused if the server answers 304 Not Modified.
+// It implements lib.NetBirdAPI.
+type CachedAPI struct {
+	service   *NetBirdService
+	dir       string
+	ttl       time.Duration
+	refresh   bool   // ignore cached responses, but still store fresh ones
+	serverURL string // part of the cache key
+	tokenHash string // part of the cache key: accounts behind one URL differ by token
+}
+
+// NewCachedAPI wraps the API client with the cache in dir
+func NewCachedAPI(service *NetBirdService, config *Config) *CachedAPI {
+	tokenHash := sha256.Sum256([]byte(config.APIToken))
+	return &CachedAPI{
+		service:   service,
+		dir:       config.CacheDir,
+		ttl:       config.CacheTTL,
+		refresh:   config.CacheRefresh,
+		serverURL: config.ServerURL,
+		tokenHash: hex.EncodeToString(tokenHash[:]),
+	}
+}
+
+// Get decodes a cached response, or fetches and caches it
+func (c *CachedAPI) Get(ctx context.Context, endpoint string, result interface{}) error {
+	body, err := c.GetRaw(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+	return c.service.decodeResponse(endpoint, body, result)
+}
+
+// GetRaw returns the response body of an endpoint from the cache or the API
+func (c *CachedAPI) GetRaw(ctx context.Context, endpoint string) ([]byte, error) {
+	path := c.path(endpoint)
+
+	var expired *cacheEntry
+	if !c.refresh {
+		entry, err := readCacheEntry(path)
+		switch {
+		case err == nil && time.Since(entry.FetchedAt) < c.ttl:
+			slog.Debug("Using cached API response", "component", "cache", "endpoint", endpoint, "age", time.Since(entry.FetchedAt).Round(time.Second))
+			c.service.stats.RecordCacheHit(endpoint, false)
+			return entry.Body, nil
+		case err == nil:
+			slog.Debug("Cached API response expired", "component", "cache", "endpoint", endpoint, "etag", entry.ETag != "")
+			expired = entry
+		case !errors.Is(err, fs.ErrNotExist):
+			slog.Warn("Ignoring unreadable cache entry", "endpoint", endpoint, "path", path, "error", err)
+		}
+	}
+
+	etag := ""
+	if expired != nil {
+		etag = expired.ETag
+	}
+	body, etag, err := c.service.GetConditional(ctx, endpoint, etag)
+	if errors.Is(err, errNotModified) && expired != nil {
+		slog.Debug("Cached API response not modified", "component", "cache", "endpoint", endpoint)
+		c.service.stats.RecordCacheHit(endpoint, true)
+		body, err = expired.Body, nil
+		if etag == "" {
+			etag = expired.ETag
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeCacheEntry(path, cacheEntry{
+		ServerURL: c.serverURL,
+		Endpoint:  endpoint,
+		FetchedAt: time.Now().UTC(),
+		ETag:      etag,
+		Body:      body,
+	})
+	if err != nil {
+		slog.Warn("Failed to cache API response", "endpoint", endpoint, "error", err)
+	}
+	return body, nil
+}
+
+// path returns the cache file of an endpoint. The key covers the server, the
+// token and the endpoint; the token itself is never written.
+func (c *CachedAPI) path(endpoint string) string {
+	key := sha256.Sum256([]byte(c.serverURL + "\n" + c.tokenHash + "\n" + endpoint))
+	return filepath.Join(c.dir, hex.EncodeToString(key[:16])+".json")
+}
+
+// readCacheEntry reads a cache file
+func readCacheEntry(path string) (*cacheEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("invalid cache entry: %w", err)
+	}
+	return &entry, nil
+}
+
+// writeCacheEntry writes a cache file readable only by the current user, since
+// responses describe the whole account
+func writeCacheEntry(path string, entry cacheEntry) error {
+	if !json.Valid(entry.Body) {
+		return fmt.Errorf("response is not valid JSON")
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic writes a file readable only by the current user, creating
+// its directory. The file is renamed into place so a concurrent reader never
+// sees partial content.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return lib.ReplaceFile(tmp.Name(), path)
+}
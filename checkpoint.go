@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"netbird-terraformer/lib"
+)
+
+// checkpointState is the progress of a run as written to the checkpoint file.
+// Generated files are not stored: generating again from the same responses
+// yields the same files.
+type checkpointState struct {
+	ServerURL string                     `json:"server_url"`
+	TokenHash string                     `json:"token_hash"`
+	StartedAt time.Time                  `json:"started_at"`
+	UpdatedAt time.Time                  `json:"updated_at"`
+	Responses map[string]json.RawMessage `json:"responses"` // raw bodies by endpoint
+	Imported  map[string]string          `json:"imported"`  // imported object IDs by address
+}
+
+// Checkpoint persists the API responses and completed imports of a run to the
+// output directory, so an interrupted run resumes with --resume instead of
+// fetching and importing everything again. It is removed once a run succeeds.
+type Checkpoint struct {
+	path string
+
+	mu    sync.Mutex
+	state checkpointState
+}
+
+// openCheckpoint starts the checkpoint of a run. With resume the checkpoint
+// of an earlier run against the same server and token is continued; anything
+// else starts over.
+func openCheckpoint(config *Config, resume bool) *Checkpoint {
+	tokenHash := sha256.Sum256([]byte(config.APIToken))
+	checkpoint := &Checkpoint{
+		path: filepath.Join(config.OutputDir, lib.CheckpointFile),
+		state: checkpointState{
+			ServerURL: config.ServerURL,
+			TokenHash: hex.EncodeToString(tokenHash[:]),
+			StartedAt: time.Now().UTC(),
+			Responses: make(map[string]json.RawMessage),
+			Imported:  make(map[string]string),
+		},
+	}
+
+	previous, err := readCheckpoint(checkpoint.path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if resume {
+			slog.Warn("No checkpoint to resume from, starting over", "path", checkpoint.path)
+		}
+	case err != nil:
+		slog.Warn("Ignoring unreadable checkpoint, starting over", "path", checkpoint.path, "error", err)
+	case !resume:
+		slog.Info("Discarding the checkpoint of an earlier run, pass --resume to continue it", "path", checkpoint.path, "started_at", previous.StartedAt.Format(time.RFC3339))
+	case previous.ServerURL != checkpoint.state.ServerURL || previous.TokenHash != checkpoint.state.TokenHash:
+		slog.Warn("Checkpoint is of another server or token, starting over", "path", checkpoint.path, "server_url", previous.ServerURL)
+	default:
+		if previous.Responses == nil {
+			previous.Responses = make(map[string]json.RawMessage)
+		}
+		if previous.Imported == nil {
+			previous.Imported = make(map[string]string)
+		}
+		checkpoint.state = *previous
+		slog.Info("Resuming from checkpoint", "started_at", previous.StartedAt.Format(time.RFC3339), "responses", len(previous.Responses), "imported", len(previous.Imported))
+	}
+	return checkpoint
+}
+
+// readCheckpoint reads a checkpoint file
+func readCheckpoint(path string) (*checkpointState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var state checkpointState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint: %w", err)
+	}
+	return &state, nil
+}
+
+// response returns the body of an endpoint saved by an earlier run
+func (c *Checkpoint) response(endpoint string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	body, exists := c.state.Responses[endpoint]
+	return body, exists
+}
+
+// saveResponse adds the body of an endpoint to the checkpoint
+func (c *Checkpoint) saveResponse(endpoint string, body []byte) error {
+	if !json.Valid(body) {
+		return fmt.Errorf("response is not valid JSON")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state.Responses[endpoint] = body
+	return c.write()
+}
+
+// Imported reports whether an earlier run already imported the object into
+// the address
+func (c *Checkpoint) Imported(cmd lib.ImportCommand) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id, exists := c.state.Imported[cmd.ResourceAddress]
+	return exists && id == cmd.ResourceID
+}
+
+// SaveImports records imports whose state was synced to the output directory
+func (c *Checkpoint) SaveImports(commands ...lib.ImportCommand) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cmd := range commands {
+		c.state.Imported[cmd.ResourceAddress] = cmd.ResourceID
+	}
+	if err := c.write(); err != nil {
+		slog.Warn("Failed to update checkpoint", "path", c.path, "error", err)
+	}
+}
+
+// Remove deletes the checkpoint once the run it belongs to has completed
+func (c *Checkpoint) Remove() {
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("Failed to remove checkpoint", "path", c.path, "error", err)
+	}
+}
+
+// write replaces the checkpoint file; c.mu must be held
+func (c *Checkpoint) write() error {
+	c.state.UpdatedAt = time.Now().UTC()
+	data, err := json.Marshal(c.state)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path, data)
+}
+
+// rawAPI returns undecoded response bodies, from the API or the cache
+type rawAPI interface {
+	GetRaw(ctx context.Context, endpoint string) ([]byte, error)
+}
+
+// CheckpointAPI serves the responses saved in a checkpoint and saves the ones
+// it fetches. It implements lib.NetBirdAPI.
+type CheckpointAPI struct {
+	service    *NetBirdService
+	source     rawAPI
+	checkpoint *Checkpoint
+}
+
+// NewCheckpointAPI wraps a source of responses with the checkpoint
+func NewCheckpointAPI(service *NetBirdService, source rawAPI, checkpoint *Checkpoint) *CheckpointAPI {
+	return &CheckpointAPI{service: service, source: source, checkpoint: checkpoint}
+}
+
+// Get decodes a saved response, or fetches and saves it. Failed requests are
+// not saved, so a resumed run retries them.
+func (c *CheckpointAPI) Get(ctx context.Context, endpoint string, result interface{}) error {
+	body, saved := c.checkpoint.response(endpoint)
+	if saved {
+		slog.Debug("Using API response from checkpoint", "component", "checkpoint", "endpoint", endpoint)
+	} else {
+		var err error
+		body, err = c.source.GetRaw(ctx, endpoint)
+		if err != nil {
+			return err
+		}
+		if err := c.checkpoint.saveResponse(endpoint, body); err != nil {
+			slog.Warn("Failed to save API response to checkpoint", "endpoint", endpoint, "error", err)
+		}
+	}
+	return c.service.decodeResponse(endpoint, body, result)
+}
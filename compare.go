@@ -0,0 +1,423 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"netbird-terraformer/resources"
+)
+
+// accountSnapshot holds the configuration of one account compared by
+// compare-accounts, keyed the way objects are matched across accounts: groups
+// and policies by name, routes by network identifier and routing peer or peer
+// groups. Names need not be unique, so a key holds every object sharing it.
+// IDs never match across accounts, so group and peer references are resolved
+// to names.
+type accountSnapshot struct {
+	Label      string
+	Groups     map[string][]resources.Group
+	Policies   map[string][]resources.Policy
+	Routes     map[string][]resources.Route
+	groupNames map[string]string
+	peerNames  map[string]string
+}
+
+// accountDifference is one difference between the compared accounts
+type accountDifference struct {
+	Kind   string // group, policy or route
+	Name   string
+	Detail string
+}
+
+// runCompareAccounts fetches the account of NB_MANAGEMENT_URL/NB_PAT and the
+// target account of --target-url/NB_TARGET_PAT and prints the groups, policies
+// and routes that exist in only one of them or are configured differently. It
+// returns the number of differences.
+func runCompareAccounts(ctx context.Context, config *Config) (int, error) {
+	if config.TargetToken == "" {
+		return 0, fmt.Errorf("NB_TARGET_PAT is required: the token of the account to compare against")
+	}
+	if config.TargetURL == "" {
+		return 0, fmt.Errorf("--target-url (or NB_TARGET_MANAGEMENT_URL) is required: the management URL of the account to compare against")
+	}
+
+	targetConfig := *config
+	targetConfig.ServerURL = config.TargetURL
+	targetConfig.APIToken = config.TargetToken
+
+	accounts := []struct {
+		label   string
+		service *NetBirdService
+	}{
+		{config.ServerURL, newService(config)},
+		{config.TargetURL, newService(&targetConfig)},
+	}
+
+	snapshots := make([]*accountSnapshot, len(accounts))
+	errs := make([]error, len(accounts))
+	var wg sync.WaitGroup
+	for i, account := range accounts {
+		wg.Add(1)
+		go func(i int, label string, service *NetBirdService) {
+			defer wg.Done()
+			snapshots[i], errs[i] = fetchAccountSnapshot(ctx, service, label)
+		}(i, account.label, account.service)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return 0, fmt.Errorf("failed to fetch %s: %w", accounts[i].label, err)
+		}
+	}
+
+	differences := compareAccounts(snapshots[0], snapshots[1])
+	printAccountDifferences(snapshots[0], snapshots[1], differences)
+	return len(differences), nil
+}
+
+// fetchAccountSnapshot fetches the groups, policies and routes of one account,
+// and the peers routes are routed through
+func fetchAccountSnapshot(ctx context.Context, service *NetBirdService, label string) (*accountSnapshot, error) {
+	var groups []resources.Group
+	err := service.Get(ctx, "/api/groups", &groups)
+	if err != nil {
+		return nil, err
+	}
+	var peers []resources.Peer
+	err = service.Get(ctx, "/api/peers", &peers)
+	if err != nil {
+		return nil, err
+	}
+	var policies []resources.Policy
+	err = service.Get(ctx, "/api/policies", &policies)
+	if err != nil {
+		return nil, err
+	}
+	var routes []resources.Route
+	err = service.Get(ctx, "/api/routes", &routes)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAccountSnapshot(label, groups, peers, policies, routes), nil
+}
+
+// newAccountSnapshot keys the objects of an account for comparison
+func newAccountSnapshot(label string, groups []resources.Group, peers []resources.Peer, policies []resources.Policy, routes []resources.Route) *accountSnapshot {
+	snapshot := &accountSnapshot{
+		Label:      label,
+		Groups:     make(map[string][]resources.Group, len(groups)),
+		Policies:   make(map[string][]resources.Policy, len(policies)),
+		Routes:     make(map[string][]resources.Route, len(routes)),
+		groupNames: make(map[string]string, len(groups)),
+		peerNames:  make(map[string]string, len(peers)),
+	}
+	for _, group := range groups {
+		snapshot.Groups[group.Name] = append(snapshot.Groups[group.Name], group)
+		snapshot.groupNames[group.ID] = group.Name
+	}
+	for _, peer := range peers {
+		snapshot.peerNames[peer.ID] = peer.Name
+	}
+	for _, policy := range policies {
+		snapshot.Policies[policy.Name] = append(snapshot.Policies[policy.Name], policy)
+	}
+	for _, route := range routes {
+		key := snapshot.routeKey(route)
+		snapshot.Routes[key] = append(snapshot.Routes[key], route)
+	}
+	return snapshot
+}
+
+// routeKey identifies a route across accounts: a network identifier is shared
+// by the routes of a high-availability setup, which differ in the peer or
+// peer groups routing it
+func (a *accountSnapshot) routeKey(route resources.Route) string {
+	if route.Peer != "" {
+		name, exists := a.peerNames[route.Peer]
+		if !exists {
+			name = "unknown peer " + route.Peer
+		}
+		return route.NetworkID + " via " + name
+	}
+	return route.NetworkID + " via " + a.groupIDNames(route.PeerGroups)
+}
+
+// compareAccounts lists the differences between a source and a target account
+func compareAccounts(source, target *accountSnapshot) []accountDifference {
+	differences := make([]accountDifference, 0)
+	add := func(kind, name, format string, args ...any) {
+		differences = append(differences, accountDifference{Kind: kind, Name: name, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	compare := func(kind, key string, sourceFields, targetFields []map[string]string) {
+		for i, pair := range pairObjects(sourceFields, targetFields) {
+			name := key
+			if i > 0 {
+				name = fmt.Sprintf("%s #%d", key, i+1)
+			}
+			switch {
+			case pair[1] == nil:
+				add(kind, name, "only in %s", source.Label)
+			case pair[0] == nil:
+				add(kind, name, "only in %s", target.Label)
+			default:
+				for _, field := range fieldDifferences(pair[0], pair[1]) {
+					add(kind, name, "%s", field)
+				}
+			}
+		}
+	}
+
+	// Groups are compared by name only, so their fields are empty
+	groupFields := func(groups []resources.Group) []map[string]string {
+		fields := make([]map[string]string, len(groups))
+		for i := range groups {
+			fields[i] = map[string]string{}
+		}
+		return fields
+	}
+	for _, name := range unionKeys(source.Groups, target.Groups) {
+		compare("group", name, groupFields(source.Groups[name]), groupFields(target.Groups[name]))
+	}
+	for _, name := range unionKeys(source.Policies, target.Policies) {
+		compare("policy", name, objectFields(source.Policies[name], source.policyFields), objectFields(target.Policies[name], target.policyFields))
+	}
+	for _, key := range unionKeys(source.Routes, target.Routes) {
+		compare("route", key, objectFields(source.Routes[key], source.routeFields), objectFields(target.Routes[key], target.routeFields))
+	}
+
+	return differences
+}
+
+// objectFields flattens the objects sharing a key
+func objectFields[V any](objects []V, fields func(V) map[string]string) []map[string]string {
+	flattened := make([]map[string]string, 0, len(objects))
+	for _, object := range objects {
+		flattened = append(flattened, fields(object))
+	}
+	return flattened
+}
+
+// pairObjects matches the flattened objects sharing a key across the two
+// accounts. Identical objects are paired first, the rest in the order of their
+// fields; an object without a counterpart is paired with nil.
+func pairObjects(source, target []map[string]string) [][2]map[string]string {
+	sortByFields := func(objects []map[string]string) []map[string]string {
+		sorted := append([]map[string]string{}, objects...)
+		sort.SliceStable(sorted, func(i, j int) bool { return fieldsKey(sorted[i]) < fieldsKey(sorted[j]) })
+		return sorted
+	}
+	source, target = sortByFields(source), sortByFields(target)
+
+	pairs := make([][2]map[string]string, 0, max(len(source), len(target)))
+	var sourceRest, targetRest []map[string]string
+	i, j := 0, 0
+	for i < len(source) && j < len(target) {
+		switch sourceKey, targetKey := fieldsKey(source[i]), fieldsKey(target[j]); {
+		case sourceKey == targetKey:
+			pairs = append(pairs, [2]map[string]string{source[i], target[j]})
+			i++
+			j++
+		case sourceKey < targetKey:
+			sourceRest = append(sourceRest, source[i])
+			i++
+		default:
+			targetRest = append(targetRest, target[j])
+			j++
+		}
+	}
+	sourceRest = append(sourceRest, source[i:]...)
+	targetRest = append(targetRest, target[j:]...)
+
+	for k := 0; k < max(len(sourceRest), len(targetRest)); k++ {
+		var pair [2]map[string]string
+		if k < len(sourceRest) {
+			pair[0] = sourceRest[k]
+		}
+		if k < len(targetRest) {
+			pair[1] = targetRest[k]
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs
+}
+
+// fieldsKey renders flattened fields in a stable order
+func fieldsKey(fields map[string]string) string {
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+
+	var key strings.Builder
+	for _, field := range names {
+		fmt.Fprintf(&key, "%s=%q\n", field, fields[field])
+	}
+	return key.String()
+}
+
+// policyFields flattens the comparable settings of a policy. Rules are matched by
+// name and groups referenced by name.
+func (a *accountSnapshot) policyFields(policy resources.Policy) map[string]string {
+	fields := map[string]string{
+		"description": policy.Description,
+		"enabled":     fmt.Sprint(policy.Enabled),
+		"rules":       fmt.Sprint(len(policy.Rules)),
+	}
+	for _, rule := range policy.Rules {
+		prefix := "rule " + rule.Name + ": "
+		ports := append([]string{}, rule.Ports...)
+		for _, portRange := range rule.PortRanges {
+			ports = append(ports, fmt.Sprintf("%d-%d", portRange.Start, portRange.End))
+		}
+
+		fields[prefix+"enabled"] = fmt.Sprint(rule.Enabled)
+		fields[prefix+"action"] = rule.Action
+		fields[prefix+"protocol"] = rule.Protocol
+		fields[prefix+"bidirectional"] = fmt.Sprint(rule.Bidirectional)
+		fields[prefix+"ports"] = sortedJoin(ports)
+		fields[prefix+"sources"] = a.groupInfoNames(rule.Sources)
+		fields[prefix+"destinations"] = a.groupInfoNames(rule.Destinations)
+	}
+	return fields
+}
+
+// routeFields flattens the comparable settings of a route. The routing peer is
+// left out, since peers enroll anew in the target account.
+func (a *accountSnapshot) routeFields(route resources.Route) map[string]string {
+	return map[string]string{
+		"description": route.Description,
+		"network":     route.Network,
+		"enabled":     fmt.Sprint(route.Enabled),
+		"masquerade":  fmt.Sprint(route.Masquerade),
+		"metric":      fmt.Sprint(route.Metric),
+		"keep_route":  fmt.Sprint(route.KeepRoute),
+		"groups":      a.groupIDNames(route.Groups),
+		"peer_groups": a.groupIDNames(route.PeerGroups),
+	}
+}
+
+// groupInfoNames returns the sorted names of the groups a policy rule references
+func (a *accountSnapshot) groupInfoNames(groups []resources.GroupInfo) string {
+	names := make([]string, 0, len(groups))
+	for _, group := range groups {
+		name := group.Name
+		if name == "" {
+			name = a.groupNames[group.ID]
+		}
+		names = append(names, name)
+	}
+	return sortedJoin(names)
+}
+
+// groupIDNames returns the sorted names of groups referenced by ID
+func (a *accountSnapshot) groupIDNames(ids []string) string {
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if name, exists := a.groupNames[id]; exists {
+			names = append(names, name)
+		} else {
+			names = append(names, "unknown group "+id)
+		}
+	}
+	return sortedJoin(names)
+}
+
+// fieldDifferences describes the fields whose values differ, in field order
+func fieldDifferences(source, target map[string]string) []string {
+	differences := make([]string, 0)
+	for _, field := range unionKeys(source, target) {
+		sourceValue, inSource := source[field]
+		targetValue, inTarget := target[field]
+		switch {
+		case !inTarget:
+			differences = append(differences, fieldOwner(field)+" only in source")
+		case !inSource:
+			differences = append(differences, fieldOwner(field)+" only in target")
+		case sourceValue != targetValue:
+			differences = append(differences, fmt.Sprintf("%s: %q != %q", field, sourceValue, targetValue))
+		}
+	}
+	return dedupe(differences)
+}
+
+// printAccountDifferences prints the comparison grouped by object kind
+func printAccountDifferences(source, target *accountSnapshot, differences []accountDifference) {
+	fmt.Printf("Source: %s (%d groups, %d policies, %d routes)\n", source.Label, countObjects(source.Groups), countObjects(source.Policies), countObjects(source.Routes))
+	fmt.Printf("Target: %s (%d groups, %d policies, %d routes)\n\n", target.Label, countObjects(target.Groups), countObjects(target.Policies), countObjects(target.Routes))
+
+	if len(differences) == 0 {
+		fmt.Println("No differences in groups, policies and routes")
+		return
+	}
+
+	headings := map[string]string{"group": "Groups", "policy": "Policies", "route": "Routes"}
+	kind := ""
+	for _, difference := range differences {
+		if difference.Kind != kind {
+			kind = difference.Kind
+			fmt.Printf("%s:\n", headings[kind])
+		}
+		fmt.Printf("  %-30s %s\n", difference.Name, difference.Detail)
+	}
+	fmt.Printf("\n%d differences\n", len(differences))
+}
+
+// countObjects counts the objects of a snapshot map
+func countObjects[V any](objects map[string][]V) int {
+	count := 0
+	for _, shared := range objects {
+		count += len(shared)
+	}
+	return count
+}
+
+// unionKeys returns the keys of two maps, sorted
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, exists := a[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedJoin joins a copy of the values in sorted order
+func sortedJoin(values []string) string {
+	sorted := append([]string{}, values...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ", ")
+}
+
+// fieldOwner returns the "rule <name>" part of a rule field. Only rule fields
+// can be missing on one side.
+func fieldOwner(field string) string {
+	if index := strings.LastIndex(field, ": "); index >= 0 {
+		return field[:index]
+	}
+	return field
+}
+
+// dedupe removes repeated entries, keeping the first occurrence
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"netbird-terraformer/lib"
+)
+
+// serverVersionEndpoint reports the management server's version. Servers too
+// old to have it answer 404 and are treated as of an unknown version.
+const serverVersionEndpoint = "/api/instance/version"
+
+// apiShim adapts the items of a list endpoint returned by management servers
+// older than Before to the shape the handlers decode. Shims only rewrite the
+// legacy shape and leave the current one alone, so they also apply when the
+// server's version is unknown.
+type apiShim struct {
+	Endpoint    string
+	Before      string // first server version returning the current shape
+	Description string
+	Adapt       func(item map[string]any) bool // reports whether it changed the item
+}
+
+// apiShims are the known response shape differences between server versions
+var apiShims = []apiShim{
+	{
+		Endpoint:    "/api/policies",
+		Before:      "0.26.0",
+		Description: "policy rule groups given as IDs",
+		Adapt: func(item map[string]any) bool {
+			return eachRule(item, func(rule map[string]any) bool {
+				sources := groupObjects(rule, "sources")
+				return groupObjects(rule, "destinations") || sources
+			})
+		},
+	},
+	{
+		Endpoint:    "/api/policies",
+		Before:      "0.26.0",
+		Description: "policy rule ports given as numbers",
+		Adapt: func(item map[string]any) bool {
+			return eachRule(item, func(rule map[string]any) bool { return numbersAsStrings(rule, "ports") })
+		},
+	},
+	{
+		Endpoint:    "/api/peers",
+		Before:      "0.26.0",
+		Description: "peer groups given as IDs",
+		Adapt:       func(item map[string]any) bool { return groupObjects(item, "groups") },
+	},
+	{
+		Endpoint:    "/api/routes",
+		Before:      "0.26.0",
+		Description: "route groups given as objects",
+		Adapt: func(item map[string]any) bool {
+			groups := groupIDs(item, "groups")
+			return groupIDs(item, "peer_groups") || groups
+		},
+	},
+	{
+		Endpoint:    "/api/setup-keys",
+		Before:      "0.26.0",
+		Description: "setup key auto groups given as objects",
+		Adapt:       func(item map[string]any) bool { return groupIDs(item, "auto_groups") },
+	},
+	{
+		Endpoint:    "/api/users",
+		Before:      "0.26.0",
+		Description: "user auto groups given as objects",
+		Adapt:       func(item map[string]any) bool { return groupIDs(item, "auto_groups") },
+	},
+}
+
+// detectServerVersion asks the management server for its version, without
+// the v prefix. It returns "" if the server does not say, so every shim applies.
+func detectServerVersion(ctx context.Context, service *NetBirdService) string {
+	var info struct {
+		Current string `json:"management_current_version"`
+	}
+	if err := service.Get(ctx, serverVersionEndpoint, &info); err != nil {
+		slog.Debug("Management server version unknown, adapting to every known response shape", "component", "api", "error", err)
+		return ""
+	}
+	return strings.TrimPrefix(info.Current, "v")
+}
+
+// serverSupports reports whether a server of the given version serves an
+// endpoint introduced in minVersion. An unknown version is assumed to.
+func serverSupports(serverVersion, minVersion string) bool {
+	return serverVersion == "" || minVersion == "" || !lib.VersionOlder(serverVersion, minVersion)
+}
+
+// adaptResponse rewrites a response body of an older server to the current
+// shape with the shims of its endpoint. The body is returned as is if no shim
+// applies or changes anything.
+func adaptResponse(endpoint, serverVersion string, body []byte) []byte {
+	path, _, _ := strings.Cut(endpoint, "?")
+	shims := make([]apiShim, 0)
+	for _, shim := range apiShims {
+		if shim.Endpoint == path && (serverVersion == "" || lib.VersionOlder(serverVersion, shim.Before)) {
+			shims = append(shims, shim)
+		}
+	}
+	if len(shims) == 0 {
+		return body
+	}
+
+	var document any
+	if json.Unmarshal(body, &document) != nil {
+		return body
+	}
+
+	// List endpoints return a bare array or an object wrapping it
+	lists := make([][]any, 0, 1)
+	switch typed := document.(type) {
+	case []any:
+		lists = append(lists, typed)
+	case map[string]any:
+		for _, value := range typed {
+			if list, ok := value.([]any); ok {
+				lists = append(lists, list)
+			}
+		}
+	}
+
+	adapted := make(map[string]bool)
+	for _, list := range lists {
+		for _, element := range list {
+			item, ok := element.(map[string]any)
+			if !ok {
+				continue
+			}
+			for _, shim := range shims {
+				if shim.Adapt(item) {
+					adapted[shim.Description] = true
+				}
+			}
+		}
+	}
+	if len(adapted) == 0 {
+		return body
+	}
+
+	rewritten, err := json.Marshal(document)
+	if err != nil {
+		return body
+	}
+	for _, shim := range shims {
+		if adapted[shim.Description] {
+			slog.Debug("Adapted the response of an older server", "component", "api", "endpoint", path, "shim", shim.Description, "server_version", serverVersion)
+		}
+	}
+	return rewritten
+}
+
+// eachRule calls adapt for every rule of a policy and reports whether any
+// call changed something
+func eachRule(policy map[string]any, adapt func(rule map[string]any) bool) bool {
+	rules, _ := policy["rules"].([]any)
+	changed := false
+	for _, element := range rules {
+		if rule, ok := element.(map[string]any); ok && adapt(rule) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// groupObjects replaces the group IDs in a list with {"id": ...} objects
+func groupObjects(item map[string]any, key string) bool {
+	list, _ := item[key].([]any)
+	changed := false
+	for i, element := range list {
+		if id, ok := element.(string); ok {
+			list[i] = map[string]any{"id": id}
+			changed = true
+		}
+	}
+	return changed
+}
+
+// groupIDs replaces the group objects in a list with their IDs
+func groupIDs(item map[string]any, key string) bool {
+	list, _ := item[key].([]any)
+	changed := false
+	for i, element := range list {
+		if group, ok := element.(map[string]any); ok {
+			list[i], _ = group["id"].(string)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// numbersAsStrings replaces the numbers in a list with their decimal strings
+func numbersAsStrings(item map[string]any, key string) bool {
+	list, _ := item[key].([]any)
+	changed := false
+	for i, element := range list {
+		if number, ok := element.(float64); ok {
+			list[i] = strconv.FormatFloat(number, 'f', -1, 64)
+			changed = true
+		}
+	}
+	return changed
+}
@@ -1,39 +1,1063 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"netbird-terraformer/lib"
+	"netbird-terraformer/resources"
 )
 
 type Config struct {
-	ServerURL  string
-	APIToken   string
-	Debug      bool
-	AutoImport bool
+	ServerURL     string
+	ServerVersion string // management server version, detected unless set; "" if unknown
+	APIToken      string
+	Account       string          // account of the config file this run generates, also the provider alias
+	Accounts      []AccountConfig // accounts generate runs for one after the other, see accounts.go
+	Verbosity     lib.Verbosity
+	AutoImport    bool
+	ImportMode    string
+	Reconcile     bool     // adopt the state's values until the post-import plan is empty
+	ImportScripts []string // import script formats, see lib.ImportScriptFiles
+	TerraformPath string
+	OutputDir     string
+	Format        string
+
+	ProviderVersion  string
+	ProviderDefaults bool
+	ConfigFile       string
+	Profile          string // the config file profile in use
+	Logger           *slog.Logger
+	LogWarnings      *lib.WarningCounter
+
+	ExcludedTypes  []string
+	IncludePattern *regexp.Regexp
+	ExcludePattern *regexp.Regexp
+	Rules          []*lib.Rule
+	ImportOrder    []string
+	IssuedActions  map[string]lib.RuleAction
+	Limit          int  // trial runs: objects per resource type, 0 for all
+	Sample         bool // trial runs: spread Limit over each type's objects
+	NameTemplates  map[string]*template.Template
+	NameOverrides  map[string]map[string]string
+	StateNames     map[string]map[string]string
+	StateObjects   map[string]map[string]string // the existing state, compared by --prune
+
+	SkipSystemGroups bool
+	SkipEmptyGroups  bool
+	OnlyEnabled      bool
+
+	DashboardURL string
+	URLComments  bool
+
+	Lifecycle map[string]lib.Lifecycle
+
+	SuggestGroups bool
+	PulumiImport  bool
+	Ansible       bool
+	Graph         string // resource graph format, see lib.GraphFormats
+	HTMLReport    bool
+	DryRun        bool
+	FailOnWarning bool
+	Preflight     bool // check the token's permissions before fetching, see preflight.go
+
+	EmailReport []string
+
+	MaxRetries     int
+	RetryDelay     time.Duration
+	QPS            float64
+	RequestTimeout time.Duration // per API request, retries get their own
+	RunTimeout     time.Duration // the whole run, 0 for no limit
+
+	Concurrency int
+
+	RootCAs       *x509.CertPool   // from NB_CA_CERT, nil for the system roots
+	ClientCert    *tls.Certificate // from NB_CLIENT_CERT/NB_CLIENT_KEY, for mTLS
+	TLSSkipVerify bool
+	TraceHTTP     bool // log connection timings and redacted summaries of API requests, see httptrace.go
+
+	Bundle *BundleAPI // set with --from-bundle for offline generation
+
+	RecordDir string     // record API responses here, see fixtures.go
+	Replay    *ReplayAPI // set with --replay to generate from recorded responses
+
+	StatsFile    string    // local-only usage statistics, see stats.go
+	TelemetryURL string    // opt-in: anonymous usage statistics are sent here
+	Stats        bool      // print request statistics per endpoint, see apistats.go
+	APIStats     *APIStats // the request statistics of this run, set by main
+
+	SplitState    bool
+	Backend       *lib.BackendConfig
+	ModulePackage bool
+	Merge         bool
+	Prune         bool
+	TFC           *TFCConfig // run the import in HCP Terraform, see tfc.go
+	Terragrunt    bool
+
+	Scrub lib.ScrubConfig
+
+	CacheDir     string // cache API responses here, see cache.go
+	CacheTTL     time.Duration
+	CacheRefresh bool
+
+	Checkpoint bool        // persist progress so an interrupted run can resume, see checkpoint.go
+	Resume     bool        // continue from the checkpoint of an interrupted run
+	Progress   *Checkpoint // the checkpoint of this run, set by main
+
+	WatchInterval time.Duration // watch: time between runs
+	AdminAddr     string        // watch: listen address of the admin endpoint
+
+	TargetURL   string // compare-accounts: the account compared against
+	TargetToken string
+
+	InteractiveProgress bool
 }
 
-func getConfig() *Config {
-	serverURL := os.Getenv("NB_MANAGEMENT_URL")
-	if serverURL == "" {
-		serverURL = "https://netbird.api.com:33073"
+// resourceTypes lists the resource types the importer knows how to handle,
+// including handlers registered by extensions linked into the binary
+var resourceTypes = resources.Types()
+
+// defaultConcurrency is how many resource types are fetched at the same time.
+// The API rate limit, not the client, is the bottleneck beyond a few.
+const defaultConcurrency = 4
+
+// Subcommands; generate is the default when none is given
+const (
+	commandGenerate    = "generate"
+	commandBundle      = "bundle"
+	commandListImports = "list-imports"
+	commandDoctor      = "doctor"
+	commandCompare     = "compare-accounts"
+	commandWatch       = "watch"
+	commandDeploy      = "deploy"
+	commandDrift       = "drift"
+	commandDiff        = "diff"
+	commandExport      = "export"
+)
+
+// getConfig parses the flags of a subcommand. For bundle, the positional
+// argument is the archive path instead of the output directory.
+func getConfig(command string, arguments []string) *Config {
+	flags := flag.NewFlagSet(os.Args[0]+" "+command, flag.ExitOnError)
+	configPath := flags.String("config", "", "Path to a netbird-terraformer.yaml config file")
+	profileName := flags.String("profile", "", "Use this profile of the config file's profiles")
+	tokenFile := flags.String("token-file", "", "Read the API token from the first line of this file")
+	tokenStdin := flags.Bool("token-stdin", false, "Read the API token from the first line of stdin")
+	keyring := flags.Bool("keyring", false, "Read the API token from the OS keyring when NB_PAT is not set")
+	format := flags.String("format", "hcl", "Output format")
+	excludeResources := flags.String("exclude-resources", "", "Comma-separated resource types to skip")
+	include := flags.String("include", "", "Only generate objects whose name matches this regex")
+	exclude := flags.String("exclude", "", "Skip objects whose name matches this regex")
+	importOrder := flags.String("import-order", "", "Comma-separated resource types in the order they are imported")
+	skipSystemGroups := flags.Bool("skip-system-groups", false, "Reference the All group and groups issued by JWT sync or an IdP integration as data sources")
+	skipEmptyGroups := flags.Bool("skip-empty-groups", false, "Leave out groups without peers and resources, unless another resource references them")
+	onlyEnabled := flags.Bool("only-enabled", false, "Leave out disabled policies and routes")
+	nameTemplates := make(nameTemplateFlag)
+	flags.Var(nameTemplates, "name-template", "Resource name template of a type, as type=template; repeatable")
+	nameOverridesFile := flags.String("name-overrides", "", "YAML file mapping object IDs to resource names, per resource type")
+	ignoreChanges := make(ignoreChangesFlag)
+	flags.Var(ignoreChanges, "ignore-changes", "Attributes Terraform ignores changes to, as type=attribute,...; repeatable")
+	preventDestroy := flags.String("prevent-destroy", "", "Comma-separated resource types whose resources set prevent_destroy")
+	reuseStateNames := flags.Bool("reuse-state-names", true, "Keep the resource names of objects already in the output directory's terraform.tfstate")
+	importMode := flags.String("import-mode", lib.ImportModeAuto, "How resources are imported: auto, blocks, cli, state")
+	reconcile := flags.Bool("reconcile", false, "After importing, adopt the state's values of attributes the plan would change and plan again until it is empty")
+	importScripts := flags.String("import-scripts", strings.Join(lib.DefaultImportScripts, ","), "Comma-separated import scripts to write: sh, ps1")
+	terraformPath := flags.String("terraform-path", lib.DefaultTerraformPath, "Terraform binary used for imports")
+	urlComments := flags.Bool("url-comments", false, "Write dashboard links as comments above each resource")
+	dryRun := flags.Bool("dry-run", false, "Fetch everything but write no files and run no terraform commands")
+	emailReport := flags.String("email-report", "", "Email the run summary to these comma-separated recipients")
+	failOnWarning := flags.Bool("fail-on-warning", false, "Exit with status 2 if any warning was logged")
+	preflightCheck := flags.Bool("preflight", true, "Check the token's role, or its access to every endpoint, before fetching")
+	suggestGroups := flags.Bool("suggest-groups", false, "Write role-based group membership suggestions")
+	pulumiImport := flags.Bool("pulumi-import", false, "Write pulumi-import.json for bulk importing with pulumi import -f")
+	graph := flags.String("graph", "", "Write the resource graph: dot or mermaid")
+	limit := flags.Int("limit", 0, "Generate at most this many objects per resource type, for trial runs")
+	sample := flags.Bool("sample", false, "Spread --limit over each type's objects instead of taking the first ones")
+	htmlReport := flags.Bool("html-report", false, "Write access_report.html with the groups, their peers and the traffic policies allow between them")
+	ansibleInventory := flags.Bool("ansible-inventory", false, "Write ansible_inventory.yaml with the peers grouped by their NetBird groups")
+	maxRetries := flags.Int("max-retries", defaultMaxRetries, "Retries for failed API requests (network errors, 429, 5xx)")
+	retryDelay := flags.String("retry-delay", defaultRetryDelay.String(), "Base delay between API retries, doubled on every attempt")
+	requestTimeout := flags.String("request-timeout", defaultRequestTimeout.String(), "How long a single API request may take")
+	runTimeout := flags.String("run-timeout", "0", "How long the whole run may take, 0 for no limit")
+	cacheDir := flags.String("cache-dir", "", "Cache API responses in this directory")
+	cacheTTL := flags.String("cache-ttl", defaultCacheTTL.String(), "How long cached API responses are used")
+	refresh := flags.Bool("refresh", false, "Fetch fresh API responses instead of using the cache")
+	checkpoint := flags.Bool("checkpoint", true, "Save fetched responses and completed imports so an interrupted run can resume")
+	resume := flags.Bool("resume", false, "Resume an interrupted run from its checkpoint in the output directory")
+	serverVersion := flags.String("server-version", "", "Management server version, for servers that do not report it (default: detected)")
+	tlsSkipVerify := flags.Bool("tls-skip-verify", false, "Do not verify the management server's TLS certificate (insecure)")
+	traceHTTP := flags.Bool("trace-http", false, "Log DNS, connect, TLS and first byte timings and redacted summaries of every API request")
+	concurrency := flags.Int("concurrency", defaultConcurrency, "Resource types fetched at the same time")
+	qps := flags.Float64("qps", 0, "Maximum API requests per second (0 for no limit)")
+	merge := flags.Bool("merge", false, "Keep the existing configuration in the output directory and only append, and import, resources not in it yet")
+	tfcOrganization := flags.String("tfc-organization", "", "HCP Terraform organization to run the import in, with --tfc-workspace")
+	tfcWorkspace := flags.String("tfc-workspace", "", "HCP Terraform workspace to run the import in; created if missing")
+	tfcHostname := flags.String("tfc-hostname", "", "HCP Terraform or Terraform Enterprise hostname (default: app.terraform.io)")
+	prune := flags.Bool("prune", false, "Write removed blocks for objects in the existing state that no longer exist in NetBird")
+	splitState := flags.Bool("split-state", false, "Write one root module with its own state per resource type")
+	terragrunt := flags.Bool("terragrunt", false, "Write a Terragrunt layout: a folder per resource type and a root terragrunt.hcl")
+	watchInterval := flags.String("interval", defaultWatchInterval.String(), "watch: time between runs")
+	adminAddr := flags.String("admin-addr", defaultAdminAddr, "watch: listen address of the admin endpoint")
+	targetURL := flags.String("target-url", "", "Management URL of the account compare-accounts compares against")
+	modulePackage := flags.Bool("module-package", false, "Write a reusable module in the registry's standard module structure")
+	statsFile := flags.String("stats-file", "", "Write anonymous usage statistics to this file")
+	stats := flags.Bool("stats", false, "Print request counts, latencies, sizes, retries and cache hits per API endpoint")
+	fromBundle := flags.String("from-bundle", "", "Generate offline from a bundle written by the bundle command")
+	record := flags.String("record", "", "Record every API response to this fixtures directory")
+	replay := flags.String("replay", "", "Generate from API responses recorded with --record")
+	verbosity, args := extractVerbosity(arguments)
+	verbose := flags.String("verbose", "", "Comma-separated debug output categories: api, generate, terraform, all")
+	noProgress := flags.Bool("no-progress", false, "Disable progress bars")
+	logLevel := flags.String("log-level", "info", "Log level: debug, info, warn, error")
+	logFormat := flags.String("log-format", "text", "Log format: text, json")
+	flags.Parse(args)
+
+	setFlags := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+
+	fileConfig, configFile, err := loadConfigFile(*configPath)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	if len(serverURL) > 0 && serverURL[len(serverURL)-1] == '/' {
-		serverURL = serverURL[:len(serverURL)-1]
+	// A profile replaces the top-level settings it sets
+	var profile *FileConfig
+	selectedProfile := stringSetting(setFlags["profile"], *profileName, "NB_PROFILE", "", "")
+	if selectedProfile != "" {
+		if configFile == "" {
+			log.Fatalf("Profile %q selected, but no config file was found", selectedProfile)
+		}
+		profile, err = fileConfig.applyProfile(selectedProfile)
+		if err != nil {
+			log.Fatalf("Invalid config file %s: %v", configFile, err)
+		}
+	}
+
+	excludedTypes := fileConfig.ExcludeResources
+	if setFlags["exclude-resources"] {
+		excludedTypes = splitList(*excludeResources)
+	}
+	for _, resourceType := range excludedTypes {
+		if !isResourceType(resourceType) {
+			log.Fatalf("Unknown resource type %q in exclude_resources (supported: %s)", resourceType, strings.Join(resourceTypes, ", "))
+		}
+	}
+
+	outputFormat := stringSetting(setFlags["format"], *format, "", fileConfig.Format, "hcl")
+	if _, err := lib.NewOutputWriter(outputFormat); err != nil {
+		log.Fatal(err)
+	}
+
+	includePattern := compilePattern("include", stringSetting(setFlags["include"], *include, "", fileConfig.Include, ""))
+	excludePattern := compilePattern("exclude", stringSetting(setFlags["exclude"], *exclude, "", fileConfig.Exclude, ""))
+
+	rules := make([]*lib.Rule, 0, len(fileConfig.Rules))
+	for _, ruleConfig := range fileConfig.Rules {
+		rule, err := lib.CompileRule(ruleConfig.When, ruleConfig.Action)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rules = append(rules, rule)
+	}
+
+	typeOrder := fileConfig.ImportOrder
+	if setFlags["import-order"] {
+		typeOrder = splitList(*importOrder)
+	}
+	for _, resourceType := range typeOrder {
+		if !isResourceType(resourceType) {
+			log.Fatalf("Unknown resource type %q in import_order (supported: %s)", resourceType, strings.Join(resourceTypes, ", "))
+		}
+	}
+	if len(typeOrder) == 0 {
+		typeOrder = lib.DefaultImportOrder
+	}
+
+	issuedActions := make(map[string]lib.RuleAction, len(lib.DefaultIssuedActions)+len(fileConfig.Issued))
+	for issued, action := range lib.DefaultIssuedActions {
+		issuedActions[issued] = action
+	}
+	for issued, actionName := range fileConfig.Issued {
+		if !lib.IsIssuedType(issued) {
+			log.Fatalf("Unknown issued value %q (supported: %s)", issued, strings.Join(lib.IssuedTypes, ", "))
+		}
+		action, err := lib.ParseRuleAction(actionName)
+		if err != nil {
+			log.Fatalf("Invalid action for issued %q: %v", issued, err)
+		}
+		issuedActions[issued] = action
+	}
+
+	// Flags replace the file's template of the same type
+	for resourceType, text := range fileConfig.NameTemplates {
+		if _, exists := nameTemplates[resourceType]; !exists {
+			nameTemplates[resourceType] = text
+		}
+	}
+	namingTemplates := make(map[string]*template.Template, len(nameTemplates))
+	for resourceType, text := range nameTemplates {
+		if !isResourceType(resourceType) {
+			log.Fatalf("Unknown resource type %q in name templates (supported: %s)", resourceType, strings.Join(resourceTypes, ", "))
+		}
+		namingTemplates[resourceType], err = lib.ParseNameTemplate(resourceType, text)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var nameOverrides map[string]map[string]string
+	if path := stringSetting(setFlags["name-overrides"], *nameOverridesFile, "", fileConfig.NameOverrides, ""); path != "" {
+		nameOverrides, err = lib.LoadNameOverrides(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for resourceType := range nameOverrides {
+			if !isResourceType(resourceType) {
+				log.Fatalf("Unknown resource type %q in name overrides (supported: %s)", resourceType, strings.Join(resourceTypes, ", "))
+			}
+		}
+	}
+
+	lifecycle := lifecycleSettings(fileConfig.Lifecycle, ignoreChanges, setFlags["prevent-destroy"], splitList(*preventDestroy))
+
+	emailRecipients := fileConfig.EmailReport
+	if setFlags["email-report"] {
+		emailRecipients = splitList(*emailReport)
+	}
+
+	retryBaseDelay, err := time.ParseDuration(stringSetting(setFlags["retry-delay"], *retryDelay, "", fileConfig.RetryDelay, defaultRetryDelay.String()))
+	if err != nil {
+		log.Fatalf("Invalid retry delay: %v", err)
+	}
+	requestDeadline, err := time.ParseDuration(stringSetting(setFlags["request-timeout"], *requestTimeout, "", fileConfig.RequestTimeout, defaultRequestTimeout.String()))
+	if err != nil || requestDeadline <= 0 {
+		log.Fatalf("Invalid request timeout %q: must be a positive duration such as 30s or 2m", stringSetting(setFlags["request-timeout"], *requestTimeout, "", fileConfig.RequestTimeout, ""))
+	}
+	runDeadline, err := time.ParseDuration(stringSetting(setFlags["run-timeout"], *runTimeout, "", fileConfig.RunTimeout, "0"))
+	if err != nil || runDeadline < 0 {
+		log.Fatalf("Invalid run timeout %q: must be a duration such as 30m, or 0 for no limit", stringSetting(setFlags["run-timeout"], *runTimeout, "", fileConfig.RunTimeout, ""))
+	}
+	retries := intSetting(setFlags["max-retries"], *maxRetries, fileConfig.MaxRetries, defaultMaxRetries)
+	if retries < 0 {
+		log.Fatalf("Invalid max retries %d: must not be negative", retries)
+	}
+
+	// Cached responses would be missing from the recording
+	cacheDirectory := stringSetting(setFlags["cache-dir"], *cacheDir, "", fileConfig.CacheDir, "")
+	if cacheDirectory != "" && *record != "" {
+		log.Fatal("--record can't be combined with --cache-dir")
+	}
+	// Resumed responses would be missing from the recording as well
+	if *resume && *record != "" {
+		log.Fatal("--resume can't be combined with --record")
+	}
+	cacheMaxAge, err := time.ParseDuration(stringSetting(setFlags["cache-ttl"], *cacheTTL, "", fileConfig.CacheTTL, defaultCacheTTL.String()))
+	if err != nil {
+		log.Fatalf("Invalid cache TTL: %v", err)
 	}
 
-	apiToken := os.Getenv("NB_PAT")
-	if apiToken == "" {
-		log.Fatal("NB_PAT environment variable is required (NetBird Personal Access Token)")
+	runInterval, err := time.ParseDuration(stringSetting(setFlags["interval"], *watchInterval, "", fileConfig.WatchInterval, defaultWatchInterval.String()))
+	if err != nil || runInterval <= 0 {
+		log.Fatal("Invalid watch interval: must be a positive duration")
 	}
 
-	debug := os.Getenv("DEBUG") == "true"
-	autoImport := os.Getenv("AUTO_IMPORT") != "false"
+	requestRate := *qps
+	if !setFlags["qps"] && fileConfig.QPS != nil {
+		requestRate = *fileConfig.QPS
+	}
+	if requestRate < 0 {
+		log.Fatalf("Invalid qps %g: must not be negative", requestRate)
+	}
+
+	parallelFetches := intSetting(setFlags["concurrency"], *concurrency, fileConfig.Concurrency, defaultConcurrency)
+	if parallelFetches < 1 {
+		log.Fatalf("Invalid concurrency %d: must be at least 1", parallelFetches)
+	}
+
+	outputDir := stringSetting(flags.NArg() > 0, flags.Arg(0), "", fileConfig.OutputDir, "generated")
+	if command == commandBundle {
+		outputDir = stringSetting(flags.NArg() > 0, flags.Arg(0), "", "", defaultBundleFile)
+	}
+	if command == commandExport {
+		outputDir = stringSetting(flags.NArg() > 0, flags.Arg(0), "", "", defaultExportFile)
+	}
+	writesFile := command == commandBundle || command == commandExport
+
+	// With accounts in the config file, generate runs once per account with
+	// NB_ACCOUNT set to its name, writing into <output>/<name>; see accounts.go
+	if err := validateAccounts(fileConfig.Accounts); err != nil {
+		log.Fatalf("Invalid config file: %v", err)
+	}
+	var account *AccountConfig
+	if name := os.Getenv("NB_ACCOUNT"); name != "" {
+		found, exists := findAccount(fileConfig.Accounts, name)
+		if !exists {
+			log.Fatalf("Account %q of NB_ACCOUNT is not in the config file's accounts", name)
+		}
+		account = &found
+		if !writesFile {
+			outputDir = filepath.Join(outputDir, name)
+		}
+	}
+	generateAccounts := account == nil && len(fileConfig.Accounts) > 0
+	if generateAccounts && command != commandGenerate && command != commandWatch {
+		log.Fatalf("The config file lists several accounts; set NB_ACCOUNT to the one %s uses", command)
+	}
+
+	// Renaming an address in existing state would make terraform destroy and
+	// recreate the object, so regenerating keeps the names already in use
+	var stateNames map[string]map[string]string
+	if !writesFile && !generateAccounts && boolSetting(setFlags["reuse-state-names"], *reuseStateNames, fileConfig.ReuseStateNames, true) {
+		stateNames, err = lib.ReadStateNames(outputDir)
+		if err != nil {
+			log.Fatalf("%v (pass --reuse-state-names=false to ignore the existing state)", err)
+		}
+	}
+
+	// Pruning forgets the state objects a run no longer generates; merging
+	// keeps their blocks, which a removed block must not refer to
+	var stateObjects map[string]map[string]string
+	pruneState := !writesFile && !generateAccounts && boolSetting(setFlags["prune"], *prune, fileConfig.Prune, false)
+	if pruneState {
+		if boolSetting(setFlags["merge"], *merge, fileConfig.Merge, false) || boolSetting(setFlags["module-package"], *modulePackage, fileConfig.ModulePackage, false) {
+			log.Fatal("--prune can't be combined with --merge or --module-package")
+		}
+		stateObjects, err = lib.ReadStateNames(outputDir)
+		if err != nil {
+			log.Fatalf("%v (--prune needs to read the existing state)", err)
+		}
+	}
+
+	// A bundle provides the server URL and provider pin it was captured with
+	defaultServerURL := "https://netbird.api.com:33073"
+	defaultProviderVersion := lib.DefaultProviderVersion
+	var bundle *BundleAPI
+	if *fromBundle != "" && command != commandBundle {
+		bundle, err = OpenBundle(*fromBundle)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defaultServerURL = bundle.Manifest.ServerURL
+		defaultProviderVersion = bundle.Manifest.ProviderVersion
+	}
+
+	// Recorded fixtures likewise provide the server URL they were recorded from
+	var replayAPI *ReplayAPI
+	if *replay != "" {
+		if bundle != nil || *record != "" {
+			log.Fatal("--replay can't be combined with --from-bundle or --record")
+		}
+		replayAPI, err = OpenFixtures(*replay)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defaultServerURL = replayAPI.Manifest.ServerURL
+	}
+
+	serverURL := stringSetting(false, "", "NB_MANAGEMENT_URL", fileConfig.ServerURL, defaultServerURL)
+	serverURL = strings.TrimSuffix(serverURL, "/")
+
+	dashboardURL := stringSetting(false, "", "NB_DASHBOARD_URL", fileConfig.DashboardURL, defaultDashboardURL(serverURL))
+
+	// A profile is selected for this very run, so its URLs replace the
+	// environment's, which is likely left over from working with another one
+	if profile != nil && profile.ServerURL != "" {
+		serverURL = strings.TrimSuffix(profile.ServerURL, "/")
+		dashboardURL = stringSetting(false, "", "", profile.DashboardURL, defaultDashboardURL(serverURL))
+	} else if profile != nil && profile.DashboardURL != "" {
+		dashboardURL = profile.DashboardURL
+	}
+
+	// An account's URLs replace the environment's, which the parent run shares
+	// with every account
+	if account != nil {
+		serverURL = strings.TrimSuffix(account.ServerURL, "/")
+		dashboardURL = stringSetting(false, "", "", account.DashboardURL, defaultDashboardURL(serverURL))
+	}
+
+	// --verbose adds categories to -v, -vv and -vvv; the config file applies
+	// only without either. DEBUG=true predates the categories and enables all of them.
+	categories := fileConfig.Verbose
+	if setFlags["verbose"] {
+		categories = splitList(*verbose)
+	} else if verbosity > 0 {
+		categories = nil
+	}
+	selected, err := lib.ParseVerbosity(categories)
+	if err != nil {
+		log.Fatal(err)
+	}
+	verbosity |= selected
+	if verbosity == 0 && fileConfig.Verbosity != nil {
+		verbosity = lib.VerbosityLevel(*fileConfig.Verbosity)
+	}
+	if verbosity == 0 && (os.Getenv("DEBUG") == "true" || boolSetting(false, false, fileConfig.Debug, false)) {
+		verbosity = lib.VerboseAll
+	}
+
+	level, err := lib.ParseLogLevel(stringSetting(setFlags["log-level"], *logLevel, "", fileConfig.LogLevel, "info"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	// -v implies debug logging; an explicit debug level without -v enables all categories
+	if verbosity > 0 && !setFlags["log-level"] && fileConfig.LogLevel == "" {
+		level = slog.LevelDebug
+	}
+	if verbosity == 0 && level <= slog.LevelDebug {
+		verbosity = lib.VerboseAll
+	}
+
+	outputLogFormat := stringSetting(setFlags["log-format"], *logFormat, "", fileConfig.LogFormat, "text")
+	logger, err := lib.NewLogger(os.Stderr, level, outputLogFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	logWarnings := lib.NewWarningCounter(logger.Handler())
+
+	// Offline generation needs no token; provider.tf then expects NB_PAT at apply time
+	tokens := tokenSources{
+		File:    stringSetting(setFlags["token-file"], *tokenFile, "NB_PAT_FILE", fileConfig.TokenFile, ""),
+		Stdin:   *tokenStdin,
+		Vault:   stringSetting(false, "", "NB_PAT_VAULT_PATH", fileConfig.VaultPath, ""),
+		Keyring: boolSetting(setFlags["keyring"], *keyring, fileConfig.Keyring, false),
+	}
+	if tokens.Stdin && tokens.File != "" {
+		log.Fatal("--token-stdin can't be combined with --token-file")
+	}
+	// Stdin can only be read once and a file or Vault path holds a single token
+	if (tokens.Stdin || tokens.File != "") && (command == commandWatch || account != nil || generateAccounts) {
+		log.Fatal("--token-stdin and --token-file can't be used with watch or accounts; use the environment or --keyring")
+	}
+	if tokens.Vault != "" && (account != nil || generateAccounts) {
+		log.Fatal("NB_PAT_VAULT_PATH holds a single token and can't be used with accounts")
+	}
+	tokenVariable := stringSetting(false, "", "", fileConfig.TokenEnv, "NB_PAT")
+	if account != nil {
+		tokenVariable = account.tokenVariable()
+	}
+	apiToken, err := tokens.token(tokenVariable, serverURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if account != nil && apiToken == "" {
+		log.Fatalf("%s environment variable is required for account %q", account.tokenVariable(), account.Name)
+	}
+	if generateAccounts && !tokens.Keyring {
+		var missing []string
+		for _, account := range fileConfig.Accounts {
+			if os.Getenv(account.tokenVariable()) == "" {
+				missing = append(missing, account.tokenVariable())
+			}
+		}
+		if len(missing) > 0 {
+			log.Fatalf("Missing the token of every account in %s", strings.Join(missing, ", "))
+		}
+	}
+	if apiToken == "" && bundle == nil && replayAPI == nil && !generateAccounts {
+		log.Fatalf("%s environment variable is required (NetBird Personal Access Token); see --token-file, --token-stdin and --keyring for other sources", tokenVariable)
+	}
+
+	rootCAs, err := loadCACertPool(stringSetting(false, "", "NB_CA_CERT", fileConfig.CACert, ""))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	clientCert, err := loadClientCertificate(
+		stringSetting(false, "", "NB_CLIENT_CERT", fileConfig.ClientCert, ""),
+		stringSetting(false, "", "NB_CLIENT_KEY", fileConfig.ClientKey, ""),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	splitByType := boolSetting(setFlags["split-state"], *splitState, fileConfig.SplitState, false)
+
+	// A Terragrunt layout is the split state layout with the provider and
+	// backend generated by Terragrunt, which therefore runs the imports too
+	terragruntLayout := boolSetting(setFlags["terragrunt"], *terragrunt, fileConfig.Terragrunt, false)
+	if terragruntLayout {
+		splitByType = true
+	}
+	if fileConfig.Backend != nil && fileConfig.Backend.Type == "" {
+		log.Fatal("Invalid config file: backend.type is required")
+	}
+
+	autoImport := boolSetting(false, false, fileConfig.AutoImport, true)
+	if value, exists := os.LookupEnv("AUTO_IMPORT"); exists {
+		autoImport = value != "false"
+	}
+
+	scrub, err := newScrubConfig(fileConfig.Scrub, apiToken, os.Getenv("SMTP_PASSWORD"))
+	if err != nil {
+		log.Fatalf("Invalid config file: %v", err)
+	}
+
+	// Merging appends to the files of a single root module
+	mergeExisting := boolSetting(setFlags["merge"], *merge, fileConfig.Merge, false)
+	if mergeExisting {
+		if splitByType || boolSetting(setFlags["module-package"], *modulePackage, fileConfig.ModulePackage, false) {
+			log.Fatal("--merge can't be combined with --split-state or --module-package")
+		}
+		if outputFormat != "hcl" {
+			log.Fatal("--merge appends HCL; --format json is not supported")
+		}
+	}
+
+	// HCP Terraform holds the state and runs the import instead of local terraform
+	var tfc *TFCConfig
+	if fileConfig.TFC != nil || *tfcOrganization != "" || *tfcWorkspace != "" || os.Getenv("TFC_WORKSPACE") != "" {
+		tfc = &TFCConfig{}
+		if fileConfig.TFC != nil {
+			*tfc = *fileConfig.TFC
+		}
+		tfc.Organization = stringSetting(setFlags["tfc-organization"], *tfcOrganization, "TFC_ORGANIZATION", tfc.Organization, "")
+		tfc.Workspace = stringSetting(setFlags["tfc-workspace"], *tfcWorkspace, "TFC_WORKSPACE", tfc.Workspace, "")
+		tfc.Hostname = stringSetting(setFlags["tfc-hostname"], *tfcHostname, "TFE_HOSTNAME", tfc.Hostname, defaultTFCHostname)
+		tfc.Token = os.Getenv("TFE_TOKEN")
+		if tfc.Organization == "" || tfc.Workspace == "" {
+			log.Fatal("HCP Terraform needs both --tfc-organization and --tfc-workspace")
+		}
+		if tfc.Token == "" {
+			log.Fatal("HCP Terraform needs an API token in TFE_TOKEN")
+		}
+		if splitByType || fileConfig.Backend != nil {
+			log.Fatal("HCP Terraform runs a single workspace; --split-state and backend can't be combined with it")
+		}
+	}
+
+	// A module package is not a root module; its example adopts the objects
+	packageModule := boolSetting(setFlags["module-package"], *modulePackage, fileConfig.ModulePackage, false)
+	if packageModule {
+		if splitByType {
+			log.Fatal("--module-package and --split-state can't be combined")
+		}
+		if outputFormat != "hcl" {
+			log.Fatal("--module-package writes HCL; --format json is not supported")
+		}
+		autoImport = false
+	}
+
+	// The modules only get a provider once Terragrunt generated it; their
+	// import.sh scripts run terragrunt import instead
+	if terragruntLayout {
+		autoImport = false
+	}
+
+	// A CDKTF application or Pulumi program is a single stack whose resources
+	// import themselves on the next cdktf apply or pulumi up
+	if lib.IsProgramFormat(outputFormat) {
+		if splitByType || packageModule || mergeExisting || pruneState || fileConfig.Backend != nil || tfc != nil {
+			log.Fatalf("--format %s writes a single stack; --split-state, --terragrunt, --module-package, --merge, --prune, backend and HCP Terraform are not supported", outputFormat)
+		}
+		autoImport = false
+	}
+
+	// Every account gets its own folder and aliased provider, which layouts
+	// generating the provider elsewhere don't support
+	if account != nil || generateAccounts {
+		if terragruntLayout || packageModule || tfc != nil || lib.IsProgramFormat(outputFormat) {
+			log.Fatal("accounts can't be combined with --terragrunt, --module-package, HCP Terraform, CDKTF or Pulumi formats")
+		}
+		if bundle != nil || replayAPI != nil || *record != "" {
+			log.Fatal("accounts can't be combined with --from-bundle, --replay or --record, which hold a single account")
+		}
+	}
+	var accountName string
+	if account != nil {
+		accountName = account.Name
+	}
+	var accounts []AccountConfig
+	if generateAccounts {
+		accounts = fileConfig.Accounts
+	}
+
+	// A trial run generates a subset, which --prune would take for deletions
+	objectLimit := *limit
+	if objectLimit < 0 {
+		log.Fatalf("Invalid limit %d: must not be negative", objectLimit)
+	}
+	if *sample && objectLimit == 0 {
+		objectLimit = lib.DefaultSampleSize
+	}
+	if objectLimit > 0 && pruneState {
+		log.Fatal("--limit and --sample can't be combined with --prune")
+	}
+
+	graphFormat := stringSetting(setFlags["graph"], *graph, "", fileConfig.Graph, "")
+	if _, known := lib.GraphFormats[graphFormat]; graphFormat != "" && !known {
+		log.Fatalf("Unknown graph format %q (supported: dot, mermaid)", graphFormat)
+	}
+
+	importWith := stringSetting(setFlags["import-mode"], *importMode, "", fileConfig.ImportMode, lib.ImportModeAuto)
+	if !isImportMode(importWith) {
+		log.Fatalf("Unknown import mode %q (supported: %s)", importWith, strings.Join(lib.ImportModes, ", "))
+	}
+	if importWith == lib.ImportModeState && fileConfig.Backend != nil {
+		log.Fatal("Import mode state writes the local terraform.tfstate and can't be used with a backend")
+	}
+
+	// Reconciling rewrites the generated files, which merging never touches
+	reconcileState := boolSetting(setFlags["reconcile"], *reconcile, fileConfig.Reconcile, false)
+	if reconcileState && (mergeExisting || tfc != nil) {
+		log.Fatal("--reconcile can't be combined with --merge or HCP Terraform")
+	}
+
+	scriptFormats := lib.DefaultImportScripts
+	if fileConfig.ImportScripts != nil {
+		scriptFormats = fileConfig.ImportScripts
+	}
+	if setFlags["import-scripts"] {
+		scriptFormats = append(make([]string, 0), splitList(*importScripts)...)
+	}
+	for _, scriptFormat := range scriptFormats {
+		if _, known := lib.ImportScriptFiles[scriptFormat]; !known {
+			log.Fatalf("Unknown import script %q (supported: sh, ps1)", scriptFormat)
+		}
+	}
 
 	return &Config{
-		ServerURL:  serverURL,
-		APIToken:   apiToken,
-		Debug:      debug,
-		AutoImport: autoImport,
+		ServerURL:        serverURL,
+		ServerVersion:    strings.TrimPrefix(stringSetting(setFlags["server-version"], *serverVersion, "NB_SERVER_VERSION", fileConfig.ServerVersion, ""), "v"),
+		APIToken:         apiToken,
+		Account:          accountName,
+		Accounts:         accounts,
+		WatchInterval:    runInterval,
+		AdminAddr:        stringSetting(setFlags["admin-addr"], *adminAddr, "", fileConfig.AdminAddr, defaultAdminAddr),
+		TargetURL:        stringSetting(setFlags["target-url"], *targetURL, "NB_TARGET_MANAGEMENT_URL", "", ""),
+		TargetToken:      os.Getenv("NB_TARGET_PAT"),
+		Verbosity:        verbosity,
+		AutoImport:       autoImport,
+		ImportMode:       importWith,
+		Reconcile:        reconcileState,
+		ImportScripts:    scriptFormats,
+		TerraformPath:    stringSetting(setFlags["terraform-path"], *terraformPath, "TERRAFORM_BIN", fileConfig.TerraformPath, lib.DefaultTerraformPath),
+		OutputDir:        outputDir,
+		Format:           outputFormat,
+		ProviderVersion:  stringSetting(false, "", "", fileConfig.ProviderVersion, defaultProviderVersion),
+		ProviderDefaults: boolSetting(false, false, fileConfig.ProviderDefaults, true),
+		ConfigFile:       configFile,
+		Profile:          selectedProfile,
+		Logger:           slog.New(logWarnings),
+		LogWarnings:      logWarnings,
+
+		ExcludedTypes:  excludedTypes,
+		IncludePattern: includePattern,
+		ExcludePattern: excludePattern,
+		Rules:          rules,
+		ImportOrder:    typeOrder,
+		IssuedActions:  issuedActions,
+		Limit:          objectLimit,
+		Sample:         *sample,
+		NameTemplates:  namingTemplates,
+		NameOverrides:  nameOverrides,
+		StateNames:     stateNames,
+		StateObjects:   stateObjects,
+
+		SkipSystemGroups: boolSetting(setFlags["skip-system-groups"], *skipSystemGroups, fileConfig.SkipSystemGroups, false),
+		SkipEmptyGroups:  boolSetting(setFlags["skip-empty-groups"], *skipEmptyGroups, fileConfig.SkipEmptyGroups, false),
+		OnlyEnabled:      boolSetting(setFlags["only-enabled"], *onlyEnabled, fileConfig.OnlyEnabled, false),
+
+		DashboardURL: strings.TrimSuffix(dashboardURL, "/"),
+		URLComments:  boolSetting(setFlags["url-comments"], *urlComments, fileConfig.URLComments, false),
+
+		Lifecycle: lifecycle,
+
+		SuggestGroups: boolSetting(setFlags["suggest-groups"], *suggestGroups, fileConfig.SuggestGroups, false),
+		PulumiImport:  boolSetting(setFlags["pulumi-import"], *pulumiImport, fileConfig.PulumiImport, false),
+		Ansible:       boolSetting(setFlags["ansible-inventory"], *ansibleInventory, fileConfig.AnsibleInventory, false),
+		Graph:         graphFormat,
+		HTMLReport:    boolSetting(setFlags["html-report"], *htmlReport, fileConfig.HTMLReport, false),
+		DryRun:        boolSetting(setFlags["dry-run"], *dryRun, fileConfig.DryRun, false),
+		FailOnWarning: boolSetting(setFlags["fail-on-warning"], *failOnWarning, fileConfig.FailOnWarning, false),
+		Preflight:     boolSetting(setFlags["preflight"], *preflightCheck, fileConfig.Preflight, true),
+
+		EmailReport: emailRecipients,
+
+		MaxRetries:     retries,
+		RetryDelay:     retryBaseDelay,
+		QPS:            requestRate,
+		RequestTimeout: requestDeadline,
+		RunTimeout:     runDeadline,
+
+		Concurrency: parallelFetches,
+
+		RootCAs:       rootCAs,
+		ClientCert:    clientCert,
+		TLSSkipVerify: boolSetting(setFlags["tls-skip-verify"], *tlsSkipVerify, fileConfig.TLSSkipVerify, false),
+		TraceHTTP:     boolSetting(setFlags["trace-http"], *traceHTTP, fileConfig.TraceHTTP, false),
+
+		Bundle: bundle,
+
+		RecordDir: *record,
+		Replay:    replayAPI,
+
+		CacheDir:     cacheDirectory,
+		CacheTTL:     cacheMaxAge,
+		CacheRefresh: *refresh,
+
+		Checkpoint: boolSetting(setFlags["checkpoint"], *checkpoint, fileConfig.Checkpoint, true),
+		Resume:     *resume,
+
+		StatsFile:    stringSetting(setFlags["stats-file"], *statsFile, "", fileConfig.StatsFile, ""),
+		TelemetryURL: stringSetting(false, "", "NB_TELEMETRY_URL", fileConfig.TelemetryURL, ""),
+		Stats:        boolSetting(setFlags["stats"], *stats, fileConfig.Stats, false),
+
+		SplitState:    splitByType,
+		Backend:       fileConfig.Backend,
+		ModulePackage: packageModule,
+		Merge:         mergeExisting,
+		Prune:         pruneState,
+		TFC:           tfc,
+		Terragrunt:    terragruntLayout,
+
+		Scrub: scrub,
+
+		// Progress bars would garble JSON logs and redirected output
+		InteractiveProgress: !*noProgress && outputLogFormat == "text" && lib.IsTerminal(os.Stderr),
+	}
+}
+
+// newScrubConfig combines the default secret patterns with those of the config
+// file. The given secrets are always redacted verbatim.
+func newScrubConfig(fileConfig ScrubFileConfig, secrets ...string) (lib.ScrubConfig, error) {
+	scrub := lib.ScrubConfig{
+		Secrets:          secrets,
+		Patterns:         append([]lib.SecretPattern{}, lib.DefaultSecretPatterns...),
+		EntropyThreshold: lib.DefaultEntropyThreshold,
+		EntropyMinLength: lib.DefaultEntropyMinLength,
+	}
+
+	for _, patternConfig := range fileConfig.Patterns {
+		if patternConfig.Name == "" {
+			return scrub, fmt.Errorf("scrub pattern %q has no name", patternConfig.Pattern)
+		}
+		pattern, err := regexp.Compile(patternConfig.Pattern)
+		if err != nil {
+			return scrub, fmt.Errorf("scrub pattern %s: %w", patternConfig.Name, err)
+		}
+		scrub.Patterns = append(scrub.Patterns, lib.SecretPattern{Name: patternConfig.Name, Pattern: pattern})
+	}
+
+	for _, allowConfig := range fileConfig.Allow {
+		allow, err := regexp.Compile(allowConfig)
+		if err != nil {
+			return scrub, fmt.Errorf("scrub allow pattern %q: %w", allowConfig, err)
+		}
+		scrub.Allow = append(scrub.Allow, allow)
+	}
+
+	if fileConfig.Entropy != nil {
+		scrub.EntropyThreshold = *fileConfig.Entropy
+	}
+	if fileConfig.MinLength != nil {
+		scrub.EntropyMinLength = max(*fileConfig.MinLength, 1)
+	}
+
+	return scrub, nil
+}
+
+// defaultDashboardURL derives the dashboard URL from the management URL. NetBird
+// Cloud serves the dashboard on app.netbird.io; self-hosted deployments usually
+// serve it on the management host without the API port, under the same path
+// prefix as the API.
+func defaultDashboardURL(serverURL string) string {
+	parsed, err := url.Parse(serverURL)
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+
+	if parsed.Hostname() == "api.netbird.io" {
+		return "https://app.netbird.io"
+	}
+
+	host := parsed.Hostname()
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return fmt.Sprintf("%s://%s%s", parsed.Scheme, host, strings.TrimSuffix(parsed.EscapedPath(), "/"))
+}
+
+// extractVerbosity removes -v, -vv and -vvv from the arguments and returns the
+// categories of the requested level. The flag package cannot parse repeated
+// short flags.
+func extractVerbosity(args []string) (lib.Verbosity, []string) {
+	level := 0
+	remaining := make([]string, 0, len(args))
+
+	for _, arg := range args {
+		if len(arg) > 1 && strings.Trim(arg, "v") == "-" {
+			level += len(arg) - 1
+			continue
+		}
+		remaining = append(remaining, arg)
+	}
+
+	return lib.VerbosityLevel(level), remaining
+}
+
+// loadCACertPool returns the system roots plus the certificates of a PEM bundle,
+// for self-hosted servers using a private CA. An empty path returns nil, which
+// makes the client use the system roots.
+func loadCACertPool(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificates found in CA certificate file %s", path)
+	}
+	return pool, nil
+}
+
+// loadClientCertificate loads the client certificate presented to management
+// servers behind mutual TLS. Both paths must be set, or neither.
+func loadClientCertificate(certPath, keyPath string) (*tls.Certificate, error) {
+	if certPath == "" && keyPath == "" {
+		return nil, nil
+	}
+	if certPath == "" || keyPath == "" {
+		return nil, fmt.Errorf("NB_CLIENT_CERT and NB_CLIENT_KEY must be set together")
+	}
+
+	certificate, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+	return &certificate, nil
+}
+
+// compilePattern compiles an optional regex flag value, exiting on invalid input
+func compilePattern(flagName, value string) *regexp.Regexp {
+	if value == "" {
+		return nil
+	}
+
+	pattern, err := regexp.Compile(value)
+	if err != nil {
+		log.Fatalf("Invalid --%s pattern: %v", flagName, err)
+	}
+	return pattern
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// isImportMode reports whether the given name is a supported import mode
+func isImportMode(name string) bool {
+	for _, mode := range lib.ImportModes {
+		if mode == name {
+			return true
+		}
+	}
+	return false
+}
+
+// nameTemplateFlag collects repeated --name-template type=template flags
+type nameTemplateFlag map[string]string
+
+func (f nameTemplateFlag) String() string {
+	return ""
+}
+
+func (f nameTemplateFlag) Set(value string) error {
+	resourceType, text, found := strings.Cut(value, "=")
+	if !found || resourceType == "" {
+		return errors.New("expected type=template, e.g. user={{.Email | localpart}}")
+	}
+	f[resourceType] = text
+	return nil
+}
+
+// ignoreChangesFlag collects repeated --ignore-changes type=attribute,... flags
+type ignoreChangesFlag map[string][]string
+
+func (f ignoreChangesFlag) String() string {
+	return ""
+}
+
+func (f ignoreChangesFlag) Set(value string) error {
+	resourceType, attributes, found := strings.Cut(value, "=")
+	if !found || resourceType == "" || attributes == "" {
+		return errors.New("expected type=attribute,..., e.g. group=peers")
+	}
+	f[resourceType] = append(f[resourceType], splitList(attributes)...)
+	return nil
+}
+
+// lifecycleSettings merges the lifecycle blocks of the config file with the
+// flags. --ignore-changes replaces the file's ignored attributes of its type;
+// --prevent-destroy, when given, replaces prevent_destroy of every type.
+func lifecycleSettings(fileLifecycle map[string]lib.Lifecycle, ignoreChanges ignoreChangesFlag, preventDestroySet bool, preventDestroy []string) map[string]lib.Lifecycle {
+	lifecycle := make(map[string]lib.Lifecycle, len(fileLifecycle))
+	for resourceType, settings := range fileLifecycle {
+		lifecycle[resourceType] = settings
+	}
+	for resourceType, attributes := range ignoreChanges {
+		settings := lifecycle[resourceType]
+		settings.IgnoreChanges = attributes
+		lifecycle[resourceType] = settings
+	}
+	if preventDestroySet {
+		for resourceType, settings := range lifecycle {
+			settings.PreventDestroy = false
+			lifecycle[resourceType] = settings
+		}
+		for _, resourceType := range preventDestroy {
+			settings := lifecycle[resourceType]
+			settings.PreventDestroy = true
+			lifecycle[resourceType] = settings
+		}
+	}
+
+	for resourceType, settings := range lifecycle {
+		if !isResourceType(resourceType) {
+			log.Fatalf("Unknown resource type %q in lifecycle (supported: %s)", resourceType, strings.Join(resourceTypes, ", "))
+		}
+		if err := settings.Validate(); err != nil {
+			log.Fatalf("Invalid lifecycle for %s: %v", resourceType, err)
+		}
+	}
+	return lifecycle
+}
+
+// isResourceType reports whether the given name is a supported resource type
+func isResourceType(name string) bool {
+	for _, resourceType := range resourceTypes {
+		if resourceType == name {
+			return true
+		}
 	}
+	return false
 }
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"netbird-terraformer/lib"
+)
+
+// defaultConfigFiles are looked up in the working directory when no config file
+// is given explicitly
+var defaultConfigFiles = []string{"netbird-terraformer.yaml", "netbird-terraformer.yml"}
+
+// FileConfig represents the optional netbird-terraformer.yaml configuration file.
+// Environment variables and flags override the values set here.
+type FileConfig struct {
+	ServerURL       string `json:"server_url"`
+	ServerVersion   string `json:"server_version"`
+	DashboardURL    string `json:"dashboard_url"`
+	OutputDir       string `json:"output_dir"`
+	Format          string `json:"format"`
+	ProviderVersion string `json:"provider_version"`
+
+	// ProviderDefaults disables the provider defaults knowledge base when false
+	ProviderDefaults *bool `json:"provider_defaults"`
+
+	ExcludeResources []string     `json:"exclude_resources"`
+	Include          string       `json:"include"`
+	Exclude          string       `json:"exclude"`
+	Rules            []RuleConfig `json:"rules"`
+	ImportOrder      []string     `json:"import_order"`
+
+	// Issued maps an issued value (api, jwt, integration) to a rule action
+	Issued map[string]string `json:"issued"`
+
+	SkipSystemGroups *bool `json:"skip_system_groups"`
+	SkipEmptyGroups  *bool `json:"skip_empty_groups"`
+	OnlyEnabled      *bool `json:"only_enabled"`
+
+	// NameTemplates maps a resource type to its resource name template
+	NameTemplates map[string]string `json:"name_templates"`
+
+	// NameOverrides is the path of a file mapping object IDs to resource names
+	NameOverrides string `json:"name_overrides"`
+
+	// Lifecycle maps a resource type to the lifecycle block of its resources
+	Lifecycle map[string]lib.Lifecycle `json:"lifecycle"`
+
+	// ReuseStateNames keeps the names of objects in the output directory's
+	// state when false is not given
+	ReuseStateNames *bool `json:"reuse_state_names"`
+
+	Debug         *bool    `json:"debug"`
+	Verbosity     *int     `json:"verbosity"`
+	Verbose       []string `json:"verbose"`
+	LogLevel      string   `json:"log_level"`
+	LogFormat     string   `json:"log_format"`
+	AutoImport    *bool    `json:"auto_import"`
+	ImportMode    string   `json:"import_mode"`
+	Reconcile     *bool    `json:"reconcile"`
+	TerraformPath string   `json:"terraform_path"`
+	URLComments   *bool    `json:"url_comments"`
+	SuggestGroups *bool    `json:"suggest_groups"`
+	PulumiImport  *bool    `json:"pulumi_import"`
+	DryRun        *bool    `json:"dry_run"`
+	FailOnWarning *bool    `json:"fail_on_warning"`
+	Preflight     *bool    `json:"preflight"`
+
+	// ImportScripts lists the import scripts to write; an empty list writes none
+	ImportScripts []string `json:"import_scripts"`
+
+	AnsibleInventory *bool  `json:"ansible_inventory"`
+	Graph            string `json:"graph"`
+	HTMLReport       *bool  `json:"html_report"`
+
+	EmailReport []string `json:"email_report"`
+
+	MaxRetries     *int     `json:"max_retries"`
+	RetryDelay     string   `json:"retry_delay"`
+	QPS            *float64 `json:"qps"`
+	RequestTimeout string   `json:"request_timeout"`
+	RunTimeout     string   `json:"run_timeout"`
+
+	Concurrency *int `json:"concurrency"`
+
+	CacheDir string `json:"cache_dir"`
+	CacheTTL string `json:"cache_ttl"`
+
+	Checkpoint *bool `json:"checkpoint"`
+
+	WatchInterval string `json:"watch_interval"`
+	AdminAddr     string `json:"admin_addr"`
+
+	TokenEnv  string `json:"token_env"` // environment variable holding the token, NB_PAT by default
+	TokenFile string `json:"token_file"`
+	VaultPath string `json:"vault_path"`
+	Keyring   *bool  `json:"keyring"`
+
+	CACert        string `json:"ca_cert"`
+	ClientCert    string `json:"client_cert"`
+	ClientKey     string `json:"client_key"`
+	TLSSkipVerify *bool  `json:"tls_skip_verify"`
+	TraceHTTP     *bool  `json:"trace_http"`
+
+	StatsFile    string `json:"stats_file"`
+	TelemetryURL string `json:"telemetry_url"`
+	Stats        *bool  `json:"stats"`
+
+	SplitState    *bool              `json:"split_state"`
+	Backend       *lib.BackendConfig `json:"backend"`
+	ModulePackage *bool              `json:"module_package"`
+	Merge         *bool              `json:"merge"`
+	Prune         *bool              `json:"prune"`
+	TFC           *TFCConfig         `json:"tfc"`
+	Terragrunt    *bool              `json:"terragrunt"`
+
+	Scrub ScrubFileConfig `json:"scrub"`
+
+	Accounts []AccountConfig `json:"accounts"`
+
+	// Profiles are named sets of settings selected with --profile or
+	// NB_PROFILE, see applyProfile
+	Profiles map[string]FileConfig `json:"profiles"`
+
+	// OIDC is the identity provider the login command authenticates with
+	OIDC OIDCConfig `json:"oidc"`
+}
+
+// AccountConfig is one of several accounts generated in a single run, e.g.
+//
+//	accounts:
+//	  - name: prod
+//	    server_url: https://netbird.example.com
+//	    token_env: NB_PAT_PROD
+//
+// Each account is generated into <output>/<name> with a provider aliased
+// <name>. The token is read from token_env, NB_PAT_<NAME> by default.
+type AccountConfig struct {
+	Name         string `json:"name"`
+	ServerURL    string `json:"server_url"`
+	DashboardURL string `json:"dashboard_url"`
+	TokenEnv     string `json:"token_env"`
+}
+
+// accountNamePattern matches the names Terraform accepts as provider aliases
+var accountNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// tokenVariable returns the environment variable holding the account's token
+func (a AccountConfig) tokenVariable() string {
+	if a.TokenEnv != "" {
+		return a.TokenEnv
+	}
+	return "NB_PAT_" + strings.ToUpper(strings.ReplaceAll(a.Name, "-", "_"))
+}
+
+// validateAccounts checks the accounts of the config file
+func validateAccounts(accounts []AccountConfig) error {
+	seen := make(map[string]bool, len(accounts))
+	for _, account := range accounts {
+		if !accountNamePattern.MatchString(account.Name) {
+			return fmt.Errorf("invalid account name %q: use letters, digits, underscores and dashes", account.Name)
+		}
+		if seen[account.Name] {
+			return fmt.Errorf("account %q is listed twice", account.Name)
+		}
+		seen[account.Name] = true
+		if account.ServerURL == "" {
+			return fmt.Errorf("account %q has no server_url", account.Name)
+		}
+	}
+	return nil
+}
+
+// findAccount returns the account of the given name
+func findAccount(accounts []AccountConfig, name string) (AccountConfig, bool) {
+	for _, account := range accounts {
+		if account.Name == name {
+			return account, true
+		}
+	}
+	return AccountConfig{}, false
+}
+
+// applyProfile overlays a profile onto the file's top-level settings, e.g.
+//
+//	profiles:
+//	  prod:
+//	    server_url: https://netbird.example.com
+//	    token_env: NB_PAT_PROD
+//	    exclude_resources: [setup_key]
+//
+// Every key the profile sets replaces the top-level key of the same name as a
+// whole; keys it leaves out keep their top-level value. The profile is
+// returned so its URLs can take precedence over the environment.
+func (c *FileConfig) applyProfile(name string) (*FileConfig, error) {
+	profile, exists := c.Profiles[name]
+	if !exists {
+		names := make([]string, 0, len(c.Profiles))
+		for profileName := range c.Profiles {
+			names = append(names, profileName)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return nil, fmt.Errorf("profile %q not found: the config file defines no profiles", name)
+		}
+		return nil, fmt.Errorf("profile %q not found (defined: %s)", name, strings.Join(names, ", "))
+	}
+	if profile.Profiles != nil || profile.Accounts != nil {
+		return nil, fmt.Errorf("profile %q can't define profiles or accounts", name)
+	}
+
+	base := reflect.ValueOf(c).Elem()
+	overlay := reflect.ValueOf(profile)
+	for i := 0; i < overlay.NumField(); i++ {
+		if field := overlay.Field(i); !field.IsZero() {
+			base.Field(i).Set(field)
+		}
+	}
+	return &profile, nil
+}
+
+// ScrubFileConfig adds to the secret scrubbing of generated files, e.g.
+//
+//	scrub:
+//	  patterns:
+//	    - name: internal_api_key
+//	      pattern: 'ik_[A-Za-z0-9]{32}'
+//	  entropy: 4.5
+//	  allow: ['^[A-Za-z0-9+/]{43}=$']
+type ScrubFileConfig struct {
+	Patterns  []SecretPatternConfig `json:"patterns"`
+	Entropy   *float64              `json:"entropy"`    // bits per character, 0 disables
+	MinLength *int                  `json:"min_length"` // shortest string checked for entropy
+	Allow     []string              `json:"allow"`
+}
+
+// SecretPatternConfig is a named secret pattern of the scrub section
+type SecretPatternConfig struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// RuleConfig is a per-object rule, e.g.
+//
+//	rules:
+//	  - when: 'resource.type == "group" && resource.name.startsWith("idp-")'
+//	    action: data_source
+type RuleConfig struct {
+	When   string `json:"when"`
+	Action string `json:"action"`
+}
+
+// loadConfigFile reads the config file from the given path, NB_CONFIG, or one of
+// the default locations. A missing default file is not an error.
+func loadConfigFile(path string) (*FileConfig, string, error) {
+	if path == "" {
+		path = os.Getenv("NB_CONFIG")
+	}
+
+	candidates := []string{path}
+	if path == "" {
+		candidates = defaultConfigFiles
+	}
+
+	for _, candidate := range candidates {
+		data, err := os.ReadFile(candidate)
+		if errors.Is(err, os.ErrNotExist) && path == "" {
+			continue
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read config file: %w", err)
+		}
+
+		fileConfig := &FileConfig{}
+		err = lib.DecodeYAML(data, fileConfig)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to parse config file %s: %w", candidate, err)
+		}
+		return fileConfig, candidate, nil
+	}
+
+	return &FileConfig{}, "", nil
+}
+
+// stringSetting resolves a string setting with flag > env > file > default precedence
+func stringSetting(flagSet bool, flagValue, envName, fileValue, defaultValue string) string {
+	if flagSet {
+		return flagValue
+	}
+	if envName != "" {
+		if value := os.Getenv(envName); value != "" {
+			return value
+		}
+	}
+	if fileValue != "" {
+		return fileValue
+	}
+	return defaultValue
+}
+
+// intSetting resolves an integer setting with flag > file > default precedence
+func intSetting(flagSet bool, flagValue int, fileValue *int, defaultValue int) int {
+	if flagSet {
+		return flagValue
+	}
+	if fileValue != nil {
+		return *fileValue
+	}
+	return defaultValue
+}
+
+// boolSetting resolves a boolean setting with flag > file > default precedence
+func boolSetting(flagSet bool, flagValue bool, fileValue *bool, defaultValue bool) bool {
+	if flagSet {
+		return flagValue
+	}
+	if fileValue != nil {
+		return *fileValue
+	}
+	return defaultValue
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/template"
+
+	"netbird-terraformer/lib"
+)
+
+// Deploy targets
+const (
+	deployKubernetes = "kubernetes"
+	deployCompose    = "compose"
+)
+
+// deployOutputDir is where the output volume is mounted in the container
+const deployOutputDir = "/output"
+
+// uploaders sync the output volume to object storage after a run, per URL scheme
+var uploaders = map[string]struct {
+	image   string
+	command []string
+}{
+	"s3://": {"amazon/aws-cli:latest", []string{"aws", "s3", "sync", deployOutputDir}},
+	"gs://": {"gcr.io/google.com/cloudsdktool/google-cloud-cli:slim", []string{"gsutil", "-m", "rsync", "-r", deployOutputDir}},
+}
+
+// deployOptions configure the manifest written by the deploy command
+type deployOptions struct {
+	Target        string
+	Name          string
+	Namespace     string
+	Schedule      string // kubernetes: cron schedule of the CronJob
+	Interval      string // compose: watch interval
+	Image         string
+	Secret        string // kubernetes: secret holding NB_PAT
+	Volume        string // persistent volume claim or compose volume
+	StorageSize   string
+	Upload        string // object storage URL the output is synced to
+	ManagementURL string
+	AutoImport    bool
+	Args          []string // importer flags
+
+	// Derived by renderDeployManifest
+	ContainerArgs []string
+	UploadImage   string
+	UploadCommand []string
+}
+
+// runDeploy writes a Kubernetes CronJob or docker-compose manifest running the
+// importer on a schedule. Flags after "--" are passed to the importer; the
+// output directory is always the mounted volume.
+func runDeploy(arguments []string) int {
+	flags := flag.NewFlagSet(os.Args[0]+" "+commandDeploy, flag.ExitOnError)
+	options := deployOptions{}
+	flags.StringVar(&options.Target, "target", deployKubernetes, "Manifest to write: kubernetes (CronJob) or compose")
+	flags.StringVar(&options.Name, "name", "netbird-importer", "Name of the CronJob or compose service")
+	flags.StringVar(&options.Namespace, "namespace", "", "Kubernetes namespace")
+	flags.StringVar(&options.Schedule, "schedule", "0 * * * *", "Cron schedule of the CronJob")
+	flags.StringVar(&options.Interval, "interval", defaultWatchInterval.String(), "compose: time between runs of the watch command")
+	flags.StringVar(&options.Image, "image", "netbird-importer:"+version, "Container image of the importer")
+	flags.StringVar(&options.Secret, "secret", "netbird-importer", "Kubernetes secret holding NB_PAT")
+	flags.StringVar(&options.Volume, "volume", "", "Persistent volume claim or compose volume for the output (default: <name>-output)")
+	flags.StringVar(&options.StorageSize, "storage-size", "1Gi", "Size of the persistent volume claim")
+	flags.StringVar(&options.Upload, "upload", "", "Sync the output to object storage after each run: s3://bucket/prefix or gs://bucket/prefix")
+	flags.BoolVar(&options.AutoImport, "auto-import", false, "Run terraform imports in the job (the image must contain terraform)")
+	outputFile := flags.String("o", "", "Write the manifest to this file instead of stdout")
+	flags.Parse(arguments)
+
+	options.Args = flags.Args()
+	options.ManagementURL = os.Getenv("NB_MANAGEMENT_URL")
+	if options.Volume == "" {
+		options.Volume = options.Name + "-output"
+	}
+
+	manifest, err := renderDeployManifest(options)
+	if err != nil {
+		slog.Error("Failed to write deploy manifest", "error", err)
+		return exitFatal
+	}
+
+	if *outputFile == "" {
+		fmt.Print(manifest)
+		return exitOK
+	}
+	if err := os.WriteFile(*outputFile, []byte(manifest), 0644); err != nil {
+		slog.Error("Failed to write deploy manifest", "error", err)
+		return exitFatal
+	}
+	slog.Info("Wrote deploy manifest", "target", options.Target, "path", *outputFile)
+	return exitOK
+}
+
+// renderDeployManifest validates the options and renders the manifest
+func renderDeployManifest(options deployOptions) (string, error) {
+	if options.Upload != "" {
+		if options.Target != deployKubernetes {
+			return "", fmt.Errorf("--upload is only supported for --target %s; sync the compose volume with your storage tooling instead", deployKubernetes)
+		}
+		for scheme, uploader := range uploaders {
+			if strings.HasPrefix(options.Upload, scheme) {
+				options.UploadImage = uploader.image
+				options.UploadCommand = append(append([]string{}, uploader.command...), options.Upload)
+			}
+		}
+		if options.UploadImage == "" {
+			return "", fmt.Errorf("unsupported upload URL %q: use s3://bucket/prefix or gs://bucket/prefix", options.Upload)
+		}
+	}
+
+	var manifest *template.Template
+	switch options.Target {
+	case deployKubernetes:
+		manifest = kubernetesManifest
+		options.ContainerArgs = options.containerArgs(commandGenerate)
+	case deployCompose:
+		manifest = composeManifest
+		options.ContainerArgs = options.containerArgs(commandWatch)
+	default:
+		return "", fmt.Errorf("unknown deploy target %q (supported: %s, %s)", options.Target, deployKubernetes, deployCompose)
+	}
+
+	var out strings.Builder
+	if err := manifest.Execute(&out, options); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// containerArgs returns the arguments of the importer container
+func (o deployOptions) containerArgs(command string) []string {
+	args := []string{command}
+	if command == commandWatch {
+		args = append(args, "--interval", o.Interval, "--admin-addr", ":9090")
+	}
+	args = append(args, o.Args...)
+	return append(args, deployOutputDir)
+}
+
+// quoteYAMLList writes a flow sequence of quoted strings
+func quoteYAMLList(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, lib.QuoteYAML(value))
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+var deployFuncs = template.FuncMap{"quote": lib.QuoteYAML, "list": quoteYAMLList}
+
+var kubernetesManifest = template.Must(template.New(deployKubernetes).Funcs(deployFuncs).Parse(`# Generated by netbird-importer deploy. Create the token secret first:
+#   kubectl create secret generic {{.Secret}} --from-literal=NB_PAT=<token>{{if .Namespace}} -n {{.Namespace}}{{end}}
+{{- if .Upload}}
+# The upload container needs write access to {{.Upload}}, e.g. through
+# workload identity (IRSA on EKS, Workload Identity on GKE).
+{{- end}}
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: {{quote .Name}}
+{{- if .Namespace}}
+  namespace: {{quote .Namespace}}
+{{- end}}
+spec:
+  schedule: {{quote .Schedule}}
+  concurrencyPolicy: Forbid
+  jobTemplate:
+    spec:
+      backoffLimit: 0
+      template:
+        spec:
+          restartPolicy: Never
+{{- if .Upload}}
+          initContainers:
+{{- template "importer" .}}
+          containers:
+            - name: upload
+              image: {{quote .UploadImage}}
+              command: {{list .UploadCommand}}
+              volumeMounts:
+                - name: output
+                  mountPath: /output
+                  readOnly: true
+          volumes:
+            - name: output
+              emptyDir: {}
+{{- else}}
+          containers:
+{{- template "importer" .}}
+          volumes:
+            - name: output
+              persistentVolumeClaim:
+                claimName: {{quote .Volume}}
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: {{quote .Volume}}
+{{- if .Namespace}}
+  namespace: {{quote .Namespace}}
+{{- end}}
+spec:
+  accessModes: ["ReadWriteOnce"]
+  resources:
+    requests:
+      storage: {{quote .StorageSize}}
+{{- end}}
+{{- define "importer"}}
+            - name: importer
+              image: {{quote .Image}}
+              args: {{list .ContainerArgs}}
+              env:
+                - name: NB_PAT
+                  valueFrom:
+                    secretKeyRef:
+                      name: {{quote .Secret}}
+                      key: NB_PAT
+{{- if .ManagementURL}}
+                - name: NB_MANAGEMENT_URL
+                  value: {{quote .ManagementURL}}
+{{- end}}
+                - name: AUTO_IMPORT
+                  value: {{if .AutoImport}}"true"{{else}}"false"{{end}}
+              volumeMounts:
+                - name: output
+                  mountPath: /output
+{{- end}}
+`))
+
+var composeManifest = template.Must(template.New(deployCompose).Funcs(deployFuncs).Parse(`# Generated by netbird-importer deploy. NB_PAT is read from the environment or
+# an .env file next to this file; it is never written here.
+services:
+  {{.Name}}:
+    image: {{quote .Image}}
+    command: {{list .ContainerArgs}}
+    environment:
+      NB_PAT: ${NB_PAT:?NB_PAT is required}
+{{- if .ManagementURL}}
+      NB_MANAGEMENT_URL: {{quote .ManagementURL}}
+{{- end}}
+      AUTO_IMPORT: {{if .AutoImport}}"true"{{else}}"false"{{end}}
+    volumes:
+      - {{.Volume}}:/output
+    ports:
+      - "127.0.0.1:9090:9090"  # admin endpoint: /healthz, /readyz, /status, POST /sync
+    restart: unless-stopped
+
+volumes:
+  {{.Volume}}: {}
+`))
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+)
+
+// runDiff compares the configurations generated by two runs, e.g. last week's
+// and today's output directory, and prints the resources added, changed and
+// removed between them, grouped by resource type. It needs no API access.
+func runDiff(arguments []string) int {
+	flags := flag.NewFlagSet(os.Args[0]+" "+commandDiff, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s %s <old-output-directory> <new-output-directory>\n", os.Args[0], commandDiff)
+	}
+	flags.Parse(arguments)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return exitFatal
+	}
+
+	oldDir, newDir := flags.Arg(0), flags.Arg(1)
+	before, err := readResourceBlocks(oldDir)
+	if err != nil {
+		slog.Error("Failed to read configuration", "dir", oldDir, "error", err)
+		return exitFatal
+	}
+	after, err := readResourceBlocks(newDir)
+	if err != nil {
+		slog.Error("Failed to read configuration", "dir", newDir, "error", err)
+		return exitFatal
+	}
+	for dir, blocks := range map[string]map[string]resourceBlock{oldDir: before, newDir: after} {
+		if len(blocks) == 0 {
+			slog.Error("No generated configuration found", "dir", dir)
+			return exitFatal
+		}
+	}
+
+	report := compareResourceBlocks(before, after)
+	fmt.Printf("Comparing %s with %s\n\n", oldDir, newDir)
+	printDiff(report)
+	if report.Count() > 0 {
+		return exitPartialFailure
+	}
+	return exitOK
+}
+
+// printDiff prints the changes between two runs grouped by resource type
+func printDiff(report driftReport) {
+	if report.Count() == 0 {
+		fmt.Println("No changes")
+		return
+	}
+
+	lines := make(map[string][]string)
+	add := func(key, marker string, details ...string) {
+		resourceType := blockResourceType(key)
+		lines[resourceType] = append(lines[resourceType], fmt.Sprintf("  %s %s", marker, key))
+		lines[resourceType] = append(lines[resourceType], details...)
+	}
+	for _, key := range report.Added {
+		add(key, "+")
+	}
+	for _, change := range report.Changed {
+		details := make([]string, 0, len(change.Removed)+len(change.Added))
+		for _, line := range change.Removed {
+			details = append(details, "      - "+line)
+		}
+		for _, line := range change.Added {
+			details = append(details, "      + "+line)
+		}
+		add(change.Address, "~", details...)
+	}
+	for _, key := range report.Removed {
+		add(key, "-")
+	}
+
+	types := make([]string, 0, len(lines))
+	for resourceType := range lines {
+		types = append(types, resourceType)
+	}
+	sort.Strings(types)
+	for _, resourceType := range types {
+		fmt.Printf("%s:\n", resourceType)
+		for _, line := range lines[resourceType] {
+			fmt.Println(line)
+		}
+	}
+	fmt.Printf("\n%d added, %d changed, %d removed\n", len(report.Added), len(report.Changed), len(report.Removed))
+}
+
+// blockResourceType returns the resource type of a block key such as
+// "group/data.netbird_peer.build_01"
+func blockResourceType(key string) string {
+	address := key[strings.LastIndex(key, "/")+1:]
+	address = strings.TrimPrefix(address, "data.")
+	resourceType, _, _ := strings.Cut(address, ".")
+	return strings.TrimPrefix(resourceType, "netbird_")
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"netbird-terraformer/lib"
+	"netbird-terraformer/resources"
+)
+
+// windowsMaxPath is the path length Windows APIs are limited to unless long
+// paths are enabled. terraform init writes the provider plugin this deep
+// below the output directory.
+const (
+	windowsMaxPath     = 260
+	providerPluginPath = `.terraform\providers\registry.terraform.io\netbirdio\netbird\0.0.0\windows_amd64\terraform-provider-netbird_v0.0.0.exe`
+)
+
+// localCheck is the result of checking one prerequisite on this machine.
+// A warning is printed but does not fail the check.
+type localCheck struct {
+	Name    string
+	Status  string
+	Err     error
+	Warning string
+}
+
+// endpointCheck is the result of probing one API endpoint
+type endpointCheck struct {
+	Endpoint string
+	Latency  time.Duration
+	Status   string
+	Items    int
+	Err      error
+}
+
+// runDoctor requests every endpoint the importer uses at the same time and prints
+// the status, latency and item count of each, as a health and sizing snapshot of
+// the account before a migration, followed by the local checks. It returns the
+// number of failed endpoints and checks.
+func runDoctor(ctx context.Context, config *Config) int {
+	// Retries would hide flaky endpoints and inflate their latency
+	probeConfig := *config
+	probeConfig.MaxRetries = 0
+	service := newService(&probeConfig)
+
+	endpoints := importerEndpoints(config.ExcludedTypes)
+	checks := make([]endpointCheck, len(endpoints))
+
+	fmt.Printf("Checking %d endpoints of %s\n\n", len(endpoints), config.ServerURL)
+	startedAt := time.Now()
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, max(config.Concurrency, 1))
+	for i, endpoint := range endpoints {
+		wg.Add(1)
+		go func(i int, endpoint string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			checks[i] = checkEndpoint(ctx, service, endpoint)
+		}(i, endpoint)
+	}
+	wg.Wait()
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ENDPOINT\tSTATUS\tLATENCY\tITEMS")
+	failed := 0
+	for _, check := range checks {
+		items := "-"
+		if check.Err == nil {
+			items = fmt.Sprintf("%d", check.Items)
+		} else {
+			failed++
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", check.Endpoint, check.Status, check.Latency.Round(time.Millisecond), items)
+	}
+	table.Flush()
+
+	fmt.Printf("\n%d of %d endpoints healthy (%s total)\n", len(checks)-failed, len(checks), time.Since(startedAt).Round(time.Millisecond))
+	for _, check := range checks {
+		if check.Err != nil {
+			fmt.Printf("  %s: %v\n", check.Endpoint, check.Err)
+		}
+	}
+
+	fmt.Printf("\nLocal checks (%s/%s)\n", runtime.GOOS, runtime.GOARCH)
+	for _, check := range checkLocal(config) {
+		fmt.Printf("  %-17s %s\n", check.Name, check.Status)
+		if check.Err != nil {
+			failed++
+			fmt.Printf("  %-17s %v\n", "", check.Err)
+		}
+		if check.Warning != "" {
+			fmt.Printf("  %-17s warning: %s\n", "", check.Warning)
+		}
+	}
+
+	return failed
+}
+
+// checkLocal checks what a run needs on this machine, so CI agents, Windows
+// ones in particular, fail fast: the terraform binary when imports run, and an
+// output directory that can be written. On Windows it also warns when the
+// output directory is too deep for the provider plugin terraform init installs.
+func checkLocal(config *Config) []localCheck {
+	checks := make([]localCheck, 0, 2)
+
+	terraform := localCheck{Name: "terraform"}
+	if config.AutoImport {
+		runner := lib.NewTerraformRunner(&lib.Config{TerraformPath: config.TerraformPath})
+		if path, err := runner.Resolve(); err != nil {
+			terraform.Status = "missing"
+			terraform.Err = err
+		} else {
+			terraform.Status = "ok (" + path + ")"
+		}
+	} else {
+		terraform.Status = "not needed, auto import is off"
+	}
+	checks = append(checks, terraform)
+
+	output := localCheck{Name: "output directory"}
+	if err := checkWritable(config.OutputDir); err != nil {
+		output.Status = "not writable"
+		output.Err = err
+	} else {
+		output.Status = "ok (" + config.OutputDir + ")"
+	}
+	if runtime.GOOS == "windows" {
+		if path, err := filepath.Abs(config.OutputDir); err == nil && len(path)+1+len(providerPluginPath) >= windowsMaxPath {
+			output.Warning = fmt.Sprintf("paths below %s exceed %d characters; enable long paths (LongPathsEnabled) or use a shorter output directory", path, windowsMaxPath)
+		}
+	}
+	checks = append(checks, output)
+
+	return checks
+}
+
+// checkWritable checks that a file can be created in a directory or, if it
+// does not exist yet, in its closest existing parent, without creating it
+func checkWritable(dir string) error {
+	for {
+		info, err := os.Stat(dir)
+		if errors.Is(err, fs.ErrNotExist) && filepath.Dir(dir) != dir {
+			dir = filepath.Dir(dir)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+		break
+	}
+
+	file, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// checkEndpoint requests one list endpoint and counts its items
+func checkEndpoint(ctx context.Context, service *NetBirdService, endpoint string) endpointCheck {
+	check := endpointCheck{Endpoint: endpoint}
+
+	var items []json.RawMessage
+	startedAt := time.Now()
+	err := service.Get(ctx, endpoint, &items)
+	check.Latency = time.Since(startedAt)
+
+	var status *statusError
+	switch {
+	case err == nil:
+		check.Status = "ok"
+		check.Items = len(items)
+	case errors.As(err, &status):
+		check.Status = fmt.Sprintf("HTTP %d", status.StatusCode)
+		check.Err = err
+	default:
+		check.Status = "error (" + errorCategory(err.Error()) + ")"
+		check.Err = err
+	}
+
+	return check
+}
+
+// importerEndpoints returns the API endpoints fetched for the resource types
+// that are not excluded, in resource type order and without duplicates
+func importerEndpoints(excludedTypes []string) []string {
+	endpoints := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, resourceType := range resourceTypes {
+		if containsString(excludedTypes, resourceType) {
+			continue
+		}
+		registration, _ := resources.Lookup(resourceType)
+		for _, endpoint := range registration.Endpoints {
+			if !seen[endpoint] {
+				seen[endpoint] = true
+				endpoints = append(endpoints, endpoint)
+			}
+		}
+	}
+	return endpoints
+}
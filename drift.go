@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"netbird-terraformer/lib"
+)
+
+// resourceBlock is one resource or data source block of a Terraform
+// configuration, with the lines compared by drift
+type resourceBlock struct {
+	Address string // e.g. netbird_group.developers, or data.netbird_peer.build_01
+	Lines   []string
+}
+
+// driftChange is a resource that differs between the configuration in the
+// output directory and the account
+type driftChange struct {
+	Address string
+	Removed []string // lines only in the configuration
+	Added   []string // lines only in the account
+}
+
+// driftReport lists the differences found by drift
+type driftReport struct {
+	Added   []string // in the account, not in the configuration
+	Changed []driftChange
+	Removed []string // in the configuration, gone from the account
+}
+
+// Count returns the number of drifted resources
+func (r driftReport) Count() int {
+	return len(r.Added) + len(r.Changed) + len(r.Removed)
+}
+
+// runDrift fetches the account, generates its configuration into a temporary
+// directory with the settings of a generate run, and compares it with the
+// configuration in the output directory. Nothing in the output directory is
+// changed and nothing is imported. It returns the number of drifted resources.
+func runDrift(ctx context.Context, config *Config) (int, error) {
+	existing, err := readResourceBlocks(config.OutputDir)
+	if err != nil {
+		return 0, err
+	}
+	if len(existing) == 0 {
+		return 0, fmt.Errorf("no generated configuration in %s; run generate first", config.OutputDir)
+	}
+
+	tempDir, err := os.MkdirTemp("", "netbird-drift-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	generatorConfig := newGeneratorConfig(config)
+	terraformGen := lib.NewTerraformGenerator(tempDir, generatorConfig)
+	summary := NewRunSummary(newRunID(), config)
+	fetchResources(ctx, config, generatorConfig, newService(config), terraformGen, summary)
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+	if len(summary.Warnings) > 0 {
+		return 0, fmt.Errorf("not every resource type could be fetched, so the comparison would be incomplete: %s", strings.Join(summary.Warnings, "; "))
+	}
+	if err := generateTerraformFiles(terraformGen, config.SplitState); err != nil {
+		return 0, err
+	}
+
+	current, err := readResourceBlocks(tempDir)
+	if err != nil {
+		return 0, err
+	}
+
+	report := compareResourceBlocks(existing, current)
+	printDrift(config, report)
+	return report.Count(), nil
+}
+
+// readResourceBlocks reads the resource and data source blocks of the .tf and
+// .tf.json files in a directory and, for split state, its module directories.
+// Blocks are keyed by module directory and address.
+func readResourceBlocks(dir string) (map[string]resourceBlock, error) {
+	paths := make([]string, 0)
+	for _, pattern := range []string{"*.tf", "*/*.tf", "*.tf.json", "*/*.tf.json"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+
+	blocks := make(map[string]resourceBlock)
+	for _, path := range paths {
+		module, err := filepath.Rel(dir, filepath.Dir(path))
+		if err != nil {
+			return nil, err
+		}
+
+		var fileBlocks []resourceBlock
+		if strings.HasSuffix(path, ".tf.json") {
+			fileBlocks, err = readJSONBlocks(path)
+		} else {
+			fileBlocks, err = readHCLBlocks(path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		for _, block := range fileBlocks {
+			key := block.Address
+			if module != "." {
+				key = module + "/" + key
+			}
+			blocks[key] = block
+		}
+	}
+	return blocks, nil
+}
+
+// readHCLBlocks reads the netbird blocks of a .tf file as written by the HCL
+// writer: a block starts with its header at the start of a line and ends with
+// a closing brace at the start of a line. Comments and blank lines are ignored.
+func readHCLBlocks(path string) ([]resourceBlock, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	blocks := make([]resourceBlock, 0)
+	var current *resourceBlock
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case current == nil:
+			if address, ok := blockAddress(line); ok {
+				current = &resourceBlock{Address: address}
+			}
+		case line == "}":
+			blocks = append(blocks, *current)
+			current = nil
+		case trimmed != "" && !strings.HasPrefix(trimmed, "#"):
+			current.Lines = append(current.Lines, trimmed)
+		}
+	}
+	return blocks, scanner.Err()
+}
+
+// blockAddress returns the address of a resource or data source block header
+// such as `resource "netbird_group" "developers" {`
+func blockAddress(line string) (string, bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 4 || fields[3] != "{" || (fields[0] != "resource" && fields[0] != "data") {
+		return "", false
+	}
+	resourceType, name := strings.Trim(fields[1], `"`), strings.Trim(fields[2], `"`)
+	if !strings.HasPrefix(resourceType, "netbird_") {
+		return "", false
+	}
+	if fields[0] == "data" {
+		return "data." + resourceType + "." + name, true
+	}
+	return resourceType + "." + name, true
+}
+
+// readJSONBlocks reads the netbird blocks of a .tf.json file. Each top-level
+// attribute of a block is compared as one line.
+func readJSONBlocks(path string) ([]resourceBlock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]map[string]map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(data, &document); err != nil {
+		// provider, backend and metadata files have a different shape
+		return nil, nil
+	}
+
+	blocks := make([]resourceBlock, 0)
+	for _, mode := range []string{"resource", "data"} {
+		for resourceType, named := range document[mode] {
+			if !strings.HasPrefix(resourceType, "netbird_") {
+				continue
+			}
+			for name, body := range named {
+				address := resourceType + "." + name
+				if mode == "data" {
+					address = "data." + address
+				}
+				block := resourceBlock{Address: address}
+				for attribute, value := range body {
+					if attribute == "//" {
+						continue
+					}
+					block.Lines = append(block.Lines, attribute+" = "+string(value))
+				}
+				sort.Strings(block.Lines)
+				blocks = append(blocks, block)
+			}
+		}
+	}
+	return blocks, nil
+}
+
+// compareResourceBlocks compares the blocks of the existing configuration with
+// the blocks generated from the account
+func compareResourceBlocks(existing, current map[string]resourceBlock) driftReport {
+	report := driftReport{}
+	for _, key := range unionKeys(existing, current) {
+		before, inExisting := existing[key]
+		after, inCurrent := current[key]
+		switch {
+		case !inExisting:
+			report.Added = append(report.Added, key)
+		case !inCurrent:
+			report.Removed = append(report.Removed, key)
+		default:
+			removed, added := lineDifferences(before.Lines, after.Lines)
+			if len(removed) > 0 || len(added) > 0 {
+				report.Changed = append(report.Changed, driftChange{Address: key, Removed: removed, Added: added})
+			}
+		}
+	}
+	return report
+}
+
+// lineDifferences returns the lines only in before and the lines only in
+// after, each in their original order
+func lineDifferences(before, after []string) ([]string, []string) {
+	count := func(lines []string) map[string]int {
+		counts := make(map[string]int, len(lines))
+		for _, line := range lines {
+			counts[line]++
+		}
+		return counts
+	}
+	only := func(lines []string, other map[string]int) []string {
+		result := make([]string, 0)
+		for _, line := range lines {
+			if other[line] > 0 {
+				other[line]--
+				continue
+			}
+			result = append(result, line)
+		}
+		return result
+	}
+	return only(before, count(after)), only(after, count(before))
+}
+
+// printDrift prints the drifted resources grouped by kind of change
+func printDrift(config *Config, report driftReport) {
+	fmt.Printf("Comparing %s with %s\n\n", config.ServerURL, config.OutputDir)
+
+	if report.Count() == 0 {
+		fmt.Println("No drift: the configuration matches the account")
+		return
+	}
+
+	if len(report.Added) > 0 {
+		fmt.Println("Added in NetBird, not in the configuration:")
+		for _, address := range report.Added {
+			fmt.Printf("  + %s\n", address)
+		}
+	}
+	if len(report.Changed) > 0 {
+		fmt.Println("Changed in NetBird:")
+		for _, change := range report.Changed {
+			fmt.Printf("  ~ %s\n", change.Address)
+			for _, line := range change.Removed {
+				fmt.Printf("      - %s\n", line)
+			}
+			for _, line := range change.Added {
+				fmt.Printf("      + %s\n", line)
+			}
+		}
+	}
+	if len(report.Removed) > 0 {
+		fmt.Println("Removed from NetBird, still in the configuration:")
+		for _, address := range report.Removed {
+			fmt.Printf("  - %s\n", address)
+		}
+	}
+	fmt.Printf("\n%d added, %d changed, %d removed\n", len(report.Added), len(report.Changed), len(report.Removed))
+}
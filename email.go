@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+
+	"netbird-terraformer/lib"
+)
+
+// SMTPConfig holds the SMTP settings used to email run reports
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// getSMTPConfig reads the SMTP settings from the environment
+func getSMTPConfig() (*SMTPConfig, error) {
+	config := &SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+
+	if config.Host == "" {
+		return nil, fmt.Errorf("SMTP_HOST is required to send email reports")
+	}
+	if config.Port == "" {
+		config.Port = "587"
+	}
+	if config.From == "" {
+		config.From = config.Username
+	}
+	if config.From == "" {
+		return nil, fmt.Errorf("SMTP_FROM (or SMTP_USERNAME) is required to send email reports")
+	}
+
+	return config, nil
+}
+
+// sendEmailReport emails the run summary to the given recipients. STARTTLS is used
+// whenever the server offers it.
+func sendEmailReport(recipients []string, scrub lib.ScrubConfig, summary *RunSummary) error {
+	config, err := getSMTPConfig()
+	if err != nil {
+		return err
+	}
+
+	status := "OK"
+	if !summary.Succeeded() {
+		status = "WARNINGS"
+	}
+
+	var message strings.Builder
+	fmt.Fprintf(&message, "From: %s\r\n", config.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&message, "Subject: [netbird-terraformer] %s run %s\r\n", status, summary.RunID)
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	message.WriteString("\r\n")
+	body, _ := lib.ScrubText(summary.Text(), scrub)
+	message.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if config.Username != "" {
+		auth = smtp.PlainAuth("", config.Username, config.Password, config.Host)
+	}
+
+	address := net.JoinHostPort(config.Host, config.Port)
+	return smtp.SendMail(address, auth, config.From, recipients, []byte(message.String()))
+}
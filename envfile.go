@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// defaultEnvFile is loaded from the working directory when present
+const defaultEnvFile = ".env"
+
+// envFile is what loadEnvFile did, logged once the logger is set up
+type envFile struct {
+	Path    string
+	Loaded  []string // variables set from the file
+	Ignored []string // variables already set in the environment, which win
+}
+
+// envNamePattern matches the variable names accepted in an env file
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// applyEnvFile loads the env file given with --env-file, or .env, and removes
+// the flag from os.Args. Variables already in the environment win.
+func applyEnvFile() *envFile {
+	path, arguments, err := extractEnvFile(os.Args[1:])
+	if err != nil {
+		fatal("Invalid arguments", err)
+	}
+	os.Args = append(os.Args[:1], arguments...)
+
+	loaded, err := loadEnvFile(path)
+	if err != nil {
+		fatal("Failed to load the env file", err)
+	}
+	return loaded
+}
+
+// extractEnvFile removes --env-file <path> or --env-file=<path> from the
+// arguments, since it applies before any command parses its flags. It returns
+// the path, or "" when the flag is not given.
+func extractEnvFile(args []string) (string, []string, error) {
+	path := ""
+	remaining := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !strings.HasPrefix(args[i], "-") || name != "env-file" {
+			remaining = append(remaining, args[i])
+			continue
+		}
+		if !hasValue {
+			if i+1 == len(args) {
+				return "", nil, fmt.Errorf("flag needs an argument: --env-file")
+			}
+			i++
+			value = args[i]
+		}
+		if value == "" {
+			return "", nil, fmt.Errorf("--env-file needs a path")
+		}
+		path = value
+	}
+	return path, remaining, nil
+}
+
+// loadEnvFile sets the variables of an env file that are not set in the
+// environment already. Without a path, .env in the working directory is loaded
+// if it exists; a given path must exist. It returns nil if nothing was read.
+func loadEnvFile(path string) (*envFile, error) {
+	explicit := path != ""
+	if !explicit {
+		path = defaultEnvFile
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+
+	variables, err := parseEnvFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid env file %s: %w", path, err)
+	}
+
+	result := &envFile{Path: path}
+	for _, variable := range variables {
+		if _, set := os.LookupEnv(variable[0]); set {
+			result.Ignored = append(result.Ignored, variable[0])
+			continue
+		}
+		if err := os.Setenv(variable[0], variable[1]); err != nil {
+			return nil, fmt.Errorf("failed to set %s from %s: %w", variable[0], path, err)
+		}
+		result.Loaded = append(result.Loaded, variable[0])
+	}
+	return result, nil
+}
+
+// parseEnvFile parses the lines of an env file into name and value pairs, in
+// order. A line is NAME=value, optionally prefixed with export. Values may be
+// single-quoted, taken literally, or double-quoted, where \n, \", \\ and \$
+// are unescaped; unquoted values end at a # preceded by a space. Blank lines
+// and lines starting with # are skipped. Variables are not expanded.
+func parseEnvFile(data []byte) ([][2]string, error) {
+	variables := make([][2]string, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+		name, value, found := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !found || !envNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("line %d: expected NAME=value", number)
+		}
+
+		value, err := envValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number, err)
+		}
+		variables = append(variables, [2]string{name, value})
+	}
+	return variables, scanner.Err()
+}
+
+// envValue unquotes the value of an env file line
+func envValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "'"):
+		end := strings.Index(value[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated single quote")
+		}
+		return value[1 : end+1], nil
+	case strings.HasPrefix(value, `"`):
+		var builder strings.Builder
+		for i := 1; i < len(value); i++ {
+			switch {
+			case value[i] == '"':
+				return builder.String(), nil
+			case value[i] == '\\' && i+1 < len(value):
+				i++
+				switch value[i] {
+				case 'n':
+					builder.WriteByte('\n')
+				case '"', '\\', '$':
+					builder.WriteByte(value[i])
+				default:
+					builder.WriteByte('\\')
+					builder.WriteByte(value[i])
+				}
+			default:
+				builder.WriteByte(value[i])
+			}
+		}
+		return "", fmt.Errorf("unterminated double quote")
+	}
+
+	if index := strings.Index(value, " #"); index >= 0 {
+		value = value[:index]
+	}
+	return strings.TrimSpace(value), nil
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"netbird-terraformer/lib"
+	"netbird-terraformer/resources"
+)
+
+// defaultExportFile is where `export` writes the account when no path is given
+const defaultExportFile = "netbird-export.json"
+
+// accountExport is the account written by export. Objects refer to each other
+// by ID only; relationships lists every reference as an edge of the graph.
+type accountExport struct {
+	ServerURL     string                   `json:"server_url"`
+	ExportedAt    time.Time                `json:"exported_at"`
+	Groups        []exportGroup            `json:"groups"`
+	Peers         []exportPeer             `json:"peers"`
+	Users         []resources.User         `json:"users"`
+	PostureChecks []resources.PostureCheck `json:"posture_checks"`
+	Policies      []exportPolicy           `json:"policies"`
+	Routes        []resources.Route        `json:"routes"`
+	SetupKeys     []resources.SetupKey     `json:"setup_keys"`
+	Relationships []exportRelationship     `json:"relationships"`
+}
+
+// exportGroup is a group with the IDs of its member peers
+type exportGroup struct {
+	ID        string                    `json:"id"`
+	Name      string                    `json:"name"`
+	Issued    string                    `json:"issued,omitempty"`
+	Peers     []string                  `json:"peers"`
+	Resources []resources.GroupResource `json:"resources"`
+}
+
+// exportPeer is a peer with the IDs of its groups
+type exportPeer struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	IP         string   `json:"ip"`
+	DNSLabel   string   `json:"dns_label"`
+	Hostname   string   `json:"hostname"`
+	OS         string   `json:"os"`
+	SSHEnabled bool     `json:"ssh_enabled"`
+	Connected  bool     `json:"connected"`
+	Groups     []string `json:"groups"`
+}
+
+// exportPolicy is a policy whose rules refer to groups by ID
+type exportPolicy struct {
+	ID                  string       `json:"id"`
+	Name                string       `json:"name"`
+	Description         string       `json:"description"`
+	Enabled             bool         `json:"enabled"`
+	SourcePostureChecks []string     `json:"source_posture_checks"`
+	Rules               []exportRule `json:"rules"`
+}
+
+// exportRule is a policy rule whose sources and destinations are group IDs
+type exportRule struct {
+	Name                string                `json:"name"`
+	Description         string                `json:"description,omitempty"`
+	Enabled             bool                  `json:"enabled"`
+	Action              string                `json:"action"`
+	Bidirectional       bool                  `json:"bidirectional"`
+	Protocol            string                `json:"protocol"`
+	Ports               []string              `json:"ports"`
+	PortRanges          []resources.PortRange `json:"port_ranges"`
+	Sources             []string              `json:"sources"`
+	SourceResource      *resources.Resource   `json:"source_resource,omitempty"`
+	Destinations        []string              `json:"destinations"`
+	DestinationResource *resources.Resource   `json:"destination_resource,omitempty"`
+}
+
+// exportRelationship is a reference from one object to another, both written
+// as <type>/<id>
+type exportRelationship struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+}
+
+// runExport fetches the account and writes it, independent of Terraform, as
+// JSON, or as YAML when the path ends in .yaml or .yml. A path of - writes
+// JSON to stdout. Excluded resource types are left out.
+func runExport(ctx context.Context, config *Config) error {
+	export, err := fetchAccountExport(ctx, config, newAPI(config))
+	if err != nil {
+		return err
+	}
+
+	var content []byte
+	switch strings.ToLower(filepath.Ext(config.OutputDir)) {
+	case ".yaml", ".yml":
+		content, err = lib.EncodeYAML(export)
+	default:
+		content, err = json.MarshalIndent(export, "", "  ")
+		content = append(content, '\n')
+	}
+	if err != nil {
+		return err
+	}
+
+	if config.OutputDir == "-" {
+		_, err = os.Stdout.Write(content)
+		return err
+	}
+	if err := os.WriteFile(config.OutputDir, content, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("\nAccount exported to %s (%d groups, %d peers, %d users, %d policies, %d routes, %d relationships)\n",
+		config.OutputDir, len(export.Groups), len(export.Peers), len(export.Users), len(export.Policies), len(export.Routes), len(export.Relationships))
+	return nil
+}
+
+// fetchAccountExport fetches every resource type that is not excluded and
+// normalizes the references between the objects to IDs
+func fetchAccountExport(ctx context.Context, config *Config, service lib.NetBirdAPI) (*accountExport, error) {
+	export := &accountExport{
+		ServerURL:     config.ServerURL,
+		ExportedAt:    time.Now().UTC(),
+		Groups:        make([]exportGroup, 0),
+		Peers:         make([]exportPeer, 0),
+		Users:         make([]resources.User, 0),
+		PostureChecks: make([]resources.PostureCheck, 0),
+		Policies:      make([]exportPolicy, 0),
+		Routes:        make([]resources.Route, 0),
+		SetupKeys:     make([]resources.SetupKey, 0),
+		Relationships: make([]exportRelationship, 0),
+	}
+	relate := func(fromType, fromID, toType, toID, kind string) {
+		if toID != "" {
+			export.Relationships = append(export.Relationships, exportRelationship{From: fromType + "/" + fromID, To: toType + "/" + toID, Kind: kind})
+		}
+	}
+	fetch := func(resourceType, endpoint string, result any) (bool, error) {
+		if containsString(config.ExcludedTypes, resourceType) {
+			return false, nil
+		}
+		slog.Info("Exporting", "type", resourceType)
+		if err := service.Get(ctx, endpoint, result); err != nil {
+			return false, fmt.Errorf("failed to fetch %s: %w", endpoint, err)
+		}
+		return true, nil
+	}
+
+	var groups []resources.Group
+	if fetched, err := fetch("group", "/api/groups", &groups); err != nil {
+		return nil, err
+	} else if fetched {
+		for _, group := range groups {
+			item := exportGroup{ID: group.ID, Name: group.Name, Issued: group.Issued, Peers: make([]string, 0), Resources: group.Resources}
+			if item.Resources == nil {
+				item.Resources = make([]resources.GroupResource, 0)
+			}
+			for _, peer := range group.Peers {
+				id, _ := peer.(string)
+				if peerMap, ok := peer.(map[string]any); ok {
+					id, _ = peerMap["id"].(string)
+				}
+				if id != "" {
+					item.Peers = append(item.Peers, id)
+					relate("group", group.ID, "peer", id, "member")
+				}
+			}
+			for _, resource := range item.Resources {
+				relate("group", group.ID, "network_resource", resource.ID, "member")
+			}
+			export.Groups = append(export.Groups, item)
+		}
+	}
+
+	var peers []resources.Peer
+	if _, err := fetch("peer", "/api/peers", &peers); err != nil {
+		return nil, err
+	}
+	for _, peer := range peers {
+		item := exportPeer{ID: peer.ID, Name: peer.Name, IP: peer.IP, DNSLabel: peer.DNSLabel, Hostname: peer.Hostname, OS: peer.OS, SSHEnabled: peer.SSHEnabled, Connected: peer.Connected, Groups: groupInfoIDs(peer.Groups)}
+		export.Peers = append(export.Peers, item)
+	}
+
+	if _, err := fetch("user", "/api/users", &export.Users); err != nil {
+		return nil, err
+	}
+	for _, user := range export.Users {
+		for _, group := range user.AutoGroups {
+			relate("user", user.ID, "group", group, "auto_group")
+		}
+	}
+
+	if _, err := fetch("posture_check", "/api/posture-checks", &export.PostureChecks); err != nil {
+		return nil, err
+	}
+
+	var policies []resources.Policy
+	if _, err := fetch("policy", "/api/policies", &policies); err != nil {
+		return nil, err
+	}
+	for _, policy := range policies {
+		item := exportPolicy{ID: policy.ID, Name: policy.Name, Description: policy.Description, Enabled: policy.Enabled, SourcePostureChecks: policy.SourcePostureChecks, Rules: make([]exportRule, 0, len(policy.Rules))}
+		if item.SourcePostureChecks == nil {
+			item.SourcePostureChecks = make([]string, 0)
+		}
+		for _, check := range item.SourcePostureChecks {
+			relate("policy", policy.ID, "posture_check", check, "posture_check")
+		}
+		for _, rule := range policy.Rules {
+			exported := exportRule{
+				Name: rule.Name, Description: rule.Description, Enabled: rule.Enabled, Action: rule.Action,
+				Bidirectional: rule.Bidirectional, Protocol: rule.Protocol, Ports: rule.Ports, PortRanges: rule.PortRanges,
+				Sources: groupInfoIDs(rule.Sources), SourceResource: rule.SourceResource,
+				Destinations: groupInfoIDs(rule.Destinations), DestinationResource: rule.DestinationResource,
+			}
+			for _, group := range exported.Sources {
+				relate("policy", policy.ID, "group", group, "source")
+			}
+			for _, group := range exported.Destinations {
+				relate("policy", policy.ID, "group", group, "destination")
+			}
+			if rule.SourceResource != nil {
+				relate("policy", policy.ID, "network_resource", rule.SourceResource.ID, "source")
+			}
+			if rule.DestinationResource != nil {
+				relate("policy", policy.ID, "network_resource", rule.DestinationResource.ID, "destination")
+			}
+			item.Rules = append(item.Rules, exported)
+		}
+		export.Policies = append(export.Policies, item)
+	}
+
+	if _, err := fetch("route", "/api/routes", &export.Routes); err != nil {
+		return nil, err
+	}
+	for _, route := range export.Routes {
+		relate("route", route.ID, "peer", route.Peer, "routing_peer")
+		for _, group := range route.PeerGroups {
+			relate("route", route.ID, "group", group, "routing_peer_group")
+		}
+		for _, group := range route.Groups {
+			relate("route", route.ID, "group", group, "distribution")
+		}
+	}
+
+	if _, err := fetch("setup_key", "/api/setup-keys", &export.SetupKeys); err != nil {
+		return nil, err
+	}
+	for _, key := range export.SetupKeys {
+		for _, group := range key.AutoGroups {
+			relate("setup_key", key.ID, "group", group, "auto_group")
+		}
+	}
+
+	return export, nil
+}
+
+// groupInfoIDs returns the IDs of group references
+func groupInfoIDs(groups []resources.GroupInfo) []string {
+	ids := make([]string, 0, len(groups))
+	for _, group := range groups {
+		ids = append(ids, group.ID)
+	}
+	return ids
+}
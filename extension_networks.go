@@ -0,0 +1,6 @@
+//go:build networks
+
+package main
+
+// Link the example networks handler; see "Adding Resource Handlers" in the README
+import _ "netbird-terraformer/extensions/networks"
@@ -0,0 +1,84 @@
+// Package networks is an example of a handler registered from outside the
+// resources package. It generates netbird_network resources for the networks
+// of an account and is linked into the importer with `go build -tags networks`.
+package networks
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"netbird-terraformer/lib"
+	"netbird-terraformer/resources"
+)
+
+func init() {
+	resources.Register(resources.Handler{
+		Name:             "network",
+		Endpoints:        []string{"/api/networks"},
+		MinServerVersion: "0.35.0",
+		New: func(deps resources.Dependencies) lib.ResourceHandler {
+			return &handler{service: deps.Service, terraformWriter: deps.Writer, idToResourceName: make(map[string]string)}
+		},
+	})
+}
+
+// Network represents a NetBird network
+type Network struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// handler implements ResourceHandler for networks
+type handler struct {
+	service          lib.NetBirdAPI
+	terraformWriter  lib.TerraformWriter
+	idToResourceName map[string]string
+}
+
+// ImportAndGenerate imports networks from NetBird and generates Terraform resources
+func (h *handler) ImportAndGenerate(ctx context.Context) error {
+	slog.Info("Importing networks")
+
+	var networks []Network
+	err := h.service.Get(ctx, "/api/networks", &networks)
+	if err != nil {
+		return fmt.Errorf("failed to fetch networks: %w", err)
+	}
+	h.terraformWriter.RecordDiscovered("network", len(networks))
+
+	progress := h.terraformWriter.StartProgress("Generating networks", len(networks))
+	for _, network := range networks {
+		progress.Increment()
+		if !h.terraformWriter.IncludeResource("network", network.ID, network.Name, "") {
+			continue
+		}
+
+		resourceName := h.terraformWriter.ResourceName(lib.NameData{Type: "network", ID: network.ID, Name: network.Name})
+		if resourceName == "" {
+			resourceName = fmt.Sprintf("network_%s", network.ID)
+		}
+		resourceName = h.terraformWriter.UniqueName("network", network.ID, resourceName)
+		h.terraformWriter.AddResource("network", resourceName, map[string]any{
+			"id":          network.ID,
+			"name":        network.Name,
+			"description": network.Description,
+		})
+		h.idToResourceName[network.ID] = resourceName
+	}
+	progress.Done()
+
+	slog.Info("Imported networks", "count", len(networks))
+	return nil
+}
+
+// GetResourceMapping returns the mapping from network IDs to resource names
+func (h *handler) GetResourceMapping() map[string]string {
+	return h.idToResourceName
+}
+
+// GetResourceType returns the resource type
+func (h *handler) GetResourceType() string {
+	return "network"
+}
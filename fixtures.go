@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// fixturesManifestFile describes a fixtures directory written by --record
+const fixturesManifestFile = "manifest.json"
+
+// FixturesManifest describes recorded API traffic. The API token is never
+// recorded.
+type FixturesManifest struct {
+	ImporterVersion string    `json:"importer_version"`
+	ServerURL       string    `json:"server_url"`
+	RecordedAt      time.Time `json:"recorded_at"`
+	Endpoints       []string  `json:"endpoints"`
+}
+
+// Fixture is one recorded API response. Failed requests are recorded too, so
+// a replay fails the same way the recorded run did.
+type Fixture struct {
+	Endpoint   string          `json:"endpoint"`
+	StatusCode int             `json:"status_code"` // 0 if no response was received
+	Body       json.RawMessage `json:"body,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// RecordingAPI records every API response of a run to a fixtures directory.
+// It implements lib.NetBirdAPI.
+type RecordingAPI struct {
+	service *NetBirdService
+	dir     string
+
+	mu       sync.Mutex
+	manifest FixturesManifest
+}
+
+// NewRecordingAPI wraps the API client to record into dir
+func NewRecordingAPI(service *NetBirdService, config *Config) *RecordingAPI {
+	return &RecordingAPI{
+		service: service,
+		dir:     config.RecordDir,
+		manifest: FixturesManifest{
+			ImporterVersion: version,
+			ServerURL:       config.ServerURL,
+			RecordedAt:      time.Now().UTC(),
+			Endpoints:       make([]string, 0),
+		},
+	}
+}
+
+// Get fetches and records an API response
+func (r *RecordingAPI) Get(ctx context.Context, endpoint string, result interface{}) error {
+	body, err := r.service.GetRaw(ctx, endpoint)
+
+	// An interrupted run says nothing about the server
+	if ctx.Err() == nil {
+		if recordErr := r.record(endpoint, body, err); recordErr != nil {
+			slog.Warn("Failed to record API response", "endpoint", endpoint, "error", recordErr)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+	return r.service.decodeResponse(endpoint, body, result)
+}
+
+// record writes the fixture of an endpoint and adds it to the manifest
+func (r *RecordingAPI) record(endpoint string, body []byte, requestErr error) error {
+	fixture := Fixture{Endpoint: endpoint, StatusCode: 200}
+	var apiErr *statusError
+	switch {
+	case errors.As(requestErr, &apiErr):
+		fixture.StatusCode, fixture.Error = apiErr.StatusCode, apiErr.Body
+	case requestErr != nil:
+		fixture.StatusCode, fixture.Error = 0, requestErr.Error()
+	case json.Valid(body):
+		fixture.Body = body
+	default:
+		fixture.Error = "invalid JSON response: " + string(body)
+	}
+
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := writeFileAtomic(filepath.Join(r.dir, bundleMemberName(endpoint)), data); err != nil {
+		return err
+	}
+	if !containsString(r.manifest.Endpoints, endpoint) {
+		r.manifest.Endpoints = append(r.manifest.Endpoints, endpoint)
+		sort.Strings(r.manifest.Endpoints)
+	}
+
+	manifest, err := json.MarshalIndent(r.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(r.dir, fixturesManifestFile), manifest)
+}
+
+// ReplayAPI serves the API responses of a fixtures directory written by
+// --record, implementing lib.NetBirdAPI without network access
+type ReplayAPI struct {
+	Manifest FixturesManifest
+	dir      string
+}
+
+// OpenFixtures reads the manifest of a fixtures directory
+func OpenFixtures(dir string) (*ReplayAPI, error) {
+	data, err := os.ReadFile(filepath.Join(dir, fixturesManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open fixtures: %w", err)
+	}
+
+	replay := &ReplayAPI{dir: dir}
+	if err := json.Unmarshal(data, &replay.Manifest); err != nil {
+		return nil, fmt.Errorf("invalid fixtures manifest: %w", err)
+	}
+	return replay, nil
+}
+
+// Get decodes a recorded API response, or returns the recorded error
+func (r *ReplayAPI) Get(ctx context.Context, endpoint string, result interface{}) error {
+	data, err := os.ReadFile(filepath.Join(r.dir, bundleMemberName(endpoint)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s was not recorded in %s", endpoint, r.dir)
+	}
+	if err != nil {
+		return err
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return fmt.Errorf("invalid fixture for %s: %w", endpoint, err)
+	}
+
+	switch {
+	case fixture.StatusCode >= 400:
+		return &statusError{StatusCode: fixture.StatusCode, Body: fixture.Error}
+	case fixture.Error != "":
+		return errors.New(fixture.Error)
+	}
+
+	// The fixtures do not say which server version they were recorded from
+	_, err = decodeTolerant(adaptResponse(endpoint, "", fixture.Body), result)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", endpoint, err)
+	}
+	return nil
+}
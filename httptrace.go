@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+)
+
+// traceBodyExcerpt bounds how much of a response body --trace-http logs
+const traceBodyExcerpt = 512
+
+// requestTrace records how one API request went for --trace-http: the time
+// of each step of the connection, whether it was reused and the TLS session.
+// The httptrace hooks may run on other goroutines, hence the lock.
+type requestTrace struct {
+	mu         sync.Mutex
+	start      time.Time
+	dns        time.Duration
+	connect    time.Duration
+	handshake  time.Duration
+	firstByte  time.Duration
+	reused     bool
+	remoteAddr string
+	tlsState   *tls.ConnectionState
+	dnsErr     error
+	connectErr error
+	tlsErr     error
+
+	dnsStart, connectStart, tlsStart time.Time
+}
+
+// newRequestTrace starts tracing a request now
+func newRequestTrace() (*requestTrace, *httptrace.ClientTrace) {
+	t := &requestTrace{start: time.Now()}
+	return t, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.set(func() { t.dnsStart = time.Now() }) },
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			t.set(func() { t.dns, t.dnsErr = time.Since(t.dnsStart), info.Err })
+		},
+		ConnectStart: func(string, string) { t.set(func() { t.connectStart = time.Now() }) },
+		ConnectDone: func(_, _ string, err error) {
+			t.set(func() { t.connect, t.connectErr = time.Since(t.connectStart), err })
+		},
+		TLSHandshakeStart: func() { t.set(func() { t.tlsStart = time.Now() }) },
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			t.set(func() { t.handshake, t.tlsState, t.tlsErr = time.Since(t.tlsStart), &state, err })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.set(func() {
+				t.reused = info.Reused
+				if info.Conn != nil {
+					t.remoteAddr = info.Conn.RemoteAddr().String()
+				}
+			})
+		},
+		GotFirstResponseByte: func() { t.set(func() { t.firstByte = time.Since(t.start) }) },
+	}
+}
+
+func (t *requestTrace) set(update func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	update()
+}
+
+// log writes the trace of a finished request, with the redacted headers of
+// both sides. The redacted body is excerpted only for errors and responses
+// that are not JSON, which are what a proxy answers instead of the API, so
+// the account's data stays out of the log. Responses typical of a
+// misconfigured reverse proxy get a hint.
+func (t *requestTrace) log(s *NetBirdService, req *http.Request, resp *http.Response, body []byte, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	attrs := []any{"component", "http", "method", req.Method, "url", req.URL.Redacted(),
+		"reused_conn", t.reused, "remote_addr", t.remoteAddr,
+		"dns", t.dns.Round(time.Microsecond), "connect", t.connect.Round(time.Microsecond),
+		"tls_handshake", t.handshake.Round(time.Microsecond), "ttfb", t.firstByte.Round(time.Microsecond),
+		"total", time.Since(t.start).Round(time.Microsecond),
+		"request_headers", redactHeaders(req.Header)}
+	for _, step := range []struct {
+		name string
+		err  error
+	}{{"dns_error", t.dnsErr}, {"connect_error", t.connectErr}, {"tls_error", t.tlsErr}} {
+		if step.err != nil {
+			attrs = append(attrs, step.name, step.err.Error())
+		}
+	}
+	if t.tlsState != nil && t.tlsErr == nil {
+		attrs = append(attrs, "tls_version", tls.VersionName(t.tlsState.Version), "alpn", t.tlsState.NegotiatedProtocol)
+		if len(t.tlsState.PeerCertificates) > 0 {
+			certificate := t.tlsState.PeerCertificates[0]
+			attrs = append(attrs, "certificate", certificate.Subject.String(), "issuer", certificate.Issuer.String(),
+				"certificate_expires", certificate.NotAfter.UTC().Format(time.RFC3339))
+		}
+	}
+	if err != nil {
+		attrs = append(attrs, "error", err.Error())
+	}
+	if resp != nil {
+		attrs = append(attrs, "status", resp.StatusCode, "proto", resp.Proto, "gzip", resp.Uncompressed,
+			"response_headers", redactHeaders(resp.Header), "body_bytes", len(body))
+		mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if resp.StatusCode >= 300 || mediaType != "application/json" {
+			excerpt := s.redactBody(body)
+			if len(excerpt) > traceBodyExcerpt {
+				excerpt = strings.ToValidUTF8(excerpt[:traceBodyExcerpt], "") + "..."
+			}
+			attrs = append(attrs, "body", excerpt)
+		}
+		if hint := proxyHint(resp); hint != "" {
+			attrs = append(attrs, "hint", hint)
+		}
+	}
+	slog.Info("HTTP trace", attrs...)
+}
+
+// proxyHint explains responses a reverse proxy in front of the management
+// server typically answers with when it is misconfigured
+func proxyHint(resp *http.Response) string {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case resp.StatusCode == http.StatusBadGateway || resp.StatusCode == http.StatusGatewayTimeout:
+		return "the proxy could not reach the management service: check its upstream address and port"
+	case mediaType == "text/html":
+		return "HTML instead of JSON: the proxy routed /api to the dashboard or an error page; check the management URL and the proxy's /api route"
+	case resp.StatusCode == http.StatusUnauthorized && resp.Header.Get("Www-Authenticate") != "":
+		return "the proxy asked for its own authentication: it must pass the Authorization header through to the management service"
+	case resp.StatusCode == http.StatusNotFound && mediaType != "application/json":
+		return "not found outside the API: check that the proxy does not strip or rewrite the /api prefix"
+	}
+	return ""
+}
+
+// traceRedirect logs a redirect the client follows. Tokens are only sent on
+// redirects to the same host, so a proxy redirecting elsewhere makes every
+// request fail with 401.
+func traceRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	previous := via[len(via)-1]
+	slog.Info("HTTP redirect", "component", "http", "from", previous.URL.Redacted(), "to", req.URL.Redacted(),
+		"status", req.Response.StatusCode, "same_host", previous.URL.Host == req.URL.Host)
+	return nil
+}
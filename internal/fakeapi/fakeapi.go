@@ -0,0 +1,146 @@
+// Package fakeapi is an in-memory NetBird management API for integration
+// tests. It serves the list endpoints the importer reads from a seed, checks
+// the token like the real server and records every request. Responses carry
+// an ETag and conditional requests for an unchanged one are answered with 304.
+package fakeapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"netbird-terraformer/resources"
+)
+
+// DefaultToken is the token the server accepts unless Token is changed
+const DefaultToken = "nbp_fakeapitesttoken0000000000000000000"
+
+// Seed is the account served by the fake server
+type Seed struct {
+	Groups        []resources.Group
+	Users         []resources.User
+	Peers         []resources.Peer
+	Policies      []resources.Policy
+	Routes        []resources.Route
+	SetupKeys     []resources.SetupKey
+	PostureChecks []resources.PostureCheck
+}
+
+// Server is a running fake management server. URL is the management URL to
+// pass to the importer.
+type Server struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	token       string
+	responses   map[string]any
+	failures    map[string]int
+	requests    []string
+	notModified int
+}
+
+// New starts a fake server serving the seeded account. Close it when done.
+func New(seed Seed) *Server {
+	s := &Server{
+		token: DefaultToken,
+		responses: map[string]any{
+			"/api/groups":         list(seed.Groups),
+			"/api/users":          list(seed.Users),
+			"/api/peers":          list(seed.Peers),
+			"/api/policies":       list(seed.Policies),
+			"/api/routes":         list(seed.Routes),
+			"/api/setup-keys":     list(seed.SetupKeys),
+			"/api/posture-checks": list(seed.PostureChecks),
+		},
+		failures: make(map[string]int),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// SetToken changes the token the server accepts
+func (s *Server) SetToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = token
+}
+
+// Set replaces the response of an endpoint, e.g. with a list wrapped in an
+// object as some server versions return it
+func (s *Server) Set(path string, response any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[path] = response
+}
+
+// Fail makes an endpoint answer with an error status
+func (s *Server) Fail(path string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[path] = status
+}
+
+// NotModified returns how many requests were answered with 304 Not Modified
+func (s *Server) NotModified() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.notModified
+}
+
+// Requests returns the paths requested so far, in order
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.URL.Path)
+	token := s.token
+	status, failing := s.failures[r.URL.Path]
+	response, exists := s.responses[r.URL.Path]
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method != http.MethodGet:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	case r.Header.Get("Authorization") != "Token "+token:
+		writeError(w, http.StatusUnauthorized, "token invalid")
+	case failing:
+		writeError(w, status, http.StatusText(status))
+	case !exists:
+		writeError(w, http.StatusNotFound, "not found")
+	default:
+		body, _ := json.Marshal(response)
+		sum := sha256.Sum256(body)
+		etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			s.mu.Lock()
+			s.notModified++
+			s.mu.Unlock()
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write(body)
+	}
+}
+
+// writeError answers with the error body the management server uses
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"message": message, "code": status})
+}
+
+// list serves an unseeded endpoint as an empty list rather than null
+func list[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
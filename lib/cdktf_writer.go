@@ -0,0 +1,604 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// CDKTF languages, selected with --format cdktf-<language>
+const (
+	cdktfTypeScript = "ts"
+	cdktfGo         = "go"
+)
+
+// cdktfStack is the construct ID of the generated stack
+const cdktfStack = "netbird"
+
+// cdktfGoModule is the Go module the generated bindings are imported from; the
+// README has the matching go mod init
+const cdktfGoModule = "netbird"
+
+// CDKTFWriter writes the resources as a CDK for Terraform application, one
+// construct per resource, for teams defining infrastructure in code instead of
+// HCL. The bindings of the provider are generated by `cdktf get` from the
+// cdktf.json the writer creates. Managed resources are adopted with importFrom
+// on the next `cdktf apply` instead of terraform import.
+type CDKTFWriter struct {
+	language  string
+	config    *Config
+	resources map[string][]TerraformResource // per resource type
+}
+
+// Format returns the format name
+func (w *CDKTFWriter) Format() string {
+	return "cdktf-" + w.language
+}
+
+// IsCDKTFFormat reports whether an output format writes a CDKTF application
+// rather than Terraform configuration files
+func IsCDKTFFormat(format string) bool {
+	return strings.HasPrefix(format, "cdktf-")
+}
+
+// WriteProvider writes cdktf.json and an application without resources
+func (w *CDKTFWriter) WriteProvider(outputDir string, config *Config) error {
+	w.config = config
+
+	project := map[string]any{
+		"language":           "typescript",
+		"app":                "npx ts-node main.ts",
+		"codeMakerOutput":    ".gen",
+		"terraformProviders": []string{"netbirdio/netbird@" + config.ProviderVersion},
+		"context":            map[string]any{},
+	}
+	if w.language == cdktfGo {
+		project["language"] = "go"
+		project["app"] = "go run main.go"
+		project["codeMakerOutput"] = "generated"
+	}
+	if err := writeJSONFile(filepath.Join(outputDir, "cdktf.json"), project); err != nil {
+		return err
+	}
+
+	return w.writeApp(outputDir)
+}
+
+// WriteResources adds the resources of one type to the application and
+// rewrites it, since every construct lives in the same stack
+func (w *CDKTFWriter) WriteResources(outputDir, resourceType string, resources []TerraformResource) error {
+	if w.resources == nil {
+		w.resources = make(map[string][]TerraformResource)
+	}
+	w.resources[resourceType] = resources
+	return w.writeApp(outputDir)
+}
+
+// WriteBackend is not supported; a CDKTF stack configures its backend in code
+func (w *CDKTFWriter) WriteBackend(outputDir string, backend *BackendConfig, module string) error {
+	return fmt.Errorf("%s output does not support state backends", w.Format())
+}
+
+// WriteRunMetadata does nothing; the application is never applied by the importer
+func (w *CDKTFWriter) WriteRunMetadata(outputDir string, metadata RunMetadata) error {
+	return nil
+}
+
+// WriteImportBlocks is not supported; the constructs import themselves
+func (w *CDKTFWriter) WriteImportBlocks(outputDir string, importCommands []ImportCommand) error {
+	return fmt.Errorf("%s output imports with importFrom, not import blocks", w.Format())
+}
+
+// WriteRemovedBlocks is not supported
+func (w *CDKTFWriter) WriteRemovedBlocks(outputDir string, addresses []string) error {
+	return fmt.Errorf("%s output does not support removed blocks", w.Format())
+}
+
+// cdktfConstruct is a resource with the names it gets in the application
+type cdktfConstruct struct {
+	resource TerraformResource
+	variable string // variable holding the construct
+	class    string // construct class, e.g. Group or DataNetbirdGroup
+	module   string // TypeScript module or Go package of the class
+}
+
+// writeApp writes main.ts or main.go with a construct per resource. Data
+// sources come first and managed resources follow in import order, so every
+// reference points at a construct declared above it.
+func (w *CDKTFWriter) writeApp(outputDir string) error {
+	types := make([]string, 0, len(w.resources))
+	for resourceType := range w.resources {
+		types = append(types, resourceType)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return importRank(types[i]) < importRank(types[j]) || importRank(types[i]) == importRank(types[j]) && types[i] < types[j]
+	})
+
+	constructs := make([]*cdktfConstruct, 0)
+	for _, isData := range []bool{true, false} {
+		for _, resourceType := range types {
+			for _, resource := range w.resources[resourceType] {
+				if resource.IsData == isData {
+					constructs = append(constructs, w.newConstruct(resource))
+				}
+			}
+		}
+	}
+
+	// Variables are unique even where names differ only in punctuation
+	variables := make(map[string]*cdktfConstruct, len(constructs))
+	taken := make(map[string]bool, len(constructs))
+	for _, construct := range constructs {
+		variable := construct.variable
+		for i := 2; taken[variable]; i++ {
+			variable = fmt.Sprintf("%s%d", construct.variable, i)
+		}
+		construct.variable = variable
+		taken[variable] = true
+		variables[construct.address()] = construct
+	}
+
+	app := &cdktfApp{writer: w, variables: variables, referenced: make(map[string]bool)}
+	filename := "main.ts"
+	content := app.typeScript(constructs)
+	if w.language == cdktfGo {
+		filename = "main.go"
+		content = app.golang(constructs)
+	}
+	return os.WriteFile(filepath.Join(outputDir, filename), []byte(content), 0644)
+}
+
+// importRank returns the position of a resource type in DefaultImportOrder,
+// with unlisted types last
+func importRank(resourceType string) int {
+	for i, listed := range DefaultImportOrder {
+		if listed == resourceType {
+			return i
+		}
+	}
+	return len(DefaultImportOrder)
+}
+
+// newConstruct names the construct of a resource
+func (w *CDKTFWriter) newConstruct(resource TerraformResource) *cdktfConstruct {
+	construct := &cdktfConstruct{resource: resource}
+	prefix := ""
+	if resource.IsData {
+		prefix = "data_netbird_"
+	}
+	construct.class = pascalCase(prefix + resource.Type)
+	construct.variable = camelCase(prefix + resource.Type + "_" + resource.Name)
+	if w.language == cdktfGo {
+		construct.module = strings.ReplaceAll(prefix+resource.Type, "_", "")
+	} else {
+		construct.module = strings.ReplaceAll(prefix+resource.Type, "_", "-")
+	}
+	return construct
+}
+
+// address returns the Terraform address of the construct's resource
+func (c *cdktfConstruct) address() string {
+	address := fmt.Sprintf("netbird_%s.%s", c.resource.Type, c.resource.Name)
+	if c.resource.IsData {
+		return "data." + address
+	}
+	return address
+}
+
+// cdktfApp renders the application source of one language
+type cdktfApp struct {
+	writer     *CDKTFWriter
+	variables  map[string]*cdktfConstruct // per Terraform address
+	referenced map[string]bool            // variables other constructs refer to
+}
+
+// reference returns the construct a Terraform reference points at and the
+// referenced attribute
+func (a *cdktfApp) reference(value Expression) (*cdktfConstruct, string, bool) {
+	index := strings.LastIndex(string(value), ".")
+	if index < 0 {
+		return nil, "", false
+	}
+	construct, exists := a.variables[string(value[:index])]
+	return construct, string(value[index+1:]), exists
+}
+
+// typeScript renders main.ts
+func (a *cdktfApp) typeScript(constructs []*cdktfConstruct) string {
+	var body strings.Builder
+	for _, construct := range constructs {
+		resource := construct.resource
+		body.WriteString("\n")
+		for _, line := range commentLines(resource.Comments) {
+			fmt.Fprintf(&body, "    // %s\n", line)
+		}
+		fmt.Fprintf(&body, "    const %s = new %s(this, %s, %s);\n", construct.variable, construct.class, jsonString(resource.Name), a.tsObject(a.configAttributes(resource), "    ", resource.Lifecycle))
+		if !resource.IsData && resource.ID != "" {
+			fmt.Fprintf(&body, "    %s.importFrom(%s);\n", construct.variable, jsonString(resource.ID))
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString("// NetBird CDK for Terraform application\n// Generated by NetBird terraformer Terraformer\n\n")
+	out.WriteString("import { Construct } from \"constructs\";\n")
+	out.WriteString("import { App, TerraformStack } from \"cdktf\";\n")
+	out.WriteString("import { NetbirdProvider } from \"./.gen/providers/netbird/provider\";\n")
+	imported := make(map[string]bool)
+	for _, construct := range constructs {
+		if !imported[construct.class] {
+			imported[construct.class] = true
+			fmt.Fprintf(&out, "import { %s } from \"./.gen/providers/netbird/%s\";\n", construct.class, construct.module)
+		}
+	}
+	out.WriteString("\nclass NetbirdStack extends TerraformStack {\n")
+	out.WriteString("  constructor(scope: Construct, id: string) {\n")
+	out.WriteString("    super(scope, id);\n\n")
+	fmt.Fprintf(&out, "    new NetbirdProvider(this, \"netbird\", {\n      managementUrl: %s,\n    });\n", jsonString(a.writer.config.ServerURL))
+	out.WriteString(body.String())
+	out.WriteString("  }\n}\n\n")
+	out.WriteString("const app = new App();\n")
+	fmt.Fprintf(&out, "new NetbirdStack(app, %s);\n", jsonString(cdktfStack))
+	out.WriteString("app.synth();\n")
+	return out.String()
+}
+
+// tsObject renders attributes as a TypeScript object literal
+func (a *cdktfApp) tsObject(attributes map[string]any, indent string, lifecycle *Lifecycle) string {
+	var out strings.Builder
+	out.WriteString("{\n")
+	for _, key := range sortedKeys(attributes) {
+		name, value := a.tsAttribute(key, attributes[key], indent+"  ")
+		if value != "" {
+			fmt.Fprintf(&out, "%s  %s: %s,\n", indent, name, value)
+		}
+	}
+	if lifecycle != nil && !lifecycle.IsEmpty() {
+		fmt.Fprintf(&out, "%s  lifecycle: {\n", indent)
+		if len(lifecycle.IgnoreChanges) > 0 {
+			fmt.Fprintf(&out, "%s    ignoreChanges: %s,\n", indent, jsonString(lifecycle.IgnoreChanges))
+		}
+		if lifecycle.PreventDestroy {
+			fmt.Fprintf(&out, "%s    preventDestroy: true,\n", indent)
+		}
+		fmt.Fprintf(&out, "%s  },\n", indent)
+	}
+	out.WriteString(indent + "}")
+	return out.String()
+}
+
+// tsAttribute returns the property name and value of an attribute, or an
+// empty value for attributes that are not written
+func (a *cdktfApp) tsAttribute(key string, value any, indent string) (string, string) {
+	switch v := value.(type) {
+	case string, Expression:
+		return camelCase(key), a.tsString(v)
+	case bool, int, int64, float64:
+		return camelCase(key), fmt.Sprint(v)
+	case []string:
+		return camelCase(key), a.tsList(stringItems(v))
+	case []any:
+		blocks, strs := splitList(v)
+		if len(blocks) > 0 {
+			return camelCase(GetBlockName(key)), a.tsBlocks(blocks, indent)
+		}
+		return camelCase(key), a.tsList(strs)
+	case []map[string]any:
+		return camelCase(GetBlockName(key)), a.tsBlocks(v, indent)
+	case map[string]any:
+		return camelCase(key), a.tsObject(a.writableAttributes(v), indent, nil)
+	}
+	return key, ""
+}
+
+// tsString renders a literal, or an expression such as a reference to
+// another construct
+func (a *cdktfApp) tsString(value any) string {
+	switch v := value.(type) {
+	case Expression:
+		if construct, attribute, exists := a.reference(v); exists {
+			return construct.variable + "." + camelCase(attribute)
+		}
+		return jsonString("${" + string(v) + "}")
+	case string:
+		if v != "" {
+			return jsonString(escapeTemplate(v))
+		}
+	}
+	return ""
+}
+
+// tsList renders a list of strings and expressions, or nothing for an empty list
+func (a *cdktfApp) tsList(items []any) string {
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		if value := a.tsString(item); value != "" {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// tsBlocks renders repeated nested blocks as an array of objects
+func (a *cdktfApp) tsBlocks(blocks []map[string]any, indent string) string {
+	if len(blocks) == 0 {
+		return ""
+	}
+	items := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		items = append(items, indent+"  "+a.tsObject(a.writableAttributes(block), indent+"  ", nil))
+	}
+	return "[\n" + strings.Join(items, ",\n") + ",\n" + indent + "]"
+}
+
+// golang renders main.go. Constructs are only assigned to a variable when
+// they are imported or referenced, as Go rejects unused variables.
+func (a *cdktfApp) golang(constructs []*cdktfConstruct) string {
+	// Render first: it records the referenced variables and used packages
+	packages := map[string]bool{"provider": true}
+	bodies := make([]string, 0, len(constructs))
+	for _, construct := range constructs {
+		packages[construct.module] = true
+		bodies = append(bodies, a.goStruct(construct.module+"."+construct.class+"Config", construct.module, construct.class, a.configAttributes(construct.resource), "\t", construct.resource.Lifecycle))
+	}
+
+	var body strings.Builder
+	for i, construct := range constructs {
+		resource := construct.resource
+		imported := !resource.IsData && resource.ID != ""
+		body.WriteString("\n")
+		for _, line := range commentLines(resource.Comments) {
+			fmt.Fprintf(&body, "\t// %s\n", line)
+		}
+		call := fmt.Sprintf("%s.New%s(stack, jsii.String(%s), %s)", construct.module, construct.class, jsonString(resource.Name), bodies[i])
+		if imported || a.referenced[construct.variable] {
+			fmt.Fprintf(&body, "\t%s := %s\n", construct.variable, call)
+		} else {
+			fmt.Fprintf(&body, "\t%s\n", call)
+		}
+		if imported {
+			fmt.Fprintf(&body, "\t%s.ImportFrom(jsii.String(%s), nil)\n", construct.variable, jsonString(resource.ID))
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString("// NetBird CDK for Terraform application\n// Generated by NetBird terraformer Terraformer\n\n")
+	out.WriteString("package main\n\n")
+	out.WriteString("import (\n")
+	out.WriteString("\t\"github.com/aws/constructs-go/constructs/v10\"\n")
+	out.WriteString("\t\"github.com/aws/jsii-runtime-go\"\n")
+	out.WriteString("\t\"github.com/hashicorp/terraform-cdk-go/cdktf\"\n\n")
+	for _, name := range sortedNames(packages) {
+		fmt.Fprintf(&out, "\t\"%s/generated/netbirdio/netbird/%s\"\n", cdktfGoModule, name)
+	}
+	out.WriteString(")\n\n")
+	out.WriteString("func NewNetbirdStack(scope constructs.Construct, id string) cdktf.TerraformStack {\n")
+	out.WriteString("\tstack := cdktf.NewTerraformStack(scope, &id)\n\n")
+	fmt.Fprintf(&out, "\tprovider.NewNetbirdProvider(stack, jsii.String(\"netbird\"), &provider.NetbirdProviderConfig{\n\t\tManagementUrl: jsii.String(%s),\n\t})\n", jsonString(a.writer.config.ServerURL))
+	out.WriteString(body.String())
+	out.WriteString("\n\treturn stack\n}\n\n")
+	out.WriteString("func main() {\n")
+	out.WriteString("\tapp := cdktf.NewApp(nil)\n")
+	fmt.Fprintf(&out, "\tNewNetbirdStack(app, %s)\n", jsonString(cdktfStack))
+	out.WriteString("\tapp.Synth()\n")
+	out.WriteString("}\n")
+
+	// Align the struct fields as gofmt would
+	formatted, err := format.Source([]byte(out.String()))
+	if err != nil {
+		return out.String()
+	}
+	return string(formatted)
+}
+
+// goStruct renders attributes as a pointer to a struct literal. Nested block
+// structs are named after the construct class and the block path, as in the
+// generated bindings.
+func (a *cdktfApp) goStruct(structType, pkg, typeName string, attributes map[string]any, indent string, lifecycle *Lifecycle) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "&%s{\n", structType)
+	out.WriteString(a.goFields(pkg, typeName, attributes, indent))
+	if lifecycle != nil && !lifecycle.IsEmpty() {
+		fmt.Fprintf(&out, "%s\tLifecycle: &cdktf.TerraformResourceLifecycle{\n", indent)
+		if len(lifecycle.IgnoreChanges) > 0 {
+			fmt.Fprintf(&out, "%s\t\tIgnoreChanges: %s,\n", indent, goStringList(lifecycle.IgnoreChanges))
+		}
+		if lifecycle.PreventDestroy {
+			fmt.Fprintf(&out, "%s\t\tPreventDestroy: jsii.Bool(true),\n", indent)
+		}
+		fmt.Fprintf(&out, "%s\t},\n", indent)
+	}
+	out.WriteString(indent + "}")
+	return out.String()
+}
+
+// goFields renders the struct fields of attributes
+func (a *cdktfApp) goFields(pkg, typeName string, attributes map[string]any, indent string) string {
+	var out strings.Builder
+	for _, key := range sortedKeys(attributes) {
+		field, value := a.goAttribute(pkg, typeName, key, attributes[key], indent+"\t")
+		if value != "" {
+			fmt.Fprintf(&out, "%s\t%s: %s,\n", indent, field, value)
+		}
+	}
+	return out.String()
+}
+
+// goAttribute returns the struct field and value of an attribute, or an empty
+// value for attributes that are not written
+func (a *cdktfApp) goAttribute(pkg, typeName, key string, value any, indent string) (string, string) {
+	switch v := value.(type) {
+	case string, Expression:
+		return pascalCase(key), a.goString(v)
+	case bool:
+		return pascalCase(key), fmt.Sprintf("jsii.Bool(%t)", v)
+	case int, int64, float64:
+		return pascalCase(key), fmt.Sprintf("jsii.Number(%v)", v)
+	case []string:
+		return pascalCase(key), a.goList(stringItems(v))
+	case []any:
+		blocks, strs := splitList(v)
+		if len(blocks) > 0 {
+			name := pascalCase(GetBlockName(key))
+			return name, a.goBlocks(pkg, typeName+name, blocks, indent)
+		}
+		return pascalCase(key), a.goList(strs)
+	case []map[string]any:
+		name := pascalCase(GetBlockName(key))
+		return name, a.goBlocks(pkg, typeName+name, v, indent)
+	case map[string]any:
+		name := pascalCase(key)
+		return name, a.goStruct(pkg+"."+typeName+name, pkg, typeName+name, a.writableAttributes(v), indent, nil)
+	}
+	return key, ""
+}
+
+// goString renders a literal, or an expression such as a reference to
+// another construct
+func (a *cdktfApp) goString(value any) string {
+	switch v := value.(type) {
+	case Expression:
+		if construct, attribute, exists := a.reference(v); exists {
+			a.referenced[construct.variable] = true
+			return construct.variable + "." + pascalCase(attribute) + "()"
+		}
+		return fmt.Sprintf("jsii.String(%s)", jsonString("${"+string(v)+"}"))
+	case string:
+		if v != "" {
+			return fmt.Sprintf("jsii.String(%s)", jsonString(escapeTemplate(v)))
+		}
+	}
+	return ""
+}
+
+// goList renders a list of strings and expressions, or nothing for an empty list
+func (a *cdktfApp) goList(items []any) string {
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		if value := a.goString(item); value != "" {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return "&[]*string{" + strings.Join(values, ", ") + "}"
+}
+
+// goBlocks renders repeated nested blocks as a slice of structs
+func (a *cdktfApp) goBlocks(pkg, typeName string, blocks []map[string]any, indent string) string {
+	if len(blocks) == 0 {
+		return ""
+	}
+	var out strings.Builder
+	fmt.Fprintf(&out, "&[]*%s.%s{\n", pkg, typeName)
+	for _, block := range blocks {
+		fmt.Fprintf(&out, "%s\t{\n", indent)
+		out.WriteString(a.goFields(pkg, typeName, a.writableAttributes(block), indent+"\t"))
+		fmt.Fprintf(&out, "%s\t},\n", indent)
+	}
+	out.WriteString(indent + "}")
+	return out.String()
+}
+
+// goStringList renders literal strings as a Go string pointer slice
+func goStringList(items []string) string {
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		values = append(values, fmt.Sprintf("jsii.String(%s)", jsonString(item)))
+	}
+	return "&[]*string{" + strings.Join(values, ", ") + "}"
+}
+
+// configAttributes returns the attributes of a resource's construct config.
+// Data sources keep their id, the key they are looked up by.
+func (a *cdktfApp) configAttributes(resource TerraformResource) map[string]any {
+	attributes := a.writableAttributes(resource.Attributes)
+	if id, exists := resource.Attributes["id"]; exists && resource.IsData {
+		if _, isExpression := id.(Expression); !isExpression {
+			id = fmt.Sprint(id)
+		}
+		attributes["id"] = id
+	}
+	return attributes
+}
+
+// writableAttributes returns the attributes isWritableAttribute accepts
+func (a *cdktfApp) writableAttributes(attributes map[string]any) map[string]any {
+	writable := make(map[string]any, len(attributes))
+	for key, value := range attributes {
+		if isWritableAttribute(key) {
+			writable[key] = value
+		}
+	}
+	return writable
+}
+
+// splitList separates a list attribute into nested blocks and strings or
+// expressions
+func splitList(items []any) ([]map[string]any, []any) {
+	blocks := make([]map[string]any, 0)
+	strs := make([]any, 0)
+	for _, item := range items {
+		switch v := item.(type) {
+		case map[string]any:
+			blocks = append(blocks, v)
+		case string, Expression:
+			strs = append(strs, v)
+		}
+	}
+	return blocks, strs
+}
+
+// stringItems converts a list of literal strings to list items
+func stringItems(items []string) []any {
+	converted := make([]any, 0, len(items))
+	for _, item := range items {
+		converted = append(converted, item)
+	}
+	return converted
+}
+
+// jsonString renders a value as JSON, which is a valid TypeScript and Go
+// literal for strings and string arrays
+func jsonString(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return `""`
+	}
+	return string(data)
+}
+
+// pascalCase converts a Terraform name like posture_check to PostureCheck
+func pascalCase(name string) string {
+	var out strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
+
+// camelCase converts a Terraform name like posture_check to postureCheck
+func camelCase(name string) string {
+	pascal := []rune(pascalCase(name))
+	if len(pascal) > 0 {
+		pascal[0] = unicode.ToLower(pascal[0])
+	}
+	return string(pascal)
+}
@@ -0,0 +1,72 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCDKTFWriter(t *testing.T) {
+	resources := []TerraformResource{
+		{Type: "group", Name: "all", IsData: true, Attributes: map[string]any{"id": "g1"}},
+		{Type: "group", Name: "dev-team", ID: "g2", Attributes: map[string]any{"id": "g2", "name": "Dev Team"}},
+	}
+	policy := TerraformResource{Type: "policy", Name: "dev_access", ID: "p1", Attributes: map[string]any{
+		"name": "Dev access",
+		"rules": []any{map[string]any{
+			"sources":      []any{Expression("netbird_group.dev-team.id")},
+			"destinations": []any{Expression("data.netbird_group.all.id")},
+		}},
+	}}
+
+	tests := map[string][]string{
+		"cdktf-ts": {
+			`import { DataNetbirdGroup } from "./.gen/providers/netbird/data-netbird-group";`,
+			`const groupDevTeam = new Group(this, "dev-team", {`,
+			`sources: [groupDevTeam.id],`,
+			`destinations: [dataNetbirdGroupAll.id],`,
+			`policyDevAccess.importFrom("p1");`,
+		},
+		"cdktf-go": {
+			`"netbird/generated/netbirdio/netbird/datanetbirdgroup"`,
+			`groupDevTeam := group.NewGroup(stack, jsii.String("dev-team"), &group.GroupConfig{`,
+			`Rule: &[]*policy.PolicyRule{`,
+			`Sources:      &[]*string{groupDevTeam.Id()},`,
+			`policyDevAccess.ImportFrom(jsii.String("p1"), nil)`,
+		},
+	}
+
+	for format, want := range tests {
+		outputDir := t.TempDir()
+		writer, err := NewOutputWriter(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.WriteProvider(outputDir, &Config{ServerURL: "https://api.example.com", ProviderVersion: DefaultProviderVersion}); err != nil {
+			t.Fatal(err)
+		}
+		// Policies are written first; the application still declares groups above them
+		if err := writer.WriteResources(outputDir, "policy", []TerraformResource{policy}); err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.WriteResources(outputDir, "group", resources); err != nil {
+			t.Fatal(err)
+		}
+
+		filename := "main." + strings.TrimPrefix(format, "cdktf-")
+		content, err := os.ReadFile(filepath.Join(outputDir, filename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		app := string(content)
+		for _, line := range want {
+			if !strings.Contains(app, line) {
+				t.Errorf("%s: %s should contain %q:\n%s", format, filename, line, app)
+			}
+		}
+		if strings.Index(app, "groupDevTeam") > strings.Index(app, "policyDevAccess") {
+			t.Errorf("%s: groups should be declared before the policies referring to them", format)
+		}
+	}
+}
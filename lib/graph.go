@@ -0,0 +1,148 @@
+package lib
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GraphFormats lists the supported --graph formats and the file each writes
+var GraphFormats = map[string]string{
+	"dot":     "graph.dot",
+	"mermaid": "graph.mmd",
+}
+
+// GraphEdge is a reference from one resource to another. Attribute is the
+// attribute holding the reference, e.g. sources for a policy rule.
+type GraphEdge struct {
+	From      string
+	To        string
+	Attribute string
+}
+
+// ResourceGraph returns the addresses of the generated resources and data
+// sources and the references between them, both sorted. References that were
+// not resolved, and so hold an object ID, are not edges.
+func (tg *TerraformGenerator) ResourceGraph() ([]string, []GraphEdge) {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+
+	nodes := make(map[string]bool, len(tg.resources))
+	for _, resource := range tg.resources {
+		if !tg.config.IsExcluded(resource.Type) {
+			nodes[resourceAddress(resource)] = true
+		}
+	}
+
+	edges := make(map[GraphEdge]bool)
+	for _, resource := range tg.resolveDataReferences(tg.resources) {
+		from := resourceAddress(resource)
+		if !nodes[from] {
+			continue
+		}
+		collectGraphEdges(from, "", resource.Attributes, func(edge GraphEdge) {
+			if nodes[edge.To] && edge.To != from {
+				edges[edge] = true
+			}
+		})
+	}
+
+	sortedEdges := make([]GraphEdge, 0, len(edges))
+	for edge := range edges {
+		sortedEdges = append(sortedEdges, edge)
+	}
+	sort.Slice(sortedEdges, func(i, j int) bool {
+		a, b := sortedEdges[i], sortedEdges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Attribute < b.Attribute
+	})
+	return sortedNames(nodes), sortedEdges
+}
+
+// collectGraphEdges calls add for every reference in an attribute value,
+// labelled with the innermost attribute name holding it
+func collectGraphEdges(from, attribute string, value any, add func(GraphEdge)) {
+	switch typed := value.(type) {
+	case Expression:
+		if index := strings.LastIndex(string(typed), "."); index > 0 {
+			add(GraphEdge{From: from, To: string(typed[:index]), Attribute: attribute})
+		}
+	case []any:
+		for _, item := range typed {
+			collectGraphEdges(from, attribute, item, add)
+		}
+	case []map[string]any:
+		for _, item := range typed {
+			collectGraphEdges(from, attribute, item, add)
+		}
+	case map[string]any:
+		for key, item := range typed {
+			if isWritableAttribute(key) {
+				collectGraphEdges(from, key, item, add)
+			}
+		}
+	}
+}
+
+// resourceAddress returns the Terraform address of a resource or data source
+func resourceAddress(resource TerraformResource) string {
+	address := fmt.Sprintf("netbird_%s.%s", resource.Type, resource.Name)
+	if resource.IsData {
+		return "data." + address
+	}
+	return address
+}
+
+// WriteGraph writes the resource graph to graph.dot or graph.mmd in the output
+// directory. Data sources are drawn dashed, or rounded in Mermaid, to tell
+// the objects looked up apart from the ones imported.
+func (tg *TerraformGenerator) WriteGraph(format string) error {
+	filename, exists := GraphFormats[format]
+	if !exists {
+		return fmt.Errorf("unknown graph format %q (supported: dot, mermaid)", format)
+	}
+
+	nodes, edges := tg.ResourceGraph()
+	var builder strings.Builder
+	switch format {
+	case "dot":
+		builder.WriteString("// NetBird resource graph\n// Generated by NetBird terraformer Terraformer\n")
+		builder.WriteString("digraph netbird {\n")
+		builder.WriteString("  rankdir=LR;\n")
+		builder.WriteString("  node [shape=box];\n\n")
+		for _, node := range nodes {
+			if strings.HasPrefix(node, "data.") {
+				fmt.Fprintf(&builder, "  %q [style=dashed];\n", node)
+			} else {
+				fmt.Fprintf(&builder, "  %q;\n", node)
+			}
+		}
+		builder.WriteString("\n")
+		for _, edge := range edges {
+			fmt.Fprintf(&builder, "  %q -> %q [label=%q];\n", edge.From, edge.To, edge.Attribute)
+		}
+		builder.WriteString("}\n")
+	case "mermaid":
+		ids := make(map[string]string, len(nodes))
+		builder.WriteString("%% NetBird resource graph\n%% Generated by NetBird terraformer Terraformer\n")
+		builder.WriteString("graph LR\n")
+		for i, node := range nodes {
+			ids[node] = fmt.Sprintf("n%d", i)
+			if strings.HasPrefix(node, "data.") {
+				fmt.Fprintf(&builder, "  %s([\"%s\"])\n", ids[node], node)
+			} else {
+				fmt.Fprintf(&builder, "  %s[\"%s\"]\n", ids[node], node)
+			}
+		}
+		for _, edge := range edges {
+			fmt.Fprintf(&builder, "  %s -->|%s| %s\n", ids[edge.From], edge.Attribute, ids[edge.To])
+		}
+	}
+
+	return tg.WriteFile(filename, []byte(builder.String()))
+}
@@ -0,0 +1,33 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResourceGraph(t *testing.T) {
+	generator := NewTerraformGenerator(t.TempDir(), &Config{})
+	generator.AddDataSource("group", "all", map[string]any{"id": "g1"})
+	generator.AddResource("group", "devs", map[string]any{"id": "g2", "name": "devs"})
+	generator.AddResource("policy", "ssh", map[string]any{
+		"id":   "p1",
+		"name": "ssh",
+		"rules": []any{map[string]any{
+			"sources":      []any{Expression("netbird_group.devs.id"), "g-unresolved"},
+			"destinations": []any{Expression("data.netbird_group.all.id")},
+		}},
+	})
+
+	nodes, edges := generator.ResourceGraph()
+	wantNodes := []string{"data.netbird_group.all", "netbird_group.devs", "netbird_policy.ssh"}
+	if !reflect.DeepEqual(nodes, wantNodes) {
+		t.Errorf("nodes = %v, want %v", nodes, wantNodes)
+	}
+	wantEdges := []GraphEdge{
+		{From: "netbird_policy.ssh", To: "data.netbird_group.all", Attribute: "destinations"},
+		{From: "netbird_policy.ssh", To: "netbird_group.devs", Attribute: "sources"},
+	}
+	if !reflect.DeepEqual(edges, wantEdges) {
+		t.Errorf("edges = %+v, want %+v", edges, wantEdges)
+	}
+}
@@ -0,0 +1,305 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HCLWriter writes resources as native Terraform configuration (.tf files)
+type HCLWriter struct{}
+
+// Format returns the format name
+func (w *HCLWriter) Format() string {
+	return "hcl"
+}
+
+// WriteProvider generates the provider.tf file
+func (w *HCLWriter) WriteProvider(outputDir string, config *Config) error {
+	filename := filepath.Join(outputDir, "provider.tf")
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	providerAliasLine := ""
+	if config.ProviderAlias != "" {
+		providerAliasLine = fmt.Sprintf("  alias          = \"%s\"\n", config.ProviderAlias)
+	}
+
+	// The token is never written; the provider reads NB_PAT at plan and apply time
+	providerConfig := fmt.Sprintf(`# NetBird Terraform Provider Configuration
+# Generated by NetBird Terraformer
+
+terraform {
+  required_providers {
+    netbird = {
+      source  = "netbirdio/netbird"
+      version = "%s"
+    }
+  }
+}
+
+provider "netbird" {
+%s  management_url = "%s"
+}
+`, config.ProviderVersion, providerAliasLine, config.ServerURL)
+
+	fmt.Fprint(file, providerConfig)
+	return nil
+}
+
+// WriteResources writes resources of one type to <type>.tf
+func (w *HCLWriter) WriteResources(outputDir, resourceType string, resources []TerraformResource) error {
+	filename := filepath.Join(outputDir, fmt.Sprintf("%s.tf", resourceType))
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Write file header
+	fmt.Fprintf(file, "# NetBird %s resources\n# Generated by NetBird terraformer Terraformer\n\n", resourceType)
+
+	// Write each resource
+	for _, resource := range resources {
+		err := w.WriteResource(file, resource)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(file, "\n")
+	}
+
+	return nil
+}
+
+// WriteBackend writes backend.tf configuring the state backend of a module
+func (w *HCLWriter) WriteBackend(outputDir string, backend *BackendConfig, module string) error {
+	filename := filepath.Join(outputDir, "backend.tf")
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	settings := make(map[string]any, len(backend.Settings)+1)
+	for key, value := range backend.Settings {
+		settings[key] = value
+	}
+	keyAttribute, key := backend.StateKey(module)
+	settings[keyAttribute] = key
+
+	fmt.Fprintf(file, "# NetBird %s state backend\n# Generated by NetBird terraformer Terraformer\n\n", module)
+	fmt.Fprintf(file, "terraform {\n")
+	fmt.Fprintf(file, "  backend \"%s\" {\n", backend.Type)
+	for _, name := range sortedKeys(settings) {
+		err := w.writeAttribute(file, name, settings[name], 2)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(file, "  }\n")
+	fmt.Fprintf(file, "}\n")
+
+	return nil
+}
+
+// WriteRunMetadata writes importer_metadata.tf with the run metadata output
+func (w *HCLWriter) WriteRunMetadata(outputDir string, metadata RunMetadata) error {
+	filename := filepath.Join(outputDir, "importer_metadata.tf")
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fmt.Fprintf(file, "# NetBird Terraformer run metadata\n# Generated by NetBird terraformer Terraformer\n\n")
+	fmt.Fprintf(file, "output \"%s\" {\n", runMetadataOutput)
+	fmt.Fprintf(file, "  description = \"Importer run that adopted the NetBird resources in this configuration\"\n")
+	fmt.Fprintf(file, "  value = {\n")
+	fmt.Fprintf(file, "    importer_version   = \"%s\"\n", EscapeString(metadata.Version))
+	fmt.Fprintf(file, "    run_id             = \"%s\"\n", EscapeString(metadata.RunID))
+	fmt.Fprintf(file, "    imported_at        = \"%s\"\n", EscapeString(metadata.ImportedAt))
+	fmt.Fprintf(file, "    imported_resources = %d\n", metadata.ImportedResources)
+	fmt.Fprintf(file, "  }\n")
+	fmt.Fprintf(file, "}\n")
+
+	return nil
+}
+
+// WriteImportBlocks writes imports.tf with an import block per import command
+func (w *HCLWriter) WriteImportBlocks(outputDir string, importCommands []ImportCommand) error {
+	filename := filepath.Join(outputDir, "imports.tf")
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fmt.Fprintf(file, "# NetBird import blocks\n# Generated by NetBird terraformer Terraformer\n")
+	for _, cmd := range importCommands {
+		fmt.Fprintf(file, "\nimport {\n")
+		fmt.Fprintf(file, "  to = %s\n", cmd.ResourceAddress)
+		fmt.Fprintf(file, "  id = \"%s\"\n", EscapeString(cmd.ResourceID))
+		fmt.Fprintf(file, "}\n")
+	}
+
+	return nil
+}
+
+// WriteRemovedBlocks writes removed.tf, which requires terraform 1.7 or later
+func (w *HCLWriter) WriteRemovedBlocks(outputDir string, addresses []string) error {
+	filename := filepath.Join(outputDir, "removed.tf")
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fmt.Fprintf(file, "# NetBird objects no longer in the account, forgotten without being destroyed\n# Generated by NetBird terraformer Terraformer\n")
+	for _, address := range addresses {
+		fmt.Fprintf(file, "\nremoved {\n")
+		fmt.Fprintf(file, "  from = %s\n\n", address)
+		fmt.Fprintf(file, "  lifecycle {\n")
+		fmt.Fprintf(file, "    destroy = false\n")
+		fmt.Fprintf(file, "  }\n")
+		fmt.Fprintf(file, "}\n")
+	}
+
+	return nil
+}
+
+// WriteResource writes a single resource or data source block
+func (w *HCLWriter) WriteResource(out io.Writer, resource TerraformResource) error {
+	for _, line := range commentLines(resource.Comments) {
+		fmt.Fprintf(out, "# %s\n", line)
+	}
+
+	// Write resource or data source block
+	if resource.IsData {
+		fmt.Fprintf(out, "data \"netbird_%s\" \"%s\" {\n", resource.Type, resource.Name)
+	} else {
+		fmt.Fprintf(out, "resource \"netbird_%s\" \"%s\" {\n", resource.Type, resource.Name)
+	}
+	if resource.Provider != "" {
+		fmt.Fprintf(out, "  provider = %s\n\n", resource.Provider)
+	}
+
+	// Write attributes
+	for _, key := range sortedKeys(resource.Attributes) {
+		if resource.IsData && key == "id" {
+			if id, ok := resource.Attributes[key].(Expression); ok {
+				fmt.Fprintf(out, "  id = %s\n", id)
+			} else {
+				fmt.Fprintf(out, "  id = \"%s\"\n", EscapeString(fmt.Sprint(resource.Attributes[key])))
+			}
+			continue
+		}
+
+		err := w.writeAttribute(out, key, resource.Attributes[key], 1)
+		if err != nil {
+			return err
+		}
+	}
+
+	if resource.Lifecycle != nil && !resource.IsData {
+		resource.Lifecycle.writeHCL(out)
+	}
+
+	fmt.Fprintf(out, "}\n")
+	return nil
+}
+
+// writeAttribute writes an attribute with proper formatting
+func (w *HCLWriter) writeAttribute(out io.Writer, key string, value any, indent int) error {
+	// Skip read-only fields in Terraform
+	if !isWritableAttribute(key) {
+		return nil
+	}
+
+	indentStr := strings.Repeat("  ", indent)
+
+	switch v := value.(type) {
+	case Expression:
+		fmt.Fprintf(out, "%s%s = %s\n", indentStr, key, v)
+	case string:
+		if v != "" {
+			fmt.Fprintf(out, "%s%s = \"%s\"\n", indentStr, key, EscapeString(v))
+		}
+	case bool:
+		fmt.Fprintf(out, "%s%s = %t\n", indentStr, key, v)
+	case int, int64, float64:
+		fmt.Fprintf(out, "%s%s = %v\n", indentStr, key, v)
+	case []any:
+		if len(v) > 0 {
+			// Check if this is a list of maps (like rules)
+			if _, isMap := v[0].(map[string]any); isMap {
+				// Handle as blocks (e.g., rules blocks)
+				for _, item := range v {
+					if itemMap, ok := item.(map[string]any); ok {
+						err := w.writeBlock(out, GetBlockName(key), itemMap, indent)
+						if err != nil {
+							return err
+						}
+					}
+				}
+				return nil
+			}
+
+			// Handle as regular list
+			w.writeStringList(out, key, v, indent)
+		}
+	case []map[string]any:
+		for _, item := range v {
+			err := w.writeBlock(out, GetBlockName(key), item, indent)
+			if err != nil {
+				return err
+			}
+		}
+	case []string:
+		if len(v) > 0 {
+			w.writeStringList(out, key, stringItems(v), indent)
+		}
+	case map[string]any:
+		return w.writeBlock(out, key, v, indent)
+	}
+
+	return nil
+}
+
+// writeBlock writes a nested block
+func (w *HCLWriter) writeBlock(out io.Writer, name string, attributes map[string]any, indent int) error {
+	indentStr := strings.Repeat("  ", indent)
+
+	fmt.Fprintf(out, "%s%s {\n", indentStr, name)
+	for _, key := range sortedKeys(attributes) {
+		err := w.writeAttribute(out, key, attributes[key], indent+1)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(out, "%s}\n", indentStr)
+	return nil
+}
+
+// writeStringList writes a list of strings and expressions, leaving the
+// expressions unquoted
+func (w *HCLWriter) writeStringList(out io.Writer, key string, items []any, indent int) {
+	indentStr := strings.Repeat("  ", indent)
+
+	fmt.Fprintf(out, "%s%s = [\n", indentStr, key)
+	for _, item := range items {
+		switch v := item.(type) {
+		case Expression:
+			fmt.Fprintf(out, "%s  %s,\n", indentStr, v)
+		case string:
+			if v != "" {
+				fmt.Fprintf(out, "%s  \"%s\",\n", indentStr, EscapeString(v))
+			}
+		}
+	}
+	fmt.Fprintf(out, "%s]\n", indentStr)
+}
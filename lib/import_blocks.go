@@ -0,0 +1,93 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Import modes selecting how the generated resources are imported into state
+const (
+	ImportModeAuto   = "auto"   // blocks when terraform supports them, cli otherwise
+	ImportModeBlocks = "blocks" // import blocks and a single plan/apply
+	ImportModeCLI    = "cli"    // one terraform import per resource
+	ImportModeState  = "state"  // terraform.tfstate written directly, validated by a plan
+)
+
+// ImportModes lists the supported import modes
+var ImportModes = []string{ImportModeAuto, ImportModeBlocks, ImportModeCLI, ImportModeState}
+
+// importBlocksMinVersion is the first terraform version supporting import blocks
+var importBlocksMinVersion = [2]int{1, 5}
+
+// SupportsImportBlocks reports whether a terraform version ("1.6.2",
+// "v1.5.0-beta1") supports import blocks
+func SupportsImportBlocks(version string) bool {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	minor, err := strconv.Atoi(strings.SplitN(parts[1], "-", 2)[0])
+	if err != nil {
+		return false
+	}
+
+	if major != importBlocksMinVersion[0] {
+		return major > importBlocksMinVersion[0]
+	}
+	return minor >= importBlocksMinVersion[1]
+}
+
+// planDocument is the subset of `terraform show -json <plan>` the importer reads
+type planDocument struct {
+	ResourceChanges []struct {
+		Address string `json:"address"`
+		Change  struct {
+			Actions   []string        `json:"actions"`
+			Importing json.RawMessage `json:"importing"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+}
+
+// CheckImportPlan verifies that a plan only imports the expected resources. Any
+// other change, including an update of an imported resource because the generated
+// configuration differs from the live object, would modify the account on apply.
+func CheckImportPlan(planJSON []byte, importCommands []ImportCommand) error {
+	var plan planDocument
+	err := json.Unmarshal(planJSON, &plan)
+	if err != nil {
+		return fmt.Errorf("failed to decode plan: %w", err)
+	}
+
+	pending := make(map[string]bool, len(importCommands))
+	for _, cmd := range importCommands {
+		pending[cmd.ResourceAddress] = true
+	}
+
+	changed := make([]string, 0)
+	for _, resourceChange := range plan.ResourceChanges {
+		actions := resourceChange.Change.Actions
+		// Forgetting, from the removed blocks of --prune, leaves the account as is
+		if len(actions) != 1 || (actions[0] != "no-op" && actions[0] != "read" && actions[0] != "forget") {
+			changed = append(changed, fmt.Sprintf("%s (%s)", resourceChange.Address, strings.Join(actions, ", ")))
+		}
+		if len(resourceChange.Change.Importing) > 0 {
+			delete(pending, resourceChange.Address)
+		}
+	}
+
+	if len(changed) > 0 {
+		return fmt.Errorf("plan changes more than it imports: %s", strings.Join(changed, "; "))
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("plan does not import %s", strings.Join(sortedNames(pending), ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package lib
+
+import "testing"
+
+func TestSupportsImportBlocks(t *testing.T) {
+	tests := map[string]bool{
+		"1.5.0":        true,
+		"1.5.0-beta1":  true,
+		"v1.9.8":       true,
+		"2.0.0":        true,
+		"1.4.7":        false,
+		"0.15.5":       false,
+		"1":            false,
+		"not-a-number": false,
+	}
+
+	for version, want := range tests {
+		if got := SupportsImportBlocks(version); got != want {
+			t.Errorf("SupportsImportBlocks(%q) = %v, want %v", version, got, want)
+		}
+	}
+}
+
+func TestCheckImportPlan(t *testing.T) {
+	commands := []ImportCommand{
+		{ResourceType: "group", ResourceAddress: "netbird_group.all", ResourceID: "g1"},
+		{ResourceType: "policy", ResourceAddress: "netbird_policy.default", ResourceID: "p1"},
+	}
+
+	tests := []struct {
+		name  string
+		plan  string
+		valid bool
+	}{
+		{"imports only", `{"resource_changes":[
+			{"address":"netbird_group.all","change":{"actions":["no-op"],"importing":{"id":"g1"}}},
+			{"address":"netbird_policy.default","change":{"actions":["no-op"],"importing":{"id":"p1"}}}]}`, true},
+		{"import with update", `{"resource_changes":[
+			{"address":"netbird_group.all","change":{"actions":["no-op"],"importing":{"id":"g1"}}},
+			{"address":"netbird_policy.default","change":{"actions":["update"],"importing":{"id":"p1"}}}]}`, false},
+		{"imports and forgets pruned objects", `{"resource_changes":[
+			{"address":"netbird_group.all","change":{"actions":["no-op"],"importing":{"id":"g1"}}},
+			{"address":"netbird_group.deleted","change":{"actions":["forget"]}},
+			{"address":"netbird_policy.default","change":{"actions":["no-op"],"importing":{"id":"p1"}}}]}`, true},
+		{"missing import", `{"resource_changes":[
+			{"address":"netbird_group.all","change":{"actions":["no-op"],"importing":{"id":"g1"}}}]}`, false},
+		{"not json", `Error: Invalid import id`, false},
+	}
+
+	for _, test := range tests {
+		err := CheckImportPlan([]byte(test.plan), commands)
+		if test.valid && err != nil {
+			t.Errorf("%s: CheckImportPlan() = %v, want no error", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: CheckImportPlan() = nil, want an error", test.name)
+		}
+	}
+}
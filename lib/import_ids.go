@@ -0,0 +1,45 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+)
+
+// importIDFormats describes the ID `terraform import` expects for each resource
+// of the netbirdio/netbird provider. All of them take the plain NetBird object
+// ID; none uses a composite ID.
+var importIDFormats = map[string]string{
+	"group":         "group ID",
+	"policy":        "policy ID",
+	"posture_check": "posture check ID",
+	"route":         "route ID",
+	"setup_key":     "setup key ID",
+	"user":          "user ID (not the email address)",
+}
+
+// ImportIDFormat returns the documented import ID format of a resource type
+func ImportIDFormat(resourceType string) string {
+	if format, exists := importIDFormats[resourceType]; exists {
+		return format
+	}
+	return resourceType + " ID"
+}
+
+// ValidateImportID checks that an ID matches the format the provider expects when
+// importing a resource of the given type
+func ValidateImportID(resourceType, id string) error {
+	format := ImportIDFormat(resourceType)
+
+	switch {
+	case id == "":
+		return fmt.Errorf("empty import ID, expected the %s", format)
+	case strings.TrimSpace(id) != id || strings.ContainsAny(id, " \t\n"):
+		return fmt.Errorf("import ID %q contains whitespace, expected the %s", id, format)
+	case strings.Contains(id, "/"):
+		return fmt.Errorf("import ID %q looks composite, expected the %s", id, format)
+	case resourceType == "user" && strings.Contains(id, "@"):
+		return fmt.Errorf("import ID %q looks like an email address, expected the %s", id, format)
+	}
+
+	return nil
+}
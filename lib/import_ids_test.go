@@ -0,0 +1,52 @@
+package lib
+
+import "testing"
+
+func TestValidateImportID(t *testing.T) {
+	tests := []struct {
+		resourceType string
+		id           string
+		valid        bool
+	}{
+		{"group", "ch8i4ug6lnn4g9hqv7m0", true},
+		{"group", "", false},
+		{"group", "ch8i4ug6lnn4g9hqv7m0 ", false},
+		{"group", "account/ch8i4ug6lnn4g9hqv7m0", false},
+		{"policy", "ch8i4ug6lnn4g9hqv7mg", true},
+		{"policy", "policy id", false},
+		{"route", "chacdk86lnnboviihd7g", true},
+		{"route", "net/chacdk86lnnboviihd7g", false},
+		{"setup_key", "2531583362", true},
+		{"setup_key", "A616097E-FCF0-48FA-9354-CA4A61142761", true},
+		{"user", "google-oauth2|277474792786460067937", true},
+		{"user", "b6bdd3e1-e9d1-4c8c-a37c-3e3c4a1c7d34", true},
+		{"user", "alice@example.com", false},
+	}
+
+	for _, test := range tests {
+		err := ValidateImportID(test.resourceType, test.id)
+		if test.valid && err != nil {
+			t.Errorf("ValidateImportID(%q, %q) = %v, want no error", test.resourceType, test.id, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("ValidateImportID(%q, %q) = nil, want an error", test.resourceType, test.id)
+		}
+	}
+}
+
+func TestAddResourceSkipsInvalidImportID(t *testing.T) {
+	generator := NewTerraformGenerator(t.TempDir(), &Config{})
+
+	generator.AddResource("user", "alice", map[string]any{"id": "alice@example.com", "email": "alice@example.com"})
+	generator.AddResource("user", "bob", map[string]any{"id": "u2", "email": "bob@example.com"})
+
+	if len(generator.GetResources()) != 1 || generator.GetResources()[0].Name != "bob" {
+		t.Fatalf("resources = %+v, want only bob", generator.GetResources())
+	}
+	if len(generator.GetImportCommands()) != 1 || generator.GetImportCommands()[0].ResourceID != "u2" {
+		t.Fatalf("import commands = %+v, want only u2", generator.GetImportCommands())
+	}
+	if len(generator.GetSkipped()) != 1 || generator.GetSkipped()[0].Name != "alice" {
+		t.Fatalf("skipped = %+v, want alice", generator.GetSkipped())
+	}
+}
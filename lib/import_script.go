@@ -0,0 +1,179 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Import script formats
+const (
+	ImportScriptBash       = "sh"  // import.sh, for bash
+	ImportScriptPowerShell = "ps1" // import.ps1, for Windows PowerShell and pwsh
+)
+
+// ImportScriptFiles maps the import script formats to the file each writes
+var ImportScriptFiles = map[string]string{
+	ImportScriptBash:       "import.sh",
+	ImportScriptPowerShell: "import.ps1",
+}
+
+// DefaultImportScripts are the import scripts written unless configured
+var DefaultImportScripts = []string{ImportScriptBash, ImportScriptPowerShell}
+
+// GenerateImportScript generates the import scripts with all terraform import
+// commands, in every format of Config.ImportScripts. With split state every
+// module gets its own scripts, run in order by the scripts in the output
+// directory.
+func (tg *TerraformGenerator) GenerateImportScript() error {
+	// CDKTF constructs and Pulumi resources import themselves
+	if len(tg.importCommands) == 0 || IsProgramFormat(tg.config.Format) {
+		return nil
+	}
+
+	formats := tg.config.ImportScripts
+	if formats == nil {
+		formats = DefaultImportScripts
+	}
+
+	if !tg.config.SplitState {
+		for _, format := range formats {
+			if err := writeImportScript(tg.outputDir, format, "terraform", tg.GetImportCommands()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	modules := make([]string, 0)
+	commandsByModule := make(map[string][]ImportCommand)
+	for _, cmd := range tg.GetImportCommands() {
+		if _, exists := commandsByModule[cmd.ResourceType]; !exists {
+			modules = append(modules, cmd.ResourceType)
+		}
+		commandsByModule[cmd.ResourceType] = append(commandsByModule[cmd.ResourceType], cmd)
+	}
+
+	binary := "terraform"
+	if tg.config.Terragrunt {
+		binary = "terragrunt"
+	}
+	for _, format := range formats {
+		for _, module := range modules {
+			err := writeImportScript(tg.ModuleDir(module), format, binary, commandsByModule[module])
+			if err != nil {
+				return err
+			}
+		}
+		if err := writeModulesImportScript(tg.outputDir, format, modules); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeImportScript writes the import script of a format running the given
+// import commands in dir
+func writeImportScript(dir, format, binary string, importCommands []ImportCommand) error {
+	var script strings.Builder
+	switch format {
+	case ImportScriptBash:
+		script.WriteString("#!/usr/bin/env bash\n")
+		script.WriteString("# NetBird Terraform Import Script\n")
+		script.WriteString("# Generated by NetBird terraformer Terraformer\n\n")
+		script.WriteString("set -euo pipefail\n\n")
+		fmt.Fprintf(&script, "echo \"Running %s init...\"\n", binary)
+		fmt.Fprintf(&script, "%s init\n\n", binary)
+		fmt.Fprintf(&script, "echo \"Running %s imports...\"\n", binary)
+		for _, cmd := range importCommands {
+			fmt.Fprintf(&script, "echo \"Importing %s...\"\n", cmd.ResourceAddress)
+			fmt.Fprintf(&script, "%s import \"%s\" \"%s\"\n\n", binary, cmd.ResourceAddress, cmd.ResourceID)
+		}
+		script.WriteString("echo \"All imports completed!\"\n")
+	case ImportScriptPowerShell:
+		script.WriteString("# NetBird Terraform Import Script\n")
+		script.WriteString("# Generated by NetBird terraformer Terraformer\n\n")
+		script.WriteString(powerShellPreamble)
+		fmt.Fprintf(&script, "Write-Host \"Running %s init...\"\n", binary)
+		fmt.Fprintf(&script, "Invoke-Native %s init\n\n", binary)
+		fmt.Fprintf(&script, "Write-Host \"Running %s imports...\"\n", binary)
+		for _, cmd := range importCommands {
+			fmt.Fprintf(&script, "Write-Host \"Importing %s...\"\n", cmd.ResourceAddress)
+			fmt.Fprintf(&script, "Invoke-Native %s import %s %s\n\n", binary, powerShellQuote(cmd.ResourceAddress), powerShellQuote(cmd.ResourceID))
+		}
+		script.WriteString("Write-Host \"All imports completed!\"\n")
+	default:
+		return fmt.Errorf("unknown import script format %q", format)
+	}
+	return writeScript(filepath.Join(dir, ImportScriptFiles[format]), script.String())
+}
+
+// writeModulesImportScript writes the import script of a format in the output
+// directory of a split state layout, running the script of every module
+func writeModulesImportScript(dir, format string, modules []string) error {
+	var script strings.Builder
+	switch format {
+	case ImportScriptBash:
+		script.WriteString("#!/usr/bin/env bash\n")
+		script.WriteString("# NetBird Terraform Import Script\n")
+		script.WriteString("# Generated by NetBird terraformer Terraformer\n")
+		script.WriteString("# Runs the import script of every root module\n\n")
+		script.WriteString("set -euo pipefail\n")
+		script.WriteString("cd \"$(dirname \"$0\")\"\n\n")
+		for _, module := range modules {
+			fmt.Fprintf(&script, "echo \"Importing the %s module...\"\n", module)
+			fmt.Fprintf(&script, "(cd \"%s\" && ./import.sh)\n\n", module)
+		}
+		script.WriteString("echo \"All modules imported!\"\n")
+	case ImportScriptPowerShell:
+		script.WriteString("# NetBird Terraform Import Script\n")
+		script.WriteString("# Generated by NetBird terraformer Terraformer\n")
+		script.WriteString("# Runs the import script of every root module\n\n")
+		script.WriteString("$ErrorActionPreference = \"Stop\"\n")
+		script.WriteString("Set-Location -Path $PSScriptRoot\n\n")
+		for _, module := range modules {
+			fmt.Fprintf(&script, "Write-Host \"Importing the %s module...\"\n", module)
+			fmt.Fprintf(&script, "Push-Location -Path %s\n", powerShellQuote(module))
+			script.WriteString("try { & .\\import.ps1 } finally { Pop-Location }\n\n")
+		}
+		script.WriteString("Write-Host \"All modules imported!\"\n")
+	default:
+		return fmt.Errorf("unknown import script format %q", format)
+	}
+	return writeScript(filepath.Join(dir, ImportScriptFiles[format]), script.String())
+}
+
+// powerShellPreamble stops an import script at the first failing command.
+// $ErrorActionPreference does not cover native commands before PowerShell 7.3,
+// so their exit code is checked by Invoke-Native.
+const powerShellPreamble = `$ErrorActionPreference = "Stop"
+
+function Invoke-Native {
+    $command, $arguments = $args
+    & $command @arguments
+    if ($LASTEXITCODE -ne 0) {
+        throw "$command $($arguments -join ' ') failed with exit code $LASTEXITCODE"
+    }
+}
+
+`
+
+// powerShellQuote quotes a value as a PowerShell single-quoted string
+func powerShellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// writeScript writes an executable script. Windows has no executable bit, so
+// the mode is left alone there.
+func writeScript(path, content string) error {
+	if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	// WriteFile keeps the mode of an existing file
+	return os.Chmod(path, 0755)
+}
@@ -1,11 +1,18 @@
 package lib
 
-import "os"
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+)
 
 // ResourceHandler defines the interface for resource-specific handlers
 type ResourceHandler interface {
 	// ImportAndGenerate imports resources from NetBird and generates Terraform files
-	ImportAndGenerate() error
+	ImportAndGenerate(ctx context.Context) error
 
 	// GetResourceMapping returns mapping of resource IDs to Terraform resource names
 	GetResourceMapping() map[string]string
@@ -19,34 +26,200 @@ type TerraformResource struct {
 	Type       string
 	Name       string
 	Attributes map[string]interface{}
-	IsData     bool   // true for data sources, false for resources
-	ID         string // stored separately for import, not written to .tf files
+	IsData     bool     // true for data sources, false for resources
+	ID         string   // stored separately for import, not written to .tf files
+	URL        string   // dashboard deep link for the NetBird object, if known
+	Comments   []string // comment lines written above the block
+
+	// Lifecycle is written as the lifecycle block of a managed resource, if set
+	Lifecycle *Lifecycle
+
+	// Provider is the provider meta-argument, e.g. netbird.prod, if the
+	// provider configuration has an alias
+	Provider string
 }
 
 // ImportCommand represents a terraform import command to be executed
 type ImportCommand struct {
+	ResourceType    string
 	ResourceAddress string
 	ResourceID      string
 }
 
+// SkippedResource records an object that was fetched from the API but not generated
+type SkippedResource struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
 // TerraformWriter handles writing Terraform files and managing imports
 type TerraformWriter interface {
 	AddResource(resourceType, name string, attributes map[string]interface{})
 	AddDataSource(dataType, name string, attributes map[string]interface{})
+
+	// AddDataSourceIfReferenced adds a data source that is dropped again if no
+	// resource references it, recording the object as skipped with reason
+	AddDataSourceIfReferenced(dataType, name, displayName, reason string, attributes map[string]interface{})
+
 	WriteResource(file *os.File, resource TerraformResource) error
 	QueueImport(resourceType, name string, resourceID string)
 	GetImportCommands() []ImportCommand
+
+	// IncludeResource reports whether an object with the given ID and display
+	// name (group name, policy name, user email) should be generated. issued is
+	// the object's issued field (api, jwt, integration), empty if it has none.
+	IncludeResource(resourceType, id, displayName, issued string) bool
+
+	// StartProgress starts a progress indicator for a batch of work
+	StartProgress(label string, total int) *Progress
+
+	// RecordDiscovered records how many objects of a type were fetched
+	RecordDiscovered(resourceType string, count int)
+
+	// SkipResource records an object that was fetched but not generated
+	SkipResource(resourceType, name, reason string)
+
+	// ResourceName returns the Terraform name of an object, empty if it has none
+	ResourceName(data NameData) string
+
+	// UniqueName claims a resource name for an object and returns it, with a
+	// suffix if another object of the type already uses it
+	UniqueName(resourceType, id, name string) string
 }
 
 // NetBirdAPI defines the interface for NetBird API operations
 type NetBirdAPI interface {
-	Get(endpoint string, result interface{}) error
+	Get(ctx context.Context, endpoint string, result interface{}) error
 }
 
 // Config represents the application configuration
 type Config struct {
 	ServerURL  string
-	APIToken   string
-	Debug      bool
+	Verbosity  Verbosity // enabled debug output categories
 	AutoImport bool
+	Format     string // output format, see OutputFormats
+
+	ProviderVersion  string // version constraint for the netbirdio/netbird provider
+	ProviderDefaults bool   // apply the ProviderDefaults knowledge base
+	LockedVersion    string // provider version the output directory's lock file pins, if any
+	ProviderAlias    string // alias of the provider configuration, empty for the default one
+	TerraformPath    string // terraform binary, DefaultTerraformPath if empty
+	UserAgent        string // appended to the provider's User-Agent, see TerraformRunner
+
+	ExcludedTypes  []string              // resource types to skip entirely (e.g. "user")
+	IncludePattern *regexp.Regexp        // only generate objects whose name matches, if set
+	ExcludePattern *regexp.Regexp        // skip objects whose name matches, if set
+	Rules          []*Rule               // per-object import/skip/data_source rules, first match wins
+	ImportOrder    []string              // resource types in import order, unlisted types go last
+	IssuedActions  map[string]RuleAction // action per issued value when no rule matches
+
+	// Limit generates at most this many objects per resource type for trial
+	// runs, 0 for all of them; Sample spreads them over the type's objects
+	// instead of taking the first ones. See limit.go.
+	Limit  int
+	Sample bool
+
+	// SkipSystemGroups turns the All group and groups issued by JWT sync or an
+	// IdP integration into data sources when no rule matches
+	SkipSystemGroups bool
+
+	NameTemplates map[string]*template.Template // resource name template per resource type
+	NameOverrides map[string]map[string]string  // resource name per resource type and object ID
+	StateNames    map[string]map[string]string  // names of objects in the existing state, kept unless overridden
+
+	DashboardURL string // base URL of the NetBird dashboard used for deep links
+	URLComments  bool   // write dashboard links as comments above each resource
+
+	Lifecycle map[string]Lifecycle // lifecycle block per resource type
+
+	SplitState bool           // write one root module with its own state per resource type
+	Backend    *BackendConfig // state backend of the split modules, nil for local state
+	Terragrunt bool           // write the split modules as a Terragrunt layout, see terragrunt.go
+
+	Concurrency int // resource types fetched at the same time
+
+	ImportScripts []string // formats of the import scripts, DefaultImportScripts if nil
+
+	InteractiveProgress bool // render progress bars instead of logging percentages
+}
+
+// ProviderReference returns the provider meta-argument of the generated
+// resources, or "" when they use the default provider configuration
+func (c *Config) ProviderReference() string {
+	if c.ProviderAlias == "" {
+		return ""
+	}
+	return "netbird." + c.ProviderAlias
+}
+
+// MatchesNameFilter reports whether a name passes the include/exclude patterns
+func (c *Config) MatchesNameFilter(name string) bool {
+	if c.IncludePattern != nil && !c.IncludePattern.MatchString(name) {
+		return false
+	}
+	if c.ExcludePattern != nil && c.ExcludePattern.MatchString(name) {
+		return false
+	}
+	return true
+}
+
+// Verbosity is a set of debug output categories, each enabled on its own with
+// --verbose or cumulatively with -v, -vv and -vvv
+type Verbosity int
+
+const (
+	VerboseAPI       Verbosity = 1 << iota // api: API requests and responses
+	VerboseGenerate                        // generate: per-resource generation tracing
+	VerboseTerraform                       // terraform: terraform command echoing
+
+	VerboseAll = VerboseAPI | VerboseGenerate | VerboseTerraform
+)
+
+// VerbosityCategories lists the supported --verbose categories in -v order
+var VerbosityCategories = []string{"api", "generate", "terraform"}
+
+// Has reports whether the category is enabled
+func (v Verbosity) Has(category Verbosity) bool {
+	return v&category != 0
+}
+
+// VerbosityLevel returns the categories of -v (1), -vv (2) and -vvv (3), each
+// level adding the next category
+func VerbosityLevel(level int) Verbosity {
+	level = min(max(level, 0), len(VerbosityCategories))
+	return Verbosity(1<<level - 1)
+}
+
+// ParseVerbosity parses --verbose categories, e.g. "api,terraform"; "all"
+// enables every category
+func ParseVerbosity(categories []string) (Verbosity, error) {
+	var verbosity Verbosity
+	for _, category := range categories {
+		if category == "all" {
+			verbosity |= VerboseAll
+			continue
+		}
+		known := false
+		for index, name := range VerbosityCategories {
+			if name == category {
+				verbosity |= 1 << index
+				known = true
+			}
+		}
+		if !known {
+			return 0, fmt.Errorf("unknown verbose category %q (supported: %s, all)", category, strings.Join(VerbosityCategories, ", "))
+		}
+	}
+	return verbosity, nil
+}
+
+// IsExcluded reports whether a resource type was excluded from the import
+func (c *Config) IsExcluded(resourceType string) bool {
+	for _, excluded := range c.ExcludedTypes {
+		if excluded == resourceType {
+			return true
+		}
+	}
+	return false
 }
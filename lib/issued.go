@@ -0,0 +1,59 @@
+package lib
+
+import "fmt"
+
+// Who issued a group or user, as reported in the API's issued field
+const (
+	IssuedAPI         = "api"         // created through the API or dashboard
+	IssuedJWT         = "jwt"         // created from the groups claim of user tokens
+	IssuedIntegration = "integration" // synced by an identity provider integration
+)
+
+// IssuedTypes lists the known issued values
+var IssuedTypes = []string{IssuedAPI, IssuedJWT, IssuedIntegration}
+
+// DefaultIssuedActions leaves objects synced by an IdP integration to the
+// integration; managing them in Terraform as well would fight the sync
+var DefaultIssuedActions = map[string]RuleAction{
+	IssuedIntegration: RuleSkip,
+}
+
+// AllGroupName is the name of the group every peer of an account belongs to
+const AllGroupName = "All"
+
+// SystemGroup returns why a group is maintained by NetBird or an identity
+// provider rather than by its users, or an empty string for other groups
+func SystemGroup(name, issued string) string {
+	switch {
+	case name == AllGroupName:
+		return "built-in All group"
+	case issued == IssuedJWT:
+		return "issued by JWT group sync"
+	case issued == IssuedIntegration:
+		return "issued by an IdP integration"
+	default:
+		return ""
+	}
+}
+
+// IssuedComment returns the comment annotating an object not created through the API
+func IssuedComment(issued string) string {
+	switch issued {
+	case IssuedJWT:
+		return "Issued by JWT group sync: NetBird recreates this group from the groups claim of user tokens"
+	case IssuedIntegration:
+		return "Issued by an IdP integration: changes may be overwritten by the next sync"
+	default:
+		return fmt.Sprintf("Issued by %s", issued)
+	}
+}
+
+// IsIssuedType reports whether a value is one of the known issued values
+func IsIssuedType(issued string) bool {
+	for _, known := range IssuedTypes {
+		if known == issued {
+			return true
+		}
+	}
+	return false
+}
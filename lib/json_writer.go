@@ -0,0 +1,249 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// JSONWriter writes resources using Terraform's JSON configuration syntax (.tf.json files)
+type JSONWriter struct{}
+
+// Format returns the format name
+func (w *JSONWriter) Format() string {
+	return "json"
+}
+
+// WriteProvider generates the provider.tf.json file
+func (w *JSONWriter) WriteProvider(outputDir string, config *Config) error {
+	// The token is never written; the provider reads NB_PAT at plan and apply time
+	provider := map[string]any{
+		"management_url": config.ServerURL,
+	}
+	if config.ProviderAlias != "" {
+		provider["alias"] = config.ProviderAlias
+	}
+
+	document := map[string]any{
+		"terraform": map[string]any{
+			"required_providers": map[string]any{
+				"netbird": map[string]any{
+					"source":  "netbirdio/netbird",
+					"version": config.ProviderVersion,
+				},
+			},
+		},
+		"provider": map[string]any{
+			"netbird": provider,
+		},
+	}
+
+	return writeJSONFile(filepath.Join(outputDir, "provider.tf.json"), document)
+}
+
+// WriteResources writes resources of one type to <type>.tf.json
+func (w *JSONWriter) WriteResources(outputDir, resourceType string, resources []TerraformResource) error {
+	managed := make(map[string]any)
+	data := make(map[string]any)
+
+	for _, resource := range resources {
+		body := w.convertAttributes(resource.Attributes)
+		if id, exists := resource.Attributes["id"]; exists && resource.IsData {
+			body["id"] = w.convertValue(id)
+		}
+		if len(resource.Comments) > 0 {
+			// "//" is the comment property of Terraform's JSON syntax
+			body["//"] = strings.Join(resource.Comments, "\n")
+		}
+		if resource.Lifecycle != nil && !resource.IsData {
+			body["lifecycle"] = resource.Lifecycle.jsonBody()
+		}
+		if resource.Provider != "" {
+			body["provider"] = resource.Provider
+		}
+		if resource.IsData {
+			data[resource.Name] = body
+		} else {
+			managed[resource.Name] = body
+		}
+	}
+
+	document := make(map[string]any)
+	blockType := fmt.Sprintf("netbird_%s", resourceType)
+	if len(managed) > 0 {
+		document["resource"] = map[string]any{blockType: managed}
+	}
+	if len(data) > 0 {
+		document["data"] = map[string]any{blockType: data}
+	}
+
+	return writeJSONFile(filepath.Join(outputDir, fmt.Sprintf("%s.tf.json", resourceType)), document)
+}
+
+// WriteBackend writes backend.tf.json configuring the state backend of a module
+func (w *JSONWriter) WriteBackend(outputDir string, backend *BackendConfig, module string) error {
+	settings := make(map[string]any, len(backend.Settings)+1)
+	for key, value := range backend.Settings {
+		settings[key] = value
+	}
+	keyAttribute, key := backend.StateKey(module)
+	settings[keyAttribute] = key
+
+	document := map[string]any{
+		"terraform": map[string]any{
+			"backend": map[string]any{
+				backend.Type: settings,
+			},
+		},
+	}
+
+	return writeJSONFile(filepath.Join(outputDir, "backend.tf.json"), document)
+}
+
+// WriteRunMetadata writes importer_metadata.tf.json with the run metadata output
+func (w *JSONWriter) WriteRunMetadata(outputDir string, metadata RunMetadata) error {
+	document := map[string]any{
+		"output": map[string]any{
+			runMetadataOutput: map[string]any{
+				"description": "Importer run that adopted the NetBird resources in this configuration",
+				"value": map[string]any{
+					"importer_version":   metadata.Version,
+					"run_id":             metadata.RunID,
+					"imported_at":        metadata.ImportedAt,
+					"imported_resources": metadata.ImportedResources,
+				},
+			},
+		},
+	}
+
+	return writeJSONFile(filepath.Join(outputDir, "importer_metadata.tf.json"), document)
+}
+
+// WriteImportBlocks writes imports.tf.json with an import block per import command
+func (w *JSONWriter) WriteImportBlocks(outputDir string, importCommands []ImportCommand) error {
+	blocks := make([]map[string]any, 0, len(importCommands))
+	for _, cmd := range importCommands {
+		blocks = append(blocks, map[string]any{
+			"to": cmd.ResourceAddress,
+			"id": cmd.ResourceID,
+		})
+	}
+
+	return writeJSONFile(filepath.Join(outputDir, "imports.tf.json"), map[string]any{"import": blocks})
+}
+
+// WriteRemovedBlocks writes removed.tf.json, which requires terraform 1.7 or later
+func (w *JSONWriter) WriteRemovedBlocks(outputDir string, addresses []string) error {
+	blocks := make([]map[string]any, 0, len(addresses))
+	for _, address := range addresses {
+		blocks = append(blocks, map[string]any{
+			"from":      address,
+			"lifecycle": map[string]any{"destroy": false},
+		})
+	}
+
+	return writeJSONFile(filepath.Join(outputDir, "removed.tf.json"), map[string]any{"removed": blocks})
+}
+
+// convertAttributes converts resource attributes into their JSON syntax equivalent,
+// mirroring the skipping rules of the HCL writer
+func (w *JSONWriter) convertAttributes(attributes map[string]any) map[string]any {
+	body := make(map[string]any)
+
+	for key, value := range attributes {
+		if !isWritableAttribute(key) {
+			continue
+		}
+
+		switch v := value.(type) {
+		case Expression:
+			body[key] = w.convertExpression(v)
+		case string:
+			if v != "" {
+				body[key] = escapeTemplate(v)
+			}
+		case bool, int, int64, float64:
+			body[key] = v
+		case []any:
+			if len(v) == 0 {
+				continue
+			}
+			if _, isMap := v[0].(map[string]any); isMap {
+				blocks := make([]any, 0, len(v))
+				for _, item := range v {
+					if itemMap, ok := item.(map[string]any); ok {
+						blocks = append(blocks, w.convertAttributes(itemMap))
+					}
+				}
+				body[GetBlockName(key)] = blocks
+				continue
+			}
+			items := make([]any, 0, len(v))
+			for _, item := range v {
+				switch value := item.(type) {
+				case Expression:
+					items = append(items, w.convertExpression(value))
+				case string:
+					if value != "" {
+						items = append(items, escapeTemplate(value))
+					}
+				}
+			}
+			body[key] = items
+		case []map[string]any:
+			if len(v) == 0 {
+				continue
+			}
+			blocks := make([]any, 0, len(v))
+			for _, item := range v {
+				blocks = append(blocks, w.convertAttributes(item))
+			}
+			body[GetBlockName(key)] = blocks
+		case []string:
+			if len(v) == 0 {
+				continue
+			}
+			items := make([]any, 0, len(v))
+			for _, item := range v {
+				if item != "" {
+					items = append(items, escapeTemplate(item))
+				}
+			}
+			body[key] = items
+		case map[string]any:
+			body[key] = w.convertAttributes(v)
+		}
+	}
+
+	return body
+}
+
+// convertValue converts a scalar: an expression, or a literal whose template
+// sequences are escaped
+func (w *JSONWriter) convertValue(value any) any {
+	switch v := value.(type) {
+	case Expression:
+		return w.convertExpression(v)
+	case string:
+		return escapeTemplate(v)
+	}
+	return value
+}
+
+// convertExpression wraps an expression in interpolation syntax, which is how
+// JSON tells it apart from a literal string
+func (w *JSONWriter) convertExpression(value Expression) string {
+	return fmt.Sprintf("${%s}", value)
+}
+
+// writeJSONFile writes an indented JSON document to the given path
+func writeJSONFile(path string, document any) error {
+	content, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(content, '\n'), 0644)
+}
@@ -0,0 +1,54 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Lifecycle is the lifecycle block written into every resource of a type, for
+// attributes NetBird changes outside Terraform, e.g. the peers of a group
+// maintained by the agent
+type Lifecycle struct {
+	IgnoreChanges  []string `json:"ignore_changes"`
+	PreventDestroy bool     `json:"prevent_destroy"`
+}
+
+// IsEmpty reports whether the lifecycle block has no settings
+func (l Lifecycle) IsEmpty() bool {
+	return len(l.IgnoreChanges) == 0 && !l.PreventDestroy
+}
+
+// Validate checks that the ignored attributes are valid attribute names
+func (l Lifecycle) Validate() error {
+	for _, attribute := range l.IgnoreChanges {
+		if !terraformIdentifier.MatchString(attribute) {
+			return fmt.Errorf("invalid attribute %q in ignore_changes", attribute)
+		}
+	}
+	return nil
+}
+
+// writeHCL writes the lifecycle block at the end of a resource block
+func (l Lifecycle) writeHCL(out io.Writer) {
+	fmt.Fprintf(out, "\n  lifecycle {\n")
+	if len(l.IgnoreChanges) > 0 {
+		fmt.Fprintf(out, "    ignore_changes = [%s]\n", strings.Join(l.IgnoreChanges, ", "))
+	}
+	if l.PreventDestroy {
+		fmt.Fprintf(out, "    prevent_destroy = true\n")
+	}
+	fmt.Fprintf(out, "  }\n")
+}
+
+// jsonBody returns the lifecycle block in Terraform's JSON syntax
+func (l Lifecycle) jsonBody() map[string]any {
+	body := make(map[string]any)
+	if len(l.IgnoreChanges) > 0 {
+		body["ignore_changes"] = l.IgnoreChanges
+	}
+	if l.PreventDestroy {
+		body["prevent_destroy"] = true
+	}
+	return body
+}
@@ -0,0 +1,32 @@
+package lib
+
+// DefaultSampleSize is the number of objects per type --sample generates
+// when no limit is given
+const DefaultSampleSize = 5
+
+// withinLimit reports whether the next object of a type that would be
+// imported stays within Config.Limit, counting it if so. Objects beyond the
+// limit are converted to data sources, which are dropped again unless a kept
+// resource references them, so a trial run still resolves its references.
+// With Config.Sample every n-th object of the type is kept, spreading the
+// limit over all of them. The caller holds mu.
+func (tg *TerraformGenerator) withinLimit(resourceType string) bool {
+	if tg.config.Limit <= 0 {
+		return true
+	}
+
+	seen := tg.limitSeen[resourceType]
+	tg.limitSeen[resourceType]++
+	if tg.limitKept[resourceType] >= tg.config.Limit {
+		return false
+	}
+	if tg.config.Sample {
+		stride := (tg.discovered[resourceType] + tg.config.Limit - 1) / tg.config.Limit
+		if stride > 1 && seen%stride != 0 {
+			return false
+		}
+	}
+
+	tg.limitKept[resourceType]++
+	return true
+}
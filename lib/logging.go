@@ -0,0 +1,78 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+)
+
+// LogFormats lists the supported --log-format values
+var LogFormats = []string{"text", "json"}
+
+// NewLogger creates the structured logger used for progress, warning and debug
+// messages. User-facing results (help, tables, next steps) stay on stdout.
+func NewLogger(out io.Writer, level slog.Level, format string) (*slog.Logger, error) {
+	options := &slog.HandlerOptions{Level: level}
+
+	switch format {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(out, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(out, options)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q (supported: %s)", format, strings.Join(LogFormats, ", "))
+	}
+}
+
+// ParseLogLevel parses a --log-level value (debug, info, warn, error)
+func ParseLogLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(value))
+	if err != nil {
+		return slog.LevelInfo, fmt.Errorf("unknown log level %q (supported: debug, info, warn, error)", value)
+	}
+	return level, nil
+}
+
+// WarningCounter wraps a log handler and counts records at warn level or above,
+// including those below the configured log level, so --fail-on-warning works
+// with --log-level error
+type WarningCounter struct {
+	handler slog.Handler
+	count   *atomic.Int64
+}
+
+// NewWarningCounter wraps the given handler
+func NewWarningCounter(handler slog.Handler) *WarningCounter {
+	return &WarningCounter{handler: handler, count: &atomic.Int64{}}
+}
+
+// Count returns the number of warnings logged so far
+func (c *WarningCounter) Count() int {
+	return int(c.count.Load())
+}
+
+func (c *WarningCounter) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelWarn || c.handler.Enabled(ctx, level)
+}
+
+func (c *WarningCounter) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level >= slog.LevelWarn {
+		c.count.Add(1)
+	}
+	if !c.handler.Enabled(ctx, record.Level) {
+		return nil
+	}
+	return c.handler.Handle(ctx, record)
+}
+
+func (c *WarningCounter) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &WarningCounter{handler: c.handler.WithAttrs(attrs), count: c.count}
+}
+
+func (c *WarningCounter) WithGroup(name string) slog.Handler {
+	return &WarningCounter{handler: c.handler.WithGroup(name), count: c.count}
+}
@@ -0,0 +1,107 @@
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Address returns the Terraform address of a resource or data source
+func (r TerraformResource) Address() string {
+	if r.IsData {
+		return fmt.Sprintf("data.netbird_%s.%s", r.Type, r.Name)
+	}
+	return fmt.Sprintf("netbird_%s.%s", r.Type, r.Name)
+}
+
+// AppendNewResources merges the generated resources into an output directory
+// holding an earlier run's configuration: resources whose address is in
+// existing are left alone, the others are appended to the file of their type,
+// and only their imports stay queued. Existing blocks, hand edits included,
+// are never rewritten. It returns the number of resources appended.
+func (tg *TerraformGenerator) AppendNewResources(existing map[string]bool) (int, error) {
+	if tg.writer.Format() != "hcl" {
+		return 0, fmt.Errorf("merging is only supported for hcl output, not %s", tg.writer.Format())
+	}
+
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+
+	if err := os.MkdirAll(tg.outputDir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if _, err := os.Stat(filepath.Join(tg.outputDir, "provider.tf")); errors.Is(err, fs.ErrNotExist) {
+		if err := tg.writer.WriteProvider(tg.outputDir, tg.config); err != nil {
+			return 0, err
+		}
+	}
+
+	added := make(map[string][]TerraformResource)
+	types := make([]string, 0)
+	for _, resource := range tg.resources {
+		if existing[resource.Address()] || tg.config.IsExcluded(resource.Type) {
+			continue
+		}
+		if _, seen := added[resource.Type]; !seen {
+			types = append(types, resource.Type)
+		}
+		added[resource.Type] = append(added[resource.Type], resource)
+	}
+
+	appended := 0
+	for _, resourceType := range types {
+		if err := tg.appendResourceFile(resourceType, tg.resolveDataReferences(added[resourceType])); err != nil {
+			return appended, fmt.Errorf("failed to append %s resources: %w", resourceType, err)
+		}
+		appended += len(added[resourceType])
+	}
+
+	queued := make([]ImportCommand, 0, len(tg.importCommands))
+	for _, cmd := range tg.importCommands {
+		if !existing[cmd.ResourceAddress] {
+			queued = append(queued, cmd)
+		}
+	}
+	tg.importCommands = queued
+	return appended, nil
+}
+
+// appendResourceFile appends resources to <type>.tf, creating it with the
+// usual header if it does not exist yet. The appended blocks use the line
+// endings of the existing file, so a file checked out with CRLF line endings
+// on Windows does not end up with mixed ones.
+func (tg *TerraformGenerator) appendResourceFile(resourceType string, resources []TerraformResource) error {
+	path := filepath.Join(tg.outputDir, resourceType+".tf")
+	existing, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return tg.writer.WriteResources(tg.outputDir, resourceType, resources)
+	}
+	if err != nil {
+		return err
+	}
+
+	var blocks bytes.Buffer
+	writer := &HCLWriter{}
+	for _, resource := range resources {
+		if err := writer.WriteResource(&blocks, resource); err != nil {
+			return err
+		}
+		fmt.Fprintf(&blocks, "\n")
+	}
+	content := blocks.Bytes()
+	if ending := lineEnding(existing); ending != "\n" {
+		content = bytes.ReplaceAll(content, []byte("\n"), []byte(ending))
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(content)
+	return err
+}
@@ -0,0 +1,250 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// modulePackageExample is the directory of the example calling the packaged module
+var modulePackageExample = filepath.Join("examples", "basic")
+
+// WriteModulePackage writes the generated resources as a reusable module in the
+// standard module structure of the Terraform registry: main.tf, variables.tf,
+// outputs.tf, versions.tf, README.md and examples/basic calling the module. The
+// IDs of existing objects looked up through data sources are account specific,
+// so they become map variables keyed by data source name, filled in by the example.
+// The module configures no provider; the example does. Module packages are HCL.
+func (tg *TerraformGenerator) WriteModulePackage() error {
+	err := os.MkdirAll(filepath.Join(tg.outputDir, modulePackageExample), 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create module package: %w", err)
+	}
+
+	resources := tg.orderedModuleResources()
+	lookupIDs := make(map[string]map[string]string)
+	managed := make(map[string][]string)
+	for i, resource := range resources {
+		if !resource.IsData {
+			managed[resource.Type] = append(managed[resource.Type], resource.Name)
+			continue
+		}
+
+		if lookupIDs[resource.Type] == nil {
+			lookupIDs[resource.Type] = make(map[string]string)
+		}
+		lookupIDs[resource.Type][resource.Name] = fmt.Sprint(resource.Attributes["id"])
+
+		attributes := make(map[string]any, len(resource.Attributes))
+		for key, value := range resource.Attributes {
+			attributes[key] = value
+		}
+		attributes["id"] = Expression(fmt.Sprintf("var.%s_ids[\"%s\"]", resource.Type, resource.Name))
+		resources[i].Attributes = attributes
+	}
+
+	files := map[string]func() ([]byte, error){
+		"versions.tf":  func() ([]byte, error) { return tg.moduleVersions(), nil },
+		"main.tf":      func() ([]byte, error) { return moduleMain(resources) },
+		"variables.tf": func() ([]byte, error) { return moduleVariables(lookupIDs), nil },
+		"outputs.tf":   func() ([]byte, error) { return moduleOutputs(managed), nil },
+		"README.md":    func() ([]byte, error) { return moduleReadme(lookupIDs, managed), nil },
+		filepath.Join(modulePackageExample, "main.tf"):    func() ([]byte, error) { return tg.moduleExample(lookupIDs), nil },
+		filepath.Join(modulePackageExample, "imports.tf"): func() ([]byte, error) { return tg.moduleExampleImports(), nil },
+	}
+
+	for _, filename := range sortedNames(files) {
+		content, err := files[filename]()
+		if err != nil {
+			return fmt.Errorf("failed to generate %s: %w", filename, err)
+		}
+		err = tg.WriteFile(filename, content)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", filename, err)
+		}
+	}
+
+	return nil
+}
+
+// orderedModuleResources returns copies of the generated resources with data
+// references resolved, data sources first, then by import order and name
+func (tg *TerraformGenerator) orderedModuleResources() []TerraformResource {
+	resources := make([]TerraformResource, 0, len(tg.resources))
+	for _, resource := range tg.resolveDataReferences(tg.resources) {
+		if !tg.config.IsExcluded(resource.Type) {
+			resources = append(resources, resource)
+		}
+	}
+
+	rank := make(map[string]int, len(tg.config.ImportOrder))
+	for i, resourceType := range tg.config.ImportOrder {
+		rank[resourceType] = i + 1
+	}
+	sort.SliceStable(resources, func(i, j int) bool {
+		a, b := resources[i], resources[j]
+		if a.IsData != b.IsData {
+			return a.IsData
+		}
+		if a.Type != b.Type {
+			return rank[a.Type] < rank[b.Type] || (rank[a.Type] == rank[b.Type] && a.Type < b.Type)
+		}
+		return a.Name < b.Name
+	})
+	return resources
+}
+
+// moduleVersions renders versions.tf with the provider requirement of the module
+func (tg *TerraformGenerator) moduleVersions() []byte {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "# Generated by NetBird terraformer Terraformer\n\n")
+	fmt.Fprintf(&out, "terraform {\n")
+	fmt.Fprintf(&out, "  required_version = \">= 1.0\"\n\n")
+	fmt.Fprintf(&out, "  required_providers {\n")
+	fmt.Fprintf(&out, "    netbird = {\n")
+	fmt.Fprintf(&out, "      source  = \"netbirdio/netbird\"\n")
+	fmt.Fprintf(&out, "      version = \"%s\"\n", EscapeString(tg.config.ProviderVersion))
+	fmt.Fprintf(&out, "    }\n")
+	fmt.Fprintf(&out, "  }\n")
+	fmt.Fprintf(&out, "}\n")
+	return out.Bytes()
+}
+
+// moduleMain renders main.tf with every resource and data source
+func moduleMain(resources []TerraformResource) ([]byte, error) {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "# NetBird resources\n# Generated by NetBird terraformer Terraformer\n")
+
+	writer := &HCLWriter{}
+	for _, resource := range resources {
+		out.WriteString("\n")
+		err := writer.WriteResource(&out, resource)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// moduleVariables renders variables.tf with a map of IDs per looked up type
+func moduleVariables(lookupIDs map[string]map[string]string) []byte {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "# Generated by NetBird terraformer Terraformer\n")
+	for _, resourceType := range sortedNames(lookupIDs) {
+		fmt.Fprintf(&out, "\nvariable \"%s_ids\" {\n", resourceType)
+		fmt.Fprintf(&out, "  description = \"IDs of the existing NetBird %s objects the module looks up, by data source name (%s)\"\n",
+			displayType(resourceType), strings.Join(sortedNames(lookupIDs[resourceType]), ", "))
+		fmt.Fprintf(&out, "  type        = map(string)\n")
+		fmt.Fprintf(&out, "}\n")
+	}
+	return out.Bytes()
+}
+
+// moduleOutputs renders outputs.tf with the IDs of the managed objects per type
+func moduleOutputs(managed map[string][]string) []byte {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "# Generated by NetBird terraformer Terraformer\n")
+	for _, resourceType := range sortedNames(managed) {
+		fmt.Fprintf(&out, "\noutput \"%s_ids\" {\n", resourceType)
+		fmt.Fprintf(&out, "  description = \"IDs of the NetBird %s objects managed by the module, by resource name\"\n", displayType(resourceType))
+		fmt.Fprintf(&out, "  value = {\n")
+		for _, name := range managed[resourceType] {
+			fmt.Fprintf(&out, "    %s = netbird_%s.%s.id\n", name, resourceType, name)
+		}
+		fmt.Fprintf(&out, "  }\n")
+		fmt.Fprintf(&out, "}\n")
+	}
+	return out.Bytes()
+}
+
+// moduleExample renders examples/basic/main.tf, which configures the provider
+// and calls the module with the IDs of the imported account
+func (tg *TerraformGenerator) moduleExample(lookupIDs map[string]map[string]string) []byte {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "# Generated by NetBird terraformer Terraformer\n\n")
+	fmt.Fprintf(&out, "terraform {\n")
+	fmt.Fprintf(&out, "  required_providers {\n")
+	fmt.Fprintf(&out, "    netbird = {\n")
+	fmt.Fprintf(&out, "      source  = \"netbirdio/netbird\"\n")
+	fmt.Fprintf(&out, "      version = \"%s\"\n", EscapeString(tg.config.ProviderVersion))
+	fmt.Fprintf(&out, "    }\n")
+	fmt.Fprintf(&out, "  }\n")
+	fmt.Fprintf(&out, "}\n\n")
+	fmt.Fprintf(&out, "# The token is read from NB_PAT\n")
+	fmt.Fprintf(&out, "provider \"netbird\" {\n")
+	fmt.Fprintf(&out, "  management_url = \"%s\"\n", EscapeString(tg.config.ServerURL))
+	fmt.Fprintf(&out, "}\n\n")
+	fmt.Fprintf(&out, "module \"netbird\" {\n")
+	fmt.Fprintf(&out, "  source = \"../..\"\n")
+	for _, resourceType := range sortedNames(lookupIDs) {
+		fmt.Fprintf(&out, "\n  %s_ids = {\n", resourceType)
+		for _, name := range sortedNames(lookupIDs[resourceType]) {
+			fmt.Fprintf(&out, "    %s = \"%s\"\n", name, EscapeString(lookupIDs[resourceType][name]))
+		}
+		fmt.Fprintf(&out, "  }\n")
+	}
+	fmt.Fprintf(&out, "}\n")
+	return out.Bytes()
+}
+
+// moduleExampleImports renders examples/basic/imports.tf, adopting the existing
+// objects into the module's resources on the first apply (Terraform 1.5+)
+func (tg *TerraformGenerator) moduleExampleImports() []byte {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "# Adopts the existing NetBird objects on the first apply (Terraform 1.5+);\n")
+	fmt.Fprintf(&out, "# remove this file afterwards\n# Generated by NetBird terraformer Terraformer\n")
+	for _, cmd := range tg.GetImportCommands() {
+		fmt.Fprintf(&out, "\nimport {\n")
+		fmt.Fprintf(&out, "  to = module.netbird.%s\n", cmd.ResourceAddress)
+		fmt.Fprintf(&out, "  id = \"%s\"\n", EscapeString(cmd.ResourceID))
+		fmt.Fprintf(&out, "}\n")
+	}
+	return out.Bytes()
+}
+
+// moduleReadme renders README.md documenting the module's inputs and outputs
+func moduleReadme(lookupIDs map[string]map[string]string, managed map[string][]string) []byte {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "# NetBird\n\n")
+	fmt.Fprintf(&out, "NetBird account configuration generated by NetBird terraformer. Objects managed, by type:\n\n")
+	for _, resourceType := range sortedNames(managed) {
+		fmt.Fprintf(&out, "- %s: %d\n", displayType(resourceType), len(managed[resourceType]))
+	}
+
+	fmt.Fprintf(&out, "\n## Usage\n\n")
+	fmt.Fprintf(&out, "```hcl\nmodule \"netbird\" {\n  source = \"<registry or git source>\"\n")
+	for _, resourceType := range sortedNames(lookupIDs) {
+		fmt.Fprintf(&out, "\n  %s_ids = { ... }\n", resourceType)
+	}
+	fmt.Fprintf(&out, "}\n```\n\n")
+	fmt.Fprintf(&out, "The calling configuration configures the `netbirdio/netbird` provider. `examples/basic` calls the module for the account it was generated from, with import blocks adopting the existing objects.\n")
+
+	fmt.Fprintf(&out, "\n## Inputs\n\n")
+	if len(lookupIDs) == 0 {
+		fmt.Fprintf(&out, "None.\n")
+	} else {
+		fmt.Fprintf(&out, "| Name | Type | Description |\n|------|------|-------------|\n")
+		for _, resourceType := range sortedNames(lookupIDs) {
+			fmt.Fprintf(&out, "| `%s_ids` | `map(string)` | IDs of the existing %s objects looked up by the module |\n", resourceType, displayType(resourceType))
+		}
+	}
+
+	fmt.Fprintf(&out, "\n## Outputs\n\n")
+	if len(managed) == 0 {
+		fmt.Fprintf(&out, "None.\n")
+	} else {
+		fmt.Fprintf(&out, "| Name | Description |\n|------|-------------|\n")
+		for _, resourceType := range sortedNames(managed) {
+			fmt.Fprintf(&out, "| `%s_ids` | IDs of the managed %s objects, by resource name |\n", resourceType, displayType(resourceType))
+		}
+	}
+	return out.Bytes()
+}
+
+// displayType turns a resource type into words, e.g. setup_key into setup key
+func displayType(resourceType string) string {
+	return strings.ReplaceAll(resourceType, "_", " ")
+}
@@ -0,0 +1,203 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// NameData is what a name template is evaluated with. Fields a resource type
+// does not have are empty.
+type NameData struct {
+	Type  string // resource type, e.g. "group"
+	ID    string // NetBird object ID
+	Name  string // the name the importer uses by default: group, policy or user name, route network ID
+	Email string // users only
+	Role  string // users only
+}
+
+// nameTemplateFuncs are available in name templates, e.g. {{.Email | localpart}}
+var nameTemplateFuncs = template.FuncMap{
+	"localpart": func(email string) string {
+		localPart, _, _ := strings.Cut(email, "@")
+		return localPart
+	},
+	"domain": func(email string) string {
+		_, domain, _ := strings.Cut(email, "@")
+		return domain
+	},
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"trimprefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimsuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+}
+
+// ParseNameTemplate parses the name template of a resource type and checks it
+// against sample data, so a misspelled field fails before anything is fetched
+func ParseNameTemplate(resourceType, text string) (*template.Template, error) {
+	tmpl, err := template.New(resourceType).Funcs(nameTemplateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name template for %s: %w", resourceType, err)
+	}
+
+	sample := NameData{Type: resourceType, ID: "id", Name: "name", Email: "user@example.com", Role: "user"}
+	if err := tmpl.Execute(&bytes.Buffer{}, sample); err != nil {
+		return nil, fmt.Errorf("invalid name template for %s: %w", resourceType, err)
+	}
+	return tmpl, nil
+}
+
+// ResourceName returns the sanitized Terraform name of an object, rendered with
+// the name template of its type if one is configured. It returns an empty
+// string if the name is empty, so the handler can fall back to an ID-based
+// name.
+func (tg *TerraformGenerator) ResourceName(data NameData) string {
+	name := data.Name
+	if tmpl, exists := tg.config.NameTemplates[data.Type]; exists {
+		var rendered bytes.Buffer
+		if err := tmpl.Execute(&rendered, data); err != nil {
+			slog.Warn("Failed to render name template, using the default name", "type", data.Type, "id", data.ID, "error", err)
+		} else {
+			name = rendered.String()
+		}
+	}
+
+	if strings.TrimSpace(name) == "" {
+		return ""
+	}
+	return SanitizeResourceName(name)
+}
+
+// terraformIdentifier matches a valid Terraform resource name
+var terraformIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// LoadNameOverrides reads a name overrides file, mapping resource types to
+// NetBird object IDs to the resource names they should get:
+//
+//	group:
+//	  ch8i4ug6lnn4g9hqv7m0: developers
+//	user:
+//	  google-oauth2|277474792786460067937: alice
+func LoadNameOverrides(path string) (map[string]map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read name overrides: %w", err)
+	}
+
+	overrides := make(map[string]map[string]string)
+	if err := DecodeYAML(data, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse name overrides %s: %w", path, err)
+	}
+
+	for resourceType, names := range overrides {
+		ids := make(map[string]string, len(names))
+		for id, name := range names {
+			if !terraformIdentifier.MatchString(name) {
+				return nil, fmt.Errorf("invalid name override %q for %s %s: not a valid Terraform name", name, resourceType, id)
+			}
+			if other, exists := ids[name]; exists {
+				return nil, fmt.Errorf("name override %q is given to both %s %s and %s", name, resourceType, other, id)
+			}
+			ids[name] = id
+		}
+	}
+	return overrides, nil
+}
+
+// GetUnusedNameOverrides returns the type/id keys of name overrides whose
+// object was never named, usually a mistyped ID or an object that was deleted
+func (tg *TerraformGenerator) GetUnusedNameOverrides() []string {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+
+	unused := make([]string, 0)
+	for resourceType, names := range tg.config.NameOverrides {
+		if tg.config.IsExcluded(resourceType) {
+			continue
+		}
+		for id := range names {
+			if !tg.overridesUsed[resourceType+"/"+id] {
+				unused = append(unused, resourceType+"/"+id)
+			}
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
+
+// NameCollision records an object whose resource name was already used by
+// another object of the same type, e.g. groups "Dev Team" and "dev-team"
+type NameCollision struct {
+	Type     string `json:"type"`
+	ID       string `json:"id"`
+	Name     string `json:"name"`     // the name both objects derive
+	TakenBy  string `json:"taken_by"` // ID of the object keeping the name
+	Assigned string `json:"assigned"` // the name given to this object instead
+}
+
+// UniqueName claims a resource name for an object. An object with a name
+// override or a name in the existing state gets it, since those are reserved
+// when the generator is created. Otherwise the first object to claim a name keeps it; later ones get
+// their ID appended, which stays the same across runs no matter which other
+// objects exist. An object asking again, e.g. when another handler references
+// it, gets the name it was given before.
+func (tg *TerraformGenerator) UniqueName(resourceType, id, name string) string {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+
+	key := resourceType + "/" + id
+	if assigned, exists := tg.assignedNames[key]; exists {
+		if _, overridden := tg.config.NameOverrides[resourceType][id]; overridden {
+			tg.overridesUsed[key] = true
+		}
+		return assigned
+	}
+
+	assigned := name
+	owner, taken := tg.nameOwners[resourceType+"/"+name]
+	if taken {
+		assigned = SanitizeResourceName(name + "_" + nameSuffix(id))
+		for n := 2; tg.nameTaken(resourceType, assigned); n++ {
+			assigned = SanitizeResourceName(fmt.Sprintf("%s_%s_%d", name, nameSuffix(id), n))
+		}
+		tg.nameCollisions = append(tg.nameCollisions, NameCollision{Type: resourceType, ID: id, Name: name, TakenBy: owner, Assigned: assigned})
+	}
+
+	tg.assignedNames[key] = assigned
+	tg.nameOwners[resourceType+"/"+assigned] = id
+	return assigned
+}
+
+// nameTaken reports whether an object already uses a name; the caller holds mu
+func (tg *TerraformGenerator) nameTaken(resourceType, name string) bool {
+	_, taken := tg.nameOwners[resourceType+"/"+name]
+	return taken
+}
+
+// GetNameCollisions returns the objects that were renamed by UniqueName
+func (tg *TerraformGenerator) GetNameCollisions() []NameCollision {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	return append([]NameCollision(nil), tg.nameCollisions...)
+}
+
+// nameSuffix reduces an object ID to the letters and digits usable in a name
+func nameSuffix(id string) string {
+	var suffix strings.Builder
+	for _, r := range strings.ToLower(id) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			suffix.WriteRune(r)
+		}
+	}
+	if suffix.Len() == 0 {
+		return "dup"
+	}
+	return suffix.String()
+}
@@ -0,0 +1,107 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+)
+
+func TestResourceName(t *testing.T) {
+	templates := make(map[string]*template.Template)
+	for resourceType, text := range map[string]string{
+		"user":  "{{.Email | localpart}}",
+		"group": "{{.Type}}_{{.Name | lower}}",
+	} {
+		tmpl, err := ParseNameTemplate(resourceType, text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		templates[resourceType] = tmpl
+	}
+	generator := NewTerraformGenerator(t.TempDir(), &Config{NameTemplates: templates})
+
+	tests := []struct {
+		data NameData
+		want string
+	}{
+		{NameData{Type: "user", ID: "u1", Name: "Alice", Email: "alice.smith@example.com"}, "alice_smith"},
+		{NameData{Type: "user", ID: "u2", Name: "ci"}, ""},
+		{NameData{Type: "group", ID: "g1", Name: "Dev Team"}, "group_dev_team"},
+		{NameData{Type: "policy", ID: "p1", Name: "SSH access"}, "ssh_access"},
+		{NameData{Type: "policy", ID: "p2"}, ""},
+	}
+	for _, test := range tests {
+		if got := generator.ResourceName(test.data); got != test.want {
+			t.Errorf("ResourceName(%+v) = %q, want %q", test.data, got, test.want)
+		}
+	}
+}
+
+func TestParseNameTemplateRejectsUnknownFields(t *testing.T) {
+	for _, text := range []string{"{{.Mail}}", "{{.Name | camel}}", "{{.Name"} {
+		if _, err := ParseNameTemplate("user", text); err == nil {
+			t.Errorf("ParseNameTemplate(%q) succeeded", text)
+		}
+	}
+}
+
+func TestUniqueName(t *testing.T) {
+	generator := NewTerraformGenerator(t.TempDir(), &Config{})
+
+	if got := generator.UniqueName("group", "g1", "dev_team"); got != "dev_team" {
+		t.Errorf("first group got %q, want dev_team", got)
+	}
+	if got := generator.UniqueName("group", "G-2", "dev_team"); got != "dev_team_g2" {
+		t.Errorf("colliding group got %q, want dev_team_g2", got)
+	}
+	if got := generator.UniqueName("group", "G-2", "dev_team"); got != "dev_team_g2" {
+		t.Errorf("asking again got %q, want the name given before", got)
+	}
+	if got := generator.UniqueName("policy", "p1", "dev_team"); got != "dev_team" {
+		t.Errorf("policy got %q, names of other types do not collide", got)
+	}
+
+	collisions := generator.GetNameCollisions()
+	want := NameCollision{Type: "group", ID: "G-2", Name: "dev_team", TakenBy: "g1", Assigned: "dev_team_g2"}
+	if len(collisions) != 1 || collisions[0] != want {
+		t.Errorf("collisions = %+v, want [%+v]", collisions, want)
+	}
+}
+
+func TestNameOverrides(t *testing.T) {
+	generator := NewTerraformGenerator(t.TempDir(), &Config{NameOverrides: map[string]map[string]string{
+		"group": {"g2": "dev_team", "g9": "deleted"},
+	}})
+
+	if got := generator.UniqueName("group", "g1", "dev_team"); got != "dev_team_g1" {
+		t.Errorf("group whose name is overridden for another got %q, want dev_team_g1", got)
+	}
+	if got := generator.UniqueName("group", "g2", "developers"); got != "dev_team" {
+		t.Errorf("overridden group got %q, want dev_team", got)
+	}
+	if unused := generator.GetUnusedNameOverrides(); len(unused) != 1 || unused[0] != "group/g9" {
+		t.Errorf("unused overrides = %v, want [group/g9]", unused)
+	}
+}
+
+func TestLoadNameOverrides(t *testing.T) {
+	tests := map[string]bool{
+		"group:\n  g1: developers\nuser:\n  \"google-oauth2|1\": alice\n": true,
+		"group:\n  g1: dev team\n":                     false,
+		"group:\n  g1: developers\n  g2: developers\n": false,
+	}
+	for content, valid := range tests {
+		path := filepath.Join(t.TempDir(), "overrides.yaml")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		overrides, err := LoadNameOverrides(path)
+		if valid && (err != nil || overrides["user"]["google-oauth2|1"] != "alice") {
+			t.Errorf("LoadNameOverrides(%q) = %v, %v", content, overrides, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("LoadNameOverrides(%q) accepted an invalid file", content)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package lib
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// NormalizeNetwork validates a route network in CIDR notation and returns its
+// canonical form: host bits cleared and IPv6 addresses in compressed lowercase
+// notation (2001:0DB8:0000::/48 becomes 2001:db8::/48). The provider compares
+// networks as strings, so non-canonical values show up as a permanent diff.
+func NormalizeNetwork(network string) (string, error) {
+	prefix, err := netip.ParsePrefix(strings.TrimSpace(network))
+	if err != nil {
+		return "", fmt.Errorf("invalid network %q: %w", network, err)
+	}
+
+	if prefix.Addr().Zone() != "" {
+		return "", fmt.Errorf("invalid network %q: zoned addresses are not routable", network)
+	}
+
+	return prefix.Masked().String(), nil
+}
@@ -0,0 +1,41 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeNetwork(t *testing.T) {
+	tests := []struct {
+		network string
+		want    string
+		err     string
+	}{
+		{"10.0.0.0/24", "10.0.0.0/24", ""},
+		{"10.0.0.17/24", "10.0.0.0/24", ""},
+		{" 192.168.1.1/16 ", "192.168.0.0/16", ""},
+		{"10.0.0.1/32", "10.0.0.1/32", ""},
+		{"2001:db8::/48", "2001:db8::/48", ""},
+		{"2001:0DB8:0000:0000:0000:0000:0000:0001/48", "2001:db8::/48", ""},
+		{"2001:db8:abcd:12::1/64", "2001:db8:abcd:12::/64", ""},
+		{"::/0", "::/0", ""},
+		{"fe80::1%eth0/64", "", "invalid network"},
+		{"10.0.0.0", "", "invalid network"},
+		{"10.0.0.0/33", "", "invalid network"},
+		{"2001:db8::/129", "", "invalid network"},
+		{"example.com/24", "", "invalid network"},
+		{"", "", "invalid network"},
+	}
+	for _, test := range tests {
+		got, err := NormalizeNetwork(test.network)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("NormalizeNetwork(%q) = %q, %v, want an error containing %q", test.network, got, err, test.err)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("NormalizeNetwork(%q) = %q, %v, want %q", test.network, got, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package lib
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OutputWriter renders the collected resource model in a specific output format.
+// Handlers only ever add TerraformResources to the generator; writers decide how
+// those resources end up on disk.
+type OutputWriter interface {
+	// Format returns the name used to select this writer with --format
+	Format() string
+
+	// WriteProvider writes the provider configuration for the output directory
+	WriteProvider(outputDir string, config *Config) error
+
+	// WriteResources writes all resources of a single type
+	WriteResources(outputDir, resourceType string, resources []TerraformResource) error
+
+	// WriteBackend writes the state backend configuration of a split root module
+	WriteBackend(outputDir string, backend *BackendConfig, module string) error
+
+	// WriteRunMetadata writes an output recording which importer run adopted the resources
+	WriteRunMetadata(outputDir string, metadata RunMetadata) error
+
+	// WriteImportBlocks writes an import block for each import command
+	WriteImportBlocks(outputDir string, importCommands []ImportCommand) error
+
+	// WriteRemovedBlocks writes a removed block for each address, forgetting
+	// the object without destroying it
+	WriteRemovedBlocks(outputDir string, addresses []string) error
+}
+
+// RunMetadata describes an importer run, recorded in Terraform outputs so that drift
+// investigations can tell which tool version produced the adoption
+type RunMetadata struct {
+	Version           string
+	RunID             string
+	ImportedAt        string
+	ImportedResources int
+}
+
+// runMetadataOutput is the name of the Terraform output holding the run metadata
+const runMetadataOutput = "netbird_terraformer_run"
+
+// DefaultImportOrder imports the groups everything else references and the
+// posture checks policies reference first and users, which identity providers
+// often manage anyway, last
+var DefaultImportOrder = []string{"group", "posture_check", "policy", "route", "setup_key", "user"}
+
+// DefaultProviderVersion is the netbirdio/netbird provider version constraint used
+// when none is configured
+const DefaultProviderVersion = "~> 0.0.5"
+
+// outputWriters maps format names to writer constructors
+var outputWriters = map[string]func() OutputWriter{
+	"hcl":  func() OutputWriter { return &HCLWriter{} },
+	"json": func() OutputWriter { return &JSONWriter{} },
+
+	"cdktf-go": func() OutputWriter { return &CDKTFWriter{language: cdktfGo} },
+	"cdktf-ts": func() OutputWriter { return &CDKTFWriter{language: cdktfTypeScript} },
+
+	PulumiFormat: func() OutputWriter { return &PulumiWriter{} },
+}
+
+// NewOutputWriter returns the writer registered for the given format
+func NewOutputWriter(format string) (OutputWriter, error) {
+	if format == "" {
+		format = "hcl"
+	}
+
+	constructor, exists := outputWriters[format]
+	if !exists {
+		return nil, fmt.Errorf("unknown output format %q (supported: %s)", format, strings.Join(OutputFormats(), ", "))
+	}
+
+	return constructor(), nil
+}
+
+// OutputFormats returns the names of all supported output formats
+func OutputFormats() []string {
+	formats := make([]string, 0, len(outputWriters))
+	for format := range outputWriters {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// isWritableAttribute reports whether an attribute should be written to the output.
+// Read-only fields are computed by the provider and rejected if set. Data sources
+// write "id" themselves since it is their lookup key.
+func isWritableAttribute(key string) bool {
+	return key != "id" && key != "network_type" && key != "peers"
+}
+
+// Expression is an attribute value written as a Terraform expression rather
+// than a quoted string, such as a reference to another resource. Only the
+// generator creates expressions: every plain string, which is what the API
+// returns, is written as a literal however it looks.
+type Expression string
+
+// commentLines splits comments into lines, so that a name from the API with
+// a line break in it can't end the comment
+func commentLines(comments []string) []string {
+	lines := make([]string, 0, len(comments))
+	for _, comment := range comments {
+		comment = strings.ReplaceAll(comment, "\r", "")
+		lines = append(lines, strings.Split(comment, "\n")...)
+	}
+	return lines
+}
+
+// sortedKeys returns the keys of an attribute map in a stable order
+func sortedKeys(attributes map[string]any) []string {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
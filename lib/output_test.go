@@ -0,0 +1,57 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// API strings that look like references or templates must stay literals
+func TestWritersQuoteLiterals(t *testing.T) {
+	resource := TerraformResource{Type: "policy", Name: "ops", Comments: []string{"TODO unresolved group ops\nresource \"x\" \"y\" {}"}, Attributes: map[string]any{
+		"name":        "var.ops-team",
+		"description": "var.x\n}\ndata \"external\" \"pwn\" {\n  program = [\"sh\"]\n",
+		"rules": []any{map[string]any{
+			"sources":      []any{Expression("netbird_group.ops.id"), "netbird_group.fake.id"},
+			"destinations": []string{"data.netbird_group.all.id", "${file(\"/etc/passwd\")}"},
+		}},
+	}}
+
+	var out bytes.Buffer
+	if err := (&HCLWriter{}).WriteResource(&out, resource); err != nil {
+		t.Fatal(err)
+	}
+	hcl := out.String()
+	for _, want := range []string{
+		`name = "var.ops-team"`,
+		`description = "var.x\n}\ndata \"external\" \"pwn\" {\n  program = [\"sh\"]\n"`,
+		"  netbird_group.ops.id,\n",
+		`"netbird_group.fake.id",`,
+		`"data.netbird_group.all.id",`,
+		`"$${file(\"/etc/passwd\")}",`,
+		"# TODO unresolved group ops\n# resource \"x\" \"y\" {}\n",
+	} {
+		if !strings.Contains(hcl, want) {
+			t.Errorf("HCL should contain %q:\n%s", want, hcl)
+		}
+	}
+	if strings.Contains(hcl, "\ndata \"external\"") {
+		t.Errorf("the description escaped its string:\n%s", hcl)
+	}
+
+	body, err := json.Marshal((&JSONWriter{}).convertAttributes(resource.Attributes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"name":"var.ops-team"`,
+		`"${netbird_group.ops.id}"`,
+		`"netbird_group.fake.id"`,
+		`"$${file(\"/etc/passwd\")}"`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("JSON should contain %s:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package lib
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"runtime"
+	"time"
+)
+
+// replaceRetries and replaceRetryDelay bound how long ReplaceFile waits for a
+// destination another process holds open on Windows
+const (
+	replaceRetries    = 10
+	replaceRetryDelay = 50 * time.Millisecond
+)
+
+// InterruptProcess asks a child process to stop. On Unix it is sent an
+// interrupt, so terraform can release the state lock. Windows has no interrupt
+// to send to a single process: a Ctrl-C in the console already reaches every
+// process attached to it, so nothing is sent and the caller's WaitDelay kills
+// the process if it does not exit on its own.
+func InterruptProcess(process *os.Process) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	return process.Signal(os.Interrupt)
+}
+
+// ReplaceFile renames source over destination. On Windows the rename fails
+// while another process, such as a virus scanner or an editor, has the
+// destination open, so it is retried for a short while.
+func ReplaceFile(source, destination string) error {
+	err := os.Rename(source, destination)
+	for attempt := 0; err != nil && runtime.GOOS == "windows" && errors.Is(err, fs.ErrPermission) && attempt < replaceRetries; attempt++ {
+		time.Sleep(replaceRetryDelay)
+		err = os.Rename(source, destination)
+	}
+	return err
+}
+
+// lineEnding returns the line ending used by existing content, "\r\n" for
+// files checked out with Windows line endings and "\n" otherwise
+func lineEnding(content []byte) string {
+	for i, b := range content {
+		if b == '\n' {
+			if i > 0 && content[i-1] == '\r' {
+				return "\r\n"
+			}
+			return "\n"
+		}
+	}
+	return "\n"
+}
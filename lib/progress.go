@@ -0,0 +1,96 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	progressBarWidth = 30
+	// progressLogThreshold is the minimum total for which non-interactive progress
+	// is logged; small batches finish before a percentage would be useful
+	progressLogThreshold = 20
+	progressLogStep      = 25
+)
+
+// Progress reports progress of a batch of work. On a terminal it renders a bar on
+// stderr; otherwise it logs the percentage every 25%. It is safe for concurrent use.
+type Progress struct {
+	mu          sync.Mutex
+	label       string
+	total       int
+	current     int
+	interactive bool
+	out         io.Writer
+	lastLogged  int
+}
+
+// NewProgress creates a progress indicator for total items
+func NewProgress(label string, total int, interactive bool) *Progress {
+	return &Progress{
+		label:       label,
+		total:       total,
+		interactive: interactive,
+		out:         os.Stderr,
+	}
+}
+
+// IsTerminal reports whether the file is an interactive terminal
+func IsTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Increment marks one more item as done
+func (p *Progress) Increment() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.current < p.total {
+		p.current++
+	}
+
+	if p.interactive {
+		p.render()
+		return
+	}
+
+	if p.total < progressLogThreshold {
+		return
+	}
+
+	percent := p.current * 100 / p.total
+	if percent >= p.lastLogged+progressLogStep || p.current == p.total {
+		p.lastLogged = percent - percent%progressLogStep
+		slog.Info("Progress", "task", p.label, "percent", percent, "done", p.current, "total", p.total)
+	}
+}
+
+// Done finishes the progress indicator
+func (p *Progress) Done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.interactive && p.total > 0 {
+		p.render()
+		fmt.Fprintln(p.out)
+	}
+}
+
+// render redraws the progress bar on the current line
+func (p *Progress) render() {
+	filled := 0
+	if p.total > 0 {
+		filled = p.current * progressBarWidth / p.total
+	}
+
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+	fmt.Fprintf(p.out, "\r%-28s [%s] %d/%d", p.label, bar, p.current, p.total)
+}
@@ -0,0 +1,272 @@
+package lib
+
+import (
+	"bufio"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ProviderDefault is a knowledge base entry about an object or attribute the
+// netbirdio/netbird provider creates or reconciles itself. Generating such an
+// object as-is would fail on the first apply or never reach a clean plan.
+type ProviderDefault struct {
+	Type        string // resource type the entry applies to
+	Description string // why the provider owns the object or attribute
+
+	// MinVersion and MaxVersion bound the provider versions the entry applies
+	// to, both inclusive; empty means unbounded
+	MinVersion string
+	MaxVersion string
+
+	// Names lists the objects the provider manages itself; they are handled
+	// with Action instead of being generated as resources
+	Names  []string
+	Action RuleAction
+
+	// Adjust rewrites attributes to the values the provider reconciles them
+	// to and reports whether anything changed
+	Adjust func(attributes map[string]any) bool
+}
+
+// ProviderDefaults is the curated knowledge base. Add an entry, with a version
+// range, when a provider release starts reconciling another default.
+var ProviderDefaults = []ProviderDefault{
+	{
+		Type:        "group",
+		Description: "the All group always contains every peer and can't be changed",
+		Names:       []string{AllGroupName},
+		Action:      RuleDataSource,
+	},
+	{
+		Type:        "policy",
+		Description: "rules for all protocols or ICMP have no ports",
+		Adjust:      dropPortsWithoutProtocolPorts,
+	},
+	{
+		Type:        "policy",
+		Description: "provider releases before 0.0.5 have no port_range block, so ranges are written as ports",
+		MaxVersion:  "0.0.4",
+		Adjust:      portRangesAsPorts,
+	},
+}
+
+// maxExpandedPortRange is the largest port range portRangesAsPorts writes as
+// single ports; larger ones would bloat the rule beyond review
+const maxExpandedPortRange = 64
+
+// portRangesAsPorts moves the port ranges of policy rules into their ports,
+// for provider releases that reject the port_range block. A range of more than
+// maxExpandedPortRange ports can't be expressed and is kept with a warning, so
+// the plan fails instead of silently opening fewer ports.
+func portRangesAsPorts(attributes map[string]any) bool {
+	rules, ok := attributes["rules"].([]any)
+	if !ok {
+		return false
+	}
+
+	changed := false
+	for _, rule := range rules {
+		ruleMap, ok := rule.(map[string]any)
+		if !ok {
+			continue
+		}
+		portRanges, ok := ruleMap["port_ranges"].([]map[string]any)
+		if !ok {
+			continue
+		}
+
+		ports, _ := ruleMap["ports"].([]string)
+		kept := make([]map[string]any, 0)
+		for _, portRange := range portRanges {
+			start, startOK := portRange["start"].(int)
+			end, endOK := portRange["end"].(int)
+			if !startOK || !endOK || end < start || end-start >= maxExpandedPortRange {
+				slog.Warn("Port range can't be written for the pinned provider version, require 0.0.5 or later", "policy", attributes["name"], "rule", ruleMap["name"], "start", portRange["start"], "end", portRange["end"])
+				kept = append(kept, portRange)
+				continue
+			}
+			for port := start; port <= end; port++ {
+				ports = appendMissing(ports, strconv.Itoa(port))
+			}
+			changed = true
+		}
+
+		if len(ports) > 0 {
+			ruleMap["ports"] = ports
+		}
+		if len(kept) > 0 {
+			ruleMap["port_ranges"] = kept
+		} else {
+			delete(ruleMap, "port_ranges")
+		}
+	}
+	return changed
+}
+
+// appendMissing appends a value to a list unless it is already in it
+func appendMissing(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// dropPortsWithoutProtocolPorts removes ports from policy rules whose protocol
+// has none; the provider drops them and would plan to do so on every run
+func dropPortsWithoutProtocolPorts(attributes map[string]any) bool {
+	rules, ok := attributes["rules"].([]any)
+	if !ok {
+		return false
+	}
+
+	changed := false
+	for _, rule := range rules {
+		ruleMap, ok := rule.(map[string]any)
+		if !ok || (ruleMap["protocol"] != "all" && ruleMap["protocol"] != "icmp") {
+			continue
+		}
+		for _, key := range []string{"ports", "port_ranges"} {
+			if _, exists := ruleMap[key]; exists {
+				delete(ruleMap, key)
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// AppliesTo reports whether the entry covers a provider version constraint.
+// The lowest version the constraint allows is checked.
+func (d ProviderDefault) AppliesTo(constraint string) bool {
+	version := ConstraintVersion(constraint)
+	if version == nil {
+		return true
+	}
+	if d.MinVersion != "" && compareVersions(version, parseVersion(d.MinVersion)) < 0 {
+		return false
+	}
+	if d.MaxVersion != "" && compareVersions(version, parseVersion(d.MaxVersion)) > 0 {
+		return false
+	}
+	return true
+}
+
+// ProviderManagedObject returns the knowledge base entry for an object the
+// provider manages itself, or nil
+func ProviderManagedObject(constraint, resourceType, name string) *ProviderDefault {
+	for i, entry := range ProviderDefaults {
+		if entry.Type != resourceType || !entry.AppliesTo(constraint) {
+			continue
+		}
+		for _, managed := range entry.Names {
+			if managed == name {
+				return &ProviderDefaults[i]
+			}
+		}
+	}
+	return nil
+}
+
+// AdjustProviderDefaults rewrites the attributes of a resource with every
+// applicable knowledge base entry and returns the descriptions of those that
+// changed something
+func AdjustProviderDefaults(constraint, resourceType string, attributes map[string]any) []string {
+	adjusted := make([]string, 0)
+	for _, entry := range ProviderDefaults {
+		if entry.Type != resourceType || entry.Adjust == nil || !entry.AppliesTo(constraint) {
+			continue
+		}
+		if entry.Adjust(attributes) {
+			adjusted = append(adjusted, entry.Description)
+		}
+	}
+	return adjusted
+}
+
+var versionPattern = regexp.MustCompile(`\d+(\.\d+){0,2}`)
+
+// ProviderLockFile is the dependency lock file terraform init writes
+const ProviderLockFile = ".terraform.lock.hcl"
+
+// KnowledgeBaseVersion returns the provider version the knowledge base entries
+// are checked against: the locked version if the output directory has a lock
+// file, otherwise the version constraint
+func (c *Config) KnowledgeBaseVersion() string {
+	if c.LockedVersion != "" {
+		return c.LockedVersion
+	}
+	return c.ProviderVersion
+}
+
+// LockedProviderVersion returns the netbirdio/netbird provider version the
+// lock file in a directory pins, or "" if there is none. It is the version
+// terraform actually installs, which a constraint such as "~> 0.0.5" leaves
+// open.
+func LockedProviderVersion(dir string) string {
+	file, err := os.Open(filepath.Join(dir, ProviderLockFile))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	inProvider := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "provider "):
+			inProvider = strings.Contains(line, "/netbirdio/netbird\"")
+		case inProvider && strings.HasPrefix(line, "version"):
+			_, value, found := strings.Cut(line, "=")
+			if found {
+				return strings.Trim(strings.TrimSpace(value), `"`)
+			}
+		}
+	}
+	return ""
+}
+
+// ConstraintVersion returns the first version in a constraint such as
+// "~> 0.0.5" or ">= 0.1, < 1.0", or nil if there is none
+func ConstraintVersion(constraint string) []int {
+	match := versionPattern.FindString(constraint)
+	if match == "" {
+		return nil
+	}
+	return parseVersion(match)
+}
+
+// parseVersion parses a major.minor.patch version, missing parts being zero
+func parseVersion(version string) []int {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		numbers[i], _ = strconv.Atoi(strings.SplitN(part, "-", 2)[0])
+	}
+	return numbers
+}
+
+// VersionOlder reports whether a major.minor.patch version is older than
+// another, e.g. 0.25.9 than 0.26.0
+func VersionOlder(version, than string) bool {
+	return compareVersions(parseVersion(version), parseVersion(than)) < 0
+}
+
+// compareVersions compares two parsed versions like strings.Compare
+func compareVersions(a, b []int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,108 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestProviderDefaultAppliesTo(t *testing.T) {
+	entry := ProviderDefault{MinVersion: "0.0.4", MaxVersion: "0.1.0"}
+	tests := map[string]bool{
+		"~> 0.0.5":        true,
+		">= 0.1, < 1.0":   true,
+		"0.0.3":           false,
+		"~> 0.2":          false,
+		"":                true,
+		"latest-unpinned": true,
+	}
+
+	for constraint, want := range tests {
+		if got := entry.AppliesTo(constraint); got != want {
+			t.Errorf("AppliesTo(%q) = %v, want %v", constraint, got, want)
+		}
+	}
+}
+
+func TestProviderManagedObject(t *testing.T) {
+	if entry := ProviderManagedObject(DefaultProviderVersion, "group", "All"); entry == nil || entry.Action != RuleDataSource {
+		t.Errorf("the All group should be a data source, got %+v", entry)
+	}
+	if entry := ProviderManagedObject(DefaultProviderVersion, "group", "all-hands"); entry != nil {
+		t.Errorf("unexpected entry for a regular group: %+v", entry)
+	}
+}
+
+func TestAdjustProviderDefaults(t *testing.T) {
+	attributes := map[string]any{"rules": []any{
+		map[string]any{"protocol": "all", "ports": []string{"22"}},
+		map[string]any{"protocol": "tcp", "ports": []string{"22"}},
+	}}
+
+	adjusted := AdjustProviderDefaults(DefaultProviderVersion, "policy", attributes)
+	if len(adjusted) != 1 {
+		t.Fatalf("expected one adjustment, got %v", adjusted)
+	}
+
+	rules := attributes["rules"].([]any)
+	if _, exists := rules[0].(map[string]any)["ports"]; exists {
+		t.Error("ports of an all-protocol rule were kept")
+	}
+	if _, exists := rules[1].(map[string]any)["ports"]; !exists {
+		t.Error("ports of a tcp rule were dropped")
+	}
+}
+
+func TestPortRangesAsPorts(t *testing.T) {
+	rule := map[string]any{
+		"name":        "web",
+		"protocol":    "tcp",
+		"ports":       []string{"22", "8080"},
+		"port_ranges": []map[string]any{{"start": 8080, "end": 8082}, {"start": 1024, "end": 65535}},
+	}
+	attributes := map[string]any{"name": "Web", "rules": []any{rule}}
+
+	if adjusted := AdjustProviderDefaults("~> 0.0.4", "policy", attributes); len(adjusted) != 1 {
+		t.Fatalf("expected one adjustment for 0.0.4, got %v", adjusted)
+	}
+	if got := rule["ports"]; !reflect.DeepEqual(got, []string{"22", "8080", "8081", "8082"}) {
+		t.Errorf("ports = %v, want the small range expanded", got)
+	}
+	if got := rule["port_ranges"]; !reflect.DeepEqual(got, []map[string]any{{"start": 1024, "end": 65535}}) {
+		t.Errorf("port_ranges = %v, want the range too large to expand kept", got)
+	}
+
+	rule = map[string]any{"protocol": "tcp", "port_ranges": []map[string]any{{"start": 8080, "end": 8082}}}
+	if adjusted := AdjustProviderDefaults(DefaultProviderVersion, "policy", map[string]any{"rules": []any{rule}}); len(adjusted) != 0 {
+		t.Errorf("port ranges were adjusted for %s: %v", DefaultProviderVersion, adjusted)
+	}
+}
+
+func TestLockedProviderVersion(t *testing.T) {
+	dir := t.TempDir()
+	if version := LockedProviderVersion(dir); version != "" {
+		t.Errorf("LockedProviderVersion() without a lock file = %q", version)
+	}
+
+	lock := `provider "registry.terraform.io/hashicorp/null" {
+  version = "3.2.2"
+}
+
+provider "registry.terraform.io/netbirdio/netbird" {
+  version     = "0.0.4"
+  constraints = "~> 0.0.4"
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, ProviderLockFile), []byte(lock), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if version := LockedProviderVersion(dir); version != "0.0.4" {
+		t.Errorf("LockedProviderVersion() = %q, want 0.0.4", version)
+	}
+
+	config := &Config{ProviderVersion: DefaultProviderVersion, LockedVersion: "0.0.4"}
+	if version := config.KnowledgeBaseVersion(); version != "0.0.4" {
+		t.Errorf("KnowledgeBaseVersion() = %q, want the locked version", version)
+	}
+}
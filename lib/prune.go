@@ -0,0 +1,86 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// PrunedResource is an object in the existing state that the run no longer
+// generates, usually because it was deleted in the NetBird console
+type PrunedResource struct {
+	Type    string `json:"type"`
+	ID      string `json:"id"`
+	Address string `json:"address"`
+}
+
+// FindPrunedResources returns the objects of the existing state, per resource
+// type and object ID, that are not generated as managed resources. Only types
+// that were fetched are compared: a type whose fetch failed or that is excluded
+// would otherwise look deleted as a whole.
+func (tg *TerraformGenerator) FindPrunedResources(stateObjects map[string]map[string]string) []PrunedResource {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+
+	generated := make(map[string]bool, len(tg.resources))
+	for _, resource := range tg.resources {
+		if !resource.IsData {
+			generated[resource.Type+"/"+resource.ID] = true
+		}
+	}
+
+	pruned := make([]PrunedResource, 0)
+	for resourceType, objects := range stateObjects {
+		if _, fetched := tg.discovered[resourceType]; !fetched || tg.config.IsExcluded(resourceType) {
+			continue
+		}
+		for id, name := range objects {
+			if generated[resourceType+"/"+id] {
+				continue
+			}
+			pruned = append(pruned, PrunedResource{Type: resourceType, ID: id, Address: fmt.Sprintf("netbird_%s.%s", resourceType, name)})
+		}
+	}
+	sort.Slice(pruned, func(i, j int) bool { return pruned[i].Address < pruned[j].Address })
+	return pruned
+}
+
+// WriteRemovedBlocks writes a removed block for each pruned object into the
+// configuration holding its state, so terraform forgets the object instead of
+// planning to destroy it. A removed file left by an earlier run is deleted
+// from configurations with nothing to prune.
+func (tg *TerraformGenerator) WriteRemovedBlocks(pruned []PrunedResource) error {
+	addresses := make(map[string][]string)
+	for _, resource := range pruned {
+		dir := tg.ModuleDir(resource.Type)
+		addresses[dir] = append(addresses[dir], resource.Address)
+	}
+
+	for _, pattern := range []string{"removed.tf*", "*/removed.tf*"} {
+		stale, err := filepath.Glob(filepath.Join(tg.outputDir, pattern))
+		if err != nil {
+			return err
+		}
+		for _, path := range stale {
+			if len(addresses[filepath.Dir(path)]) > 0 {
+				continue
+			}
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+	}
+
+	for dir, dirAddresses := range addresses {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+		if err := tg.writer.WriteRemovedBlocks(dir, dirAddresses); err != nil {
+			return fmt.Errorf("failed to write removed blocks: %w", err)
+		}
+	}
+	return nil
+}
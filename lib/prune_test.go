@@ -0,0 +1,43 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindPrunedResources(t *testing.T) {
+	outputDir := t.TempDir()
+	generator := NewTerraformGenerator(outputDir, &Config{})
+	generator.RecordDiscovered("group", 1)
+	generator.AddResource("group", "devs", map[string]any{"id": "g1", "name": "devs"})
+
+	// The policy fetch failed, so its state objects are not compared
+	pruned := generator.FindPrunedResources(map[string]map[string]string{
+		"group":  {"g1": "devs", "g2": "contractors"},
+		"policy": {"p1": "allow_all"},
+	})
+	if len(pruned) != 1 || pruned[0].Address != "netbird_group.contractors" || pruned[0].ID != "g2" {
+		t.Fatalf("FindPrunedResources() = %+v, want only netbird_group.contractors", pruned)
+	}
+
+	if err := generator.WriteRemovedBlocks(pruned); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := os.ReadFile(filepath.Join(outputDir, "removed.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(removed), "from = netbird_group.contractors") || !strings.Contains(string(removed), "destroy = false") {
+		t.Errorf("removed.tf should forget netbird_group.contractors:\n%s", removed)
+	}
+
+	// Once nothing is left to prune, the file goes away
+	if err := generator.WriteRemovedBlocks(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "removed.tf")); !os.IsNotExist(err) {
+		t.Errorf("removed.tf should be deleted when nothing is pruned, got %v", err)
+	}
+}
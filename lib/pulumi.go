@@ -0,0 +1,45 @@
+package lib
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// pulumiImportFile is the bulk import file read by `pulumi import -f`
+const pulumiImportFile = "pulumi-import.json"
+
+// PulumiImportResource is a resource of a Pulumi bulk import file
+type PulumiImportResource struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+// PulumiType returns the Pulumi type token of a resource type in the package
+// bridged from the netbirdio/netbird provider, e.g. netbird:index/postureCheck:PostureCheck
+func PulumiType(resourceType string) string {
+	return "netbird:index/" + camelCase(resourceType) + ":" + pascalCase(resourceType)
+}
+
+// GeneratePulumiImport writes pulumi-import.json with every managed resource,
+// so the account can be adopted with `pulumi import -f` instead of terraform.
+// Resources keep their Terraform names as Pulumi names; data sources are not
+// imported.
+func (tg *TerraformGenerator) GeneratePulumiImport() error {
+	importCommands := tg.GetImportCommands()
+	resources := make([]PulumiImportResource, 0, len(importCommands))
+	for _, cmd := range importCommands {
+		_, name, _ := strings.Cut(cmd.ResourceAddress, ".")
+		resources = append(resources, PulumiImportResource{
+			Type: PulumiType(cmd.ResourceType),
+			Name: name,
+			ID:   cmd.ResourceID,
+		})
+	}
+
+	content, err := json.MarshalIndent(map[string]any{"resources": resources}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return tg.WriteFile(pulumiImportFile, append(content, '\n'))
+}
@@ -0,0 +1,121 @@
+package lib
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGeneratePulumiImport(t *testing.T) {
+	outputDir := t.TempDir()
+	generator := NewTerraformGenerator(outputDir, &Config{})
+	generator.AddResource("posture_check", "min_version", map[string]any{"id": "pc1", "name": "min version"})
+	generator.AddDataSource("group", "all", map[string]any{"id": "g1"})
+
+	if err := generator.GeneratePulumiImport(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(outputDir, "pulumi-import.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file struct {
+		Resources []PulumiImportResource `json:"resources"`
+	}
+	if err := json.Unmarshal(content, &file); err != nil {
+		t.Fatal(err)
+	}
+
+	want := PulumiImportResource{Type: "netbird:index/postureCheck:PostureCheck", Name: "min_version", ID: "pc1"}
+	if len(file.Resources) != 1 || file.Resources[0] != want {
+		t.Errorf("pulumi-import.json resources = %+v, want only %+v", file.Resources, want)
+	}
+}
+
+func TestPulumiWriter(t *testing.T) {
+	outputDir := t.TempDir()
+	writer, err := NewOutputWriter("pulumi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WriteProvider(outputDir, &Config{ServerURL: "https://api.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	policy := TerraformResource{Type: "policy", Name: "dev_access", ID: "p1", Comments: []string{"TODO unresolved group gZ"}, Lifecycle: &Lifecycle{IgnoreChanges: []string{"source_posture_checks"}, PreventDestroy: true}, Attributes: map[string]any{
+		"id":          "p1",
+		"name":        "Dev access",
+		"description": "costs ${price}",
+		"rules": []any{map[string]any{
+			"sources":      []any{Expression("netbird_group.dev_team.id"), "gZ"},
+			"destinations": []any{Expression("data.netbird_group.all.id")},
+			"port_ranges":  []any{map[string]any{"start": 8000, "end": 8080}},
+		}},
+	}}
+	if err := writer.WriteResources(outputDir, "policy", []TerraformResource{policy}); err != nil {
+		t.Fatal(err)
+	}
+	groups := []TerraformResource{
+		{Type: "group", Name: "all", IsData: true, Attributes: map[string]any{"id": "g1"}},
+		{Type: "group", Name: "dev_team", ID: "g2", Attributes: map[string]any{"id": "g2", "name": "Dev Team"}},
+	}
+	if err := writer.WriteResources(outputDir, "group", groups); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "Pulumi.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseYAML(content)
+	if err != nil {
+		t.Fatalf("Pulumi.yaml is not valid YAML: %v\n%s", err, content)
+	}
+	program := parsed.(map[string]any)
+	resources := program["resources"].(map[string]any)
+
+	if got := resources["netbird"]; !reflect.DeepEqual(got, map[string]any{"type": "pulumi:providers:netbird", "properties": map[string]any{"managementUrl": "https://api.example.com"}}) {
+		t.Errorf("provider = %v", got)
+	}
+	want := map[string]any{
+		"type": "netbird:index/policy:Policy",
+		"properties": map[string]any{
+			"name":        "Dev access",
+			"description": "costs $${price}",
+			"rules": []any{map[string]any{
+				"sources":      []any{"${group_dev_team.id}", "gZ"},
+				"destinations": []any{"${data_group_all.id}"},
+				"portRanges":   []any{map[string]any{"start": int64(8000), "end": int64(8080)}},
+			}},
+		},
+		"options": map[string]any{
+			"provider":      "${netbird}",
+			"import":        "p1",
+			"ignoreChanges": []any{"sourcePostureChecks"},
+			"protect":       true,
+		},
+	}
+	if got := resources["policy_dev_access"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("policy_dev_access =\n%v\nwant\n%v", got, want)
+	}
+	if got := resources["group_dev_team"].(map[string]any)["options"].(map[string]any)["import"]; got != "g2" {
+		t.Errorf("group_dev_team imports %v, want g2", got)
+	}
+
+	lookup := map[string]any{"fn::invoke": map[string]any{
+		"function":  "netbird:index/getGroup:getGroup",
+		"arguments": map[string]any{"id": "g1"},
+		"options":   map[string]any{"provider": "${netbird}"},
+	}}
+	if got := program["variables"].(map[string]any)["data_group_all"]; !reflect.DeepEqual(got, lookup) {
+		t.Errorf("data_group_all = %v, want %v", got, lookup)
+	}
+	if !strings.Contains(string(content), "  # TODO unresolved group gZ\n  policy_dev_access:\n") {
+		t.Errorf("the comment should precede the policy:\n%s", content)
+	}
+	if strings.Index(string(content), "group_dev_team:") > strings.Index(string(content), "policy_dev_access:") {
+		t.Error("groups should be declared before the policies referring to them")
+	}
+}
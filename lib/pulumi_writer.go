@@ -0,0 +1,297 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PulumiFormat is the --format of the Pulumi YAML program
+const PulumiFormat = "pulumi"
+
+// pulumiProgramFile is the project file holding the Pulumi YAML program
+const pulumiProgramFile = "Pulumi.yaml"
+
+// pulumiProvider is the logical name of the explicit provider resource every
+// resource and function uses
+const pulumiProvider = "netbird"
+
+// PulumiWriter writes the resources as a Pulumi YAML program, for teams
+// managing infrastructure with Pulumi instead of Terraform. The program uses
+// the package bridged from the netbirdio/netbird provider. Managed resources
+// are adopted with the import resource option on the next `pulumi up`, data
+// sources become function calls.
+type PulumiWriter struct {
+	config    *Config
+	resources map[string][]TerraformResource // per resource type
+}
+
+// Format returns the format name
+func (w *PulumiWriter) Format() string {
+	return PulumiFormat
+}
+
+// IsProgramFormat reports whether an output format writes a program that
+// adopts the objects itself, a CDKTF application or a Pulumi program, rather
+// than Terraform configuration files
+func IsProgramFormat(format string) bool {
+	return IsCDKTFFormat(format) || format == PulumiFormat
+}
+
+// WriteProvider writes a program without resources
+func (w *PulumiWriter) WriteProvider(outputDir string, config *Config) error {
+	w.config = config
+	return w.writeProgram(outputDir)
+}
+
+// WriteResources adds the resources of one type to the program and rewrites
+// it, since every resource lives in the same project file
+func (w *PulumiWriter) WriteResources(outputDir, resourceType string, resources []TerraformResource) error {
+	if w.resources == nil {
+		w.resources = make(map[string][]TerraformResource)
+	}
+	w.resources[resourceType] = resources
+	return w.writeProgram(outputDir)
+}
+
+// WriteBackend is not supported; a Pulumi stack keeps its state in the
+// backend `pulumi login` selected
+func (w *PulumiWriter) WriteBackend(outputDir string, backend *BackendConfig, module string) error {
+	return fmt.Errorf("%s output does not support state backends", w.Format())
+}
+
+// WriteRunMetadata does nothing; the program is never run by the importer
+func (w *PulumiWriter) WriteRunMetadata(outputDir string, metadata RunMetadata) error {
+	return nil
+}
+
+// WriteImportBlocks is not supported; the resources import themselves
+func (w *PulumiWriter) WriteImportBlocks(outputDir string, importCommands []ImportCommand) error {
+	return fmt.Errorf("%s output imports with the import resource option, not import blocks", w.Format())
+}
+
+// WriteRemovedBlocks is not supported
+func (w *PulumiWriter) WriteRemovedBlocks(outputDir string, addresses []string) error {
+	return fmt.Errorf("%s output does not support removed blocks", w.Format())
+}
+
+// PulumiFunction returns the Pulumi token of the function reading a data
+// source, e.g. netbird:index/getGroup:getGroup
+func PulumiFunction(resourceType string) string {
+	return "netbird:index/get" + pascalCase(resourceType) + ":get" + pascalCase(resourceType)
+}
+
+// pulumiName returns the logical name of a resource in the program. Resources
+// and variables share one namespace, so the name includes the type.
+func pulumiName(resource TerraformResource) string {
+	name := resource.Type + "_" + resource.Name
+	if resource.IsData {
+		return "data_" + name
+	}
+	return name
+}
+
+// writeProgram writes Pulumi.yaml with the provider, a variable per data
+// source and a resource per managed resource, in import order
+func (w *PulumiWriter) writeProgram(outputDir string) error {
+	types := make([]string, 0, len(w.resources))
+	for resourceType := range w.resources {
+		types = append(types, resourceType)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return importRank(types[i]) < importRank(types[j]) || importRank(types[i]) == importRank(types[j]) && types[i] < types[j]
+	})
+
+	program := &pulumiProgram{names: make(map[string]string)}
+	var data, managed []TerraformResource
+	for _, resourceType := range types {
+		for _, resource := range w.resources[resourceType] {
+			address := fmt.Sprintf("netbird_%s.%s", resource.Type, resource.Name)
+			if resource.IsData {
+				program.names["data."+address] = pulumiName(resource)
+				data = append(data, resource)
+			} else {
+				program.names[address] = pulumiName(resource)
+				managed = append(managed, resource)
+			}
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString("# NetBird Pulumi program\n# Generated by NetBird terraformer Terraformer\n\n")
+	out.WriteString("name: netbird\nruntime: yaml\n")
+
+	if len(data) > 0 {
+		out.WriteString("\nvariables:\n")
+		for _, resource := range data {
+			out.WriteString(program.comments(resource, "  "))
+			fmt.Fprintf(&out, "  %s:\n", pulumiName(resource))
+			out.WriteString("    fn::invoke:\n")
+			fmt.Fprintf(&out, "      function: %s\n", PulumiFunction(resource.Type))
+			// Data sources are looked up by their id
+			out.WriteString("      arguments:\n")
+			if id, exists := resource.Attributes["id"]; exists {
+				if _, isExpression := id.(Expression); !isExpression {
+					id = fmt.Sprint(id)
+				}
+				fmt.Fprintf(&out, "        id: %s\n", program.scalar(id))
+			}
+			out.WriteString(program.properties(resource.Attributes, "        "))
+			fmt.Fprintf(&out, "      options:\n        provider: ${%s}\n", pulumiProvider)
+		}
+	}
+
+	// The token is never written; the provider reads NB_PAT
+	out.WriteString("\nresources:\n")
+	fmt.Fprintf(&out, "  %s:\n    type: pulumi:providers:netbird\n    properties:\n", pulumiProvider)
+	serverURL := ""
+	if w.config != nil {
+		serverURL = w.config.ServerURL
+	}
+	fmt.Fprintf(&out, "      managementUrl: %s\n", QuoteYAML(pulumiLiteral(serverURL)))
+	for _, resource := range managed {
+		out.WriteString("\n")
+		out.WriteString(program.comments(resource, "  "))
+		fmt.Fprintf(&out, "  %s:\n", pulumiName(resource))
+		fmt.Fprintf(&out, "    type: %s\n", PulumiType(resource.Type))
+		if properties := program.properties(resource.Attributes, "      "); properties != "" {
+			out.WriteString("    properties:\n")
+			out.WriteString(properties)
+		}
+		out.WriteString("    options:\n")
+		fmt.Fprintf(&out, "      provider: ${%s}\n", pulumiProvider)
+		if resource.ID != "" {
+			fmt.Fprintf(&out, "      import: %s\n", QuoteYAML(resource.ID))
+		}
+		if lifecycle := resource.Lifecycle; lifecycle != nil {
+			if len(lifecycle.IgnoreChanges) > 0 {
+				names := make([]any, 0, len(lifecycle.IgnoreChanges))
+				for _, name := range lifecycle.IgnoreChanges {
+					names = append(names, camelCase(name))
+				}
+				fmt.Fprintf(&out, "      ignoreChanges: %s\n", program.list(names))
+			}
+			if lifecycle.PreventDestroy {
+				out.WriteString("      protect: true\n")
+			}
+		}
+	}
+
+	return os.WriteFile(filepath.Join(outputDir, pulumiProgramFile), []byte(out.String()), 0644)
+}
+
+// pulumiProgram renders the YAML of resource properties
+type pulumiProgram struct {
+	names map[string]string // logical names per Terraform address
+}
+
+// comments renders the comment lines above a resource or variable
+func (p *pulumiProgram) comments(resource TerraformResource, indent string) string {
+	var out strings.Builder
+	for _, line := range commentLines(resource.Comments) {
+		fmt.Fprintf(&out, "%s# %s\n", indent, line)
+	}
+	return out.String()
+}
+
+// properties renders attributes as the lines of a mapping at an indentation.
+// The bridged package names properties in camel case and keeps the plural of
+// repeated blocks, e.g. rules instead of rule.
+func (p *pulumiProgram) properties(attributes map[string]any, indent string) string {
+	var out strings.Builder
+	for _, key := range sortedKeys(attributes) {
+		if !isWritableAttribute(key) {
+			continue
+		}
+		name := camelCase(key)
+		switch v := attributes[key].(type) {
+		case string, Expression:
+			if value := p.scalar(v); value != "" {
+				fmt.Fprintf(&out, "%s%s: %s\n", indent, name, value)
+			}
+		case bool, int, int64, float64:
+			fmt.Fprintf(&out, "%s%s: %v\n", indent, name, v)
+		case []string:
+			if list := p.list(stringItems(v)); list != "" {
+				fmt.Fprintf(&out, "%s%s: %s\n", indent, name, list)
+			}
+		case []any:
+			blocks, items := splitList(v)
+			if len(blocks) > 0 {
+				out.WriteString(p.blocks(name, blocks, indent))
+			} else if list := p.list(items); list != "" {
+				fmt.Fprintf(&out, "%s%s: %s\n", indent, name, list)
+			}
+		case []map[string]any:
+			out.WriteString(p.blocks(name, v, indent))
+		case map[string]any:
+			if nested := p.properties(v, indent+"  "); nested != "" {
+				fmt.Fprintf(&out, "%s%s:\n%s", indent, name, nested)
+			}
+		}
+	}
+	return out.String()
+}
+
+// blocks renders repeated nested blocks as a sequence of mappings
+func (p *pulumiProgram) blocks(name string, blocks []map[string]any, indent string) string {
+	if len(blocks) == 0 {
+		return ""
+	}
+	var out strings.Builder
+	fmt.Fprintf(&out, "%s%s:\n", indent, name)
+	itemIndent := indent + "    "
+	for _, block := range blocks {
+		properties := p.properties(block, itemIndent)
+		if properties == "" {
+			fmt.Fprintf(&out, "%s  - {}\n", indent)
+			continue
+		}
+		// The first property follows the dash
+		out.WriteString(indent + "  - " + strings.TrimPrefix(properties, itemIndent))
+	}
+	return out.String()
+}
+
+// list renders strings and expressions as a flow sequence, or nothing for an
+// empty list
+func (p *pulumiProgram) list(items []any) string {
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		if value := p.scalar(item); value != "" {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// scalar renders a literal, or a reference to another resource or variable as
+// an interpolation. Expressions the program has no name for, which only
+// generator layouts Pulumi output rejects produce, are written as literals.
+func (p *pulumiProgram) scalar(value any) string {
+	switch v := value.(type) {
+	case Expression:
+		if index := strings.LastIndex(string(v), "."); index >= 0 {
+			if name, exists := p.names[string(v[:index])]; exists {
+				return QuoteYAML("${" + name + "." + camelCase(string(v[index+1:])) + "}")
+			}
+		}
+		return QuoteYAML(pulumiLiteral(string(v)))
+	case string:
+		if v != "" {
+			return QuoteYAML(pulumiLiteral(v))
+		}
+	}
+	return ""
+}
+
+// pulumiLiteral escapes the interpolation sequence of Pulumi YAML, so API
+// strings containing ${ stay literals
+func pulumiLiteral(value string) string {
+	return strings.ReplaceAll(value, "${", "$${")
+}
@@ -0,0 +1,206 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// reconcileDocument is the subset of `terraform show -json <plan>` Reconcile
+// reads: the refreshed state of each resource and the values its
+// configuration would set
+type reconcileDocument struct {
+	ResourceChanges []struct {
+		Address string `json:"address"`
+		Mode    string `json:"mode"`
+		Change  struct {
+			Actions         []string        `json:"actions"`
+			Before          map[string]any  `json:"before"`
+			After           map[string]any  `json:"after"`
+			AfterUnknown    map[string]any  `json:"after_unknown"`
+			BeforeSensitive json.RawMessage `json:"before_sensitive"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+}
+
+// Reconciliation is the outcome of Reconcile. Adopted lists the attributes,
+// as address.attribute, whose generated value was replaced by the one in the
+// state; Unreconcilable lists the changes left, with the reason.
+type Reconciliation struct {
+	Adopted        []string
+	Unreconcilable []string
+}
+
+// Reconcile reads the plan of imported resources and adopts the values of the
+// refreshed state for the attributes the plan would change, so the next plan
+// of the rewritten configuration is empty. Only literal strings, numbers,
+// bools and lists of strings are adopted: an attribute holding a reference to
+// another resource, a nested block, a sensitive value or one unknown until
+// apply is left alone and reported, as is a resource the plan would create or
+// destroy. An attribute whose generated value already equals the state's is
+// reported too, since the provider does not accept the value it reads.
+func (tg *TerraformGenerator) Reconcile(planJSON []byte) (*Reconciliation, error) {
+	var plan reconcileDocument
+	if err := json.Unmarshal(planJSON, &plan); err != nil {
+		return nil, fmt.Errorf("failed to decode plan: %w", err)
+	}
+
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+
+	byAddress := make(map[string]int, len(tg.resources))
+	for i, resource := range tg.resources {
+		if !resource.IsData {
+			byAddress[resource.Address()] = i
+		}
+	}
+
+	result := &Reconciliation{Adopted: make([]string, 0), Unreconcilable: make([]string, 0)}
+	unreconcilable := func(address, reason string) {
+		result.Unreconcilable = append(result.Unreconcilable, address+" ("+reason+")")
+	}
+	for _, resourceChange := range plan.ResourceChanges {
+		address, change := resourceChange.Address, resourceChange.Change
+		if resourceChange.Mode == "data" {
+			continue
+		}
+		switch strings.Join(change.Actions, ",") {
+		case "no-op", "read", "forget":
+			continue
+		case "update", "delete,create", "create,delete":
+		default:
+			unreconcilable(address, "would be "+strings.Join(change.Actions, " and ")+"d")
+			continue
+		}
+		index, generated := byAddress[address]
+		if !generated {
+			unreconcilable(address, "not generated by this run")
+			continue
+		}
+
+		attributes := tg.resources[index].Attributes
+		sensitive := sensitiveAttributes(change.BeforeSensitive)
+		for _, attribute := range changedAttributes(change.Before, change.After) {
+			path := address + "." + attribute
+			current, set := attributes[attribute]
+			value, safe := stateValue(change.Before[attribute])
+			switch {
+			case !isWritableAttribute(attribute):
+				continue
+			case change.AfterUnknown[attribute] == true:
+				unreconcilable(path, "unknown until apply")
+			case sensitive[attribute]:
+				unreconcilable(path, "sensitive")
+			case change.Before[attribute] == nil:
+				unreconcilable(path, "not set on the object")
+			case set && holdsReference(current):
+				unreconcilable(path, "references another resource")
+			case !safe:
+				unreconcilable(path, "not a literal value")
+			case set && reflect.DeepEqual(normalizedValue(current), value):
+				unreconcilable(path, "the provider does not accept the value it reads")
+			default:
+				attributes[attribute] = value
+				result.Adopted = append(result.Adopted, path)
+			}
+		}
+	}
+	return result, nil
+}
+
+// changedAttributes returns the attributes whose value differs between the
+// state and the configuration, sorted
+func changedAttributes(before, after map[string]any) []string {
+	changed := make([]string, 0)
+	for attribute, value := range before {
+		if !reflect.DeepEqual(value, after[attribute]) {
+			changed = append(changed, attribute)
+		}
+	}
+	for attribute := range after {
+		if _, exists := before[attribute]; !exists && after[attribute] != nil {
+			changed = append(changed, attribute)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// sensitiveAttributes returns the top-level attributes before_sensitive marks,
+// which is false when nothing is sensitive
+func sensitiveAttributes(raw json.RawMessage) map[string]bool {
+	var marks map[string]any
+	if json.Unmarshal(raw, &marks) != nil {
+		return nil
+	}
+	sensitive := make(map[string]bool, len(marks))
+	for attribute, mark := range marks {
+		sensitive[attribute] = mark != false
+	}
+	return sensitive
+}
+
+// stateValue converts a value of the state to a generated attribute value,
+// and reports whether it is a literal that can be adopted: a string, number,
+// bool or list of strings
+func stateValue(value any) (any, bool) {
+	switch typed := value.(type) {
+	case string, bool, float64:
+		return normalizedValue(typed), true
+	case []any:
+		items := make([]string, 0, len(typed))
+		for _, item := range typed {
+			str, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			items = append(items, str)
+		}
+		return items, true
+	}
+	return nil, false
+}
+
+// normalizedValue returns integral numbers as int and lists of strings as
+// []string, the types handlers generate, so values compare equal
+func normalizedValue(value any) any {
+	switch typed := value.(type) {
+	case float64:
+		if typed == float64(int(typed)) {
+			return int(typed)
+		}
+	case int64:
+		return int(typed)
+	case []any:
+		if items, ok := stateValue(typed); ok {
+			return items
+		}
+	}
+	return value
+}
+
+// holdsReference reports whether a generated value is or contains a Terraform
+// reference, or a placeholder not resolved yet
+func holdsReference(value any) bool {
+	switch typed := value.(type) {
+	case Expression:
+		return true
+	case string:
+		return strings.Contains(typed, referenceMarker)
+	case []string:
+		for _, item := range typed {
+			if holdsReference(item) {
+				return true
+			}
+		}
+	case []any:
+		for _, item := range typed {
+			if holdsReference(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReconcile(t *testing.T) {
+	generator := NewTerraformGenerator(t.TempDir(), &Config{})
+	generator.AddResource("group", "developers", map[string]any{"id": "g1", "name": "Developers"})
+	generator.AddResource("policy", "ssh", map[string]any{
+		"id":                    "pol1",
+		"name":                  "SSH",
+		"description":           "generated",
+		"enabled":               true,
+		"source_posture_checks": []any{Expression("netbird_posture_check.edr.id")},
+		"rules":                 []any{map[string]any{"name": "ssh"}},
+	})
+	generator.AddResource("route", "office", map[string]any{"id": "r1", "metric": 9999})
+
+	plan := []byte(`{"resource_changes": [
+		{"address": "netbird_group.developers", "mode": "managed", "change": {"actions": ["no-op"]}},
+		{"address": "netbird_policy.ssh", "mode": "managed", "change": {
+			"actions": ["update"],
+			"before": {"id": "pol1", "name": "SSH", "description": "", "enabled": false, "source_posture_checks": ["pc2"], "rules": [{"name": "ssh", "action": "drop"}]},
+			"after": {"id": "pol1", "name": "SSH", "description": "generated", "enabled": true, "source_posture_checks": ["pc1"], "rules": [{"name": "ssh", "action": "accept"}]},
+			"after_unknown": {},
+			"before_sensitive": false
+		}},
+		{"address": "netbird_route.office", "mode": "managed", "change": {
+			"actions": ["update"],
+			"before": {"id": "r1", "metric": 9999, "keep_route": true},
+			"after": {"id": "r1", "metric": 9999, "keep_route": false}
+		}},
+		{"address": "netbird_setup_key.ci", "mode": "managed", "change": {"actions": ["create"]}}
+	]}`)
+
+	result, err := generator.Reconcile(plan)
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	wantAdopted := []string{"netbird_policy.ssh.description", "netbird_policy.ssh.enabled", "netbird_route.office.keep_route"}
+	if !reflect.DeepEqual(result.Adopted, wantAdopted) {
+		t.Errorf("adopted = %v, want %v", result.Adopted, wantAdopted)
+	}
+	wantUnreconcilable := []string{
+		"netbird_policy.ssh.rules (not a literal value)",
+		"netbird_policy.ssh.source_posture_checks (references another resource)",
+		"netbird_setup_key.ci (would be created)",
+	}
+	if !reflect.DeepEqual(result.Unreconcilable, wantUnreconcilable) {
+		t.Errorf("unreconcilable = %v, want %v", result.Unreconcilable, wantUnreconcilable)
+	}
+
+	attributes := generator.GetResources()[1].Attributes
+	if attributes["description"] != "" || attributes["enabled"] != false {
+		t.Errorf("policy attributes = %v, want the state's description and enabled", attributes)
+	}
+
+	// Adopting the same value again means the provider does not accept it
+	result, err = generator.Reconcile(plan)
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if len(result.Adopted) != 0 || len(result.Unreconcilable) != 6 {
+		t.Errorf("second round = %+v, want nothing adopted and every change reported", result)
+	}
+}
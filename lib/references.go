@@ -0,0 +1,195 @@
+package lib
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// UnresolvedReference records a reference to an object that is not among the
+// generated resources, e.g. a policy naming a group filtered out by --include.
+// The referencing resource uses the object's ID instead.
+type UnresolvedReference struct {
+	Type       string `json:"type"`     // type of the referencing resource
+	Resource   string `json:"resource"` // resource name of the referencing resource
+	Attribute  string `json:"attribute"`
+	TargetType string `json:"target_type"`
+	TargetID   string `json:"target_id"`
+	TargetName string `json:"target_name"`
+}
+
+// Target describes the referenced object by name and ID, or by ID if the
+// reference carries no name
+func (r UnresolvedReference) Target() string {
+	if r.TargetName == "" {
+		return r.TargetID
+	}
+	return fmt.Sprintf("%s (%s)", r.TargetName, r.TargetID)
+}
+
+// Comment returns the comment written above the referencing resource
+func (r UnresolvedReference) Comment() string {
+	return fmt.Sprintf("TODO unresolved %s %s in %s", r.TargetType, r.Target(), r.Attribute)
+}
+
+// referenceMarker starts and separates the fields of a placeholder written by
+// Reference. NUL never occurs in NetBird IDs or names.
+const referenceMarker = "\x00"
+
+// Reference returns a placeholder for a reference to an object by type and ID,
+// for handlers to write into attributes. ResolveReferences replaces it with the
+// reference to the object's resource or data source once every handler ran,
+// so a handler need not know the resource names of the objects it references.
+// name is the object's display name, if known, for the unresolved reference
+// recorded when the object has neither.
+func Reference(targetType, id, name string) string {
+	return referenceMarker + strings.Join([]string{targetType, id, name}, referenceMarker)
+}
+
+// parsePlaceholder returns the fields of a placeholder written by Reference
+func parsePlaceholder(value string) (targetType, id, name string, ok bool) {
+	if !strings.HasPrefix(value, referenceMarker) {
+		return "", "", "", false
+	}
+	fields := strings.Split(value[len(referenceMarker):], referenceMarker)
+	if len(fields) != 3 {
+		return "", "", "", false
+	}
+	return fields[0], fields[1], fields[2], true
+}
+
+// ReferenceResolver maps the IDs of generated objects to the references of
+// their resources or data sources, e.g. netbird_group.developers.id, by type
+type ReferenceResolver struct {
+	mu         sync.Mutex
+	references map[string]map[string]string
+}
+
+// NewReferenceResolver creates an empty resolver
+func NewReferenceResolver() *ReferenceResolver {
+	return &ReferenceResolver{references: make(map[string]map[string]string)}
+}
+
+// Add maps an object to the reference of its resource or data source
+func (r *ReferenceResolver) Add(resourceType, id, reference string) {
+	if id == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.references[resourceType] == nil {
+		r.references[resourceType] = make(map[string]string)
+	}
+	r.references[resourceType][id] = reference
+}
+
+// Lookup returns the reference to an object
+func (r *ReferenceResolver) Lookup(resourceType, id string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reference, exists := r.references[resourceType][id]
+	return reference, exists
+}
+
+// Resolve returns a copy of an attribute value with the placeholders written
+// by Reference replaced by references. A placeholder whose object is unknown
+// becomes the object's ID and is passed to unresolved with the attribute
+// holding it, e.g. rules.sources. Map keys are visited in sorted order.
+func (r *ReferenceResolver) Resolve(value any, attribute string, unresolved func(attribute, targetType, id, name string)) any {
+	switch typed := value.(type) {
+	case string:
+		targetType, id, name, ok := parsePlaceholder(typed)
+		if !ok {
+			return typed
+		}
+		if reference, exists := r.Lookup(targetType, id); exists {
+			return Expression(reference)
+		}
+		unresolved(attribute, targetType, id, name)
+		return id
+	case []string:
+		// A list holding references becomes a []any of strings and expressions
+		resolved := make([]any, 0, len(typed))
+		literals := make([]string, 0, len(typed))
+		for _, item := range typed {
+			value := r.Resolve(item, attribute, unresolved)
+			resolved = append(resolved, value)
+			if literal, ok := value.(string); ok {
+				literals = append(literals, literal)
+			}
+		}
+		if len(literals) == len(typed) {
+			return literals
+		}
+		return resolved
+	case []any:
+		resolved := make([]any, 0, len(typed))
+		for _, item := range typed {
+			resolved = append(resolved, r.Resolve(item, attribute, unresolved))
+		}
+		return resolved
+	case map[string]any:
+		keys := make([]string, 0, len(typed))
+		for key := range typed {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		resolved := make(map[string]any, len(typed))
+		for _, key := range keys {
+			path := key
+			if attribute != "" {
+				path = attribute + "." + key
+			}
+			resolved[key] = r.Resolve(typed[key], path, unresolved)
+		}
+		return resolved
+	case []map[string]any:
+		resolved := make([]map[string]any, 0, len(typed))
+		for _, item := range typed {
+			resolved = append(resolved, r.Resolve(item, attribute, unresolved).(map[string]any))
+		}
+		return resolved
+	default:
+		return value
+	}
+}
+
+// ResolveReferences replaces the placeholders written by Reference in the
+// generated resources. It runs once every handler has added its resources. A
+// reference to an object without a resource or data source, e.g. a group
+// filtered out by --include, is written as the object's ID, recorded as
+// unresolved and commented above the referencing resource. References to an
+// excluded type are expected to be IDs and are not recorded.
+func (tg *TerraformGenerator) ResolveReferences() {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+
+	for i := range tg.resources {
+		resource := &tg.resources[i]
+		resource.Attributes = tg.references.Resolve(resource.Attributes, "", func(attribute, targetType, id, name string) {
+			if tg.config.IsExcluded(targetType) {
+				return
+			}
+			reference := UnresolvedReference{
+				Type:       resource.Type,
+				Resource:   resource.Name,
+				Attribute:  attribute,
+				TargetType: targetType,
+				TargetID:   id,
+				TargetName: name,
+			}
+			tg.unresolved = append(tg.unresolved, reference)
+			resource.Comments = append(resource.Comments, reference.Comment())
+		}).(map[string]any)
+	}
+}
+
+// GetUnresolvedReferences returns the references recorded as unresolved
+func (tg *TerraformGenerator) GetUnresolvedReferences() []UnresolvedReference {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	return append([]UnresolvedReference(nil), tg.unresolved...)
+}
@@ -0,0 +1,49 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveReferences(t *testing.T) {
+	generator := NewTerraformGenerator(t.TempDir(), &Config{ExcludedTypes: []string{"posture_check"}})
+	generator.AddResource("group", "developers", map[string]any{"id": "g1", "name": "Developers"})
+	generator.AddDataSource("peer", "host_a", map[string]any{"id": "p1"})
+	generator.AddResource("policy", "ssh", map[string]any{
+		"id":                    "pol1",
+		"name":                  "SSH",
+		"source_posture_checks": []string{Reference("posture_check", "pc1", "")},
+		"rules": []any{map[string]any{
+			"sources":      []string{Reference("group", "g1", "Developers")},
+			"destinations": []string{Reference("group", "g9", "Ghosts")},
+		}},
+	})
+	generator.AddResource("route", "office", map[string]any{"id": "r1", "peer": Reference("peer", "p1", "")})
+	generator.ResolveReferences()
+
+	resources := make(map[string]TerraformResource)
+	for _, resource := range generator.GetResources() {
+		resources[resource.Type] = resource
+	}
+	rule := resources["policy"].Attributes["rules"].([]any)[0].(map[string]any)
+	if got := rule["sources"]; !reflect.DeepEqual(got, []any{Expression("netbird_group.developers.id")}) {
+		t.Errorf("sources = %v, want the group reference", got)
+	}
+	if got := rule["destinations"]; !reflect.DeepEqual(got, []string{"g9"}) {
+		t.Errorf("destinations = %v, want the unresolved group's ID", got)
+	}
+	if got := resources["policy"].Attributes["source_posture_checks"]; !reflect.DeepEqual(got, []string{"pc1"}) {
+		t.Errorf("source_posture_checks = %v, want the excluded posture check's ID", got)
+	}
+	if got := resources["route"].Attributes["peer"]; got != Expression("data.netbird_peer.host_a.id") {
+		t.Errorf("peer = %v, want the peer data source reference", got)
+	}
+
+	want := []UnresolvedReference{{Type: "policy", Resource: "ssh", Attribute: "rules.destinations", TargetType: "group", TargetID: "g9", TargetName: "Ghosts"}}
+	if got := generator.GetUnresolvedReferences(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetUnresolvedReferences() = %+v, want %+v", got, want)
+	}
+	if comments := resources["policy"].Comments; len(comments) != 1 || comments[0] != want[0].Comment() {
+		t.Errorf("policy comments = %v, want the unresolved reference", comments)
+	}
+}
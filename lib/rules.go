@@ -0,0 +1,604 @@
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// RuleAction is what a matching rule does with an object
+type RuleAction string
+
+const (
+	// RuleImport generates a resource and imports it (the default)
+	RuleImport RuleAction = "import"
+	// RuleSkip leaves the object out of the generated configuration
+	RuleSkip RuleAction = "skip"
+	// RuleDataSource references the object through a data source instead of
+	// managing it, e.g. for groups synced from an identity provider
+	RuleDataSource RuleAction = "data_source"
+)
+
+// RuleActions lists the supported rule actions
+var RuleActions = []RuleAction{RuleImport, RuleSkip, RuleDataSource}
+
+// RuleObject is the "resource" variable available to rule expressions
+type RuleObject struct {
+	Type string // resource type, e.g. "group"
+	ID   string // NetBird object ID
+	Name string // display name: group/policy name, user email, route network ID
+
+	// Issued is who created a group or user: "api", "jwt" (token group sync) or
+	// "integration" (IdP sync); empty for other resource types
+	Issued string
+}
+
+// Rule decides per object whether to import it, skip it or convert it to a data
+// source. Expressions use a CEL-like syntax:
+//
+//	resource.type == "group" && resource.name.startsWith("idp-")
+//	resource.type in ["user", "policy"] && !resource.name.matches("^svc-")
+//
+// Supported are the fields resource.type, resource.id, resource.name and
+// resource.issued, string
+// and boolean literals, lists, ==, !=, in, &&, ||, !, parentheses and the string
+// methods startsWith, endsWith, contains and matches.
+type Rule struct {
+	Expression string
+	Action     RuleAction
+	root       ruleNode
+}
+
+// CompileRule parses a rule expression and validates its action
+func CompileRule(expression, action string) (*Rule, error) {
+	ruleAction, err := ParseRuleAction(action)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := tokenizeRule(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rule %q: %w", expression, err)
+	}
+
+	parser := &ruleParser{tokens: tokens, end: len([]rune(expression))}
+	root, err := parser.parseOr()
+	if err == nil && !parser.done() {
+		err = fmt.Errorf("unexpected %q at offset %d", parser.peek().text, parser.peek().offset)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid rule %q: %w", expression, err)
+	}
+
+	return &Rule{Expression: expression, Action: ruleAction, root: root}, nil
+}
+
+// Matches evaluates the rule against an object
+func (r *Rule) Matches(object RuleObject) (bool, error) {
+	value, err := r.root.eval(object)
+	if err != nil {
+		return false, fmt.Errorf("rule %q: %w", r.Expression, err)
+	}
+
+	matched, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("rule %q: expression must be boolean, got %s", r.Expression, ruleTypeName(value))
+	}
+	return matched, nil
+}
+
+// EvaluateRules returns the action of the first matching rule, or RuleImport when
+// no rule matches
+func EvaluateRules(rules []*Rule, object RuleObject) (RuleAction, error) {
+	rule, err := FirstMatchingRule(rules, object)
+	if rule == nil {
+		return RuleImport, err
+	}
+	return rule.Action, nil
+}
+
+// FirstMatchingRule returns the first rule matching the object, or nil
+func FirstMatchingRule(rules []*Rule, object RuleObject) (*Rule, error) {
+	for _, rule := range rules {
+		matched, err := rule.Matches(object)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			return rule, nil
+		}
+	}
+	return nil, nil
+}
+
+// ParseRuleAction validates a rule action name
+func ParseRuleAction(action string) (RuleAction, error) {
+	for _, candidate := range RuleActions {
+		if string(candidate) == action {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("unknown rule action %q (supported: import, skip, data_source)", action)
+}
+
+// ruleToken is a lexical token of a rule expression
+type ruleToken struct {
+	kind   string // "ident", "string" or the operator/punctuation itself
+	text   string
+	offset int
+}
+
+// tokenizeRule splits a rule expression into tokens
+func tokenizeRule(expression string) ([]ruleToken, error) {
+	tokens := make([]ruleToken, 0)
+	runes := []rune(expression)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '"' || r == '\'':
+			// A backslash escapes the quote or a backslash; any other is kept, so
+			// patterns such as "\d+" need no doubling
+			var builder strings.Builder
+			start := i
+			i++
+			for ; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && (runes[i+1] == r || runes[i+1] == '\\') {
+					i++
+				}
+				builder.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at offset %d", start)
+			}
+			i++
+			tokens = append(tokens, ruleToken{kind: "string", text: builder.String(), offset: start})
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, ruleToken{kind: "ident", text: string(runes[start:i]), offset: start})
+
+		default:
+			operator := ""
+			for _, candidate := range []string{"==", "!=", "&&", "||", "!", "(", ")", "[", "]", ",", "."} {
+				if strings.HasPrefix(string(runes[i:]), candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator == "" {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", r, i)
+			}
+			tokens = append(tokens, ruleToken{kind: operator, text: operator, offset: i})
+			i += len(operator)
+		}
+	}
+
+	return tokens, nil
+}
+
+// ruleParser is a recursive descent parser for rule expressions
+type ruleParser struct {
+	tokens []ruleToken
+	pos    int
+	end    int // offset of the end of the expression, reported when it ends early
+}
+
+func (p *ruleParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *ruleParser) peek() ruleToken {
+	if p.done() {
+		return ruleToken{kind: "end", text: "end of expression", offset: p.end}
+	}
+	return p.tokens[p.pos]
+}
+
+// accept consumes the next token if it has the given kind
+func (p *ruleParser) accept(kind string) (ruleToken, bool) {
+	token := p.peek()
+	if token.kind != kind {
+		return token, false
+	}
+	p.pos++
+	return token, true
+}
+
+func (p *ruleParser) expect(kind string) (ruleToken, error) {
+	token, ok := p.accept(kind)
+	if !ok {
+		return token, fmt.Errorf("expected %q, got %q at offset %d", kind, token.text, token.offset)
+	}
+	return token, nil
+}
+
+func (p *ruleParser) parseOr() (ruleNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("||"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{operator: "||", left: left, right: right}
+	}
+}
+
+func (p *ruleParser) parseAnd() (ruleNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&&"); !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{operator: "&&", left: left, right: right}
+	}
+}
+
+func (p *ruleParser) parseUnary() (ruleNode, error) {
+	if _, ok := p.accept("!"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *ruleParser) parseComparison() (ruleNode, error) {
+	left, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+
+	operator := ""
+	if token, ok := p.accept("=="); ok {
+		operator = token.text
+	} else if token, ok := p.accept("!="); ok {
+		operator = token.text
+	} else if token := p.peek(); token.kind == "ident" && token.text == "in" {
+		p.pos++
+		operator = "in"
+	}
+	if operator == "" {
+		return left, nil
+	}
+
+	right, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryNode{operator: operator, left: left, right: right}, nil
+}
+
+// parsePostfix parses a primary expression followed by method calls
+func (p *ruleParser) parsePostfix() (ruleNode, error) {
+	node, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.accept("."); !ok {
+			return node, nil
+		}
+		method, err := p.expect("ident")
+		if err != nil {
+			return nil, err
+		}
+		args, err := p.parseArguments("(", ")")
+		if err != nil {
+			return nil, err
+		}
+		node, err = newCallNode(node, method, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *ruleParser) parsePrimary() (ruleNode, error) {
+	token := p.peek()
+	switch token.kind {
+	case "string":
+		p.pos++
+		return &literalNode{value: token.text}, nil
+
+	case "(":
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return node, nil
+
+	case "[":
+		items, err := p.parseArguments("[", "]")
+		if err != nil {
+			return nil, err
+		}
+		return &listNode{items: items}, nil
+
+	case "ident":
+		p.pos++
+		switch token.text {
+		case "true", "false":
+			return &literalNode{value: token.text == "true"}, nil
+		case "resource":
+			if _, err := p.expect("."); err != nil {
+				return nil, err
+			}
+			field, err := p.expect("ident")
+			if err != nil {
+				return nil, err
+			}
+			if field.text != "type" && field.text != "id" && field.text != "name" && field.text != "issued" {
+				return nil, fmt.Errorf("unknown field resource.%s (supported: type, id, name, issued)", field.text)
+			}
+			return &fieldNode{name: field.text}, nil
+		}
+		return nil, fmt.Errorf("unknown identifier %q at offset %d", token.text, token.offset)
+	}
+
+	return nil, fmt.Errorf("unexpected %q at offset %d", token.text, token.offset)
+}
+
+// parseArguments parses a delimited, comma-separated list of expressions
+func (p *ruleParser) parseArguments(open, close string) ([]ruleNode, error) {
+	if _, err := p.expect(open); err != nil {
+		return nil, err
+	}
+
+	items := make([]ruleNode, 0)
+	if _, ok := p.accept(close); ok {
+		return items, nil
+	}
+	for {
+		item, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+
+		if _, ok := p.accept(close); ok {
+			return items, nil
+		}
+		if token, ok := p.accept(","); !ok {
+			return nil, fmt.Errorf("expected \",\" or %q, got %q at offset %d", close, token.text, token.offset)
+		}
+	}
+}
+
+// ruleNode is a node of a compiled rule expression. Values are strings, bools or
+// lists of values.
+type ruleNode interface {
+	eval(object RuleObject) (any, error)
+}
+
+type literalNode struct {
+	value any
+}
+
+func (n *literalNode) eval(RuleObject) (any, error) {
+	return n.value, nil
+}
+
+type fieldNode struct {
+	name string
+}
+
+func (n *fieldNode) eval(object RuleObject) (any, error) {
+	switch n.name {
+	case "type":
+		return object.Type, nil
+	case "id":
+		return object.ID, nil
+	case "issued":
+		return object.Issued, nil
+	default:
+		return object.Name, nil
+	}
+}
+
+type listNode struct {
+	items []ruleNode
+}
+
+func (n *listNode) eval(object RuleObject) (any, error) {
+	values := make([]any, 0, len(n.items))
+	for _, item := range n.items {
+		value, err := item.eval(object)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+type notNode struct {
+	operand ruleNode
+}
+
+func (n *notNode) eval(object RuleObject) (any, error) {
+	value, err := evalBool(n.operand, object, "!")
+	if err != nil {
+		return nil, err
+	}
+	return !value, nil
+}
+
+type binaryNode struct {
+	operator    string
+	left, right ruleNode
+}
+
+func (n *binaryNode) eval(object RuleObject) (any, error) {
+	switch n.operator {
+	case "&&", "||":
+		left, err := evalBool(n.left, object, n.operator)
+		if err != nil {
+			return nil, err
+		}
+		if (n.operator == "&&") != left {
+			return left, nil
+		}
+		return evalBool(n.right, object, n.operator)
+	}
+
+	left, err := n.left.eval(object)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(object)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.operator {
+	case "in":
+		if _, ok := left.([]any); ok {
+			return nil, fmt.Errorf("left side of in cannot be a list")
+		}
+		list, ok := right.([]any)
+		if !ok {
+			return nil, fmt.Errorf("right side of in must be a list, got %s", ruleTypeName(right))
+		}
+		for _, item := range list {
+			if item == left {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		if ruleTypeName(left) != ruleTypeName(right) {
+			return nil, fmt.Errorf("cannot compare %s %s %s", ruleTypeName(left), n.operator, ruleTypeName(right))
+		}
+		if _, ok := left.([]any); ok {
+			return nil, fmt.Errorf("cannot compare lists")
+		}
+		return (left == right) == (n.operator == "=="), nil
+	}
+}
+
+type callNode struct {
+	target  ruleNode
+	method  string
+	args    []ruleNode
+	pattern *regexp.Regexp // precompiled argument of matches() when it is a literal
+}
+
+// newCallNode validates a method call at compile time
+func newCallNode(target ruleNode, method ruleToken, args []ruleNode) (ruleNode, error) {
+	switch method.text {
+	case "startsWith", "endsWith", "contains", "matches":
+	default:
+		return nil, fmt.Errorf("unknown method %q at offset %d (supported: startsWith, endsWith, contains, matches)", method.text, method.offset)
+	}
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%s expects 1 argument, got %d", method.text, len(args))
+	}
+
+	node := &callNode{target: target, method: method.text, args: args}
+	if literal, ok := args[0].(*literalNode); ok && method.text == "matches" {
+		value, ok := literal.value.(string)
+		if !ok {
+			return nil, fmt.Errorf("matches expects a string pattern")
+		}
+		pattern, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern in matches: %w", err)
+		}
+		node.pattern = pattern
+	}
+	return node, nil
+}
+
+func (n *callNode) eval(object RuleObject) (any, error) {
+	target, err := evalString(n.target, object, n.method)
+	if err != nil {
+		return nil, err
+	}
+	argument, err := evalString(n.args[0], object, n.method)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.method {
+	case "startsWith":
+		return strings.HasPrefix(target, argument), nil
+	case "endsWith":
+		return strings.HasSuffix(target, argument), nil
+	case "contains":
+		return strings.Contains(target, argument), nil
+	default:
+		pattern := n.pattern
+		if pattern == nil {
+			pattern, err = regexp.Compile(argument)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern in matches: %w", err)
+			}
+		}
+		return pattern.MatchString(target), nil
+	}
+}
+
+func evalBool(node ruleNode, object RuleObject, context string) (bool, error) {
+	value, err := node.eval(object)
+	if err != nil {
+		return false, err
+	}
+	result, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("%s expects bool, got %s", context, ruleTypeName(value))
+	}
+	return result, nil
+}
+
+func evalString(node ruleNode, object RuleObject, context string) (string, error) {
+	value, err := node.eval(object)
+	if err != nil {
+		return "", err
+	}
+	result, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("%s expects string, got %s", context, ruleTypeName(value))
+	}
+	return result, nil
+}
+
+func ruleTypeName(value any) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case []any:
+		return "list"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,194 @@
+package lib
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRuleMatches(t *testing.T) {
+	group := RuleObject{Type: "group", ID: "g1", Name: `idp-sales "EU"\d`, Issued: IssuedIntegration}
+
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{`resource.type == "group"`, true},
+		{`resource.type != "group"`, false},
+		{`resource.id == 'g1' && resource.issued == "integration"`, true},
+		// && binds tighter than ||
+		{`resource.type == "user" && false || true`, true},
+		{`true || resource.type == "user" && false`, true},
+		{`(true || true) && false`, false},
+		{`!true || true`, true},
+		{`!(true || true)`, false},
+		{`!!true`, true},
+		{`!resource.name.startsWith("svc-")`, true},
+		{`resource.type in ["user", "group"]`, true},
+		{`resource.type in ["user", "policy"]`, false},
+		{`resource.type in []`, false},
+		{`resource.name.startsWith("idp-")`, true},
+		{`resource.name.startsWith("sales")`, false},
+		{`resource.name.endsWith("\d")`, true},
+		{`resource.name.contains("\"EU\"")`, true},
+		{`resource.name.contains('"EU"')`, true},
+		{`resource.name.matches("^idp-[a-z]+ ")`, true},
+		{`resource.name.matches("^svc-")`, false},
+		{`resource.name.matches("\\\\d$")`, true},
+		{`resource.name.endsWith(resource.name)`, true},
+		{`resource.name.matches(resource.type)`, false},
+		{`"a\\b" == 'a\b'`, true},
+	}
+
+	for _, test := range tests {
+		rule, err := CompileRule(test.expression, "skip")
+		if err != nil {
+			t.Errorf("CompileRule(%s): %v", test.expression, err)
+			continue
+		}
+		got, err := rule.Matches(group)
+		if err != nil {
+			t.Errorf("%s: %v", test.expression, err)
+		} else if got != test.want {
+			t.Errorf("%s = %t, want %t", test.expression, got, test.want)
+		}
+	}
+}
+
+func TestCompileRuleErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		action     string
+		want       string
+	}{
+		{`resource.type == "group"`, "delete", `unknown rule action "delete"`},
+		{`resource.name.contains("a"`, "skip", `expected "," or ")", got "end of expression" at offset 26`},
+		{`resource.type == "group`, "skip", "unterminated string at offset 17"},
+		{`resource.type == "group" &&`, "skip", `unexpected "end of expression" at offset 27`},
+		{`resource.type = "group"`, "skip", "unexpected character '=' at offset 14"},
+		{`resource.type == "a" "b"`, "skip", `unexpected "b" at offset 21`},
+		{`resource.owner == "a"`, "skip", "unknown field resource.owner"},
+		{`name == "a"`, "skip", `unknown identifier "name" at offset 0`},
+		{`resource.name.lower()`, "skip", `unknown method "lower" at offset 14`},
+		{`resource.name.contains("a", "b")`, "skip", "contains expects 1 argument, got 2"},
+		{`resource.name.matches("(")`, "skip", "invalid pattern in matches"},
+	}
+
+	for _, test := range tests {
+		_, err := CompileRule(test.expression, test.action)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("CompileRule(%s, %s) error = %v, want %q", test.expression, test.action, err, test.want)
+		}
+	}
+}
+
+func TestRuleEvaluationErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{`resource.name`, "expression must be boolean, got string"},
+		{`resource.name == true`, "cannot compare string == bool"},
+		{`resource.type in "group"`, "right side of in must be a list, got string"},
+		{`["a"] in [["a"]]`, "left side of in cannot be a list"},
+		{`!resource.name`, "! expects bool, got string"},
+		{`resource.name.contains(true)`, "contains expects string, got bool"},
+	}
+
+	for _, test := range tests {
+		rule, err := CompileRule(test.expression, "skip")
+		if err != nil {
+			t.Errorf("CompileRule(%s): %v", test.expression, err)
+			continue
+		}
+		_, err = rule.Matches(RuleObject{Type: "group", Name: "ops"})
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: error = %v, want %q", test.expression, err, test.want)
+		}
+	}
+}
+
+func TestEvaluateRulesFirstMatchWins(t *testing.T) {
+	compile := func(expression, action string) *Rule {
+		rule, err := CompileRule(expression, action)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rule
+	}
+	rules := []*Rule{
+		compile(`resource.name.startsWith("idp-")`, "data_source"),
+		compile(`resource.type == "group"`, "skip"),
+		compile(`resource.name == "ops"`, "data_source"),
+	}
+
+	tests := []struct {
+		object RuleObject
+		want   RuleAction
+	}{
+		{RuleObject{Type: "group", Name: "idp-sales"}, RuleDataSource},
+		{RuleObject{Type: "group", Name: "ops"}, RuleSkip},
+		{RuleObject{Type: "user", Name: "ops"}, RuleDataSource},
+		{RuleObject{Type: "user", Name: "alice"}, RuleImport},
+	}
+	for _, test := range tests {
+		got, err := EvaluateRules(rules, test.object)
+		if err != nil || got != test.want {
+			t.Errorf("EvaluateRules(%+v) = %s, %v, want %s", test.object, got, err, test.want)
+		}
+	}
+
+	// An error stops the evaluation instead of falling through to a later rule
+	failing := []*Rule{compile(`resource.name`, "skip"), compile(`true`, "data_source")}
+	if got, err := EvaluateRules(failing, RuleObject{Name: "ops"}); err == nil || got != RuleImport {
+		t.Errorf("EvaluateRules() = %s, %v, want import and an error", got, err)
+	}
+}
+
+// A data_source rule turns the object into a data block looked up by ID, and
+// references to it into data source references
+func TestDataSourceRule(t *testing.T) {
+	rule, err := CompileRule(`resource.type == "group" && resource.issued == "integration"`, "data_source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	generator := NewTerraformGenerator(t.TempDir(), &Config{Rules: []*Rule{rule}})
+
+	if !generator.IncludeResource("group", "g1", "idp-sales", IssuedIntegration) {
+		t.Fatal("IncludeResource() = false, want the group included as a data source")
+	}
+	generator.AddResource("group", "idp_sales", map[string]any{"id": "g1", "name": "idp-sales"})
+	if !generator.IncludeResource("group", "g2", "ops", IssuedAPI) {
+		t.Fatal("IncludeResource() = false for a group no rule matches")
+	}
+	generator.AddResource("group", "ops", map[string]any{"id": "g2", "name": "ops"})
+	generator.AddResource("policy", "sales", map[string]any{
+		"id":    "pol1",
+		"name":  "sales",
+		"rules": []any{map[string]any{"sources": []string{Reference("group", "g1", "idp-sales"), Reference("group", "g2", "ops")}}},
+	})
+	generator.ResolveReferences()
+
+	resources := make(map[string]TerraformResource)
+	for _, resource := range generator.resolveDataReferences(generator.GetResources()) {
+		resources[resource.Type+"."+resource.Name] = resource
+	}
+	converted := resources["group.idp_sales"]
+	if !converted.IsData || !reflect.DeepEqual(converted.Attributes, map[string]any{"id": "g1"}) {
+		t.Errorf("group.idp_sales = %+v, want a data source looked up by ID", converted)
+	}
+	if resources["group.ops"].IsData {
+		t.Error("group.ops is a data source, want a resource")
+	}
+
+	rule0 := resources["policy.sales"].Attributes["rules"].([]any)[0].(map[string]any)
+	want := []any{Expression("data.netbird_group.idp_sales.id"), Expression("netbird_group.ops.id")}
+	if got := rule0["sources"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("sources = %v, want %v", got, want)
+	}
+	for _, cmd := range generator.GetImportCommands() {
+		if cmd.ResourceID == "g1" {
+			t.Errorf("the data source is queued for import: %+v", cmd)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// terminateGracePeriod is how long terraform may take to exit after an
+// interrupt before it is killed
+const terminateGracePeriod = 10 * time.Second
+
+// TerraformRunner executes terraform commands
+type TerraformRunner struct {
+	binary    string
+	echo      bool
+	userAgent string
+}
+
+// DefaultTerraformPath is the terraform binary used when none is configured,
+// looked up in PATH
+const DefaultTerraformPath = "terraform"
+
+// NewTerraformRunner creates a runner for the configured terraform binary;
+// commands are echoed with VerboseTerraform
+func NewTerraformRunner(config *Config) *TerraformRunner {
+	binary := config.TerraformPath
+	if binary == "" {
+		binary = DefaultTerraformPath
+	}
+
+	return &TerraformRunner{
+		binary:    binary,
+		echo:      config.Verbosity.Has(VerboseTerraform),
+		userAgent: config.UserAgent,
+	}
+}
+
+// Resolve checks that the terraform binary exists and is executable, and makes
+// its path absolute, since commands run in other directories
+func (r *TerraformRunner) Resolve() (string, error) {
+	// On Windows LookPath also tries the extensions in PATHEXT, so "terraform"
+	// finds terraform.exe
+	path, err := exec.LookPath(r.binary)
+	if err != nil {
+		return "", fmt.Errorf("terraform binary %q not found: install Terraform or set TERRAFORM_BIN (--terraform-path)", r.binary)
+	}
+
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	r.binary = path
+	return path, nil
+}
+
+// Init runs terraform init in the specified directory
+func (r *TerraformRunner) Init(ctx context.Context, folderPath string) error {
+	return r.run(ctx, folderPath, nil, "init")
+}
+
+// Import runs terraform import for a specific resource
+func (r *TerraformRunner) Import(ctx context.Context, folderPath string, resourceAddress string, resourceID string) error {
+	return r.run(ctx, folderPath, nil, "import", resourceAddress, resourceID)
+}
+
+// Version returns the version of the terraform binary
+func (r *TerraformRunner) Version(ctx context.Context) (string, error) {
+	output, err := r.output(ctx, "", "version", "-json")
+	if err != nil {
+		return "", err
+	}
+
+	var version struct {
+		TerraformVersion string `json:"terraform_version"`
+	}
+	err = json.Unmarshal(output, &version)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode terraform version: %w", err)
+	}
+	return version.TerraformVersion, nil
+}
+
+// Plan runs terraform plan in the specified directory, saving the plan to planFile
+func (r *TerraformRunner) Plan(ctx context.Context, folderPath, planFile string) error {
+	return r.run(ctx, folderPath, nil, "plan", "-input=false", "-out="+planFile)
+}
+
+// ShowPlan returns the JSON representation of a saved plan
+func (r *TerraformRunner) ShowPlan(ctx context.Context, folderPath, planFile string) ([]byte, error) {
+	return r.output(ctx, folderPath, "show", "-json", planFile)
+}
+
+// Apply applies a saved plan in the specified directory
+func (r *TerraformRunner) Apply(ctx context.Context, folderPath, planFile string) error {
+	return r.run(ctx, folderPath, nil, "apply", "-input=false", planFile)
+}
+
+// output executes terraform with the given arguments and returns its standard
+// output; standard error is streamed
+func (r *TerraformRunner) output(ctx context.Context, folderPath string, args ...string) ([]byte, error) {
+	var stdout bytes.Buffer
+	err := r.run(ctx, folderPath, &stdout, args...)
+	return stdout.Bytes(), err
+}
+
+// run executes terraform with the given arguments, streaming its output to the
+// console, or standard output to stdout if given. When the context is cancelled
+// terraform is interrupted, so it can release the state lock, and killed if it
+// does not exit within terminateGracePeriod. A failure is returned as a
+// *TerraformError.
+func (r *TerraformRunner) run(ctx context.Context, folderPath string, stdout io.Writer, args ...string) error {
+	if r.echo {
+		slog.Debug("Running terraform", "component", "terraform", "dir", folderPath, "command", r.binary+" "+strings.Join(args, " "), "user_agent", r.userAgent)
+	}
+
+	// Diagnostics without colors are what TerraformError.Summary parses. Only
+	// the subcommands accepting -no-color get it: version rejects it.
+	if len(args) > 0 && noColorCommands[args[0]] {
+		args = append([]string{args[0], "-no-color"}, args[1:]...)
+	}
+
+	cmd := exec.CommandContext(ctx, r.binary, args...)
+	cmd.Dir = folderPath
+	cmd.Cancel = func() error {
+		return InterruptProcess(cmd.Process)
+	}
+	cmd.WaitDelay = terminateGracePeriod
+	cmd.Env = append(os.Environ(), automationEnv...)
+	if r.userAgent != "" {
+		// Providers append it to the User-Agent of their API requests, so the
+		// provider's requests of an import show up as the importer's too
+		appended := strings.TrimSpace(os.Getenv("TF_APPEND_USER_AGENT") + " " + r.userAgent)
+		cmd.Env = append(cmd.Env, "TF_APPEND_USER_AGENT="+appended)
+	}
+
+	stderr := &tailBuffer{limit: stderrTailLimit}
+	cmd.Stdout = os.Stdout
+	if stdout != nil {
+		cmd.Stdout = stdout
+	}
+	cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
+
+	err := cmd.Run()
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+
+	terraformErr := &TerraformError{Command: args[0], ExitCode: -1, Stderr: stderr.String(), err: err}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		terraformErr.ExitCode = exitErr.ExitCode()
+	}
+	return terraformErr
+}
+
+// noColorCommands are the subcommands run with -no-color
+var noColorCommands = map[string]bool{"init": true, "import": true, "plan": true, "apply": true, "show": true}
+
+// automationEnv tells terraform it runs unattended: output suggesting commands to
+// run next is dropped and any prompt for input fails instead of hanging
+var automationEnv = []string{"TF_IN_AUTOMATION=1", "TF_INPUT=0"}
+
+// stderrTailLimit is how much of terraform's standard error a TerraformError keeps
+const stderrTailLimit = 8 * 1024
+
+// TerraformError is a failed terraform command
+type TerraformError struct {
+	Command  string // subcommand, e.g. import
+	ExitCode int    // -1 if terraform could not be started
+	Stderr   string // end of the standard error output
+	err      error
+}
+
+// ansiEscape matches the color codes of terraform's output
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// Summary returns terraform's first diagnostic ("Error: Cannot import non-existent
+// remote object"), or the last line of its error output when it has none.
+// Terraform runs with -no-color, but colors and the boxes drawn around
+// diagnostics are stripped as well, for wrapper scripts that add them.
+func (e *TerraformError) Summary() string {
+	var last string
+	for _, line := range strings.Split(ansiEscape.ReplaceAllString(e.Stderr, ""), "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "│"))
+		if line == "" || line == "╷" || line == "╵" {
+			continue
+		}
+		if strings.HasPrefix(line, "Error: ") {
+			return strings.TrimPrefix(line, "Error: ")
+		}
+		last = line
+	}
+	return last
+}
+
+func (e *TerraformError) Error() string {
+	if e.ExitCode < 0 {
+		return fmt.Sprintf("terraform %s: %v", e.Command, e.err)
+	}
+	if summary := e.Summary(); summary != "" {
+		return fmt.Sprintf("terraform %s exited with status %d: %s", e.Command, e.ExitCode, summary)
+	}
+	return fmt.Sprintf("terraform %s exited with status %d", e.Command, e.ExitCode)
+}
+
+func (e *TerraformError) Unwrap() error {
+	return e.err
+}
+
+// tailBuffer keeps the last limit bytes written to it
+type tailBuffer struct {
+	limit int
+	data  []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	if len(b.data) > b.limit {
+		b.data = b.data[len(b.data)-b.limit:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.data)
+}
@@ -0,0 +1,94 @@
+package lib
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// Standard error of `terraform import` for a deleted object, as Terraform 1.9
+// writes it with and without -no-color
+const (
+	importStderrColor = "\x1b[31m╷\x1b[0m\x1b[0m\n" +
+		"\x1b[31m│\x1b[0m \x1b[0m\x1b[1m\x1b[31mError: \x1b[0m\x1b[0m\x1b[1mCannot import non-existent remote object\x1b[0m\n" +
+		"\x1b[31m│\x1b[0m \x1b[0m\n" +
+		"\x1b[31m│\x1b[0m \x1b[0m\x1b[0mWhile attempting to import an existing object to \"netbird_group.devs\", the\n" +
+		"\x1b[31m│\x1b[0m \x1b[0mprovider detected that no object exists with the given id. Only\n" +
+		"\x1b[31m│\x1b[0m \x1b[0mpre-existing objects can be imported; check that the id is correct and that\n" +
+		"\x1b[31m│\x1b[0m \x1b[0mit is associated with the provider's configured region or endpoint, or use\n" +
+		"\x1b[31m│\x1b[0m \x1b[0m\"terraform apply\" to create a new remote object for this resource.\n" +
+		"\x1b[31m╵\x1b[0m\x1b[0m\n"
+	importStderrPlain = "\n" +
+		"Error: Cannot import non-existent remote object\n" +
+		"\n" +
+		"While attempting to import an existing object to \"netbird_group.devs\", the\n" +
+		"provider detected that no object exists with the given id. Only\n" +
+		"pre-existing objects can be imported; check that the id is correct and that\n" +
+		"it is associated with the provider's configured region or endpoint, or use\n" +
+		"\"terraform apply\" to create a new remote object for this resource.\n" +
+		"\n"
+	importStderrBoxed = "╷\n" +
+		"│ Error: Cannot import non-existent remote object\n" +
+		"│ \n" +
+		"│ While attempting to import an existing object to \"netbird_group.devs\", the\n" +
+		"╵\n"
+)
+
+func TestTerraformErrorSummary(t *testing.T) {
+	tests := map[string]struct {
+		stderr string
+		want   string
+	}{
+		"colored":       {importStderrColor, "Cannot import non-existent remote object"},
+		"no color":      {importStderrPlain, "Cannot import non-existent remote object"},
+		"boxed":         {importStderrBoxed, "Cannot import non-existent remote object"},
+		"no diagnostic": {"Initializing the backend...\nsignal: killed\n", "signal: killed"},
+		"box only":      {"╷\n│ \n╵\n", ""},
+		"empty":         {"", ""},
+	}
+	for name, test := range tests {
+		err := &TerraformError{Command: "import", ExitCode: 1, Stderr: test.stderr}
+		if got := err.Summary(); got != test.want {
+			t.Errorf("%s: Summary() = %q, want %q", name, got, test.want)
+		}
+	}
+}
+
+// version is parsed as JSON and rejects -no-color, so only the other
+// subcommands get it; the user's TF_CLI_ARGS are passed through untouched
+func TestRunnerArguments(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake terraform binary is a shell script")
+	}
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	script := "#!/bin/sh\n" +
+		"echo \"$* | TF_CLI_ARGS=$TF_CLI_ARGS\" >> " + calls + "\n" +
+		"case \"$1\" in version) echo '{\"terraform_version\": \"1.9.5\"}' ;; esac\n"
+	binary := filepath.Join(dir, "terraform")
+	if err := os.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TF_CLI_ARGS", "-lock-timeout=5s")
+
+	runner := NewTerraformRunner(&Config{TerraformPath: binary})
+	version, err := runner.Version(context.Background())
+	if err != nil || version != "1.9.5" {
+		t.Fatalf("Version() = %q, %v, want 1.9.5", version, err)
+	}
+	if err := runner.Plan(context.Background(), dir, "import.tfplan"); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "version -json | TF_CLI_ARGS=-lock-timeout=5s\n" +
+		"plan -no-color -input=false -out=import.tfplan | TF_CLI_ARGS=-lock-timeout=5s\n"
+	if string(content) != want {
+		t.Errorf("terraform was run as\n%s\nwant\n%s", content, want)
+	}
+}
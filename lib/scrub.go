@@ -0,0 +1,214 @@
+package lib
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// redactedValue replaces every secret found by the scrubber
+const redactedValue = "REDACTED"
+
+// SecretPattern is a named regular expression matching one kind of secret
+type SecretPattern struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// DefaultSecretPatterns match credentials that must never end up in generated files
+var DefaultSecretPatterns = []SecretPattern{
+	{Name: "netbird_pat", Pattern: regexp.MustCompile(`nbp_[A-Za-z0-9]{36}`)},
+	{Name: "private_key", Pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)},
+	{Name: "jwt", Pattern: regexp.MustCompile(`eyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]+`)},
+	{Name: "bearer_token", Pattern: regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]{20,}=*`)},
+	{Name: "aws_access_key", Pattern: regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`)},
+}
+
+// Entropy defaults: random tokens of 32 characters and more have 4.5 to 6 bits
+// per character, while IDs (base32 xids, hex UUIDs) and names stay below
+const (
+	DefaultEntropyThreshold = 4.5
+	DefaultEntropyMinLength = 32
+)
+
+// minSecretLength is the length below which configured secrets are not looked
+// for; shorter values (test tokens) would match ordinary text
+const minSecretLength = 8
+
+// entropyCandidate matches the strings checked for entropy. Slashes end a
+// candidate, so the path of a dashboard link (/posture-checks/<xid>) is not
+// taken for one random string.
+var entropyCandidate = regexp.MustCompile(`[A-Za-z0-9+=_-]+`)
+
+// ScrubConfig configures the secret scrubbing pass over generated files
+type ScrubConfig struct {
+	Secrets          []string // literal values that must never appear, e.g. the API token
+	Patterns         []SecretPattern
+	EntropyThreshold float64 // bits per character, 0 disables the entropy check
+	EntropyMinLength int
+	Allow            []*regexp.Regexp // matches of these are never reported
+}
+
+// ScrubFinding records a redacted secret. It never holds the secret itself.
+type ScrubFinding struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	Rule string `json:"rule"`
+}
+
+// CheckpointFile is the progress of an interrupted run in the output
+// directory. Like the state it holds the API responses unredacted, since a
+// resumed run generates from them.
+const CheckpointFile = ".netbird-checkpoint.json"
+
+// scrubbedFile reports whether a file written to the output directory is
+// scrubbed. Terraform's own files (state, lock file, providers) are not ours to
+// rewrite, nor is the checkpoint.
+func scrubbedFile(path string) bool {
+	name := filepath.Base(path)
+	return !strings.HasPrefix(name, "terraform.tfstate") && name != ".terraform.lock.hcl" && name != CheckpointFile
+}
+
+// ScrubPaths redacts secrets in place in the given files and, recursively, in the
+// files of the given directories, skipping terraform's own files. The caller
+// decides how to fail the run on findings.
+func ScrubPaths(paths []string, config ScrubConfig) ([]ScrubFinding, error) {
+	findings := make([]ScrubFinding, 0)
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				if entry.Name() == ".terraform" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !entry.Type().IsRegular() || !scrubbedFile(path) {
+				return nil
+			}
+
+			fileFindings, err := scrubFile(path, config)
+			findings = append(findings, fileFindings...)
+			return err
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return findings, fmt.Errorf("failed to scrub %s: %w", root, err)
+		}
+	}
+
+	return findings, nil
+}
+
+// scrubFile redacts secrets in one file, rewriting it only if something was found
+func scrubFile(path string, config ScrubConfig) ([]ScrubFinding, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	scrubbed, rules := ScrubText(string(data), config)
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	findings := make([]ScrubFinding, 0, len(rules))
+	for _, line := range sortedLines(rules) {
+		for _, rule := range rules[line] {
+			findings = append(findings, ScrubFinding{File: path, Line: line, Rule: rule})
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return findings, err
+	}
+	return findings, os.WriteFile(path, []byte(scrubbed), info.Mode().Perm())
+}
+
+// ScrubText redacts secrets in text. It returns the redacted text and the names
+// of the rules that matched, per 1-based line.
+func ScrubText(text string, config ScrubConfig) (string, map[int][]string) {
+	matched := make(map[int][]string)
+	lines := strings.Split(text, "\n")
+
+	for i, line := range lines {
+		for _, secret := range config.Secrets {
+			if len(secret) >= minSecretLength && strings.Contains(line, secret) {
+				line = strings.ReplaceAll(line, secret, redactedValue)
+				matched[i+1] = append(matched[i+1], "configured_secret")
+			}
+		}
+
+		for _, pattern := range config.Patterns {
+			line = pattern.Pattern.ReplaceAllStringFunc(line, func(match string) string {
+				if config.allowed(match) {
+					return match
+				}
+				matched[i+1] = append(matched[i+1], pattern.Name)
+				return redactedValue
+			})
+		}
+
+		if config.EntropyThreshold > 0 {
+			line = entropyCandidate.ReplaceAllStringFunc(line, func(match string) string {
+				if len(match) < config.EntropyMinLength || config.allowed(match) || ShannonEntropy(match) < config.EntropyThreshold {
+					return match
+				}
+				matched[i+1] = append(matched[i+1], "high_entropy")
+				return redactedValue
+			})
+		}
+
+		lines[i] = line
+	}
+
+	return strings.Join(lines, "\n"), matched
+}
+
+// allowed reports whether a match is exempted by an allow pattern
+func (c ScrubConfig) allowed(match string) bool {
+	for _, allow := range c.Allow {
+		if allow.MatchString(match) {
+			return true
+		}
+	}
+	return false
+}
+
+// ShannonEntropy returns the entropy of a string in bits per character
+func ShannonEntropy(value string) float64 {
+	if value == "" {
+		return 0
+	}
+
+	counts := make(map[rune]int)
+	total := 0
+	for _, char := range value {
+		counts[char]++
+		total++
+	}
+
+	entropy := 0.0
+	for _, count := range counts {
+		probability := float64(count) / float64(total)
+		entropy -= probability * math.Log2(probability)
+	}
+	return entropy
+}
+
+// sortedLines returns the line numbers of a findings map in ascending order
+func sortedLines(lines map[int][]string) []int {
+	numbers := make([]int, 0, len(lines))
+	for line := range lines {
+		numbers = append(numbers, line)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
@@ -0,0 +1,241 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// BackendConfig configures the state backend written into every root module when
+// the state is split by resource type. Each module gets its own state key.
+type BackendConfig struct {
+	Type      string         `json:"type"`       // backend type, e.g. s3, gcs, azurerm
+	KeyPrefix string         `json:"key_prefix"` // prefix of the per-module state keys
+	Settings  map[string]any `json:"config"`     // backend settings shared by all modules
+}
+
+// defaultBackendKeyPrefix prefixes the state keys when no key_prefix is configured
+const defaultBackendKeyPrefix = "netbird"
+
+// StateKey returns the backend attribute and value locating the state of one
+// module. The gcs backend takes a prefix instead of a key.
+func (b *BackendConfig) StateKey(module string) (string, string) {
+	prefix := strings.Trim(b.KeyPrefix, "/")
+	if prefix == "" {
+		prefix = defaultBackendKeyPrefix
+	}
+
+	if b.Type == "gcs" {
+		return "prefix", prefix + "/" + module
+	}
+	return "key", prefix + "/" + module + "/terraform.tfstate"
+}
+
+// ModuleDir returns the directory holding the configuration of a resource type:
+// the output directory, or its own root module when the state is split
+func (tg *TerraformGenerator) ModuleDir(resourceType string) string {
+	if !tg.config.SplitState {
+		return tg.outputDir
+	}
+	return filepath.Join(tg.outputDir, resourceType)
+}
+
+// ModulePath returns the path, relative to the output directory, of a file that
+// belongs to the configuration of a resource type
+func (tg *TerraformGenerator) ModulePath(resourceType, filename string) string {
+	if !tg.config.SplitState {
+		return filename
+	}
+	return filepath.Join(resourceType, filename)
+}
+
+// ModuleTypes returns the resource types with generated configuration, in the
+// order they were added. With split state each one is a root module.
+func (tg *TerraformGenerator) ModuleTypes() []string {
+	types := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, resource := range tg.resources {
+		if !seen[resource.Type] {
+			seen[resource.Type] = true
+			types = append(types, resource.Type)
+		}
+	}
+	return types
+}
+
+// WriteSplitModules writes one root module per resource type, each with its own
+// provider configuration and state. Resources referencing objects managed by
+// another module look them up through data sources instead.
+func (tg *TerraformGenerator) WriteSplitModules() error {
+	resourcesByType := make(map[string][]TerraformResource)
+	for _, resource := range tg.resources {
+		resourcesByType[resource.Type] = append(resourcesByType[resource.Type], resource)
+	}
+
+	// An empty account still gets a valid configuration to start from
+	if tg.config.Terragrunt {
+		if err := tg.writeTerragruntRoot(); err != nil {
+			return fmt.Errorf("failed to write terragrunt root configuration: %w", err)
+		}
+	} else if len(resourcesByType) == 0 {
+		return tg.GenerateProviderFile()
+	}
+
+	for _, resourceType := range tg.ModuleTypes() {
+		if tg.config.IsExcluded(resourceType) {
+			continue
+		}
+
+		dir := tg.ModuleDir(resourceType)
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			return fmt.Errorf("failed to create %s module: %w", resourceType, err)
+		}
+
+		// Terragrunt generates the provider and backend from the root configuration
+		if !tg.config.Terragrunt {
+			err = tg.writer.WriteProvider(dir, tg.config)
+			if err != nil {
+				return fmt.Errorf("failed to write %s provider: %w", resourceType, err)
+			}
+
+			if tg.config.Backend != nil {
+				err = tg.writer.WriteBackend(dir, tg.config.Backend, resourceType)
+				if err != nil {
+					return fmt.Errorf("failed to write %s backend: %w", resourceType, err)
+				}
+			}
+		}
+
+		resources, lookups := tg.crossModuleLookups(resourceType, tg.resolveDataReferences(resourcesByType[resourceType]))
+		err = tg.writer.WriteResources(dir, resourceType, resources)
+		if err != nil {
+			return fmt.Errorf("failed to generate %s resources: %w", resourceType, err)
+		}
+
+		for _, lookupType := range sortedNames(lookups) {
+			err = tg.writer.WriteResources(dir, lookupType, lookups[lookupType])
+			if err != nil {
+				return fmt.Errorf("failed to generate %s lookups for the %s module: %w", lookupType, resourceType, err)
+			}
+		}
+
+		if tg.config.Terragrunt {
+			err = tg.writeTerragruntModule(resourceType, sortedNames(lookups))
+			if err != nil {
+				return fmt.Errorf("failed to write %s terragrunt configuration: %w", resourceType, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// crossModuleLookups finds the references of a module's resources to objects of
+// other resource types and returns the data sources that resolve them within the
+// module, by type. Managed objects are looked up by ID, which every object has
+// while names need not be unique, with the name in a comment; data sources are
+// copied as they are.
+func (tg *TerraformGenerator) crossModuleLookups(module string, resources []TerraformResource) ([]TerraformResource, map[string][]TerraformResource) {
+	index := make(map[string]TerraformResource)
+	for _, resource := range tg.resources {
+		index[resource.Type+"."+resource.Name] = resource
+	}
+
+	references := make(map[string]bool)
+	for _, resource := range resources {
+		collectReferences(resource.Attributes, references)
+	}
+
+	rewrites := make(map[string]string)
+	lookups := make(map[string][]TerraformResource)
+	added := make(map[string]bool)
+	for _, reference := range sortedNames(references) {
+		resourceType, name, ok := parseReference(reference)
+		if !ok || resourceType == module {
+			continue
+		}
+
+		target, exists := index[resourceType+"."+name]
+		if !exists {
+			continue
+		}
+
+		if !target.IsData {
+			rewrites[reference] = "data." + reference
+		}
+		if added[resourceType+"."+name] {
+			continue
+		}
+		added[resourceType+"."+name] = true
+
+		lookup := target
+		if !target.IsData {
+			comment := fmt.Sprintf("Managed in the %s module", resourceType)
+			if objectName, ok := target.Attributes["name"].(string); ok && objectName != "" {
+				comment = fmt.Sprintf("%s: %q", comment, objectName)
+			}
+			lookup = TerraformResource{
+				Type:       resourceType,
+				Name:       name,
+				Attributes: map[string]any{"id": target.ID},
+				IsData:     true,
+				Comments:   []string{comment},
+			}
+		}
+		lookups[resourceType] = append(lookups[resourceType], lookup)
+	}
+
+	if len(rewrites) == 0 {
+		return resources, lookups
+	}
+
+	rewritten := make([]TerraformResource, 0, len(resources))
+	for _, resource := range resources {
+		resource.Attributes = rewriteReferences(resource.Attributes, rewrites).(map[string]any)
+		rewritten = append(rewritten, resource)
+	}
+	return rewritten, lookups
+}
+
+// collectReferences adds the Terraform references found in an attribute value
+func collectReferences(value any, found map[string]bool) {
+	switch typed := value.(type) {
+	case Expression:
+		found[string(typed)] = true
+	case []any:
+		for _, item := range typed {
+			collectReferences(item, found)
+		}
+	case map[string]any:
+		for _, item := range typed {
+			collectReferences(item, found)
+		}
+	case []map[string]any:
+		for _, item := range typed {
+			collectReferences(item, found)
+		}
+	}
+}
+
+// parseReference splits a reference such as netbird_group.admins.id or
+// data.netbird_peer.web.id into its resource type and name
+func parseReference(reference string) (string, string, bool) {
+	parts := strings.Split(strings.TrimPrefix(reference, "data."), ".")
+	if len(parts) != 3 || !strings.HasPrefix(parts[0], "netbird_") {
+		return "", "", false
+	}
+	return strings.TrimPrefix(parts[0], "netbird_"), parts[1], true
+}
+
+// sortedNames returns the keys of a set or map in a stable order
+func sortedNames[V any](values map[string]V) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,87 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		reference string
+		wantType  string
+		wantName  string
+		wantOK    bool
+	}{
+		{"netbird_group.admins.id", "group", "admins", true},
+		{"data.netbird_peer.web.id", "peer", "web", true},
+		{"var.group_ids[\"admins\"]", "", "", false},
+		{"netbird_group.admins", "", "", false},
+		{"aws_instance.web.id", "", "", false},
+	}
+	for _, test := range tests {
+		resourceType, name, ok := parseReference(test.reference)
+		if resourceType != test.wantType || name != test.wantName || ok != test.wantOK {
+			t.Errorf("parseReference(%q) = %q, %q, %t, want %q, %q, %t", test.reference, resourceType, name, ok, test.wantType, test.wantName, test.wantOK)
+		}
+	}
+}
+
+func TestWriteSplitModules(t *testing.T) {
+	outputDir := t.TempDir()
+	generator := NewTerraformGenerator(outputDir, &Config{SplitState: true})
+	// Two groups share a name; the lookups must still find the right one
+	generator.AddResource("group", "ops", map[string]any{"id": "g1", "name": "Ops"})
+	generator.AddResource("group", "ops_2", map[string]any{"id": "g2", "name": "Ops"})
+	generator.AddDataSource("group", "all", map[string]any{"id": "g0"})
+	generator.AddResource("policy", "ssh", map[string]any{
+		"id":   "pol1",
+		"name": "SSH",
+		"rules": []any{map[string]any{
+			"sources":      []string{Reference("group", "g2", "Ops")},
+			"destinations": []string{Reference("group", "g0", "All")},
+		}},
+	})
+	generator.ResolveReferences()
+
+	if err := generator.WriteSplitModules(); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(path string) string {
+		content, err := os.ReadFile(filepath.Join(outputDir, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+
+	policy := read(filepath.Join("policy", "policy.tf"))
+	for _, want := range []string{"      data.netbird_group.ops_2.id,\n", "      data.netbird_group.all.id,\n"} {
+		if !strings.Contains(policy, want) {
+			t.Errorf("policy.tf should reference %q:\n%s", want, policy)
+		}
+	}
+	if strings.Contains(policy, "  netbird_group.") {
+		t.Errorf("policy.tf references a resource of the group module:\n%s", policy)
+	}
+
+	lookups := read(filepath.Join("policy", "group.tf"))
+	for _, want := range []string{
+		"# Managed in the group module: \"Ops\"\ndata \"netbird_group\" \"ops_2\" {\n  id = \"g2\"\n}",
+		"data \"netbird_group\" \"all\" {\n  id = \"g0\"\n}",
+	} {
+		if !strings.Contains(lookups, want) {
+			t.Errorf("the policy module's group.tf should contain %q:\n%s", want, lookups)
+		}
+	}
+	if strings.Contains(lookups, "\"ops\"") || strings.Contains(lookups, "name =") {
+		t.Errorf("the policy module should look up only the referenced group, by ID:\n%s", lookups)
+	}
+
+	groups := read(filepath.Join("group", "group.tf"))
+	if !strings.Contains(groups, "resource \"netbird_group\" \"ops_2\"") {
+		t.Errorf("the group module should manage the groups:\n%s", groups)
+	}
+}
@@ -0,0 +1,82 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stateFile is the local state terraform writes into a root module
+const stateFile = "terraform.tfstate"
+
+// terraformState is the part of a version 4 state file needed to find the
+// address of every imported NetBird object
+type terraformState struct {
+	Version   int `json:"version"`
+	Resources []struct {
+		Module    string `json:"module"`
+		Mode      string `json:"mode"`
+		Type      string `json:"type"`
+		Name      string `json:"name"`
+		Instances []struct {
+			IndexKey   any `json:"index_key"`
+			Attributes struct {
+				ID string `json:"id"`
+			} `json:"attributes"`
+		} `json:"instances"`
+	} `json:"resources"`
+}
+
+// ReadStateNames returns the resource names of the NetBird objects managed in
+// the local state of an output directory, per resource type and object ID. With
+// split state every resource type directory has its own state, which is read
+// too. A directory without state yields no names.
+func ReadStateNames(outputDir string) (map[string]map[string]string, error) {
+	paths := []string{filepath.Join(outputDir, stateFile)}
+	splitStates, err := filepath.Glob(filepath.Join(outputDir, "*", stateFile))
+	if err != nil {
+		return nil, err
+	}
+	paths = append(paths, splitStates...)
+
+	names := make(map[string]map[string]string)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read state: %w", err)
+		}
+
+		var state terraformState
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("failed to parse state %s: %w", path, err)
+		}
+		if state.Version != 4 {
+			return nil, fmt.Errorf("unsupported state version %d in %s", state.Version, path)
+		}
+
+		for _, resource := range state.Resources {
+			// Objects in modules or with count/for_each were not named by the importer
+			if resource.Mode != "managed" || resource.Module != "" || !strings.HasPrefix(resource.Type, "netbird_") {
+				continue
+			}
+			for _, instance := range resource.Instances {
+				if instance.IndexKey != nil || instance.Attributes.ID == "" {
+					continue
+				}
+				resourceType := strings.TrimPrefix(resource.Type, "netbird_")
+				if names[resourceType] == nil {
+					names[resourceType] = make(map[string]string)
+				}
+				names[resourceType][instance.Attributes.ID] = resource.Name
+			}
+		}
+	}
+	return names, nil
+}
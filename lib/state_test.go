@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadStateNames(t *testing.T) {
+	outputDir := t.TempDir()
+	writeState := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeState(filepath.Join(outputDir, "terraform.tfstate"), `{"version": 4, "resources": [
+		{"mode": "managed", "type": "netbird_group", "name": "devs", "instances": [{"attributes": {"id": "g1"}}]},
+		{"mode": "data", "type": "netbird_group", "name": "all", "instances": [{"attributes": {"id": "g0"}}]},
+		{"mode": "managed", "type": "netbird_group", "name": "counted", "instances": [{"index_key": 0, "attributes": {"id": "g2"}}]},
+		{"module": "module.netbird", "mode": "managed", "type": "netbird_group", "name": "nested", "instances": [{"attributes": {"id": "g3"}}]},
+		{"mode": "managed", "type": "null_resource", "name": "other", "instances": [{"attributes": {"id": "n1"}}]}
+	]}`)
+	writeState(filepath.Join(outputDir, "setup_key", "terraform.tfstate"), `{"version": 4, "resources": [
+		{"mode": "managed", "type": "netbird_setup_key", "name": "ci", "instances": [{"attributes": {"id": "k1"}}]}
+	]}`)
+
+	names, err := ReadStateNames(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || len(names["group"]) != 1 || names["group"]["g1"] != "devs" || names["setup_key"]["k1"] != "ci" {
+		t.Errorf("ReadStateNames() = %v, want group g1 devs and setup_key k1 ci", names)
+	}
+
+	if names, err := ReadStateNames(t.TempDir()); err != nil || len(names) != 0 {
+		t.Errorf("ReadStateNames(empty directory) = %v, %v", names, err)
+	}
+}
+
+func TestWriteState(t *testing.T) {
+	outputDir := t.TempDir()
+	first := []ImportCommand{
+		{ResourceType: "group", ResourceAddress: "netbird_group.devs", ResourceID: "g1"},
+		{ResourceType: "setup_key", ResourceAddress: "netbird_setup_key.ci", ResourceID: "k1"},
+	}
+	if added, err := WriteState(outputDir, first, "1.6.0"); err != nil || added != 2 {
+		t.Fatalf("WriteState() = %d, %v, want 2 objects added", added, err)
+	}
+
+	// A second run adds only the new object to the same state
+	second := append(first, ImportCommand{ResourceType: "group", ResourceAddress: "netbird_group.ops", ResourceID: "g2"})
+	if added, err := WriteState(outputDir, second, "1.6.0"); err != nil || added != 1 {
+		t.Fatalf("WriteState() = %d, %v, want 1 object added", added, err)
+	}
+
+	names, err := ReadStateNames(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names["group"]) != 2 || names["group"]["g2"] != "ops" || names["setup_key"]["k1"] != "ci" {
+		t.Errorf("ReadStateNames() = %v, want groups devs and ops and setup_key ci", names)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "terraform.tfstate"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state struct {
+		Serial  int    `json:"serial"`
+		Lineage string `json:"lineage"`
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	if state.Serial != 2 || len(state.Lineage) != 36 {
+		t.Errorf("serial = %d, lineage = %q, want serial 2 and a UUID lineage", state.Serial, state.Lineage)
+	}
+}
@@ -0,0 +1,120 @@
+package lib
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stateProvider is the provider address recorded for every written object
+const stateProvider = `provider["registry.terraform.io/netbirdio/netbird"]`
+
+// stateFallbackVersion is recorded as the writing terraform version when the
+// terraform binary is unknown; any 1.x release reads the state
+const stateFallbackVersion = "1.0.0"
+
+// WriteState adds an object for each import command to the terraform.tfstate
+// of a root module, creating the state if there is none, instead of running
+// terraform import. Objects only get their ID; the next plan refreshes the
+// other attributes from the API. Addresses already in the state are left as
+// they are, as is everything else in an existing state. It returns the number
+// of objects added.
+func WriteState(dir string, importCommands []ImportCommand, terraformVersion string) (int, error) {
+	path := filepath.Join(dir, stateFile)
+	state := map[string]any{
+		"version":   4,
+		"serial":    0,
+		"outputs":   map[string]any{},
+		"resources": []any{},
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		lineage, err := newLineage()
+		if err != nil {
+			return 0, err
+		}
+		state["lineage"] = lineage
+	case err != nil:
+		return 0, fmt.Errorf("failed to read state: %w", err)
+	default:
+		if err := json.Unmarshal(data, &state); err != nil {
+			return 0, fmt.Errorf("failed to parse state %s: %w", path, err)
+		}
+		if version, _ := state["version"].(float64); version != 4 {
+			return 0, fmt.Errorf("unsupported state version %v in %s", state["version"], path)
+		}
+	}
+
+	resources, _ := state["resources"].([]any)
+	existing := make(map[string]bool, len(resources))
+	for _, entry := range resources {
+		resource, _ := entry.(map[string]any)
+		if resource["mode"] == "managed" && resource["module"] == nil {
+			existing[fmt.Sprintf("%v.%v", resource["type"], resource["name"])] = true
+		}
+	}
+
+	added := 0
+	for _, cmd := range importCommands {
+		if existing[cmd.ResourceAddress] {
+			continue
+		}
+		resourceType, name, _ := strings.Cut(cmd.ResourceAddress, ".")
+		resources = append(resources, map[string]any{
+			"mode":     "managed",
+			"type":     resourceType,
+			"name":     name,
+			"provider": stateProvider,
+			"instances": []any{map[string]any{
+				"schema_version":       0,
+				"attributes":           map[string]any{"id": cmd.ResourceID},
+				"sensitive_attributes": []any{},
+			}},
+		})
+		existing[cmd.ResourceAddress] = true
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+
+	if terraformVersion == "" {
+		terraformVersion = stateFallbackVersion
+	}
+	serial, _ := state["serial"].(float64)
+	state["serial"] = int(serial) + 1
+	state["terraform_version"] = terraformVersion
+	state["resources"] = resources
+
+	output, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	temporary := path + ".tmp"
+	if err := os.WriteFile(temporary, append(output, '\n'), 0644); err != nil {
+		return 0, fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := ReplaceFile(temporary, path); err != nil {
+		os.Remove(temporary)
+		return 0, fmt.Errorf("failed to write state: %w", err)
+	}
+	return added, nil
+}
+
+// newLineage returns a random UUID identifying a new state
+func newLineage() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate state lineage: %w", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
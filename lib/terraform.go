@@ -2,32 +2,176 @@ package lib
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
+	"sort"
+	"sync"
 )
 
-// TerraformGenerator handles the generation of Terraform files
+// TerraformGenerator handles the generation of Terraform files. Handlers running
+// concurrently may add resources at the same time; mu guards the collected model.
 type TerraformGenerator struct {
+	mu             sync.Mutex
 	outputDir      string
 	config         *Config
+	writer         OutputWriter
 	resources      []TerraformResource
 	importCommands []ImportCommand
+	discovered     map[string]int
+	skipped        []SkippedResource
+
+	// dataSourceIDs holds type/id keys of objects that rules convert to data
+	// sources, dataReferences maps their resource references to data references
+	dataSourceIDs  map[string]bool
+	dataReferences map[string]string
+
+	// unreferencedSkips holds data sources added by AddDataSourceIfReferenced,
+	// by their resource reference, with the skip record if nothing references them
+	unreferencedSkips map[string]SkippedResource
+
+	// issuedBy holds type/id keys of objects not created through the API,
+	// annotated by AddResource
+	issuedBy map[string]string
+
+	// assignedNames maps type/id keys to the resource name UniqueName gave the
+	// object, nameOwners maps type/name keys to the ID of the object using it
+	assignedNames  map[string]string
+	nameOwners     map[string]string
+	nameCollisions []NameCollision
+	overridesUsed  map[string]bool
+
+	// references maps object IDs to the references of their resources and
+	// data sources; unresolved holds the references written as literal IDs
+	references *ReferenceResolver
+	unresolved []UnresolvedReference
+
+	// limitSeen and limitKept count the objects per type considered for, and
+	// kept within, Config.Limit; limitedIDs holds the type/id keys beyond it
+	limitSeen  map[string]int
+	limitKept  map[string]int
+	limitedIDs map[string]bool
 }
 
 // NewTerraformGenerator creates a new Terraform generator
 func NewTerraformGenerator(outputDir string, config *Config) *TerraformGenerator {
-	return &TerraformGenerator{
+	writer, err := NewOutputWriter(config.Format)
+	if err != nil {
+		slog.Warn("Falling back to hcl output", "error", err)
+		writer = &HCLWriter{}
+	}
+
+	tg := &TerraformGenerator{
 		outputDir:      outputDir,
 		config:         config,
+		writer:         writer,
 		resources:      make([]TerraformResource, 0),
 		importCommands: make([]ImportCommand, 0),
+		discovered:     make(map[string]int),
+		skipped:        make([]SkippedResource, 0),
+		dataSourceIDs:  make(map[string]bool),
+		dataReferences: make(map[string]string),
+		issuedBy:       make(map[string]string),
+		assignedNames:  make(map[string]string),
+		nameOwners:     make(map[string]string),
+		nameCollisions: make([]NameCollision, 0),
+		overridesUsed:  make(map[string]bool),
+
+		unreferencedSkips: make(map[string]SkippedResource),
+		references:        NewReferenceResolver(),
+		unresolved:        make([]UnresolvedReference, 0),
+
+		limitSeen:  make(map[string]int),
+		limitKept:  make(map[string]int),
+		limitedIDs: make(map[string]bool),
+	}
+
+	// Override names are reserved before any object claims a name, then the
+	// names in the existing state that no override takes
+	for resourceType, names := range config.NameOverrides {
+		for id, name := range names {
+			tg.assignedNames[resourceType+"/"+id] = name
+			tg.nameOwners[resourceType+"/"+name] = id
+		}
+	}
+	for resourceType, names := range config.StateNames {
+		for id, name := range names {
+			key := resourceType + "/" + id
+			if _, overridden := tg.assignedNames[key]; overridden || tg.nameTaken(resourceType, name) {
+				continue
+			}
+			tg.assignedNames[key] = name
+			tg.nameOwners[resourceType+"/"+name] = id
+		}
+	}
+	return tg
+}
+
+// IncludeResource reports whether an object should be generated, applying the
+// resource type exclusions, the name filters, the config file rules and, when no
+// rule matches, SkipSystemGroups, the provider defaults knowledge base and the
+// action configured for who issued the object. Objects that
+// are converted to a data source are included and handled by AddResource.
+func (tg *TerraformGenerator) IncludeResource(resourceType, id, displayName, issued string) bool {
+	if tg.config.IsExcluded(resourceType) {
+		return false
+	}
+
+	if !tg.config.MatchesNameFilter(displayName) {
+		slog.Info("Skipping resource filtered by name", "type", resourceType, "name", displayName)
+		tg.SkipResource(resourceType, displayName, "filtered by name")
+		return false
+	}
+
+	action, reason := RuleImport, "by rule"
+	rule, err := FirstMatchingRule(tg.config.Rules, RuleObject{Type: resourceType, ID: id, Name: displayName, Issued: issued})
+	if err != nil {
+		slog.Warn("Rule evaluation failed, importing resource", "type", resourceType, "name", displayName, "error", err)
+	} else if rule != nil {
+		action = rule.Action
+	} else if system := SystemGroup(displayName, issued); tg.config.SkipSystemGroups && resourceType == "group" && system != "" {
+		action, reason = RuleDataSource, "as a system group: "+system
+	} else if managed := tg.providerManagedObject(resourceType, displayName); managed != nil {
+		action, reason = managed.Action, "as managed by the provider: "+managed.Description
+	} else if issuedAction, exists := tg.config.IssuedActions[issued]; exists {
+		action, reason = issuedAction, "issued by "+issued
+	}
+
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+
+	if issued != "" && issued != IssuedAPI {
+		tg.issuedBy[resourceType+"/"+id] = issued
+	}
+
+	switch action {
+	case RuleSkip:
+		slog.Info("Skipping resource", "type", resourceType, "name", displayName, "reason", reason)
+		tg.skipped = append(tg.skipped, SkippedResource{Type: resourceType, Name: displayName, Reason: "skipped " + reason})
+		return false
+	case RuleDataSource:
+		tg.trace("Converting resource to data source", "type", resourceType, "name", displayName, "reason", reason)
+		tg.dataSourceIDs[resourceType+"/"+id] = true
+	case RuleImport:
+		if !tg.withinLimit(resourceType) {
+			tg.trace("Converting resource beyond the limit to data source", "type", resourceType, "name", displayName, "limit", tg.config.Limit)
+			tg.dataSourceIDs[resourceType+"/"+id] = true
+			tg.limitedIDs[resourceType+"/"+id] = true
+		}
 	}
+
+	return true
 }
 
 // AddResource adds a resource to be generated and queues terraform import
 func (tg *TerraformGenerator) AddResource(resourceType, name string, attributes map[string]any) {
+	if tg.config.IsExcluded(resourceType) {
+		return
+	}
+
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+
 	// Extract and store the ID separately
 	var resourceID string
 	if id, exists := attributes["id"]; exists {
@@ -36,50 +180,243 @@ func (tg *TerraformGenerator) AddResource(resourceType, name string, attributes
 		}
 	}
 
-	tg.resources = append(tg.resources, TerraformResource{
+	// Rules may turn the object into a data source looked up by ID, which is
+	// referenced but neither managed nor imported
+	if tg.dataSourceIDs[resourceType+"/"+resourceID] {
+		tg.addDataSource(resourceType, name, map[string]any{"id": resourceID})
+		reference := CreateTerraformReference(resourceType, name)
+		tg.dataReferences[reference] = "data." + reference
+		tg.references.Add(resourceType, resourceID, reference)
+		if tg.limitedIDs[resourceType+"/"+resourceID] {
+			tg.unreferencedSkips[reference] = SkippedResource{Type: resourceType, Name: name, Reason: fmt.Sprintf("beyond the limit of %d", tg.config.Limit)}
+		}
+		return
+	}
+
+	// A resource whose import is bound to fail would be created anew by the next
+	// apply, duplicating the object; leave it out instead
+	if resourceID != "" {
+		if err := ValidateImportID(resourceType, resourceID); err != nil {
+			slog.Warn("Skipping resource with an invalid import ID", "type", resourceType, "name", name, "error", err)
+			tg.skipped = append(tg.skipped, SkippedResource{Type: resourceType, Name: name, Reason: "invalid import ID: " + err.Error()})
+			return
+		}
+	}
+
+	if tg.config.ProviderDefaults {
+		for _, adjustment := range AdjustProviderDefaults(tg.config.KnowledgeBaseVersion(), resourceType, attributes) {
+			tg.trace("Adjusted resource to the provider defaults", "type", resourceType, "name", name, "reason", adjustment)
+		}
+	}
+
+	resource := TerraformResource{
 		Type:       resourceType,
 		Name:       name,
 		Attributes: attributes,
 		IsData:     false,
 		ID:         resourceID,
-	})
-	fmt.Printf("  Added %s resource: %s\n", resourceType, name)
+		URL:        CreateDashboardURL(tg.config.DashboardURL, resourceType, resourceID),
+		Provider:   tg.config.ProviderReference(),
+	}
+	if tg.config.URLComments && resource.URL != "" {
+		resource.Comments = append(resource.Comments, resource.URL)
+	}
+	if issued, exists := tg.issuedBy[resourceType+"/"+resourceID]; exists {
+		resource.Comments = append(resource.Comments, IssuedComment(issued))
+	}
+	if lifecycle, exists := tg.config.Lifecycle[resourceType]; exists && !lifecycle.IsEmpty() {
+		resource.Lifecycle = &lifecycle
+	}
+
+	tg.resources = append(tg.resources, resource)
+	tg.references.Add(resourceType, resourceID, CreateTerraformReference(resourceType, name))
+	tg.trace("Added resource", "type", resourceType, "name", name)
 
 	// Queue terraform import for this resource
-	tg.QueueImport(resourceType, name, resourceID)
+	tg.queueImport(resourceType, name, resourceID)
+}
+
+// providerManagedObject returns the knowledge base entry of an object the
+// provider manages itself, unless the knowledge base is disabled
+func (tg *TerraformGenerator) providerManagedObject(resourceType, name string) *ProviderDefault {
+	if !tg.config.ProviderDefaults {
+		return nil
+	}
+	return ProviderManagedObject(tg.config.KnowledgeBaseVersion(), resourceType, name)
 }
 
 // AddDataSource adds a data source to be generated
 func (tg *TerraformGenerator) AddDataSource(dataType, name string, attributes map[string]any) {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+
+	tg.addDataSource(dataType, name, attributes)
+}
+
+// addDataSource adds a data source; the caller holds mu
+func (tg *TerraformGenerator) addDataSource(dataType, name string, attributes map[string]any) {
+	if tg.config.IsExcluded(dataType) {
+		return
+	}
+
 	tg.resources = append(tg.resources, TerraformResource{
 		Type:       dataType,
 		Name:       name,
 		Attributes: attributes,
 		IsData:     true,
+		Provider:   tg.config.ProviderReference(),
 	})
-	fmt.Printf("  Added %s data source: %s\n", dataType, name)
+	if id, ok := attributes["id"].(string); ok {
+		tg.references.Add(dataType, id, "data."+CreateTerraformReference(dataType, name))
+	}
+	tg.trace("Added data source", "type", dataType, "name", name)
+}
+
+// AddDataSourceIfReferenced adds a data source in place of a resource, which
+// DropUnreferencedDataSources removes again unless another resource references
+// it. References to the resource are rewritten to the data source like for
+// objects rules convert.
+func (tg *TerraformGenerator) AddDataSourceIfReferenced(dataType, name, displayName, reason string, attributes map[string]any) {
+	if tg.config.IsExcluded(dataType) {
+		return
+	}
+
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+
+	tg.addDataSource(dataType, name, attributes)
+	reference := CreateTerraformReference(dataType, name)
+	tg.dataReferences[reference] = "data." + reference
+	tg.unreferencedSkips[reference] = SkippedResource{Type: dataType, Name: displayName, Reason: reason}
+}
+
+// DropUnreferencedDataSources removes the data sources added by
+// AddDataSourceIfReferenced that no resource references, records them as
+// skipped and returns how many were removed. It runs once every handler has
+// added its resources.
+func (tg *TerraformGenerator) DropUnreferencedDataSources() int {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+
+	if len(tg.unreferencedSkips) == 0 {
+		return 0
+	}
+
+	referenced := make(map[string]bool)
+	for _, resource := range tg.resources {
+		if _, optional := tg.unreferencedSkips[CreateTerraformReference(resource.Type, resource.Name)]; optional && resource.IsData {
+			continue
+		}
+		collectReferences(resource.Attributes, referenced)
+	}
+
+	kept := make([]TerraformResource, 0, len(tg.resources))
+	dropped := 0
+	for _, resource := range tg.resources {
+		reference := CreateTerraformReference(resource.Type, resource.Name)
+		skip, optional := tg.unreferencedSkips[reference]
+		if optional && resource.IsData && !referenced[reference] && !referenced["data."+reference] {
+			tg.trace("Dropped unreferenced data source", "type", resource.Type, "name", resource.Name)
+			tg.skipped = append(tg.skipped, skip)
+			delete(tg.dataReferences, reference)
+			dropped++
+			continue
+		}
+		kept = append(kept, resource)
+	}
+	tg.resources = kept
+	tg.unreferencedSkips = make(map[string]SkippedResource)
+	return dropped
 }
 
 // QueueImport queues a terraform import command
 func (tg *TerraformGenerator) QueueImport(resourceType, name string, resourceID string) {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+
+	tg.queueImport(resourceType, name, resourceID)
+}
+
+// queueImport queues a terraform import command; the caller holds mu
+func (tg *TerraformGenerator) queueImport(resourceType, name string, resourceID string) {
+	if tg.config.IsExcluded(resourceType) {
+		return
+	}
+
 	if resourceID == "" {
-		fmt.Printf("  Warning: No ID found for %s resource %s, skipping terraform import\n", resourceType, name)
+		slog.Warn("No ID found, skipping terraform import", "type", resourceType, "name", name)
 		return
 	}
 
 	resourceAddress := fmt.Sprintf("netbird_%s.%s", resourceType, name)
 
 	tg.importCommands = append(tg.importCommands, ImportCommand{
+		ResourceType:    resourceType,
 		ResourceAddress: resourceAddress,
 		ResourceID:      resourceID,
 	})
 
-	fmt.Printf("  Queued terraform import for %s\n", resourceAddress)
+	tg.trace("Queued terraform import", "address", resourceAddress)
+}
+
+// trace logs per-resource generation tracing with VerboseGenerate
+func (tg *TerraformGenerator) trace(msg string, args ...any) {
+	if tg.config.Verbosity.Has(VerboseGenerate) {
+		slog.Debug(msg, append([]any{"component", "generate"}, args...)...)
+	}
 }
 
-// GetImportCommands returns the list of import commands
+// RecordDiscovered records how many objects of a type were fetched
+func (tg *TerraformGenerator) RecordDiscovered(resourceType string, count int) {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+
+	tg.discovered[resourceType] += count
+}
+
+// SkipResource records an object that was fetched but not generated
+func (tg *TerraformGenerator) SkipResource(resourceType, name, reason string) {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+
+	tg.skipped = append(tg.skipped, SkippedResource{Type: resourceType, Name: name, Reason: reason})
+}
+
+// GetDiscovered returns the number of fetched objects per resource type
+func (tg *TerraformGenerator) GetDiscovered() map[string]int {
+	return tg.discovered
+}
+
+// GetSkipped returns the objects that were fetched but not generated
+func (tg *TerraformGenerator) GetSkipped() []SkippedResource {
+	return tg.skipped
+}
+
+// StartProgress starts a progress indicator for a batch of work. Handlers
+// fetching concurrently would draw over each other's bars, so they log instead.
+func (tg *TerraformGenerator) StartProgress(label string, total int) *Progress {
+	return NewProgress(label, total, tg.config.InteractiveProgress && tg.config.Concurrency <= 1)
+}
+
+// GetImportCommands returns the import commands in the configured import order.
+// Resource types not in the order keep their generation order after the others.
 func (tg *TerraformGenerator) GetImportCommands() []ImportCommand {
-	return tg.importCommands
+	priority := make(map[string]int, len(tg.config.ImportOrder))
+	for i, resourceType := range tg.config.ImportOrder {
+		priority[resourceType] = i
+	}
+	rank := func(resourceType string) int {
+		if i, exists := priority[resourceType]; exists {
+			return i
+		}
+		return len(priority)
+	}
+
+	ordered := append([]ImportCommand{}, tg.importCommands...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return rank(ordered[i].ResourceType) < rank(ordered[j].ResourceType)
+	})
+	return ordered
 }
 
 // GetResources returns the list of resources
@@ -87,136 +424,74 @@ func (tg *TerraformGenerator) GetResources() []TerraformResource {
 	return tg.resources
 }
 
-// WriteResource writes a single resource or data source to the file
+// WriteResource writes a single resource or data source to the file in HCL
 func (tg *TerraformGenerator) WriteResource(file *os.File, resource TerraformResource) error {
-	// Write resource or data source block
-	if resource.IsData {
-		fmt.Fprintf(file, "data \"netbird_%s\" \"%s\" {\n", resource.Type, resource.Name)
-	} else {
-		fmt.Fprintf(file, "resource \"netbird_%s\" \"%s\" {\n", resource.Type, resource.Name)
-	}
-
-	// Write attributes
-	for key, value := range resource.Attributes {
-		err := tg.writeAttribute(file, key, value, 1)
-		if err != nil {
-			return err
-		}
-	}
+	return (&HCLWriter{}).WriteResource(file, resource)
+}
 
-	fmt.Fprintf(file, "}\n")
-	return nil
+// WriteImportBlocks writes import blocks for the given import commands into dir,
+// typically a workspace, using the configured output writer
+func (tg *TerraformGenerator) WriteImportBlocks(dir string, importCommands []ImportCommand) error {
+	return tg.writer.WriteImportBlocks(dir, importCommands)
 }
 
-// WriteResourceFile writes resources to a specific file
+// WriteResourceFile writes resources of a specific type using the configured output writer
 func (tg *TerraformGenerator) WriteResourceFile(resourceType string, resources []TerraformResource) error {
-	filename := filepath.Join(tg.outputDir, fmt.Sprintf("%s.tf", resourceType))
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	// Write file header
-	fmt.Fprintf(file, "# NetBird %s resources\n# Generated by NetBird terraformer Terraformer\n\n", resourceType)
-
-	// Write each resource
-	for _, resource := range resources {
-		err := tg.WriteResource(file, resource)
-		if err != nil {
-			return err
-		}
-		fmt.Fprintf(file, "\n")
+	if tg.config.IsExcluded(resourceType) {
+		return nil
 	}
 
-	return nil
+	return tg.writer.WriteResources(tg.outputDir, resourceType, tg.resolveDataReferences(resources))
 }
 
-// writeAttribute writes an attribute to the file with proper formatting
-func (tg *TerraformGenerator) writeAttribute(file *os.File, key string, value any, indent int) error {
-	// Skip read-only fields in Terraform
-	if key == "id" || key == "network_type" || key == "peers" {
-		return nil
+// resolveDataReferences returns the resources with references to objects that
+// rules converted to data sources pointing at the data sources
+func (tg *TerraformGenerator) resolveDataReferences(resources []TerraformResource) []TerraformResource {
+	if len(tg.dataReferences) == 0 {
+		return resources
 	}
 
-	indentStr := strings.Repeat("  ", indent)
+	rewritten := make([]TerraformResource, 0, len(resources))
+	for _, resource := range resources {
+		resource.Attributes = rewriteReferences(resource.Attributes, tg.dataReferences).(map[string]any)
+		rewritten = append(rewritten, resource)
+	}
+	return rewritten
+}
 
-	switch v := value.(type) {
-	case string:
-		if v != "" {
-			fmt.Fprintf(file, "%s%s = \"%s\"\n", indentStr, key, EscapeString(v))
+// rewriteReferences returns a copy of an attribute value with references to
+// converted resources replaced by their data source references
+func rewriteReferences(value any, references map[string]string) any {
+	switch typed := value.(type) {
+	case Expression:
+		if replacement, exists := references[string(typed)]; exists {
+			return Expression(replacement)
 		}
-	case bool:
-		fmt.Fprintf(file, "%s%s = %t\n", indentStr, key, v)
-	case int, int64, float64:
-		fmt.Fprintf(file, "%s%s = %v\n", indentStr, key, v)
+		return typed
 	case []any:
-		if len(v) > 0 {
-			// Check if this is a list of maps (like rules)
-			if len(v) > 0 {
-				if _, isMap := v[0].(map[string]any); isMap {
-					// Handle as blocks (e.g., rules blocks)
-					for _, item := range v {
-						if itemMap, ok := item.(map[string]any); ok {
-							// Use singular form for block name
-							blockName := GetBlockName(key)
-							fmt.Fprintf(file, "%s%s {\n", indentStr, blockName)
-							for k, val := range itemMap {
-								err := tg.writeAttribute(file, k, val, indent+1)
-								if err != nil {
-									return err
-								}
-							}
-							fmt.Fprintf(file, "%s}\n", indentStr)
-						}
-					}
-					return nil
-				}
-			}
-
-			// Handle as regular list
-			fmt.Fprintf(file, "%s%s = [\n", indentStr, key)
-			for _, item := range v {
-				if str, ok := item.(string); ok && str != "" {
-					// Check if this is a Terraform reference (starts with netbird_)
-					if strings.HasPrefix(str, "netbird_") {
-						// Output without quotes for Terraform references
-						fmt.Fprintf(file, "%s  %s,\n", indentStr, str)
-					} else {
-						fmt.Fprintf(file, "%s  \"%s\",\n", indentStr, EscapeString(str))
-					}
-				}
-			}
-			fmt.Fprintf(file, "%s]\n", indentStr)
-		}
-	case []string:
-		if len(v) > 0 {
-			fmt.Fprintf(file, "%s%s = [\n", indentStr, key)
-			for _, item := range v {
-				if item != "" {
-					// Check if this is a Terraform reference
-					if strings.HasPrefix(item, "netbird_") {
-						// Output without quotes for Terraform references
-						fmt.Fprintf(file, "%s  %s,\n", indentStr, item)
-					} else {
-						fmt.Fprintf(file, "%s  \"%s\",\n", indentStr, EscapeString(item))
-					}
-				}
-			}
-			fmt.Fprintf(file, "%s]\n", indentStr)
+		rewritten := make([]any, 0, len(typed))
+		for _, item := range typed {
+			rewritten = append(rewritten, rewriteReferences(item, references))
 		}
+		return rewritten
 	case map[string]any:
-		fmt.Fprintf(file, "%s%s {\n", indentStr, key)
-		for k, val := range v {
-			tg.writeAttribute(file, k, val, indent+1)
+		rewritten := make(map[string]any, len(typed))
+		for key, item := range typed {
+			rewritten[key] = rewriteReferences(item, references)
 		}
-		fmt.Fprintf(file, "%s}\n", indentStr)
+		return rewritten
+	case []map[string]any:
+		rewritten := make([]map[string]any, 0, len(typed))
+		for _, item := range typed {
+			rewritten = append(rewritten, rewriteReferences(item, references).(map[string]any))
+		}
+		return rewritten
+	default:
+		return value
 	}
-
-	return nil
 }
 
-// GenerateProviderFile generates the provider.tf file
+// GenerateProviderFile generates the provider configuration using the configured output writer
 func (tg *TerraformGenerator) GenerateProviderFile() error {
 	// Create output directory
 	err := os.MkdirAll(tg.outputDir, 0755)
@@ -224,73 +499,33 @@ func (tg *TerraformGenerator) GenerateProviderFile() error {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	filename := filepath.Join(tg.outputDir, "provider.tf")
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	providerConfig := fmt.Sprintf(`# NetBird Terraform Provider Configuration
-# Generated by NetBird Terraformer
-
-terraform {
-  required_providers {
-    netbird = {
-      source  = "netbirdio/netbird"
-      version = "~> 0.0.5"
-    }
-  }
-}
-
-provider "netbird" {
-  management_url = "%s"
-  token          = "%s"
+	return tg.writer.WriteProvider(tg.outputDir, tg.config)
 }
-`, tg.config.ServerURL, tg.config.APIToken)
 
-	fmt.Fprint(file, providerConfig)
-	return nil
-}
-
-// GenerateImportScript generates a script with all terraform import commands
-func (tg *TerraformGenerator) GenerateImportScript() error {
-	if len(tg.importCommands) == 0 {
-		return nil
+// GenerateRunMetadata records the importer run in a Terraform output using the
+// configured output writer. With split state every module records it.
+func (tg *TerraformGenerator) GenerateRunMetadata(metadata RunMetadata) error {
+	if !tg.config.SplitState {
+		return tg.writer.WriteRunMetadata(tg.outputDir, metadata)
 	}
 
-	scriptPath := filepath.Join(tg.outputDir, "import.sh")
-	file, err := os.Create(scriptPath)
-	if err != nil {
-		return err
+	for _, resourceType := range tg.ModuleTypes() {
+		err := tg.writer.WriteRunMetadata(tg.ModuleDir(resourceType), metadata)
+		if err != nil {
+			return err
+		}
 	}
-	defer file.Close()
+	return nil
+}
 
-	err = os.Chmod(scriptPath, 0755)
+// WriteFile writes an auxiliary file into the output directory
+func (tg *TerraformGenerator) WriteFile(filename string, content []byte) error {
+	err := os.MkdirAll(tg.outputDir, 0755)
 	if err != nil {
-		return err
-	}
-
-	fmt.Fprintln(file, "#!/bin/bash")
-	fmt.Fprintln(file, "# NetBird Terraform Import Script")
-	fmt.Fprintln(file, "# Generated by NetBird terraformer Terraformer")
-	fmt.Fprintln(file, "")
-	fmt.Fprintln(file, "set -e")
-	fmt.Fprintln(file, "")
-	fmt.Fprintln(file, "echo \"Running terraform init...\"")
-	fmt.Fprintln(file, "terraform init")
-	fmt.Fprintln(file, "")
-	fmt.Fprintln(file, "echo \"Running terraform imports...\"")
-
-	for _, cmd := range tg.importCommands {
-		fmt.Fprintf(file, "echo \"Importing %s...\"\n", cmd.ResourceAddress)
-		fmt.Fprintf(file, "terraform import \"%s\" \"%s\"\n", cmd.ResourceAddress, cmd.ResourceID)
-		fmt.Fprintln(file, "")
+		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	fmt.Fprintln(file, "echo \"All imports completed!\"")
-
-	return nil
+	return os.WriteFile(filepath.Join(tg.outputDir, filename), content, 0644)
 }
 
 // GroupMapping represents a group mapping for JSON output
@@ -298,9 +533,11 @@ type GroupMapping struct {
 	ID           string `json:"id"`
 	Name         string `json:"name"`
 	ResourceName string `json:"terraform_resource_name"`
+	URL          string `json:"dashboard_url,omitempty"`
 }
 
-// GenerateGroupMapping generates a JSON file with group mappings
+// GenerateGroupMapping generates a JSON file with group mappings. Groups are
+// listed by ID, since several groups may share a name.
 func (tg *TerraformGenerator) GenerateGroupMapping() error {
 	mappings := make([]GroupMapping, 0)
 
@@ -309,15 +546,21 @@ func (tg *TerraformGenerator) GenerateGroupMapping() error {
 			if name, exists := resource.Attributes["name"]; exists {
 				if nameStr, ok := name.(string); ok {
 					mappings = append(mappings, GroupMapping{
-						ID:           "<!-- ID will be available after terraform apply -->",
+						ID:           resource.ID,
 						Name:         nameStr,
 						ResourceName: resource.Name,
+						URL:          resource.URL,
 					})
 				}
 			}
 		}
 	}
 
+	// Nothing to look up in an account without groups
+	if len(mappings) == 0 {
+		return nil
+	}
+
 	mappingPath := filepath.Join(tg.outputDir, "group_mappings.json")
 	file, err := os.Create(mappingPath)
 	if err != nil {
@@ -326,14 +569,18 @@ func (tg *TerraformGenerator) GenerateGroupMapping() error {
 	defer file.Close()
 
 	fmt.Fprintln(file, "{")
-	fmt.Fprintln(file, "  \"_note\": \"To get actual group IDs, run 'terraform show' after 'terraform apply'\",")
+	fmt.Fprintln(file, "  \"_note\": \"Groups sharing a name have their ID appended to the terraform resource name\",")
 	fmt.Fprintln(file, "  \"_usage\": \"Use netbird_group.<resource_name>.id in auto_groups for new users\",")
 	fmt.Fprintln(file, "  \"groups\": [")
 
 	for i, mapping := range mappings {
 		fmt.Fprintf(file, "    {\n")
+		fmt.Fprintf(file, "      \"id\": %q,\n", mapping.ID)
 		fmt.Fprintf(file, "      \"name\": %q,\n", mapping.Name)
 		fmt.Fprintf(file, "      \"terraform_resource\": %q,\n", mapping.ResourceName)
+		if mapping.URL != "" {
+			fmt.Fprintf(file, "      \"dashboard_url\": %q,\n", mapping.URL)
+		}
 		fmt.Fprintf(file, "      \"terraform_reference\": \"netbird_group.%s.id\"\n", mapping.ResourceName)
 		if i < len(mappings)-1 {
 			fmt.Fprintf(file, "    },\n")
@@ -347,25 +594,3 @@ func (tg *TerraformGenerator) GenerateGroupMapping() error {
 
 	return nil
 }
-
-// TerraformInit runs terraform init in the specified directory
-func TerraformInit(folderPath string) error {
-	cmd := exec.Command("terraform", "init")
-	cmd.Dir = folderPath
-
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	return cmd.Run()
-}
-
-// TerraformImport runs terraform import for a specific resource
-func TerraformImport(folderPath string, resourceAddress string, resourceID string) error {
-	cmd := exec.Command("terraform", "import", resourceAddress, resourceID)
-	cmd.Dir = folderPath
-
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	return cmd.Run()
-}
@@ -0,0 +1,122 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// terragruntFile is the configuration file Terragrunt reads in every folder
+const terragruntFile = "terragrunt.hcl"
+
+// writeTerragruntRoot writes the root terragrunt.hcl every module includes. It
+// configures the state of each module through remote_state, generates the
+// provider configuration into each module and passes the management URL as an
+// input, so the module folders hold nothing but resources.
+func (tg *TerraformGenerator) writeTerragruntRoot() error {
+	if err := os.MkdirAll(tg.outputDir, 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(filepath.Join(tg.outputDir, terragruntFile))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := &HCLWriter{}
+	fmt.Fprintf(file, "# NetBird Terragrunt root configuration\n# Generated by NetBird terraformer Terraformer\n\n")
+
+	// Without a backend every module keeps a local state in its own folder
+	backend := &BackendConfig{Type: "local"}
+	settings := map[string]any{"path": "${get_terragrunt_dir()}/terraform.tfstate"}
+	if tg.config.Backend != nil {
+		backend = tg.config.Backend
+		settings = make(map[string]any, len(backend.Settings)+1)
+		for key, value := range backend.Settings {
+			settings[key] = value
+		}
+		keyAttribute, key := backend.StateKey("${path_relative_to_include()}")
+		settings[keyAttribute] = key
+	}
+
+	fmt.Fprintf(file, "remote_state {\n")
+	fmt.Fprintf(file, "  backend = \"%s\"\n", backend.Type)
+	fmt.Fprintf(file, "  generate = {\n")
+	fmt.Fprintf(file, "    path      = \"backend.tf\"\n")
+	fmt.Fprintf(file, "    if_exists = \"overwrite_terragrunt\"\n")
+	fmt.Fprintf(file, "  }\n")
+	fmt.Fprintf(file, "  config = {\n")
+	for _, name := range sortedKeys(settings) {
+		if err := writer.writeAttribute(file, name, settings[name], 2); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(file, "  }\n")
+	fmt.Fprintf(file, "}\n\n")
+
+	fmt.Fprintf(file, "generate \"provider\" {\n")
+	fmt.Fprintf(file, "  path      = \"provider.tf\"\n")
+	fmt.Fprintf(file, "  if_exists = \"overwrite_terragrunt\"\n")
+	fmt.Fprintf(file, "  contents  = <<EOF\n")
+	fmt.Fprintf(file, "terraform {\n")
+	fmt.Fprintf(file, "  required_providers {\n")
+	fmt.Fprintf(file, "    netbird = {\n")
+	fmt.Fprintf(file, "      source  = \"netbirdio/netbird\"\n")
+	fmt.Fprintf(file, "      version = \"%s\"\n", tg.config.ProviderVersion)
+	fmt.Fprintf(file, "    }\n")
+	fmt.Fprintf(file, "  }\n")
+	fmt.Fprintf(file, "}\n\n")
+	fmt.Fprintf(file, "variable \"management_url\" {\n")
+	fmt.Fprintf(file, "  type = string\n")
+	fmt.Fprintf(file, "}\n\n")
+	fmt.Fprintf(file, "provider \"netbird\" {\n")
+	fmt.Fprintf(file, "  management_url = var.management_url\n")
+	fmt.Fprintf(file, "}\n")
+	fmt.Fprintf(file, "EOF\n")
+	fmt.Fprintf(file, "}\n\n")
+
+	fmt.Fprintf(file, "inputs = {\n")
+	fmt.Fprintf(file, "  management_url = \"%s\"\n", EscapeString(tg.config.ServerURL))
+	fmt.Fprintf(file, "}\n")
+	return nil
+}
+
+// writeTerragruntModule writes the terragrunt.hcl of a resource type's folder.
+// It includes the root configuration and depends on the folders managing the
+// objects its data sources look up, so run-all applies them first.
+func (tg *TerraformGenerator) writeTerragruntModule(resourceType string, lookupTypes []string) error {
+	file, err := os.Create(filepath.Join(tg.ModuleDir(resourceType), terragruntFile))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fmt.Fprintf(file, "# NetBird %s module\n# Generated by NetBird terraformer Terraformer\n\n", resourceType)
+	fmt.Fprintf(file, "include \"root\" {\n")
+	fmt.Fprintf(file, "  path = find_in_parent_folders()\n")
+	fmt.Fprintf(file, "}\n")
+
+	paths := make([]string, 0, len(lookupTypes))
+	for _, lookupType := range lookupTypes {
+		if tg.isModule(lookupType) {
+			paths = append(paths, fmt.Sprintf("\"../%s\"", lookupType))
+		}
+	}
+	if len(paths) > 0 {
+		fmt.Fprintf(file, "\ndependencies {\n")
+		fmt.Fprintf(file, "  paths = [%s]\n", strings.Join(paths, ", "))
+		fmt.Fprintf(file, "}\n")
+	}
+	return nil
+}
+
+// isModule reports whether a resource type has a folder of managed resources
+func (tg *TerraformGenerator) isModule(resourceType string) bool {
+	for _, resource := range tg.resources {
+		if resource.Type == resourceType && !resource.IsData {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,9 @@
 package lib
 
-import "strings"
+import (
+	"net/url"
+	"strings"
+)
 
 // SanitizeResourceName sanitizes a string to be used as a Terraform resource name
 func SanitizeResourceName(input string) string {
@@ -40,7 +43,14 @@ func EscapeString(s string) string {
 	s = strings.ReplaceAll(s, "\"", "\\\"")
 	s = strings.ReplaceAll(s, "\n", "\\n")
 	s = strings.ReplaceAll(s, "\t", "\\t")
-	return s
+	return escapeTemplate(s)
+}
+
+// escapeTemplate escapes the template sequences of a literal string, which
+// Terraform would otherwise interpolate in quoted HCL and JSON strings alike
+func escapeTemplate(s string) string {
+	s = strings.ReplaceAll(s, "${", "$${")
+	return strings.ReplaceAll(s, "%{", "%%{")
 }
 
 // GetBlockName converts plural list names to singular block names
@@ -63,3 +73,34 @@ func GetBlockName(key string) string {
 func CreateTerraformReference(resourceType, resourceName string) string {
 	return "netbird_" + resourceType + "." + resourceName + ".id"
 }
+
+// dashboardPages maps resource types to their page in the dashboard. Objects
+// with a page of their own get it with their ID in the id parameter; the
+// others link to the list they are shown in.
+var dashboardPages = map[string]struct {
+	path     string
+	byObject bool
+}{
+	"group":         {"/group", true},
+	"peer":          {"/peer", true},
+	"user":          {"/team/user", true},
+	"network":       {"/network", true},
+	"policy":        {"/access-control", false},
+	"posture_check": {"/posture-checks", false},
+	"route":         {"/network-routes", false},
+	"setup_key":     {"/setup-keys", false},
+}
+
+// CreateDashboardURL creates a link to a NetBird object in the dashboard, or
+// "" for resource types the dashboard has no page for
+func CreateDashboardURL(dashboardURL, resourceType, resourceID string) string {
+	page, exists := dashboardPages[resourceType]
+	if dashboardURL == "" || resourceID == "" || !exists {
+		return ""
+	}
+	link := strings.TrimSuffix(dashboardURL, "/") + page.path
+	if page.byObject {
+		link += "?id=" + url.QueryEscape(resourceID)
+	}
+	return link
+}
@@ -0,0 +1,30 @@
+package lib
+
+import "testing"
+
+func TestCreateDashboardURL(t *testing.T) {
+	tests := []struct {
+		dashboardURL string
+		resourceType string
+		id           string
+		want         string
+	}{
+		{"https://app.netbird.io", "group", "g1", "https://app.netbird.io/group?id=g1"},
+		{"https://app.netbird.io", "peer", "p1", "https://app.netbird.io/peer?id=p1"},
+		{"https://app.netbird.io", "user", "google-oauth2|1", "https://app.netbird.io/team/user?id=google-oauth2%7C1"},
+		{"https://app.netbird.io", "network", "n1", "https://app.netbird.io/network?id=n1"},
+		{"https://app.netbird.io", "policy", "pol1", "https://app.netbird.io/access-control"},
+		{"https://app.netbird.io", "posture_check", "pc1", "https://app.netbird.io/posture-checks"},
+		{"https://app.netbird.io", "route", "r1", "https://app.netbird.io/network-routes"},
+		{"https://app.netbird.io", "setup_key", "k1", "https://app.netbird.io/setup-keys"},
+		{"https://netbird.example.com/netbird/", "route", "r1", "https://netbird.example.com/netbird/network-routes"},
+		{"https://app.netbird.io", "widget", "w1", ""},
+		{"https://app.netbird.io", "group", "", ""},
+		{"", "group", "g1", ""},
+	}
+	for _, test := range tests {
+		if got := CreateDashboardURL(test.dashboardURL, test.resourceType, test.id); got != test.want {
+			t.Errorf("CreateDashboardURL(%q, %q, %q) = %q, want %q", test.dashboardURL, test.resourceType, test.id, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// workspaceStateFiles are the files terraform writes that are synced back to the
+// output directory after running in a workspace
+var workspaceStateFiles = []string{"terraform.tfstate", "terraform.tfstate.backup", ".terraform.lock.hcl"}
+
+// Workspace is an isolated copy of the output directory in which terraform commands
+// run, so that generation and terraform execution never contend over the same files
+type Workspace struct {
+	outputDir string
+	dir       string
+}
+
+// NewWorkspace copies the configuration and state files of the output directory into
+// a new temporary directory. The .terraform directory is not copied; run
+// TerraformInit in the workspace before other commands.
+func NewWorkspace(outputDir string) (*Workspace, error) {
+	dir, err := os.MkdirTemp("", "netbird-terraformer-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to read output directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		err := copyFile(filepath.Join(outputDir, entry.Name()), filepath.Join(dir, entry.Name()))
+		if err != nil {
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("failed to populate workspace: %w", err)
+		}
+	}
+
+	return &Workspace{
+		outputDir: outputDir,
+		dir:       dir,
+	}, nil
+}
+
+// Dir returns the workspace directory
+func (w *Workspace) Dir() string {
+	return w.dir
+}
+
+// SyncState copies the state and lock files from the workspace back to the output
+// directory. Each file is replaced atomically.
+func (w *Workspace) SyncState() error {
+	for _, name := range workspaceStateFiles {
+		source := filepath.Join(w.dir, name)
+		if _, err := os.Stat(source); os.IsNotExist(err) {
+			continue
+		}
+
+		destination := filepath.Join(w.outputDir, name)
+		temporary := destination + ".tmp"
+		err := copyFile(source, temporary)
+		if err != nil {
+			return fmt.Errorf("failed to sync %s: %w", name, err)
+		}
+
+		err = ReplaceFile(temporary, destination)
+		if err != nil {
+			os.Remove(temporary)
+			return fmt.Errorf("failed to sync %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Close removes the workspace directory
+func (w *Workspace) Close() error {
+	return os.RemoveAll(w.dir)
+}
+
+// copyFile copies a regular file, preserving its permissions
+func copyFile(source, destination string) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(destination, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
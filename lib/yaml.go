@@ -0,0 +1,698 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a non-empty, comment-stripped line of a YAML document
+type yamlLine struct {
+	indent int
+	text   string
+	number int
+}
+
+// yamlParser parses the subset of YAML used by configuration files: block mappings
+// and sequences, flow sequences/mappings, and plain or quoted scalars. Anchors,
+// tags, multi-line scalars and multiple documents are not supported.
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// yamlPlain is an unquoted scalar as written. The decoder resolves it by the
+// type it is stored in, so that a string field keeps 1.10 or 0123 as is.
+type yamlPlain string
+
+// DecodeYAML parses a YAML document and decodes it into out, a pointer, using
+// the json tags of structs. Keys without a matching field are rejected, so
+// that a misspelt setting is not silently ignored. Plain scalars decode into
+// string fields as their literal text.
+func DecodeYAML(data []byte, out any) error {
+	value, err := parseYAMLDocument(data)
+	if err != nil {
+		return err
+	}
+
+	target := reflect.ValueOf(out)
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		return fmt.Errorf("DecodeYAML needs a non-nil pointer, got %T", out)
+	}
+	return decodeYAMLValue(value, target.Elem(), "")
+}
+
+// ParseYAML parses a YAML document into maps, slices and scalars, resolving
+// plain scalars to booleans, nulls, int64 and float64 numbers or strings
+func ParseYAML(data []byte) (any, error) {
+	value, err := parseYAMLDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	return resolveYAML(value), nil
+}
+
+// parseYAMLDocument parses a YAML document, leaving plain scalars unresolved
+func parseYAMLDocument(data []byte) (any, error) {
+	lines := make([]yamlLine, 0)
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		if strings.Contains(raw, "\t") && strings.TrimLeft(raw, " \t") != strings.TrimLeft(raw, " ") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+
+		text := strings.TrimRight(stripYAMLComment(raw), " ")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+
+		lines = append(lines, yamlLine{
+			indent: len(text) - len(trimmed),
+			text:   trimmed,
+			number: i + 1,
+		})
+	}
+
+	if len(lines) == 0 {
+		return map[string]any{}, nil
+	}
+
+	parser := &yamlParser{lines: lines}
+	value, err := parser.parseBlock(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+
+	if parser.pos < len(parser.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", parser.lines[parser.pos].number)
+	}
+
+	return value, nil
+}
+
+// parseBlock parses a mapping or sequence starting at the current line
+func (p *yamlParser) parseBlock(indent int) (any, error) {
+	if isYAMLSequenceItem(p.lines[p.pos].text) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+// parseMapping parses consecutive "key: value" lines at the given indentation
+func (p *yamlParser) parseMapping(indent int) (map[string]any, error) {
+	result := make(map[string]any)
+
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.number)
+		}
+		if isYAMLSequenceItem(line.text) {
+			return nil, fmt.Errorf("line %d: unexpected sequence item in mapping", line.number)
+		}
+
+		key, rest, ok := splitYAMLKeyValue(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", line.number)
+		}
+		p.pos++
+
+		if rest != "" {
+			value, err := parseYAMLInline(rest)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line.number, err)
+			}
+			result[key] = value
+			continue
+		}
+
+		value, err := p.parseNested(indent)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = value
+	}
+
+	return result, nil
+}
+
+// parseSequence parses consecutive "- item" lines at the given indentation
+func (p *yamlParser) parseSequence(indent int) ([]any, error) {
+	result := make([]any, 0)
+
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent || !isYAMLSequenceItem(line.text) {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.number)
+		}
+
+		rest := strings.TrimLeft(line.text[1:], " ")
+		if rest == "" {
+			p.pos++
+			value, err := p.parseNested(indent)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, value)
+			continue
+		}
+
+		if _, _, isMapping := splitYAMLKeyValue(rest); isMapping || isYAMLSequenceItem(rest) {
+			// "- key: value" starts a nested block aligned with the item content
+			p.lines[p.pos] = yamlLine{
+				indent: line.indent + len(line.text) - len(rest),
+				text:   rest,
+				number: line.number,
+			}
+			value, err := p.parseBlock(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, value)
+			continue
+		}
+
+		value, err := parseYAMLInline(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line.number, err)
+		}
+		result = append(result, value)
+		p.pos++
+	}
+
+	return result, nil
+}
+
+// parseNested parses the block value of a key or sequence item with an empty inline
+// value. Sequences may start at the parent's indentation.
+func (p *yamlParser) parseNested(parentIndent int) (any, error) {
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+
+	next := p.lines[p.pos]
+	if next.indent > parentIndent {
+		return p.parseBlock(next.indent)
+	}
+	if next.indent == parentIndent && isYAMLSequenceItem(next.text) {
+		return p.parseSequence(parentIndent)
+	}
+	return nil, nil
+}
+
+// isYAMLSequenceItem reports whether a line starts a block sequence item
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKeyValue splits "key: value" outside of quotes and flow collections
+func splitYAMLKeyValue(text string) (string, string, bool) {
+	if strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") {
+		return "", "", false
+	}
+
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 {
+				quote = c
+			}
+		case c == ':' && (i == len(text)-1 || text[i+1] == ' '):
+			key, err := parseYAMLScalar(strings.TrimSpace(text[:i]))
+			if err != nil {
+				return "", "", false
+			}
+			return fmt.Sprint(key), strings.TrimSpace(text[i+1:]), true
+		}
+	}
+
+	return "", "", false
+}
+
+// parseYAMLInline parses a flow collection or scalar
+func parseYAMLInline(text string) (any, error) {
+	switch {
+	case strings.HasPrefix(text, "["):
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("unterminated flow sequence %q", text)
+		}
+		items := make([]any, 0)
+		for _, part := range splitYAMLFlow(text[1 : len(text)-1]) {
+			item, err := parseYAMLInline(part)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case strings.HasPrefix(text, "{"):
+		if !strings.HasSuffix(text, "}") {
+			return nil, fmt.Errorf("unterminated flow mapping %q", text)
+		}
+		result := make(map[string]any)
+		for _, part := range splitYAMLFlow(text[1 : len(text)-1]) {
+			key, rest, ok := splitYAMLKeyValue(part)
+			if !ok {
+				return nil, fmt.Errorf("expected \"key: value\" in flow mapping, got %q", part)
+			}
+			value, err := parseYAMLInline(rest)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = value
+		}
+		return result, nil
+	case strings.HasPrefix(text, "|") || strings.HasPrefix(text, ">"):
+		return nil, fmt.Errorf("block scalars are not supported")
+	}
+
+	return parseYAMLScalar(text)
+}
+
+// parseYAMLScalar parses a quoted scalar, or returns a plain scalar as written
+func parseYAMLScalar(text string) (any, error) {
+	if strings.HasPrefix(text, "\"") {
+		value, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid double-quoted string %s", text)
+		}
+		return value, nil
+	}
+	if strings.HasPrefix(text, "'") {
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return nil, fmt.Errorf("invalid single-quoted string %s", text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	}
+	return yamlPlain(text), nil
+}
+
+// resolveYAML resolves the plain scalars of a parsed value
+func resolveYAML(value any) any {
+	switch typed := value.(type) {
+	case yamlPlain:
+		return resolveYAMLPlain(string(typed))
+	case []any:
+		for i, item := range typed {
+			typed[i] = resolveYAML(item)
+		}
+	case map[string]any:
+		for key, item := range typed {
+			typed[key] = resolveYAML(item)
+		}
+	}
+	return value
+}
+
+// resolveYAMLPlain resolves a plain scalar to a boolean, null, number or string
+func resolveYAMLPlain(text string) any {
+	switch strings.ToLower(text) {
+	case "", "~", "null":
+		return nil
+	case "true", "yes", "on":
+		return true
+	case "false", "no", "off":
+		return false
+	}
+
+	if number, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return number
+	}
+	if number, err := strconv.ParseFloat(text, 64); err == nil {
+		return number
+	}
+
+	return text
+}
+
+// decodeYAMLValue stores a parsed value in target. Path locates the value in
+// the document for error messages, e.g. rules[0].when.
+func decodeYAMLValue(value any, target reflect.Value, path string) error {
+	if isYAMLNull(value) {
+		target.Set(reflect.Zero(target.Type()))
+		return nil
+	}
+
+	plain, isPlain := value.(yamlPlain)
+	switch target.Kind() {
+	case reflect.Pointer:
+		element := reflect.New(target.Type().Elem())
+		if err := decodeYAMLValue(value, element.Elem(), path); err != nil {
+			return err
+		}
+		target.Set(element)
+		return nil
+
+	case reflect.Interface:
+		target.Set(reflect.ValueOf(resolveYAML(value)))
+		return nil
+
+	case reflect.String:
+		if str, ok := value.(string); ok {
+			target.SetString(str)
+			return nil
+		}
+		if isPlain {
+			target.SetString(string(plain))
+			return nil
+		}
+
+	case reflect.Bool:
+		if resolved, ok := resolveYAMLPlain(string(plain)).(bool); isPlain && ok {
+			target.SetBool(resolved)
+			return nil
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if number, err := strconv.ParseInt(string(plain), 10, target.Type().Bits()); isPlain && err == nil {
+			target.SetInt(number)
+			return nil
+		}
+
+	case reflect.Float32, reflect.Float64:
+		if number, err := strconv.ParseFloat(string(plain), target.Type().Bits()); isPlain && err == nil {
+			target.SetFloat(number)
+			return nil
+		}
+
+	case reflect.Slice:
+		if items, ok := value.([]any); ok {
+			slice := reflect.MakeSlice(target.Type(), len(items), len(items))
+			for i, item := range items {
+				if err := decodeYAMLValue(item, slice.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+			target.Set(slice)
+			return nil
+		}
+
+	case reflect.Map:
+		if mapping, ok := value.(map[string]any); ok && target.Type().Key().Kind() == reflect.String {
+			result := reflect.MakeMapWithSize(target.Type(), len(mapping))
+			for _, key := range sortedKeys(mapping) {
+				element := reflect.New(target.Type().Elem()).Elem()
+				if err := decodeYAMLValue(mapping[key], element, yamlPath(path, key)); err != nil {
+					return err
+				}
+				result.SetMapIndex(reflect.ValueOf(key).Convert(target.Type().Key()), element)
+			}
+			target.Set(result)
+			return nil
+		}
+
+	case reflect.Struct:
+		if mapping, ok := value.(map[string]any); ok {
+			fields := yamlFields(target.Type())
+			for _, key := range sortedKeys(mapping) {
+				index, exists := fields[key]
+				if !exists {
+					return fmt.Errorf("%sunknown key %q", yamlLocation(path), key)
+				}
+				if err := decodeYAMLValue(mapping[key], target.FieldByIndex(index), yamlPath(path, key)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%sexpected %s, got %s", yamlLocation(path), yamlKind(target.Type()), yamlValueKind(value))
+}
+
+// yamlFields maps the keys of a struct, taken from json tags or field names,
+// to their field indexes. Fields of embedded structs are promoted.
+func yamlFields(structType reflect.Type) map[string][]int {
+	fields := make(map[string][]int)
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for key, index := range yamlFields(field.Type) {
+				fields[key] = append([]int{i}, index...)
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = []int{i}
+	}
+	return fields
+}
+
+// isYAMLNull reports whether a parsed value is null
+func isYAMLNull(value any) bool {
+	plain, isPlain := value.(yamlPlain)
+	return value == nil || isPlain && resolveYAMLPlain(string(plain)) == nil
+}
+
+// yamlPath appends a key to the path of a value
+func yamlPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// yamlLocation prefixes an error message with the path of the value
+func yamlLocation(path string) string {
+	if path == "" {
+		return ""
+	}
+	return path + ": "
+}
+
+// yamlKind describes the YAML value a Go type is decoded from
+func yamlKind(goType reflect.Type) string {
+	switch goType.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice:
+		return "a list"
+	case reflect.Map, reflect.Struct:
+		return "a mapping"
+	}
+	return "a string"
+}
+
+// yamlValueKind describes a parsed value for error messages
+func yamlValueKind(value any) string {
+	switch typed := value.(type) {
+	case []any:
+		return "a list"
+	case map[string]any:
+		return "a mapping"
+	case yamlPlain:
+		return fmt.Sprintf("%q", string(typed))
+	}
+	return fmt.Sprintf("%q", fmt.Sprint(value))
+}
+
+// splitYAMLFlow splits the contents of a flow collection on top-level commas
+func splitYAMLFlow(text string) []string {
+	parts := make([]string, 0)
+	depth := 0
+	var quote byte
+	start := 0
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(text[start:i]))
+			start = i + 1
+		}
+	}
+
+	if last := strings.TrimSpace(text[start:]); last != "" {
+		parts = append(parts, last)
+	}
+	return parts
+}
+
+// stripYAMLComment removes a trailing "# comment" outside of quotes
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || line[i-1] == ' ' || line[i-1] == '[' || line[i-1] == '{' || line[i-1] == ',' {
+				quote = c
+			}
+		case c == '#' && (i == 0 || line[i-1] == ' '):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// EncodeYAML renders a value as a block YAML document using its json tags.
+// Mapping keys are sorted and strings are always quoted, so the output reads
+// back with ParseYAML unchanged.
+func EncodeYAML(value any) ([]byte, error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(content)))
+	decoder.UseNumber()
+	var generic any
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	var builder strings.Builder
+	writeYAMLValue(&builder, generic, 0)
+	return []byte(builder.String()), nil
+}
+
+// QuoteYAML quotes a string as a double-quoted YAML scalar, for YAML written
+// line by line; JSON strings are valid YAML
+func QuoteYAML(value string) string {
+	quoted, _ := json.Marshal(value)
+	return string(quoted)
+}
+
+// writeYAMLValue writes a block mapping or sequence at an indentation, or a
+// scalar followed by a newline
+func writeYAMLValue(builder *strings.Builder, value any, indent int) {
+	prefix := strings.Repeat(" ", indent)
+	switch typed := value.(type) {
+	case map[string]any:
+		if len(typed) == 0 {
+			builder.WriteString(prefix + "{}\n")
+			return
+		}
+		keys := make([]string, 0, len(typed))
+		for key := range typed {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			builder.WriteString(prefix + yamlKey(key) + ":")
+			writeYAMLNested(builder, typed[key], indent)
+		}
+	case []any:
+		if len(typed) == 0 {
+			builder.WriteString(prefix + "[]\n")
+			return
+		}
+		for _, item := range typed {
+			if isYAMLCollection(item) {
+				// The first line of the nested block follows the dash
+				var nested strings.Builder
+				writeYAMLValue(&nested, item, indent+2)
+				builder.WriteString(prefix + "- " + strings.TrimPrefix(nested.String(), prefix+"  "))
+				continue
+			}
+			builder.WriteString(prefix + "- " + yamlScalar(item) + "\n")
+		}
+	default:
+		builder.WriteString(prefix + yamlScalar(typed) + "\n")
+	}
+}
+
+// writeYAMLNested writes the value of a mapping key: scalars and empty
+// collections on the key's line, collections on the following lines
+func writeYAMLNested(builder *strings.Builder, value any, indent int) {
+	switch typed := value.(type) {
+	case map[string]any:
+		if len(typed) == 0 {
+			builder.WriteString(" {}\n")
+			return
+		}
+	case []any:
+		if len(typed) == 0 {
+			builder.WriteString(" []\n")
+			return
+		}
+	default:
+		builder.WriteString(" " + yamlScalar(typed) + "\n")
+		return
+	}
+	builder.WriteString("\n")
+	writeYAMLValue(builder, value, indent+2)
+}
+
+// isYAMLCollection reports whether a value is a non-empty mapping or sequence
+func isYAMLCollection(value any) bool {
+	switch typed := value.(type) {
+	case map[string]any:
+		return len(typed) > 0
+	case []any:
+		return len(typed) > 0
+	}
+	return false
+}
+
+// yamlScalar renders a decoded JSON scalar; JSON strings are valid YAML
+func yamlScalar(value any) string {
+	switch typed := value.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "{}"
+	case []any:
+		return "[]"
+	case json.Number:
+		return typed.String()
+	case bool:
+		return strconv.FormatBool(typed)
+	case string:
+		return QuoteYAML(typed)
+	default:
+		quoted, _ := json.Marshal(typed)
+		return string(quoted)
+	}
+}
+
+// yamlKey leaves identifier-like keys plain and quotes the others
+func yamlKey(key string) string {
+	for _, r := range key {
+		if !(r == '_' || r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return QuoteYAML(key)
+		}
+	}
+	if key == "" {
+		return `""`
+	}
+	return key
+}
@@ -0,0 +1,163 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEncodeYAMLRoundTrip(t *testing.T) {
+	value := map[string]any{
+		"name":    "dev: team # 1",
+		"enabled": true,
+		"count":   3,
+		"empty":   []string{},
+		"ids":     []string{"g1", "g2"},
+		"rules": []map[string]any{
+			{"name": "ssh", "ports": []string{"22"}, "nested": map[string]any{"start": 1}},
+			{"name": "web"},
+		},
+	}
+
+	encoded, err := EncodeYAML(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	if err := DecodeYAML(encoded, &decoded); err != nil {
+		t.Fatalf("DecodeYAML() error = %v for:\n%s", err, encoded)
+	}
+	want := map[string]any{
+		"name": "dev: team # 1", "enabled": true, "count": int64(3), "empty": []any{}, "ids": []any{"g1", "g2"},
+		"rules": []any{
+			map[string]any{"name": "ssh", "ports": []any{"22"}, "nested": map[string]any{"start": int64(1)}},
+			map[string]any{"name": "web"},
+		},
+	}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("round trip = %#v, want %#v\n%s", decoded, want, encoded)
+	}
+}
+
+func TestDecodeYAML(t *testing.T) {
+	type rule struct {
+		When   string `json:"when"`
+		Action string `json:"action"`
+	}
+	type settings struct {
+		Version     string            `json:"provider_version"`
+		Server      string            `json:"server_version"`
+		Zip         string            `json:"zip"`
+		Enabled     *bool             `json:"enabled"`
+		Retries     *int              `json:"retries"`
+		QPS         float64           `json:"qps"`
+		Types       []string          `json:"types"`
+		Names       map[string]string `json:"names"`
+		Rules       []rule            `json:"rules"`
+		Backend     map[string]any    `json:"backend"`
+		Cleared     string            `json:"cleared"`
+		Token       string            `json:"-"`
+		unexported  string
+		Untagged    string
+		Overwritten []string `json:"overwritten"`
+	}
+
+	document := `
+provider_version: 1.10
+server_version: 0.30.0
+zip: 0123
+enabled: yes
+retries: 3
+qps: 2.5
+types: [group, 10]
+names:
+  ch8i4ug6lnn4g9hqv7m0: 007
+  "0123": "admins"
+rules:
+  - when: resource.type == "group"
+    action: skip
+backend:
+  bucket: tf-state
+  port: 8080
+cleared: ~
+Untagged: true
+overwritten:
+`
+	got := settings{Cleared: "default", Overwritten: []string{"a"}}
+	if err := DecodeYAML([]byte(document), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	enabled, retries := true, 3
+	want := settings{
+		Version: "1.10", Server: "0.30.0", Zip: "0123", Enabled: &enabled, Retries: &retries, QPS: 2.5,
+		Types:    []string{"group", "10"},
+		Names:    map[string]string{"ch8i4ug6lnn4g9hqv7m0": "007", "0123": "admins"},
+		Rules:    []rule{{When: `resource.type == "group"`, Action: "skip"}},
+		Backend:  map[string]any{"bucket": "tf-state", "port": int64(8080)},
+		Untagged: "true",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodeYAML() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeYAMLErrors(t *testing.T) {
+	type rule struct {
+		When string `json:"when"`
+	}
+	type settings struct {
+		Version string   `json:"provider_version"`
+		Enabled *bool    `json:"enabled"`
+		Retries int      `json:"retries"`
+		Rules   []rule   `json:"rules"`
+		Types   []string `json:"types"`
+		Token   string   `json:"-"`
+	}
+
+	tests := []struct {
+		document string
+		want     string
+	}{
+		{"provider-version: 1.2", `unknown key "provider-version"`},
+		{"rules:\n  - when: 'true'\n  - whn: 'false'", `rules[1]: unknown key "whn"`},
+		{"Token: secret", `unknown key "Token"`},
+		{"enabled: maybe", `enabled: expected a boolean, got "maybe"`},
+		{"enabled: 'true'", `enabled: expected a boolean, got "true"`},
+		{"retries: 1.5", `retries: expected an integer, got "1.5"`},
+		{"types: group", `types: expected a list, got "group"`},
+		{"provider_version: [1, 2]", "provider_version: expected a string, got a list"},
+		{"rules: {when: x}", "rules: expected a list, got a mapping"},
+	}
+	for _, test := range tests {
+		var got settings
+		err := DecodeYAML([]byte(test.document), &got)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("DecodeYAML(%q) error = %v, want %s", test.document, err, test.want)
+		}
+	}
+}
+
+func TestParseYAMLResolvesScalars(t *testing.T) {
+	value, err := ParseYAML([]byte("a: 1\nb: 1.5\nc: yes\nd: ~\ne: 0123\nf: '1'\ng: text\n0123: key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"a": int64(1), "b": 1.5, "c": true, "d": nil, "e": int64(123), "f": "1", "g": "text", "0123": "key"}
+	if !reflect.DeepEqual(value, want) {
+		t.Errorf("ParseYAML() = %#v, want %#v", value, want)
+	}
+}
+
+// Quoted values read back as the same string, however they look unquoted
+func TestQuoteYAML(t *testing.T) {
+	for _, value := range []string{"web-1", "0123", "true", "null", "a: b", "# x", "- y", `say "hi"`, "tab\there", ""} {
+		parsed, err := ParseYAML([]byte("key: " + QuoteYAML(value) + "\n"))
+		if err != nil {
+			t.Errorf("QuoteYAML(%q): %v", value, err)
+			continue
+		}
+		if got := parsed.(map[string]any)["key"]; got != value {
+			t.Errorf("QuoteYAML(%q) reads back as %#v", value, got)
+		}
+	}
+}
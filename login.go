@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// commandLogin logs in through the IdP's device authorization flow
+const commandLogin = "login"
+
+// Login defaults. NetBird accepts personal access tokens valid for 1 to 365
+// days; the one created by login is short-lived unless asked otherwise.
+const (
+	defaultLoginScopes    = "openid profile email"
+	defaultLoginExpiresIn = 1
+	deviceCodeGrantType   = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+// OIDCConfig is the identity provider of a self-hosted NetBird server, the
+// same the dashboard and the netbird CLI log in with
+type OIDCConfig struct {
+	Issuer   string `json:"issuer"`
+	ClientID string `json:"client_id"`
+	Audience string `json:"audience"`
+	Scopes   string `json:"scopes"`
+}
+
+// oidcDiscovery holds the endpoints of the issuer's openid-configuration
+type oidcDiscovery struct {
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+	TokenEndpoint               string `json:"token_endpoint"`
+}
+
+// deviceAuthorization is the response of the device authorization endpoint
+type deviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+	Error                   string `json:"error"`
+	ErrorDescription        string `json:"error_description"`
+}
+
+// tokenResponse is the response of the token endpoint, a token or an error
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// runLogin logs the user in through the device authorization flow and creates
+// a personal access token with the resulting access token. The token is
+// printed to stdout, for export NB_PAT=$(netbird-importer login), or stored in
+// the OS keyring with --keyring.
+func runLogin(arguments []string) int {
+	flags := flag.NewFlagSet(os.Args[0]+" "+commandLogin, flag.ExitOnError)
+	configPath := flags.String("config", "", "Path to a netbird-terraformer.yaml config file")
+	issuer := flags.String("issuer", "", "OIDC issuer URL of the NetBird IdP")
+	clientID := flags.String("client-id", "", "OIDC client ID with the device authorization grant enabled")
+	audience := flags.String("audience", "", "Audience of the access token, if the IdP needs one")
+	scopes := flags.String("scopes", "", "Requested scopes (default: "+defaultLoginScopes+")")
+	expiresIn := flags.Int("expires-in", defaultLoginExpiresIn, "Days the created personal access token is valid, 1 to 365")
+	keyring := flags.Bool("keyring", false, "Store the token in the OS keyring instead of printing it")
+	flags.Parse(arguments)
+
+	setFlags := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+
+	fileConfig, _, err := loadConfigFile(*configPath)
+	if err != nil {
+		slog.Error("Failed to read the config file", "error", err)
+		return exitFatal
+	}
+	oidc := OIDCConfig{
+		Issuer:   stringSetting(setFlags["issuer"], *issuer, "NB_OIDC_ISSUER", fileConfig.OIDC.Issuer, ""),
+		ClientID: stringSetting(setFlags["client-id"], *clientID, "NB_OIDC_CLIENT_ID", fileConfig.OIDC.ClientID, ""),
+		Audience: stringSetting(setFlags["audience"], *audience, "NB_OIDC_AUDIENCE", fileConfig.OIDC.Audience, ""),
+		Scopes:   stringSetting(setFlags["scopes"], *scopes, "", fileConfig.OIDC.Scopes, defaultLoginScopes),
+	}
+	if oidc.Issuer == "" || oidc.ClientID == "" {
+		slog.Error("login needs the IdP: --issuer and --client-id, NB_OIDC_ISSUER and NB_OIDC_CLIENT_ID, or oidc in the config file")
+		return exitFatal
+	}
+	if *expiresIn < 1 || *expiresIn > 365 {
+		slog.Error("Invalid --expires-in: must be 1 to 365 days", "expires_in", *expiresIn)
+		return exitFatal
+	}
+	serverURL := strings.TrimSuffix(stringSetting(false, "", "NB_MANAGEMENT_URL", fileConfig.ServerURL, "https://netbird.api.com:33073"), "/")
+
+	rootCAs, err := loadCACertPool(stringSetting(false, "", "NB_CA_CERT", fileConfig.CACert, ""))
+	if err != nil {
+		slog.Error("Failed to load the CA certificates", "error", err)
+		return exitFatal
+	}
+	client := &http.Client{Transport: newTransport(ServiceOptions{RootCAs: rootCAs}), Timeout: 30 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	accessToken, err := deviceLogin(ctx, client, oidc)
+	if err != nil {
+		slog.Error("Login failed", "error", err)
+		return exitFatal
+	}
+	token, err := createPersonalAccessToken(ctx, client, serverURL, accessToken, *expiresIn)
+	if err != nil {
+		slog.Error("Failed to create a personal access token", "server_url", serverURL, "error", err)
+		return exitFatal
+	}
+
+	if *keyring {
+		if err := keyringSet(serverURL, token); err != nil {
+			slog.Error("Failed to store the token", "server_url", serverURL, "error", err)
+			return exitFatal
+		}
+		slog.Info("Stored the token in the keyring, use it with --keyring", "server_url", serverURL, "expires_in_days", *expiresIn)
+		return exitOK
+	}
+	fmt.Println(token)
+	return exitOK
+}
+
+// deviceLogin runs the device authorization flow (RFC 8628): it shows the
+// user the verification URL and code on stderr and polls the token endpoint
+// until the user approved or denied the login, or the code expired
+func deviceLogin(ctx context.Context, client *http.Client, oidc OIDCConfig) (string, error) {
+	var discovery oidcDiscovery
+	if err := getJSON(ctx, client, strings.TrimSuffix(oidc.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return "", fmt.Errorf("failed to discover the IdP endpoints: %w", err)
+	}
+	if discovery.DeviceAuthorizationEndpoint == "" {
+		return "", fmt.Errorf("the IdP %s does not support the device authorization flow", oidc.Issuer)
+	}
+
+	form := url.Values{"client_id": {oidc.ClientID}, "scope": {oidc.Scopes}}
+	if oidc.Audience != "" {
+		form.Set("audience", oidc.Audience)
+	}
+	var device deviceAuthorization
+	if _, err := postForm(ctx, client, discovery.DeviceAuthorizationEndpoint, form, &device); err != nil {
+		return "", fmt.Errorf("failed to start the device authorization: %w", err)
+	}
+	if device.Error != "" || device.DeviceCode == "" {
+		return "", fmt.Errorf("failed to start the device authorization: %s: %s", device.Error, device.ErrorDescription)
+	}
+
+	verificationURL := device.VerificationURIComplete
+	if verificationURL == "" {
+		verificationURL = device.VerificationURI
+	}
+	fmt.Fprintf(os.Stderr, "\nOpen %s in a browser and confirm the code %s to log in.\n\n", verificationURL, device.UserCode)
+
+	// RFC 8628 polls every 5 seconds unless the IdP says otherwise
+	interval := 5 * time.Second
+	if device.Interval > 0 {
+		interval = time.Duration(device.Interval) * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(device.ExpiresIn) * time.Second)
+	if device.ExpiresIn == 0 {
+		deadline = time.Now().Add(10 * time.Minute)
+	}
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(interval):
+		}
+
+		var token tokenResponse
+		_, err := postForm(ctx, client, discovery.TokenEndpoint, url.Values{
+			"grant_type":  {deviceCodeGrantType},
+			"device_code": {device.DeviceCode},
+			"client_id":   {oidc.ClientID},
+		}, &token)
+		if err != nil {
+			return "", err
+		}
+		switch token.Error {
+		case "":
+			if token.AccessToken == "" {
+				return "", errors.New("the IdP returned no access token")
+			}
+			return token.AccessToken, nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		default:
+			return "", fmt.Errorf("%s: %s", token.Error, token.ErrorDescription)
+		}
+	}
+	return "", errors.New("the code expired before the login was confirmed")
+}
+
+// createPersonalAccessToken creates a personal access token for the user the
+// access token belongs to and returns its plain text
+func createPersonalAccessToken(ctx context.Context, client *http.Client, serverURL, accessToken string, expiresIn int) (string, error) {
+	authorize := func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	var user struct {
+		ID string `json:"id"`
+	}
+	if err := getJSON(ctx, client, serverURL+"/api/users/current", &user, authorize); err != nil {
+		return "", fmt.Errorf("failed to look up the logged-in user: %w", err)
+	}
+
+	hostname, _ := os.Hostname()
+	body, err := json.Marshal(map[string]any{
+		"name":       fmt.Sprintf("netbird-terraformer %s %s", hostname, time.Now().UTC().Format("2006-01-02")),
+		"expires_in": expiresIn,
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, serverURL+"/api/users/"+url.PathEscape(user.ID)+"/tokens", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	authorize(req)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent())
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("POST /api/users/%s/tokens returned status %d", user.ID, resp.StatusCode)
+	}
+
+	var created struct {
+		PlainToken string `json:"plain_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil || created.PlainToken == "" {
+		return "", fmt.Errorf("no token in the response: %v", err)
+	}
+	return created.PlainToken, nil
+}
+
+// getJSON decodes the JSON response of a GET request
+func getJSON(ctx context.Context, client *http.Client, target string, result any, modify ...func(*http.Request)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent())
+	for _, apply := range modify {
+		apply(req)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned status %d", req.URL.Path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// postForm posts a form and decodes the JSON response, also of error
+// statuses, which carry OAuth errors
+func postForm(ctx context.Context, client *http.Client, target string, form url.Values, result any) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent())
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to decode the response of %s: %w", target, err)
+	}
+	return resp.StatusCode, nil
+}
@@ -1,9 +1,21 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"netbird-terraformer/lib"
 	"netbird-terraformer/resources"
@@ -15,115 +27,898 @@ func main() {
 		return
 	}
 
+	// The env file applies to every command, so it is loaded before any of
+	// them parses its flags
+	loadedEnv := applyEnvFile()
+
 	if len(os.Args) > 1 && os.Args[1] == "--debug-auth" {
 		debugAuth()
 		return
 	}
 
+	// deploy only writes a manifest and needs neither a token nor the config
+	if len(os.Args) > 1 && os.Args[1] == commandDeploy {
+		os.Exit(runDeploy(os.Args[2:]))
+	}
+
+	// login creates the token the other commands use
+	if len(os.Args) > 1 && os.Args[1] == commandLogin {
+		os.Exit(runLogin(os.Args[2:]))
+	}
+
+	// token only manages the keyring entry
+	if len(os.Args) > 1 && os.Args[1] == commandToken {
+		os.Exit(runToken(os.Args[2:]))
+	}
+
+	// diff only reads two output directories
+	if len(os.Args) > 1 && os.Args[1] == commandDiff {
+		os.Exit(runDiff(os.Args[2:]))
+	}
+
+	command, args := commandGenerate, os.Args[1:]
+	if len(args) > 0 && (args[0] == commandGenerate || args[0] == commandBundle || args[0] == commandListImports || args[0] == commandDoctor || args[0] == commandCompare || args[0] == commandWatch || args[0] == commandDrift || args[0] == commandExport) {
+		command, args = args[0], args[1:]
+	}
+
 	// Get configuration
-	config := getConfig()
+	config := getConfig(command, args)
+	slog.SetDefault(config.Logger)
+	if loadedEnv != nil {
+		slog.Info("Loaded env file", "path", loadedEnv.Path, "variables", len(loadedEnv.Loaded))
+		if len(loadedEnv.Ignored) > 0 {
+			slog.Debug("Env file variables already set in the environment were not changed", "variables", strings.Join(loadedEnv.Ignored, ", "))
+		}
+	}
+	if config.ConfigFile != "" {
+		slog.Info("Using config file", "path", config.ConfigFile)
+	}
 
-	// Create output directory
-	outputDir := "generated"
-	if len(os.Args) > 1 {
-		outputDir = os.Args[1]
+	if config.TLSSkipVerify {
+		slog.Warn("TLS certificate verification is DISABLED (--tls-skip-verify): the API token is sent to whoever answers at the management URL. Use NB_CA_CERT for private CAs instead.")
 	}
 
-	fmt.Printf("NetBird Terraform Importer\n")
-	fmt.Printf("Server URL: %s\n", config.ServerURL)
-	fmt.Printf("Output Directory: %s\n", outputDir)
-	fmt.Printf("Starting import...\n\n")
+	// Ctrl-C cancels in-flight API requests and terraform commands; the run then
+	// stops at the next safe point instead of being killed mid-write
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Create service and terraform generator
-	service := NewNetBirdService(config.ServerURL, config.APIToken, config.Debug)
-	terraformGen := lib.NewTerraformGenerator(outputDir, &lib.Config{
-		ServerURL:  config.ServerURL,
-		APIToken:   config.APIToken,
-		Debug:      config.Debug,
-		AutoImport: config.AutoImport,
-	})
+	if command == commandGenerate && len(config.Accounts) > 0 {
+		os.Exit(runAccounts(ctx, config, args))
+	}
 
-	// Initialize resource handlers
-	groupsHandler := resources.NewGroupsHandler(service, terraformGen)
-	usersHandler := resources.NewUsersHandler(service, terraformGen)
-	policiesHandler := resources.NewPoliciesHandler(service, terraformGen)
-	routesHandler := resources.NewRoutesHandler(service, terraformGen)
+	if command == commandDoctor {
+		if runDoctor(ctx, config) > 0 {
+			os.Exit(exitPartialFailure)
+		}
+		return
+	}
 
-	// Import groups first to establish group mappings
-	err := groupsHandler.ImportAndGenerate()
-	if err != nil {
-		fmt.Printf("Warning: %v\n", err)
+	if command == commandWatch {
+		os.Exit(runWatch(ctx, config, args))
 	}
 
-	// Get group mappings for other resources
-	groupMapping := groupsHandler.GetResourceMapping()
+	// The run timeout stops the run like Ctrl-C does; watch and several
+	// accounts pass it on to each of their runs instead
+	if config.RunTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, config.RunTimeout, errRunTimeout)
+		defer cancel()
+	}
 
-	// Set group mapping for resources that need it
-	usersHandler.SetGroupMapping(groupMapping)
-	policiesHandler.SetGroupMapping(groupMapping)
+	if command == commandCompare {
+		differences, err := runCompareAccounts(ctx, config)
+		if err != nil {
+			fatal("Failed to compare accounts", err)
+		}
+		if differences > 0 {
+			os.Exit(exitPartialFailure)
+		}
+		return
+	}
 
-	// Import other resources
-	resourceHandlers := []lib.ResourceHandler{
-		usersHandler,
-		policiesHandler,
-		routesHandler,
+	if command == commandDrift {
+		drifted, err := runDrift(ctx, config)
+		if err != nil {
+			fatal("Failed to check for drift", err)
+		}
+		if drifted > 0 {
+			os.Exit(exitPartialFailure)
+		}
+		return
 	}
 
-	for _, handler := range resourceHandlers {
-		err := handler.ImportAndGenerate()
+	if command == commandExport {
+		err := runExport(ctx, config)
 		if err != nil {
-			fmt.Printf("Warning: %v\n", err)
+			fatal("Failed to export the account", err)
 		}
+		return
+	}
+
+	if command == commandBundle {
+		err := runBundle(ctx, config)
+		if err != nil {
+			fatal("Failed to create bundle", err)
+		}
+		return
+	}
+
+	outputDir := config.OutputDir
+
+	runID := newRunID()
+	summary := NewRunSummary(runID, config)
+	config.APIStats = summary.API
+
+	slog.Info("NetBird Terraform Importer",
+		"version", version,
+		"run_id", runID,
+		"server_url", config.ServerURL,
+		"output_dir", outputDir,
+		"format", config.Format,
+	)
+
+	// Progress is saved so an interrupted run can resume; offline runs and
+	// recordings have nothing worth saving
+	if config.Checkpoint && command == commandGenerate && !config.DryRun && config.Bundle == nil && config.Replay == nil && config.RecordDir == "" {
+		config.Progress = openCheckpoint(config, config.Resume)
 	}
 
+	if config.Limit > 0 {
+		slog.Info("Trial run, generating a subset of each resource type; objects beyond it are only looked up where referenced", "limit", config.Limit, "sample", config.Sample)
+	}
+
+	// Response shapes differ between management server versions; offline runs
+	// use the version the bundle was captured from
+	if config.ServerVersion == "" && config.Bundle != nil {
+		config.ServerVersion = config.Bundle.Manifest.ServerVersion
+	}
+	if config.ServerVersion == "" && config.Bundle == nil && config.Replay == nil {
+		config.ServerVersion = detectServerVersion(ctx, newService(config))
+	}
+	if config.ServerVersion != "" {
+		slog.Info("Management server version", "version", config.ServerVersion)
+	}
+
+	// Create service and terraform generator
+	service := newAPI(config)
+	generatorConfig := newGeneratorConfig(config)
+	terraformGen := lib.NewTerraformGenerator(outputDir, generatorConfig)
+	if generatorConfig.LockedVersion != "" && config.ProviderDefaults {
+		slog.Info("Applying provider defaults for the locked provider version", "version", generatorConfig.LockedVersion, "lock_file", lib.ProviderLockFile)
+	}
+	if stateObjects := countNames(config.StateNames); stateObjects > 0 {
+		slog.Info("Keeping the resource names of objects in the existing state", "objects", stateObjects)
+	}
+
+	// A token lacking permissions would fail, or leave out types, midway
+	if config.Preflight && config.Bundle == nil && config.Replay == nil {
+		if err := preflight(ctx, config); err != nil {
+			if ctx.Err() != nil {
+				stopInterrupted(ctx, config, summary)
+			}
+			fatal("Preflight check failed", err)
+		}
+	}
+
+	// Check terraform before fetching anything, so a missing binary or a version
+	// lacking the features we emit fails in seconds rather than after the fetch
+	var runner *lib.TerraformRunner
+	if config.AutoImport && !config.DryRun && command == commandGenerate && config.TFC == nil {
+		runner = lib.NewTerraformRunner(generatorConfig)
+		importMode, err := checkTerraform(ctx, runner, config.ImportMode, summary)
+		if err != nil && ctx.Err() != nil {
+			stopInterrupted(ctx, config, summary)
+		}
+		if err != nil {
+			fatal("Terraform check failed", err)
+		}
+		summary.ImportMode = importMode
+	}
+
+	fetched := fetchResources(ctx, config, generatorConfig, service, terraformGen, summary)
+
+	// Names are only final once every handler ran
+	for _, collision := range terraformGen.GetNameCollisions() {
+		slog.Warn("Resource name already used, appended the object ID", "type", collision.Type, "name", collision.Name, "id", collision.ID, "renamed_to", collision.Assigned, "name_kept_by", collision.TakenBy)
+	}
+	for _, override := range terraformGen.GetUnusedNameOverrides() {
+		slog.Warn("Name override matched no generated object", "object", override)
+	}
+	for _, reference := range terraformGen.GetUnresolvedReferences() {
+		slog.Warn("Unresolved reference, using the object ID", "resource", reference.Type+"."+reference.Resource, "attribute", reference.Attribute, "target_type", reference.TargetType, "target", reference.TargetName, "target_id", reference.TargetID)
+	}
+
+	// Files are only written once everything was fetched
+	if ctx.Err() != nil {
+		stopInterrupted(ctx, config, summary)
+	}
+
+	if config.DryRun || command == commandListImports {
+		if config.DryRun {
+			printDryRunSummary(terraformGen)
+		} else {
+			printImportList(terraformGen)
+		}
+		summary.FinishedAt = time.Now()
+		summary.ExitCode = exitCode(config, summary)
+		if config.Stats {
+			printStats(os.Stdout, summary)
+		}
+		recordUsageStats(config, summary)
+		os.Exit(summary.ExitCode)
+	}
+
+	summary.RecordResources(terraformGen)
+	summary.SetupKeys = fetched.setupKeys.GetUsage()
+
 	// Generate files and scripts
-	err = generateTerraformFiles(terraformGen, outputDir)
+	generateStartedAt := time.Now()
+	if config.ModulePackage {
+		err := terraformGen.WriteModulePackage()
+		if err != nil {
+			fatal("Failed to generate module package", err)
+		}
+		summary.TrackPhase("generate", generateStartedAt)
+		printModulePackageNextSteps(outputDir)
+		finishRun(config, summary)
+	}
+
+	// Objects deleted in NetBird would otherwise be destroyed, or fail to
+	// refresh, on the next apply; removed blocks make terraform forget them
+	var pruned []lib.PrunedResource
+	if config.Prune {
+		pruned = terraformGen.FindPrunedResources(config.StateObjects)
+		for _, resource := range pruned {
+			slog.Warn("Object in the state is no longer generated, writing a removed block", "address", resource.Address, "id", resource.ID)
+		}
+		summary.Pruned = pruned
+	}
+
+	var err error
+	if config.Merge {
+		err = mergeTerraformFiles(terraformGen, outputDir)
+	} else {
+		err = generateTerraformFiles(terraformGen, config.SplitState)
+	}
 	if err != nil {
-		log.Fatalf("Failed to generate Terraform files: %v", err)
+		fatal("Failed to generate Terraform files", err)
+	}
+	if config.Prune {
+		err = terraformGen.WriteRemovedBlocks(pruned)
+		if err != nil {
+			fatal("Failed to write removed blocks", err)
+		}
 	}
 
 	err = terraformGen.GenerateGroupMapping()
 	if err != nil {
-		log.Fatalf("Failed to generate group mapping: %v", err)
+		fatal("Failed to generate group mapping", err)
+	}
+
+	if config.SuggestGroups {
+		err = generateGroupSuggestions(terraformGen, fetched.groups, fetched.users)
+		if err != nil {
+			fatal("Failed to generate group suggestions", err)
+		}
 	}
 
 	err = terraformGen.GenerateImportScript()
 	if err != nil {
-		log.Fatalf("Failed to generate import script: %v", err)
+		fatal("Failed to generate import script", err)
+	}
+
+	if config.PulumiImport {
+		err = terraformGen.GeneratePulumiImport()
+		if err != nil {
+			fatal("Failed to generate Pulumi import file", err)
+		}
+	}
+
+	if config.Graph != "" {
+		err = terraformGen.WriteGraph(config.Graph)
+		if err != nil {
+			fatal("Failed to generate resource graph", err)
+		}
+	}
+
+	if config.HTMLReport {
+		report, err := resources.FormatAccessReport(config.ServerURL, fetched.groups.GetGroups(), fetched.peers.GetPeers(), fetched.policies.GetPolicies())
+		if err == nil {
+			err = terraformGen.WriteFile(resources.AccessReportFile, []byte(report))
+		}
+		if err != nil {
+			fatal("Failed to generate access report", err)
+		}
+	}
+
+	if config.Ansible {
+		err = terraformGen.WriteFile(resources.AnsibleInventoryFile, []byte(resources.FormatAnsibleInventory(fetched.peers.GetPeers())))
+		if err != nil {
+			fatal("Failed to generate Ansible inventory", err)
+		}
+	}
+	summary.TrackPhase("generate", generateStartedAt)
+
+	if ctx.Err() != nil {
+		stopInterrupted(ctx, config, summary)
 	}
 
 	// Handle imports
-	if config.AutoImport {
-		err = runTerraformImports(terraformGen, outputDir)
+	if config.AutoImport && config.TFC != nil {
+		summary.ImportMode = importModeTFC
+		runURL, err := runTFCImport(ctx, config, terraformGen, runID, summary)
+		if ctx.Err() != nil {
+			stopInterrupted(ctx, config, summary)
+		}
+		if err != nil {
+			fatal("Failed to queue the import run in HCP Terraform", err)
+		}
+		if runURL != "" {
+			fmt.Printf("\nImport run queued in HCP Terraform: %s\n", runURL)
+		}
+	} else if config.AutoImport {
+		err = runTerraformImports(ctx, config, runner, terraformGen, summary)
+		if ctx.Err() != nil {
+			stopInterrupted(ctx, config, summary)
+		}
 		if err != nil {
-			log.Fatalf("Failed to run terraform imports: %v", err)
+			fatal("Failed to run terraform imports", err)
+		}
+
+		if config.Reconcile && len(terraformGen.GetImportCommands()) > 0 {
+			err = runReconcile(ctx, config, runner, terraformGen, summary)
+			if ctx.Err() != nil {
+				stopInterrupted(ctx, config, summary)
+			}
+			if err != nil {
+				fatal("Failed to reconcile the configuration", err)
+			}
+		}
+
+		if summary.ImportsSucceeded > 0 {
+			err = terraformGen.GenerateRunMetadata(lib.RunMetadata{
+				Version:           version,
+				RunID:             runID,
+				ImportedAt:        time.Now().UTC().Format(time.RFC3339),
+				ImportedResources: summary.ImportsSucceeded,
+			})
+			if err != nil {
+				fatal("Failed to generate run metadata", err)
+			}
 		}
 	} else {
-		fmt.Printf("\nAuto-import disabled. You can manually run terraform imports later.\n")
+		slog.Info("Auto-import disabled, you can manually run terraform imports later")
+	}
+
+	if len(terraformGen.GetImportCommands()) == 0 {
+		printNothingToImport(outputDir)
+	} else if lib.IsCDKTFFormat(config.Format) {
+		printCDKTFNextSteps(config.Format, outputDir)
+	} else if config.Format == lib.PulumiFormat {
+		printPulumiNextSteps(outputDir)
+	} else {
+		printNextSteps(config, outputDir)
+	}
+
+	finishRun(config, summary)
+}
+
+// newAPI returns the source of API responses: the management API, possibly
+// cached or recorded, or the bundle or fixtures given instead of it
+func newAPI(config *Config) lib.NetBirdAPI {
+	apiClient := newService(config)
+	var service lib.NetBirdAPI = apiClient
+	var source rawAPI = apiClient
+	if config.CacheDir != "" {
+		slog.Info("Caching API responses", "dir", config.CacheDir, "ttl", config.CacheTTL, "refresh", config.CacheRefresh)
+		cache := NewCachedAPI(apiClient, config)
+		service, source = cache, cache
+	}
+	if config.Progress != nil {
+		service = NewCheckpointAPI(apiClient, source, config.Progress)
+	}
+	if config.RecordDir != "" {
+		slog.Info("Recording API responses", "dir", config.RecordDir)
+		service = NewRecordingAPI(apiClient, config)
+	}
+	if config.Bundle != nil {
+		slog.Info("Generating offline from bundle", "created_at", config.Bundle.Manifest.CreatedAt.Format(time.RFC3339))
+		service = config.Bundle
+	}
+	if config.Replay != nil {
+		slog.Info("Replaying recorded API responses", "server_url", config.Replay.Manifest.ServerURL, "recorded_at", config.Replay.Manifest.RecordedAt.Format(time.RFC3339))
+		service = config.Replay
+	}
+	return service
+}
+
+// newGeneratorConfig derives the generator settings from the configuration
+func newGeneratorConfig(config *Config) *lib.Config {
+	return &lib.Config{
+		ServerURL:  config.ServerURL,
+		Verbosity:  config.Verbosity,
+		AutoImport: config.AutoImport,
+		Format:     config.Format,
+
+		ProviderVersion:  config.ProviderVersion,
+		ProviderDefaults: config.ProviderDefaults,
+		LockedVersion:    lib.LockedProviderVersion(config.OutputDir),
+		ProviderAlias:    config.Account,
+		TerraformPath:    config.TerraformPath,
+		UserAgent:        userAgent(),
+
+		ExcludedTypes:  config.ExcludedTypes,
+		IncludePattern: config.IncludePattern,
+		ExcludePattern: config.ExcludePattern,
+		Rules:          config.Rules,
+		ImportOrder:    config.ImportOrder,
+		IssuedActions:  config.IssuedActions,
+		Limit:          config.Limit,
+		Sample:         config.Sample,
+		NameTemplates:  config.NameTemplates,
+		NameOverrides:  config.NameOverrides,
+		StateNames:     config.StateNames,
+
+		SkipSystemGroups: config.SkipSystemGroups,
+
+		DashboardURL: config.DashboardURL,
+		URLComments:  config.URLComments,
+
+		Lifecycle: config.Lifecycle,
+
+		SplitState: config.SplitState,
+		Backend:    config.Backend,
+		Terragrunt: config.Terragrunt,
+
+		Concurrency: config.Concurrency,
+
+		ImportScripts: config.ImportScripts,
+
+		InteractiveProgress: config.InteractiveProgress,
+	}
+}
+
+// countNames returns the number of objects in a per-type name mapping
+func countNames(names map[string]map[string]string) int {
+	count := 0
+	for _, byID := range names {
+		count += len(byID)
+	}
+	return count
+}
+
+// fetchedResources holds the handlers whose results are used after the fetch
+type fetchedResources struct {
+	groups    *resources.GroupsHandler
+	peers     *resources.PeersHandler
+	users     *resources.UsersHandler
+	policies  *resources.PoliciesHandler
+	setupKeys *resources.SetupKeysHandler
+}
+
+// fetchResources fetches every registered resource type into the generator,
+// each after the types whose mappings it references
+func fetchResources(ctx context.Context, config *Config, generatorConfig *lib.Config, service lib.NetBirdAPI, terraformGen *lib.TerraformGenerator, summary *RunSummary) fetchedResources {
+	options := resources.Options{SkipEmptyGroups: config.SkipEmptyGroups, OnlyEnabled: config.OnlyEnabled}
+	handlers := fetchRegisteredHandlers(ctx, config, generatorConfig, service, terraformGen, options, summary)
+	terraformGen.ResolveReferences()
+
+	// Empty groups, and objects beyond --limit, are only known to be
+	// unreferenced once everything is fetched
+	if dropped := terraformGen.DropUnreferencedDataSources(); dropped > 0 {
+		slog.Info("Left out unreferenced data sources", "count", dropped)
+	}
+
+	fetched := fetchedResources{}
+	fetched.groups, _ = handlers["group"].(*resources.GroupsHandler)
+	fetched.peers, _ = handlers["peer"].(*resources.PeersHandler)
+	fetched.users, _ = handlers["user"].(*resources.UsersHandler)
+	fetched.policies, _ = handlers["policy"].(*resources.PoliciesHandler)
+	fetched.setupKeys, _ = handlers["setup_key"].(*resources.SetupKeysHandler)
+	return fetched
+}
+
+// fetchRegisteredHandlers runs the registered handlers and returns them by
+// resource type. Handlers run concurrently in the waves of resources.Waves,
+// each after the handlers it depends on. Excluded types are created but not
+// run.
+func fetchRegisteredHandlers(ctx context.Context, config *Config, generatorConfig *lib.Config, service lib.NetBirdAPI, terraformGen *lib.TerraformGenerator, options resources.Options, summary *RunSummary) map[string]lib.ResourceHandler {
+	waves, err := resources.Waves()
+	if err != nil {
+		fatal("Invalid resource handler registration", err)
 	}
 
+	fetched := make(map[string]lib.ResourceHandler)
+	for _, registrations := range waves {
+		if ctx.Err() != nil {
+			break
+		}
+
+		handlers := make([]lib.ResourceHandler, 0, len(registrations))
+		for _, registration := range registrations {
+			handler := registration.New(resources.Dependencies{Service: service, Writer: terraformGen, Options: options})
+			fetched[registration.Name] = handler
+			if !serverSupports(config.ServerVersion, registration.MinServerVersion) {
+				slog.Info("Skipping resource type the management server does not support", "type", registration.Name, "server_version", config.ServerVersion, "min_version", registration.MinServerVersion)
+				summary.SkippedTypes = append(summary.SkippedTypes, registration.Name)
+				continue
+			}
+			handlers = append(handlers, handler)
+		}
+
+		fetchHandlers(ctx, config, generatorConfig, handlers, summary)
+	}
+	return fetched
+}
+
+// finishRun writes, scrubs and sends the run report and exits with the run's
+// exit code
+func finishRun(config *Config, summary *RunSummary) {
+	summary.FinishedAt = time.Now()
+	summary.ExitCode = exitCode(config, summary)
+	if config.Progress != nil && summary.ExitCode == exitOK {
+		config.Progress.Remove()
+	}
+	err := writeReport(config.OutputDir, summary)
+	if err != nil {
+		slog.Warn("Failed to write run report", "error", err)
+	}
+	scrubArtifacts(config, summary)
+	if config.Stats {
+		printStats(os.Stdout, summary)
+	}
+
+	if len(config.EmailReport) > 0 {
+		err = sendEmailReport(config.EmailReport, config.Scrub, summary)
+		if err != nil {
+			slog.Warn("Failed to send email report", "error", err)
+		} else {
+			slog.Info("Emailed run report", "recipients", strings.Join(config.EmailReport, ", "))
+		}
+	}
+
+	recordUsageStats(config, summary)
+
+	os.Exit(summary.ExitCode)
+}
+
+// fetchHandlers runs the resource handlers once the group mapping is known, up
+// to config.Concurrency at a time. Warnings and phase timings are recorded in
+// handler order so the report does not depend on scheduling.
+func fetchHandlers(ctx context.Context, config *Config, generatorConfig *lib.Config, handlers []lib.ResourceHandler, summary *RunSummary) {
+	type fetchResult struct {
+		err      error
+		duration time.Duration
+		ran      bool
+	}
+	results := make([]fetchResult, len(handlers))
+
+	fetchProgress := lib.NewProgress("Fetching resource types", len(handlers), config.InteractiveProgress)
+	slots := make(chan struct{}, max(config.Concurrency, 1))
+	var wg sync.WaitGroup
+	for i, handler := range handlers {
+		if generatorConfig.IsExcluded(handler.GetResourceType()) {
+			fetchProgress.Increment()
+			slog.Info("Skipping excluded resource type", "type", handler.GetResourceType())
+			summary.SkippedTypes = append(summary.SkippedTypes, handler.GetResourceType())
+			continue
+		}
+
+		slots <- struct{}{}
+		if ctx.Err() != nil {
+			<-slots
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, handler lib.ResourceHandler) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			startedAt := time.Now()
+			err := handler.ImportAndGenerate(ctx)
+			results[i] = fetchResult{err: err, duration: time.Since(startedAt), ran: true}
+			fetchProgress.Increment()
+		}(i, handler)
+	}
+	wg.Wait()
+	fetchProgress.Done()
+
+	for i, result := range results {
+		if !result.ran {
+			continue
+		}
+		if result.err != nil && ctx.Err() == nil {
+			summary.AddWarning("%v", result.err)
+		}
+		summary.Phases = append(summary.Phases, PhaseTiming{Name: "fetch_" + handlers[i].GetResourceType(), Duration: result.duration})
+	}
+}
+
+// printNextSteps prints the generated files and how to continue after a run
+func printNextSteps(config *Config, outputDir string) {
 	fmt.Printf("\nImport completed successfully!\n")
 	fmt.Printf("Generated files in: %s\n", outputDir)
 	fmt.Printf("\nFiles generated:\n")
 	fmt.Printf("  - Terraform configuration files (*.tf)\n")
 	fmt.Printf("  - group_mappings.json (for ID reference)\n")
-	fmt.Printf("  - import.sh (terraform import commands)\n")
+	for _, format := range config.ImportScripts {
+		fmt.Printf("  - %s (terraform import commands)\n", lib.ImportScriptFiles[format])
+	}
+	fmt.Printf("  - report.json (machine-readable run report)\n")
 	fmt.Printf("\nNext steps:\n")
 	fmt.Printf("  1. cd %s\n", outputDir)
-	if config.AutoImport {
+	if config.AutoImport && config.TFC != nil {
+		fmt.Printf("  2. Review the import run's plan in HCP Terraform and confirm it\n")
+		fmt.Printf("  3. Review and modify the configuration as needed\n")
+		fmt.Printf("\nNote: the objects are adopted into the %s workspace's state once the run is applied.\n", config.TFC.Workspace)
+	} else if config.AutoImport {
 		fmt.Printf("  2. terraform plan\n")
 		fmt.Printf("  3. Review and modify the configuration as needed\n")
 		fmt.Printf("\nNote: All resources have been automatically imported into Terraform state!\n")
+		fmt.Printf("The netbird_terraformer_run output (importer_metadata.tf) records this run once you apply.\n")
 	} else {
-		fmt.Printf("  2. Run ./import.sh (or manually run terraform import commands)\n")
+		bash := containsString(config.ImportScripts, lib.ImportScriptBash)
+		powerShell := containsString(config.ImportScripts, lib.ImportScriptPowerShell)
+		switch {
+		case bash && powerShell:
+			fmt.Printf("  2. Run ./import.sh, or .\\import.ps1 on Windows (or manually run terraform import commands)\n")
+		case bash:
+			fmt.Printf("  2. Run ./import.sh (or manually run terraform import commands)\n")
+		case powerShell:
+			fmt.Printf("  2. Run .\\import.ps1 (or manually run terraform import commands)\n")
+		default:
+			fmt.Printf("  2. Run the terraform import commands\n")
+		}
 		fmt.Printf("  3. terraform plan\n")
 		fmt.Printf("  4. Review and modify the configuration as needed\n")
 	}
 }
 
-// generateTerraformFiles groups resources by type and generates .tf files
-func generateTerraformFiles(terraformGen *lib.TerraformGenerator, outputDir string) error {
-	fmt.Printf("\nGenerating Terraform files...\n")
+// printModulePackageNextSteps lists the files of a module package and how to
+// adopt the account through its example
+func printModulePackageNextSteps(outputDir string) {
+	fmt.Printf("\nModule package generated in: %s\n", outputDir)
+	fmt.Printf("\nFiles generated:\n")
+	fmt.Printf("  - main.tf, variables.tf, outputs.tf, versions.tf (the module)\n")
+	fmt.Printf("  - README.md (inputs and outputs)\n")
+	fmt.Printf("  - examples/basic (calls the module with this account's IDs, with import blocks)\n")
+	fmt.Printf("  - report.json (machine-readable run report)\n")
+	fmt.Printf("\nNext steps:\n")
+	fmt.Printf("  1. Publish the module, e.g. push %s to a git repository or your private registry\n", outputDir)
+	fmt.Printf("  2. cd %s && terraform init && terraform plan\n", filepath.Join(outputDir, "examples", "basic"))
+	fmt.Printf("  3. terraform apply to adopt the objects (Terraform 1.5+), then delete imports.tf\n")
+}
+
+// printCDKTFNextSteps lists the files of a CDKTF application and how to adopt
+// the account with it
+func printCDKTFNextSteps(format, outputDir string) {
+	mainFile, install := "main.ts", "npm install cdktf constructs"
+	if format == "cdktf-go" {
+		mainFile, install = "main.go", "go mod init netbird && go mod tidy"
+	}
+	fmt.Printf("\nCDK for Terraform application generated in: %s\n", outputDir)
+	fmt.Printf("\nFiles generated:\n")
+	fmt.Printf("  - cdktf.json (project with the NetBird provider)\n")
+	fmt.Printf("  - %s (a construct per resource, importing the managed ones)\n", mainFile)
+	fmt.Printf("  - group_mappings.json (for ID reference)\n")
+	fmt.Printf("  - report.json (machine-readable run report)\n")
+	fmt.Printf("\nNext steps:\n")
+	fmt.Printf("  1. cd %s && cdktf get\n", outputDir)
+	fmt.Printf("  2. %s\n", install)
+	fmt.Printf("  3. cdktf apply to adopt the objects\n")
+}
+
+// printPulumiNextSteps lists the files of a Pulumi program and how to adopt
+// the account with it
+func printPulumiNextSteps(outputDir string) {
+	fmt.Printf("\nPulumi program generated in: %s\n", outputDir)
+	fmt.Printf("\nFiles generated:\n")
+	fmt.Printf("  - Pulumi.yaml (a resource per object, importing the managed ones)\n")
+	fmt.Printf("  - group_mappings.json (for ID reference)\n")
+	fmt.Printf("  - report.json (machine-readable run report)\n")
+	fmt.Printf("\nNext steps:\n")
+	fmt.Printf("  1. cd %s && pulumi stack init\n", outputDir)
+	fmt.Printf("  2. pulumi package add terraform-provider netbirdio/netbird\n")
+	fmt.Printf("  3. pulumi up to adopt the objects, then remove the import options\n")
+}
+
+// printNothingToImport explains the minimal configuration written for an account
+// without any objects to manage
+func printNothingToImport(outputDir string) {
+	fmt.Printf("\nNothing to import: the account has no groups, users, policies, routes or setup keys to manage.\n")
+	fmt.Printf("Generated a minimal configuration in: %s\n", outputDir)
+	fmt.Printf("\nFiles generated:\n")
+	fmt.Printf("  - Provider configuration with version constraints\n")
+	fmt.Printf("  - report.json (machine-readable run report)\n")
+	fmt.Printf("\nNext steps:\n")
+	fmt.Printf("  1. cd %s\n", outputDir)
+	fmt.Printf("  2. terraform init\n")
+	fmt.Printf("  3. Add NetBird resources to the configuration, or rerun the importer once the account has some\n")
+}
+
+// Exit codes, so pipelines can tell incomplete imports from fatal errors
+const (
+	exitOK             = 0
+	exitFatal          = 1
+	exitPartialFailure = 2
+	exitInterrupted    = 130 // 128 + SIGINT, as shells report it
+)
+
+// errRunTimeout is the cause of the context cancelled by --run-timeout
+var errRunTimeout = errors.New("run timeout exceeded")
+
+// stopInterrupted ends a run cancelled by Ctrl-C or --run-timeout. Imports
+// that completed are already in the synced state; the partial report records
+// how far the run got.
+func stopInterrupted(ctx context.Context, config *Config, summary *RunSummary) {
+	if errors.Is(context.Cause(ctx), errRunTimeout) {
+		summary.AddWarning("The run did not finish within the run timeout of %s and was stopped; raise --run-timeout or set it to 0 for no limit", config.RunTimeout)
+	} else {
+		slog.Warn("Interrupted, stopping")
+	}
+	summary.Interrupted = true
+	summary.FinishedAt = time.Now()
+	summary.ExitCode = exitInterrupted
+	if config.Progress != nil {
+		slog.Info("Progress was saved, run again with --resume to continue")
+	}
+
+	if !config.DryRun {
+		err := os.MkdirAll(config.OutputDir, 0755)
+		if err == nil {
+			err = writeReport(config.OutputDir, summary)
+		}
+		if err != nil {
+			slog.Warn("Failed to write run report", "error", err)
+		}
+		scrubArtifacts(config, summary)
+	}
+	recordUsageStats(config, summary)
+
+	os.Exit(exitInterrupted)
+}
+
+// scrubArtifacts redacts secrets in everything the run wrote to the output
+// directory, protecting against a handler that accidentally emits one. Anything
+// redacted fails the run; the findings are added to the report and never hold
+// the secret itself.
+func scrubArtifacts(config *Config, summary *RunSummary) {
+	findings, err := lib.ScrubPaths([]string{config.OutputDir}, config.Scrub)
+	if err != nil {
+		slog.Warn("Failed to scrub generated files for secrets", "error", err)
+	}
+	if len(findings) == 0 {
+		return
+	}
+
+	for _, finding := range findings {
+		slog.Error("Redacted a secret from a generated file", "file", finding.File, "line", finding.Line, "rule", finding.Rule)
+	}
+	summary.SecretsRedacted = findings
+	if !summary.Interrupted {
+		summary.ExitCode = exitFatal
+	}
+
+	// The rewritten report holds any secret redacted from the warnings again
+	err = writeReport(config.OutputDir, summary)
+	if err == nil {
+		_, err = lib.ScrubPaths([]string{filepath.Join(config.OutputDir, reportFile)}, config.Scrub)
+	}
+	if err != nil {
+		slog.Warn("Failed to write run report", "error", err)
+	}
+}
+
+// exitCode returns exitPartialFailure when a resource type could not be fetched
+// or an import failed, or with --fail-on-warning when any warning was logged
+func exitCode(config *Config, summary *RunSummary) int {
+	if !summary.Succeeded() {
+		return exitPartialFailure
+	}
+	if config.FailOnWarning && config.LogWarnings.Count() > 0 {
+		return exitPartialFailure
+	}
+	return exitOK
+}
+
+// newService creates the NetBird API client from the configuration
+func newService(config *Config) *NetBirdService {
+	return NewNetBirdService(config.ServerURL, config.APIToken, ServiceOptions{
+		Debug:       config.Verbosity.Has(lib.VerboseAPI),
+		TraceHTTP:   config.TraceHTTP,
+		MaxRetries:  config.MaxRetries,
+		RetryDelay:  config.RetryDelay,
+		Timeout:     config.RequestTimeout,
+		QPS:         config.QPS,
+		Concurrency: config.Concurrency,
+
+		ServerVersion: config.ServerVersion,
+		Stats:         config.APIStats,
+
+		RootCAs:            config.RootCAs,
+		ClientCertificate:  config.ClientCert,
+		InsecureSkipVerify: config.TLSSkipVerify,
+	})
+}
+
+// fatal logs an unrecoverable error and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(exitFatal)
+}
+
+// printDryRunSummary prints what would be generated and imported, per resource type
+func printDryRunSummary(terraformGen *lib.TerraformGenerator) {
+	const sampleSize = 3
+
+	resourcesByType := make(map[string][]lib.TerraformResource)
+	for _, resource := range terraformGen.GetResources() {
+		resourcesByType[resource.Type] = append(resourcesByType[resource.Type], resource)
+	}
+
+	importsByType := make(map[string]int)
+	for _, cmd := range terraformGen.GetImportCommands() {
+		importsByType[cmd.ResourceType]++
+	}
+
+	resourceTypes := make([]string, 0, len(resourcesByType))
+	for resourceType := range resourcesByType {
+		resourceTypes = append(resourceTypes, resourceType)
+	}
+	sort.Strings(resourceTypes)
+
+	fmt.Printf("\nDry run: nothing was written and no terraform commands were run\n\n")
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "TYPE\tRESOURCES\tDATA SOURCES\tIMPORTS\tSAMPLE NAMES")
+	for _, resourceType := range resourceTypes {
+		resourceCount, dataCount := 0, 0
+		samples := make([]string, 0, sampleSize)
+		for _, resource := range resourcesByType[resourceType] {
+			if resource.IsData {
+				dataCount++
+			} else {
+				resourceCount++
+			}
+			if len(samples) < sampleSize {
+				samples = append(samples, resource.Name)
+			}
+		}
+		if len(resourcesByType[resourceType]) > sampleSize {
+			samples = append(samples, "...")
+		}
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%d\t%s\n", resourceType, resourceCount, dataCount, importsByType[resourceType], strings.Join(samples, ", "))
+	}
+	writer.Flush()
+
+	fmt.Printf("\nTotal: %d resources, %d terraform imports\n", len(terraformGen.GetResources()), len(terraformGen.GetImportCommands()))
+}
+
+// printImportList prints the terraform imports in the order they would run
+func printImportList(terraformGen *lib.TerraformGenerator) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ORDER\tTYPE\tADDRESS\tID")
+	for i, cmd := range terraformGen.GetImportCommands() {
+		fmt.Fprintf(writer, "%d\t%s\t%s\t%s\n", i+1, cmd.ResourceType, cmd.ResourceAddress, cmd.ResourceID)
+	}
+	writer.Flush()
+}
+
+// generateTerraformFiles groups resources by type and generates .tf files, or one
+// root module per resource type when the state is split
+func generateTerraformFiles(terraformGen *lib.TerraformGenerator, splitState bool) error {
+	slog.Info("Generating Terraform files")
+
+	if splitState {
+		err := terraformGen.WriteSplitModules()
+		if err != nil {
+			return err
+		}
+		slog.Info("Terraform modules generated successfully", "modules", len(terraformGen.ModuleTypes()))
+		return nil
+	}
 
 	// First generate provider.tf
 	err := terraformGen.GenerateProviderFile()
@@ -140,61 +935,485 @@ func generateTerraformFiles(terraformGen *lib.TerraformGenerator, outputDir stri
 
 	// Generate a file for each resource type
 	for resourceType, resources := range resourcesByType {
-		fmt.Printf("Generating %s.tf with %d resources...\n", resourceType, len(resources))
+		slog.Info("Generating resource file", "type", resourceType, "count", len(resources))
 		err := terraformGen.WriteResourceFile(resourceType, resources)
 		if err != nil {
 			return fmt.Errorf("failed to generate %s resources: %w", resourceType, err)
 		}
 	}
 
-	fmt.Printf("Terraform files generated successfully\n")
+	slog.Info("Terraform files generated successfully")
 	return nil
 }
 
-// runTerraformImports executes terraform init and import commands
-func runTerraformImports(terraformGen *lib.TerraformGenerator, outputDir string) error {
+// mergeTerraformFiles appends the resources missing from the configuration in
+// the output directory, leaving the existing blocks untouched
+func mergeTerraformFiles(terraformGen *lib.TerraformGenerator, outputDir string) error {
+	blocks, err := readResourceBlocks(outputDir)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool, len(blocks))
+	for address := range blocks {
+		existing[address] = true
+	}
+
+	appended, err := terraformGen.AppendNewResources(existing)
+	if err != nil {
+		return err
+	}
+	slog.Info("Merged into the existing configuration", "existing", len(existing), "appended", appended)
+	return nil
+}
+
+// generateGroupSuggestions writes role-based auto_groups suggestions derived from
+// the fetched users and groups
+func generateGroupSuggestions(terraformGen *lib.TerraformGenerator, groupsHandler *resources.GroupsHandler, usersHandler *resources.UsersHandler) error {
+	suggestions := resources.SuggestRoleGroups(
+		usersHandler.GetUsers(),
+		groupsHandler.GetGroups(),
+		usersHandler.GetResourceMapping(),
+		groupsHandler.GetResourceMapping(),
+	)
+
+	filename := terraformGen.ModulePath("group", "group_suggestions.tf")
+	slog.Info("Writing group suggestions", "file", filename, "count", len(suggestions))
+	return terraformGen.WriteFile(filename, []byte(resources.FormatGroupSuggestions(suggestions)))
+}
+
+// runTerraformImports executes terraform init and imports the resources, recording
+// the results in the run summary. With split state every module is initialized
+// and imported on its own.
+func runTerraformImports(ctx context.Context, config *Config, runner *lib.TerraformRunner, terraformGen *lib.TerraformGenerator, summary *RunSummary) error {
 	importCommands := terraformGen.GetImportCommands()
+	summary.ImportsQueued = len(importCommands)
 	if len(importCommands) == 0 {
-		fmt.Printf("No terraform imports to run\n")
+		slog.Info("No terraform imports to run")
 		return nil
 	}
 
-	fmt.Printf("\nRunning terraform imports...\n")
+	// Imports an interrupted run completed are in the state already
+	if config.Progress != nil {
+		pending := make([]lib.ImportCommand, 0, len(importCommands))
+		for _, cmd := range importCommands {
+			if !config.Progress.Imported(cmd) {
+				pending = append(pending, cmd)
+			}
+		}
+		if resumed := len(importCommands) - len(pending); resumed > 0 {
+			slog.Info("Skipping imports completed before the interruption", "count", resumed)
+			summary.ImportsSucceeded += resumed
+		}
+		importCommands = pending
+		if len(importCommands) == 0 {
+			return nil
+		}
+	}
+
+	slog.Info("Running terraform imports", "count", len(importCommands), "mode", summary.ImportMode)
+
+	moduleDirs := make([]string, 0)
+	commandsByDir := make(map[string][]lib.ImportCommand)
+	for _, cmd := range importCommands {
+		dir := terraformGen.ModuleDir(cmd.ResourceType)
+		if _, exists := commandsByDir[dir]; !exists {
+			moduleDirs = append(moduleDirs, dir)
+		}
+		commandsByDir[dir] = append(commandsByDir[dir], cmd)
+	}
+
+	progress := lib.NewProgress("Importing resources", len(importCommands), config.InteractiveProgress)
+	defer progress.Done()
+	for _, dir := range moduleDirs {
+		phaseSuffix := ""
+		if len(moduleDirs) > 1 {
+			phaseSuffix = "_" + filepath.Base(dir)
+		}
+
+		if summary.ImportMode == lib.ImportModeBlocks || summary.ImportMode == lib.ImportModeState {
+			var err error
+			if summary.ImportMode == lib.ImportModeState {
+				err = runModuleState(ctx, runner, dir, commandsByDir[dir], phaseSuffix, summary)
+			} else {
+				err = runModuleImportBlocks(ctx, runner, terraformGen, dir, commandsByDir[dir], phaseSuffix, summary)
+			}
+			if err == nil {
+				for range commandsByDir[dir] {
+					progress.Increment()
+				}
+				if config.Progress != nil {
+					config.Progress.SaveImports(commandsByDir[dir]...)
+				}
+				continue
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			slog.Warn("Importing with "+summary.ImportMode+" mode failed, importing resources one by one", "dir", dir, "error", err)
+		}
 
-	fmt.Printf("Running terraform init...\n")
-	err := lib.TerraformInit(outputDir)
+		err := runModuleImports(ctx, runner, dir, commandsByDir[dir], config.Progress, progress, phaseSuffix, summary)
+		if err != nil {
+			return err
+		}
+	}
+
+	slog.Info("Terraform import completed", "succeeded", summary.ImportsSucceeded, "total", len(importCommands))
+	return nil
+}
+
+// checkTerraform verifies that the terraform binary exists and supports the
+// requested import mode, and returns the mode to use. In auto mode, import
+// blocks are used when the terraform version supports them.
+func checkTerraform(ctx context.Context, runner *lib.TerraformRunner, mode string, summary *RunSummary) (string, error) {
+	path, err := runner.Resolve()
+	if err != nil && mode == lib.ImportModeState {
+		slog.Warn("Terraform not found, the written state will not be validated by a plan", "error", err)
+		return mode, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	terraformVersion, err := runner.Version(ctx)
 	if err != nil {
-		return fmt.Errorf("terraform init failed: %w", err)
+		if mode == lib.ImportModeState {
+			slog.Warn("Failed to detect the terraform version", "path", path, "error", err)
+			return mode, nil
+		}
+		if mode == lib.ImportModeBlocks {
+			return "", fmt.Errorf("failed to detect the version of %s, which import mode blocks requires to be 1.5 or later: %w", path, err)
+		}
+		slog.Warn("Failed to detect the terraform version, importing resources one by one", "path", path, "error", err)
+		return lib.ImportModeCLI, nil
 	}
+	summary.TerraformVersion = terraformVersion
+	slog.Info("Using terraform", "path", path, "version", terraformVersion)
+
+	supportsBlocks := lib.SupportsImportBlocks(terraformVersion)
+	switch {
+	case mode == lib.ImportModeBlocks && !supportsBlocks:
+		return "", fmt.Errorf("import mode blocks requires Terraform 1.5 or later, %s is %s; use --import-mode cli or upgrade Terraform", path, terraformVersion)
+	case mode == lib.ImportModeAuto && supportsBlocks:
+		return lib.ImportModeBlocks, nil
+	case mode == lib.ImportModeAuto:
+		slog.Debug("Terraform does not support import blocks, importing resources one by one", "version", terraformVersion)
+		return lib.ImportModeCLI, nil
+	}
+	return mode, nil
+}
+
+// importPlanFile is the saved plan of an import blocks run, inside the workspace
+const importPlanFile = "import.tfplan"
+
+// runModuleImportBlocks imports the resources of one configuration directory
+// with import blocks and a single plan and apply, which starts the provider once
+// instead of once per resource. The plan is only applied if it imports every
+// resource and changes nothing else; otherwise nothing is applied and an error
+// is returned, so the caller can fall back to terraform import.
+func runModuleImportBlocks(ctx context.Context, runner *lib.TerraformRunner, terraformGen *lib.TerraformGenerator, dir string, importCommands []lib.ImportCommand, phaseSuffix string, summary *RunSummary) error {
+	workspace, err := lib.NewWorkspace(dir)
+	if err != nil {
+		return err
+	}
+	defer workspace.Close()
+
+	err = terraformGen.WriteImportBlocks(workspace.Dir(), importCommands)
+	if err != nil {
+		return fmt.Errorf("failed to write import blocks: %w", err)
+	}
+
+	slog.Info("Running terraform init", "dir", dir)
+	startedAt := time.Now()
+	err = runner.Init(ctx, workspace.Dir())
+	if err != nil {
+		return fmt.Errorf("terraform init failed in %s: %w", dir, err)
+	}
+	summary.TrackPhase("terraform_init"+phaseSuffix, startedAt)
+
+	slog.Info("Running terraform plan with import blocks", "dir", dir, "count", len(importCommands))
+	startedAt = time.Now()
+	err = runner.Plan(ctx, workspace.Dir(), importPlanFile)
+	if err != nil {
+		return fmt.Errorf("terraform plan failed: %w", err)
+	}
+	plan, err := runner.ShowPlan(ctx, workspace.Dir(), importPlanFile)
+	if err != nil {
+		return fmt.Errorf("terraform show failed: %w", err)
+	}
+	summary.TrackPhase("terraform_plan"+phaseSuffix, startedAt)
+
+	err = lib.CheckImportPlan(plan, importCommands)
+	if err != nil {
+		return err
+	}
+
+	startedAt = time.Now()
+	err = runner.Apply(ctx, workspace.Dir(), importPlanFile)
+	if err != nil {
+		return fmt.Errorf("terraform apply failed: %w", err)
+	}
+	summary.TrackPhase("terraform_apply"+phaseSuffix, startedAt)
+
+	// Only count the imports once the state holding them is in the output
+	// directory; otherwise the caller imports them again one by one
+	err = workspace.SyncState()
+	if err != nil {
+		return err
+	}
+	summary.ImportsSucceeded += len(importCommands)
+	return nil
+}
+
+// runModuleState adopts the resources of one configuration directory by
+// writing them into its terraform.tfstate directly, without running terraform
+// import. The state is written in a workspace and validated with a plan, which
+// refreshes every object and must change nothing, before it replaces the output
+// directory's state. Without a terraform binary the state is written unvalidated.
+func runModuleState(ctx context.Context, runner *lib.TerraformRunner, dir string, importCommands []lib.ImportCommand, phaseSuffix string, summary *RunSummary) error {
+	startedAt := time.Now()
+	if _, err := runner.Resolve(); err != nil {
+		added, err := lib.WriteState(dir, importCommands, summary.TerraformVersion)
+		if err != nil {
+			return err
+		}
+		slog.Warn("Wrote the state without validating it; run terraform plan before relying on it", "dir", dir, "added", added)
+		summary.TrackPhase("state_write"+phaseSuffix, startedAt)
+		summary.ImportsSucceeded += len(importCommands)
+		return nil
+	}
+
+	workspace, err := lib.NewWorkspace(dir)
+	if err != nil {
+		return err
+	}
+	defer workspace.Close()
+
+	added, err := lib.WriteState(workspace.Dir(), importCommands, summary.TerraformVersion)
+	if err != nil {
+		return err
+	}
+	summary.TrackPhase("state_write"+phaseSuffix, startedAt)
+
+	slog.Info("Running terraform init", "dir", dir)
+	startedAt = time.Now()
+	err = runner.Init(ctx, workspace.Dir())
+	if err != nil {
+		return fmt.Errorf("terraform init failed in %s: %w", dir, err)
+	}
+	summary.TrackPhase("terraform_init"+phaseSuffix, startedAt)
+
+	slog.Info("Validating the written state with terraform plan", "dir", dir, "added", added)
+	startedAt = time.Now()
+	err = runner.Plan(ctx, workspace.Dir(), importPlanFile)
+	if err != nil {
+		return fmt.Errorf("terraform plan failed: %w", err)
+	}
+	plan, err := runner.ShowPlan(ctx, workspace.Dir(), importPlanFile)
+	if err != nil {
+		return fmt.Errorf("terraform show failed: %w", err)
+	}
+	summary.TrackPhase("terraform_plan"+phaseSuffix, startedAt)
+
+	err = lib.CheckImportPlan(plan, nil)
+	if err != nil {
+		return err
+	}
+
+	err = workspace.SyncState()
+	if err != nil {
+		return err
+	}
+	summary.ImportsSucceeded += len(importCommands)
+	return nil
+}
+
+// runModuleImports initializes one configuration directory and imports its
+// resources, syncing the state back, and recording it in the checkpoint if
+// any, after every successful import
+func runModuleImports(ctx context.Context, runner *lib.TerraformRunner, dir string, importCommands []lib.ImportCommand, checkpoint *Checkpoint, progress *lib.Progress, phaseSuffix string, summary *RunSummary) error {
+	// Run terraform in an isolated copy of the output directory and sync the
+	// state back, so terraform never works on files that are being generated
+	workspace, err := lib.NewWorkspace(dir)
+	if err != nil {
+		return err
+	}
+	defer workspace.Close()
+
+	slog.Info("Running terraform init", "dir", dir)
+	startedAt := time.Now()
+	err = runner.Init(ctx, workspace.Dir())
+	if err != nil {
+		return fmt.Errorf("terraform init failed in %s: %w", dir, err)
+	}
+	summary.TrackPhase("terraform_init"+phaseSuffix, startedAt)
+	defer summary.TrackPhase("terraform_import"+phaseSuffix, time.Now())
 
-	successCount := 0
 	for _, cmd := range importCommands {
-		fmt.Printf("Importing %s...\n", cmd.ResourceAddress)
-		err := lib.TerraformImport(outputDir, cmd.ResourceAddress, cmd.ResourceID)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		progress.Increment()
+		slog.Debug("Importing resource", "address", cmd.ResourceAddress)
+		importStartedAt := time.Now()
+		err := runner.Import(ctx, workspace.Dir(), cmd.ResourceAddress, cmd.ResourceID)
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		summary.ImportTimings = append(summary.ImportTimings, ImportTiming{
+			Address:   cmd.ResourceAddress,
+			Duration:  time.Since(importStartedAt),
+			Succeeded: err == nil,
+		})
 		if err != nil {
-			fmt.Printf("  Warning: terraform import failed for %s: %v\n", cmd.ResourceAddress, err)
-		} else {
-			fmt.Printf("  Successfully imported %s\n", cmd.ResourceAddress)
-			successCount++
+			slog.Warn("Terraform import failed", "address", cmd.ResourceAddress, "error", err)
+			summary.ImportsFailed = append(summary.ImportsFailed, ImportFailure{Address: cmd.ResourceAddress, Error: err.Error()})
+			continue
+		}
+
+		slog.Debug("Successfully imported resource", "address", cmd.ResourceAddress, "duration", time.Since(importStartedAt))
+		summary.ImportsSucceeded++
+
+		err = workspace.SyncState()
+		if err != nil {
+			return err
+		}
+		if checkpoint != nil {
+			checkpoint.SaveImports(cmd)
 		}
 	}
 
-	fmt.Printf("\nTerraform import completed: %d/%d successful\n", successCount, len(importCommands))
 	return nil
 }
 
+// newRunID returns an identifier for this importer run, sortable by start time
+func newRunID() string {
+	suffix := make([]byte, 4)
+	_, err := rand.Read(suffix)
+	if err != nil {
+		return time.Now().UTC().Format("20060102T150405Z")
+	}
+	return fmt.Sprintf("%s-%s", time.Now().UTC().Format("20060102T150405Z"), hex.EncodeToString(suffix))
+}
+
 func showHelp() {
 	fmt.Println("NetBird terraformer Terraform Importer")
 	fmt.Println("=====================================")
 	fmt.Println("")
-	fmt.Println("Usage: ./netbird-importer [output-directory]")
+	fmt.Println("Usage: ./netbird-importer [generate] [flags] [output-directory]")
+	fmt.Println("       ./netbird-importer bundle [flags] [bundle-file]")
+	fmt.Println("       ./netbird-importer export [flags] [export-file]")
+	fmt.Println("")
+	fmt.Println("Commands:")
+	fmt.Println("  generate              - Fetch resources and generate Terraform files (default)")
+	fmt.Println("  list-imports          - Fetch resources and print the terraform imports in the order they would run")
+	fmt.Println("  doctor                - Check every API endpoint concurrently: status, latency and item count, then terraform and the output directory")
+	fmt.Println("  watch                 - Run generate every --interval until stopped, with an admin endpoint at --admin-addr")
+	fmt.Println("  deploy                - Write a Kubernetes CronJob or docker-compose manifest for scheduled runs")
+	fmt.Println("  compare-accounts      - Compare groups, policies and routes with the account at --target-url")
+	fmt.Println("  drift                 - Print resources added, changed or removed in NetBird since the output directory was generated")
+	fmt.Println("  diff <old> <new>      - Compare the configurations generated by two runs, grouped by resource type")
+	fmt.Printf("  bundle                - Capture API responses into an archive for offline generation (default: %s)\n", defaultBundleFile)
+	fmt.Println("  login                 - Log in through the IdP's device flow and print, or --keyring store, a new token")
+	fmt.Println("  token store|delete    - Store the token read from stdin in the OS keyring, for the management URL, or delete it")
+	fmt.Printf("  export                - Write the account and the references between its objects as JSON, or YAML for .yaml files (default: %s)\n", defaultExportFile)
+	fmt.Println("")
+	fmt.Println("Flags:")
+	fmt.Println("  --log-level <level>   - Log level: debug, info, warn, error (default: info)")
+	fmt.Println("  --log-format <format> - Log format: text, json (default: text, logs go to stderr)")
+	fmt.Println("  --no-progress         - Disable progress bars (logged as percentages when not on a terminal)")
+	fmt.Println("  -v, -vv, -vvv         - Verbosity: API requests, + per-resource tracing, + terraform commands")
+	fmt.Println("  --verbose LIST        - Debug output categories on their own: api, generate, terraform, all")
+	fmt.Println("  --config <path>       - Config file (default: ./netbird-terraformer.yaml if present, or NB_CONFIG)")
+	fmt.Println("  --profile <name>      - Use this profile of the config file's profiles (or NB_PROFILE)")
+	fmt.Println("  --env-file <path>     - Load environment variables from this file (default: ./.env if present)")
+	fmt.Println("  --token-file <path>   - Read the token from the first line of this file instead of NB_PAT (or NB_PAT_FILE)")
+	fmt.Println("  --token-stdin         - Read the token from the first line of stdin instead of NB_PAT")
+	fmt.Println("  --keyring             - Read the token from the OS keyring when NB_PAT is not set (see the token command)")
+	fmt.Printf("  --format <format>     - Output format: %s (default: hcl)\n", strings.Join(lib.OutputFormats(), ", "))
+	fmt.Printf("  --exclude-resources   - Comma-separated resource types to skip: %s\n", strings.Join(resourceTypes, ", "))
+	fmt.Println("  --include <regex>     - Only generate groups/policies/routes/users whose name or email matches")
+	fmt.Println("  --exclude <regex>     - Skip groups/policies/routes/users whose name or email matches")
+	fmt.Printf("  --import-order <t>    - Comma-separated resource types in import order (default: %s)\n", strings.Join(lib.DefaultImportOrder, ","))
+	fmt.Println("  --skip-system-groups  - Reference the All group and JWT/IdP-issued groups as data sources instead of managing them")
+	fmt.Println("  --skip-empty-groups   - Leave out groups without peers and resources; referenced ones become data sources")
+	fmt.Println("  --only-enabled        - Leave out disabled policies and routes")
+	fmt.Println("  --name-template <t=x> - Resource name template of a type, e.g. user='{{.Email | localpart}}'; repeatable")
+	fmt.Println("  --name-overrides <f>  - YAML file mapping object IDs to resource names, per resource type")
+	fmt.Println("  --reuse-state-names   - Keep the names of objects already in the output's terraform.tfstate (default: true)")
+	fmt.Println("  --terraform-path <p>  - Terraform binary used for imports (default: terraform from PATH)")
+	fmt.Println("  --import-mode <m>     - How resources are imported: auto, blocks (import blocks, terraform >= 1.5), cli,")
+	fmt.Println("                          state (write terraform.tfstate directly) (default: auto)")
+	fmt.Println("  --import-scripts <s>  - Comma-separated import scripts to write: sh (import.sh), ps1 (import.ps1) (default: sh,ps1)")
+	fmt.Println("  --url-comments        - Write NetBird dashboard links as comments above each resource")
+	fmt.Println("  --ignore-changes      - Add lifecycle ignore_changes for attributes of a type, e.g. group=peers; repeatable")
+	fmt.Println("  --prevent-destroy <t> - Comma-separated resource types whose resources get lifecycle prevent_destroy")
+	fmt.Println("  --max-retries <n>     - Retries for failed API requests: network errors, 429, 5xx (default: 3)")
+	fmt.Println("  --retry-delay <dur>   - Base delay between API retries, doubled per attempt (default: 500ms)")
+	fmt.Println("  --request-timeout <d> - How long a single API request may take before it is retried (default: 1m)")
+	fmt.Println("  --run-timeout <dur>   - Stop the whole run after this long, like Ctrl-C (default: 0, no limit)")
+	fmt.Println("  --concurrency <n>     - Resource types fetched at the same time after groups (default: 4)")
+	fmt.Println("  --qps <n>             - Maximum API requests per second (default: 0, no limit)")
+	fmt.Println("  --cache-dir <dir>     - Cache API responses to regenerate without refetching (default: no cache)")
+	fmt.Println("  --cache-ttl <dur>     - How long cached API responses are used before revalidating (default: 15m)")
+	fmt.Println("  --refresh             - Ignore cached API responses and fetch them again")
+	fmt.Println("  --resume              - Resume an interrupted run from the checkpoint in the output directory")
+	fmt.Println("  --checkpoint          - Save fetched responses and completed imports for --resume (default: true)")
+	fmt.Println("  --server-version <v>  - Management server version, for servers that do not report it (default: detected)")
+	fmt.Println("  --trace-http          - Log connection timings, TLS details and redacted summaries of every API request")
+	fmt.Println("  --tls-skip-verify     - Do not verify the server's TLS certificate (insecure, prefer NB_CA_CERT)")
+	fmt.Println("  --fail-on-warning     - Exit with status 2 if any warning was logged")
+	fmt.Println("  --preflight           - Check the token belongs to an owner or admin, or can access every endpoint (default: true)")
+	fmt.Println("  --split-state         - Write one root module with its own state per resource type")
+	fmt.Println("  --terragrunt          - Write the split modules as a Terragrunt layout with a root terragrunt.hcl")
+	fmt.Println("  --module-package      - Write a reusable module (main.tf, variables.tf, outputs.tf, examples/) instead")
+	fmt.Println("  --reconcile           - After importing, adopt the state's values of changed attributes until the plan is empty")
+	fmt.Println("  --merge               - Keep the output directory's configuration; only append and import resources not in it")
+	fmt.Println("  --prune               - Write removed blocks for state objects deleted in NetBird (terraform >= 1.7)")
+	fmt.Println("  --tfc-organization    - HCP Terraform organization to run the import in (token from TFE_TOKEN)")
+	fmt.Println("  --tfc-workspace <w>   - HCP Terraform workspace to run the import in; created if missing")
+	fmt.Println("  --tfc-hostname <h>    - Terraform Enterprise hostname (default: app.terraform.io)")
+	fmt.Println("  --interval <dur>      - watch: time between runs (default: 1h)")
+	fmt.Printf("  --admin-addr <addr>   - watch: admin endpoint address: /healthz, /readyz, /status, POST /sync (default: %s)\n", defaultAdminAddr)
+	fmt.Println("  --target-url <url>    - compare-accounts: management URL of the other account")
+	fmt.Println("  --stats               - Print request counts, latencies, sizes, retries and cache hits per API endpoint")
+	fmt.Println("  --stats-file <path>   - Write anonymous usage statistics (counts, durations, error categories) locally")
+	fmt.Println("  --from-bundle <file>  - Generate offline from a bundle; NB_PAT is not required")
+	fmt.Println("  --record <dir>        - Record every API response (including errors) to a fixtures directory")
+	fmt.Println("  --replay <dir>        - Generate from fixtures recorded with --record; NB_PAT is not required")
+	fmt.Println("  --dry-run             - Fetch everything and print what would be generated, without writing files")
+	fmt.Println("  --limit <n>           - Generate and import at most n objects per resource type, for trial runs")
+	fmt.Println("  --sample              - Spread --limit over each type's objects instead of the first ones (default limit: 5)")
+	fmt.Println("  --email-report <to>   - Email the run summary to comma-separated recipients (requires SMTP_HOST)")
+	fmt.Println("  --suggest-groups      - Write role-based group membership suggestions (group_suggestions.tf)")
+	fmt.Println("  --pulumi-import       - Write pulumi-import.json for bulk importing with pulumi import -f")
+	fmt.Println("  --ansible-inventory   - Write ansible_inventory.yaml with the peers grouped by their NetBird groups")
+	fmt.Println("  --graph <format>      - Write the references between resources as graph.dot (dot) or graph.mmd (mermaid)")
+	fmt.Println("  --html-report         - Write access_report.html: groups, their peers and the traffic policies allow between them")
 	fmt.Println("")
 	fmt.Println("Environment variables:")
-	fmt.Println("  NB_PAT                - Your NetBird Personal Access Token (required)")
+	fmt.Println("  NB_PAT                - Your NetBird Personal Access Token (required, unless read from a file, stdin or the keyring)")
+	fmt.Println("  NB_PAT_FILE           - File holding the token, same as --token-file (optional)")
+	fmt.Println("  NB_OIDC_ISSUER, NB_OIDC_CLIENT_ID, NB_OIDC_AUDIENCE - login: IdP issuer, client ID and audience")
+	fmt.Println("  NB_PAT_VAULT_PATH     - Vault KV path holding the token, as path or path#field (optional, field: token)")
+	fmt.Println("  VAULT_ADDR, VAULT_TOKEN - Vault address and token for NB_PAT_VAULT_PATH (also VAULT_NAMESPACE, VAULT_CACERT)")
 	fmt.Println("  NB_MANAGEMENT_URL     - NetBird Management API URL (optional)")
 	fmt.Println("                          Defaults to https://api.netbird.io")
-	fmt.Println("  DEBUG                 - Enable debug output (optional, set to 'true')")
+	fmt.Println("  NB_TARGET_PAT, NB_TARGET_MANAGEMENT_URL - compare-accounts: token and URL of the other account")
+	fmt.Println("  NB_CA_CERT            - PEM file with the CA certificates of a self-hosted server (optional)")
+	fmt.Println("  NB_CLIENT_CERT, NB_CLIENT_KEY - PEM client certificate and key for mutual TLS (optional)")
+	fmt.Println("  NB_TELEMETRY_URL      - Opt in to sending the --stats-file statistics to this URL (optional)")
+	fmt.Println("  DEBUG                 - Enable all debug output, same as -vvv (optional, set to 'true')")
 	fmt.Println("  AUTO_IMPORT           - Auto-run terraform import (optional, set to 'false' to disable)")
+	fmt.Println("  TERRAFORM_BIN         - Terraform binary used for imports (optional, default: terraform from PATH)")
+	fmt.Println("  SMTP_HOST, SMTP_PORT  - SMTP server for --email-report (port defaults to 587)")
+	fmt.Println("  SMTP_USERNAME, SMTP_PASSWORD, SMTP_FROM - SMTP credentials and sender address")
+	fmt.Println("  NB_DASHBOARD_URL      - NetBird dashboard URL used for deep links (optional)")
+	fmt.Println("                          Derived from NB_MANAGEMENT_URL by default")
+	fmt.Println("  NB_ACCOUNT            - Only generate this account of the config file's accounts (optional)")
+	fmt.Println("  NB_PROFILE            - Use this profile of the config file's profiles (optional)")
+	fmt.Println("  NB_PAT_<NAME>         - Token of each account of the config file, unless it sets token_env")
 	fmt.Println("")
 	fmt.Println("Examples:")
 	fmt.Println("  # Import to default 'generated' directory")
@@ -206,6 +1425,28 @@ func showHelp() {
 	fmt.Println("  export NB_MANAGEMENT_URL=\"https://netbird.api.com:33073\"")
 	fmt.Println("  ./netbird-importer my-terraform-config")
 	fmt.Println("")
+	fmt.Println("  # Generate Terraform JSON syntax instead of HCL")
+	fmt.Println("  ./netbird-importer --format json my-terraform-config")
+	fmt.Println("")
+	fmt.Println("  # Generate a CDK for Terraform application in TypeScript")
+	fmt.Println("  ./netbird-importer --format cdktf-ts my-cdktf-app")
+	fmt.Println("")
+	fmt.Println("  # Generate a Pulumi YAML program")
+	fmt.Println("  ./netbird-importer --format pulumi my-pulumi-project")
+	fmt.Println("")
+	fmt.Println("  # Only import objects following a team naming convention")
+	fmt.Println("  ./netbird-importer --include '^(team-a|dev)' --exclude '-old$'")
+	fmt.Println("")
+	fmt.Println("  # Skip users managed by your identity provider")
+	fmt.Println("  ./netbird-importer --exclude-resources user")
+	fmt.Println("")
+	fmt.Println("  # Capture the account online, generate in an air-gapped environment")
+	fmt.Println("  ./netbird-importer bundle netbird.tar.gz")
+	fmt.Println("  ./netbird-importer generate --from-bundle netbird.tar.gz my-terraform-config")
+	fmt.Println("")
+	fmt.Println("  # Export the account for a CMDB")
+	fmt.Println("  ./netbird-importer export netbird.yaml")
+	fmt.Println("")
 	fmt.Println("Resource types imported:")
 	fmt.Println("  - Groups")
 	fmt.Println("  - Users")
@@ -213,10 +1454,11 @@ func showHelp() {
 	fmt.Println("  - Routes")
 	fmt.Println("  - Setup Keys")
 	fmt.Println("")
-	fmt.Println("Note: Peers are managed by the NetBird client and available as data sources only.")
+	fmt.Println("Note: Peers are managed by the NetBird client and generated as data sources only.")
+	fmt.Println("      Peers sharing a name are disambiguated by their NetBird IP and looked up by ID.")
 	fmt.Println("")
 	fmt.Println("Debug commands:")
-	fmt.Println("  ./netbird-importer --debug-auth   # Test authentication")
+	fmt.Println("  ./netbird-importer --debug-auth   # Test authentication and probe the access to every endpoint")
 }
 
 func debugAuth() {
@@ -238,8 +1480,8 @@ func debugAuth() {
 	}
 
 	fmt.Printf("INFO: Token length: %d characters\n", len(pat))
-	if len(pat) >= 10 {
-		fmt.Printf("INFO: Token starts with: %s...\n", pat[:10])
+	if len(pat) >= 4 {
+		fmt.Printf("INFO: Token starts with: %s...\n", pat[:4])
 	}
 
 	if len(pat) < 20 {
@@ -247,16 +1489,74 @@ func debugAuth() {
 	}
 
 	fmt.Println("\n=== Testing API Connection ===")
-	service := NewNetBirdService(managementURL, pat, true)
-
-	var groups []struct {
-		ID   string `json:"id"`
-		Name string `json:"name"`
+	rootCAs, err := loadCACertPool(os.Getenv("NB_CA_CERT"))
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		return
+	}
+	if rootCAs != nil {
+		fmt.Printf("INFO: Trusting CA certificates from %s\n", os.Getenv("NB_CA_CERT"))
 	}
-	err := service.Get("/api/groups", &groups)
+	clientCert, err := loadClientCertificate(os.Getenv("NB_CLIENT_CERT"), os.Getenv("NB_CLIENT_KEY"))
 	if err != nil {
-		fmt.Printf("ERROR: API test failed: %v\n", err)
+		fmt.Printf("ERROR: %v\n", err)
+		return
+	}
+	if clientCert != nil {
+		fmt.Printf("INFO: Presenting client certificate %s\n", os.Getenv("NB_CLIENT_CERT"))
+	}
+	service := NewNetBirdService(managementURL, pat, ServiceOptions{Debug: true, RootCAs: rootCAs, ClientCertificate: clientCert})
+
+	// Probed one after the other, so the latencies are not skewed by each other
+	endpoints := importerEndpoints(nil)
+	for _, endpoint := range debugAuthEndpoints {
+		if !containsString(endpoints, endpoint) {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	checks := make([]endpointCheck, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		checks = append(checks, checkEndpoint(context.Background(), service, endpoint))
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ENDPOINT\tSTATUS\tLATENCY\tITEMS\tHINT")
+	failed := 0
+	for _, check := range checks {
+		items := "-"
+		if check.Err == nil {
+			items = fmt.Sprintf("%d", check.Items)
+		} else {
+			failed++
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", check.Endpoint, check.Status, check.Latency.Round(time.Millisecond), items, authHint(check))
+	}
+	table.Flush()
+
+	if failed == 0 {
+		fmt.Printf("\nSUCCESS: All %d endpoints are accessible\n", len(checks))
 	} else {
-		fmt.Printf("SUCCESS: API test successful! Found %d groups\n", len(groups))
+		fmt.Printf("\nERROR: %d of %d endpoints failed; the import fails or leaves out their resources\n", failed, len(checks))
+	}
+}
+
+// debugAuthEndpoints are probed by --debug-auth besides the importer's own:
+// DNS and networks, which the provider manages but the default build does
+// not import
+var debugAuthEndpoints = []string{"/api/dns/nameservers", "/api/networks"}
+
+// authHint explains the likely cause of a failed endpoint check
+func authHint(check endpointCheck) string {
+	switch check.Status {
+	case "ok":
+		return ""
+	case "HTTP 401":
+		return "token invalid or expired"
+	case "HTTP 403":
+		return "the token's user lacks permission; admin or owner role needed"
+	case "HTTP 404":
+		return "not available on this server version"
+	default:
+		return errorCategory(check.Err.Error())
 	}
 }
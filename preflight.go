@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"netbird-terraformer/resources"
+)
+
+// fullAccessRoles are the user roles that can read and manage every object
+var fullAccessRoles = []string{"owner", "admin"}
+
+// preflight checks the token before anything is fetched. A token of an owner
+// or admin passes; for other roles every endpoint of the run is probed and
+// the run fails listing the inaccessible ones, instead of failing or leaving
+// out resource types midway.
+func preflight(ctx context.Context, config *Config) error {
+	service := newService(config)
+
+	role, err := currentUserRole(ctx, service)
+	if err != nil {
+		var status *statusError
+		if errors.As(err, &status) && status.StatusCode == http.StatusUnauthorized {
+			return fmt.Errorf("the token was rejected by %s: it is invalid or expired", config.ServerURL)
+		}
+		// The user list may itself be inaccessible; the probe tells
+		slog.Debug("Could not look up the token's user, probing the endpoints", "error", err)
+	}
+	if containsString(fullAccessRoles, role) {
+		slog.Debug("Token belongs to a user with full access", "role", role)
+		return nil
+	}
+
+	var inaccessible []string
+	for _, endpoint := range importerEndpoints(config.ExcludedTypes) {
+		check := checkEndpoint(ctx, service, endpoint)
+		if check.Err != nil {
+			inaccessible = append(inaccessible, fmt.Sprintf("%s (%s)", endpoint, check.Status))
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	describedRole := "of an unknown role"
+	if role != "" {
+		describedRole = "with the " + role + " role"
+	}
+	if len(inaccessible) > 0 {
+		return fmt.Errorf("the token belongs to a user %s, not an owner or admin, and can't access %s; use a token of an admin, or skip the types with --exclude-resources",
+			describedRole, strings.Join(inaccessible, ", "))
+	}
+	slog.Warn("The token's user is not an owner or admin; it can read every endpoint, but terraform apply needs an admin token", "role", role)
+	return nil
+}
+
+// currentUserRole returns the role of the user the token belongs to, from
+// /api/users/current or, on servers without it, the user flagged is_current
+func currentUserRole(ctx context.Context, service *NetBirdService) (string, error) {
+	var current resources.User
+	err := service.Get(ctx, "/api/users/current", &current)
+	var status *statusError
+	if err == nil {
+		return current.Role, nil
+	}
+	if !errors.As(err, &status) || status.StatusCode != http.StatusNotFound {
+		return "", err
+	}
+
+	var users []resources.User
+	if err := service.Get(ctx, "/api/users", &users); err != nil {
+		return "", err
+	}
+	for _, user := range users {
+		if user.IsCurrent {
+			return user.Role, nil
+		}
+	}
+	return "", errors.New("no user is flagged as the current one")
+}
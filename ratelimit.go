@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces API requests to a maximum rate and pauses them when the
+// server reports that the rate limit is exhausted
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration // minimum time between requests, 0 for no limit
+	next     time.Time     // earliest time the next request may start
+}
+
+// newRateLimiter creates a limiter for the given requests per second; qps <= 0
+// disables client-side limiting, server rate limit headers are still honored
+func newRateLimiter(qps float64) *rateLimiter {
+	limiter := &rateLimiter{}
+	if qps > 0 {
+		limiter.interval = time.Duration(float64(time.Second) / qps)
+	}
+	return limiter
+}
+
+// Wait blocks until the next request may be sent or the context is cancelled
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(l.interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PauseUntil holds back further requests until the given time
+func (l *rateLimiter) PauseUntil(until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until.After(l.next) {
+		l.next = until
+	}
+}
+
+// Observe pauses the limiter when a response reports an exhausted rate limit
+// through the X-RateLimit-Remaining and X-RateLimit-Reset headers
+func (l *rateLimiter) Observe(header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil || remaining > 0 {
+		return
+	}
+
+	reset := parseRateLimitReset(header.Get("X-RateLimit-Reset"))
+	if reset.IsZero() {
+		return
+	}
+
+	slog.Debug("API rate limit exhausted, pausing requests", "component", "api", "until", reset.Format(time.RFC3339))
+	l.PauseUntil(reset)
+}
+
+// parseRateLimitReset parses X-RateLimit-Reset, which servers send either as
+// seconds until the reset or as a Unix timestamp
+func parseRateLimitReset(value string) time.Time {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}
+	}
+
+	// Anything larger than a year is a timestamp rather than a delay
+	if seconds > 365*24*60*60 {
+		return time.Unix(seconds, 0)
+	}
+	return time.Now().Add(time.Duration(seconds) * time.Second)
+}
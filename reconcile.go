@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"netbird-terraformer/lib"
+)
+
+// maxReconcileRounds bounds the plans --reconcile runs per configuration
+// directory; a round that adopts nothing stops earlier
+const maxReconcileRounds = 3
+
+// reconcilePlanFile is the saved plan of a reconcile round, inside the workspace
+const reconcilePlanFile = "reconcile.tfplan"
+
+// runReconcile plans the configuration once the imports completed and adopts
+// the refreshed state's values of the attributes the plan would change,
+// rewrites the generated files and plans again, until the plan is empty or
+// only changes that can't be reconciled remain. Those are recorded in the
+// summary, with a warning, for review.
+func runReconcile(ctx context.Context, config *Config, runner *lib.TerraformRunner, terraformGen *lib.TerraformGenerator, summary *RunSummary) error {
+	dirs := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, cmd := range terraformGen.GetImportCommands() {
+		if dir := terraformGen.ModuleDir(cmd.ResourceType); !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	defer summary.TrackPhase("reconcile", time.Now())
+	for round := 1; round <= maxReconcileRounds; round++ {
+		adopted := 0
+		unreconcilable := make([]string, 0)
+		for _, dir := range dirs {
+			slog.Info("Planning to reconcile the configuration", "dir", dir, "round", round)
+			plan, err := planModule(ctx, runner, dir)
+			if err != nil {
+				return err
+			}
+			result, err := terraformGen.Reconcile(plan)
+			if err != nil {
+				return err
+			}
+			for _, attribute := range result.Adopted {
+				slog.Info("Adopted the value of the state", "attribute", attribute)
+			}
+			adopted += len(result.Adopted)
+			summary.Reconciled = append(summary.Reconciled, result.Adopted...)
+			unreconcilable = append(unreconcilable, result.Unreconcilable...)
+		}
+
+		if adopted == 0 {
+			summary.Unreconcilable = unreconcilable
+			if len(unreconcilable) > 0 {
+				summary.AddWarning("The plan still changes %d attributes or resources that can't be reconciled, review them with terraform plan", len(unreconcilable))
+			} else {
+				slog.Info("The plan is empty", "reconciled", len(summary.Reconciled))
+			}
+			return nil
+		}
+
+		if err := generateTerraformFiles(terraformGen, config.SplitState); err != nil {
+			return fmt.Errorf("failed to rewrite the reconciled configuration: %w", err)
+		}
+	}
+
+	summary.AddWarning("Stopped reconciling after %d rounds, the plan may not be empty yet; review it with terraform plan", maxReconcileRounds)
+	return nil
+}
+
+// planModule plans a configuration directory in a workspace and returns the
+// JSON representation of the plan
+func planModule(ctx context.Context, runner *lib.TerraformRunner, dir string) ([]byte, error) {
+	workspace, err := lib.NewWorkspace(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer workspace.Close()
+
+	if err := runner.Init(ctx, workspace.Dir()); err != nil {
+		return nil, fmt.Errorf("terraform init failed in %s: %w", dir, err)
+	}
+	if err := runner.Plan(ctx, workspace.Dir(), reconcilePlanFile); err != nil {
+		return nil, fmt.Errorf("terraform plan failed: %w", err)
+	}
+	plan, err := runner.ShowPlan(ctx, workspace.Dir(), reconcilePlanFile)
+	if err != nil {
+		return nil, fmt.Errorf("terraform show failed: %w", err)
+	}
+	return plan, nil
+}
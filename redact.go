@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"netbird-terraformer/lib"
+)
+
+// redacted replaces credentials in debug output
+const redacted = "REDACTED"
+
+// secretHeaders are the request and response headers carrying credentials
+var secretHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Vault-Token"}
+
+// secretFields are the JSON fields of API responses holding secrets: the key
+// of a setup key, the plain text of a created personal access token and the
+// tokens and passwords of integrations
+var secretFields = map[string]bool{
+	"key":           true,
+	"plain_token":   true,
+	"token":         true,
+	"secret":        true,
+	"password":      true,
+	"client_secret": true,
+	"access_token":  true,
+	"refresh_token": true,
+	"id_token":      true,
+}
+
+// redactHeaders returns a copy of the headers with credentials masked. The
+// authorization scheme is kept, so the output still shows which one was sent.
+func redactHeaders(header http.Header) http.Header {
+	masked := header.Clone()
+	for _, name := range secretHeaders {
+		values := masked.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		replaced := make([]string, 0, len(values))
+		for _, value := range values {
+			scheme, _, hasScheme := strings.Cut(value, " ")
+			if hasScheme && strings.HasSuffix(name, "Authorization") {
+				replaced = append(replaced, scheme+" "+redacted)
+			} else {
+				replaced = append(replaced, redacted)
+			}
+		}
+		masked[http.CanonicalHeaderKey(name)] = replaced
+	}
+	return masked
+}
+
+// redactBody returns a response body with secret fields masked, if it is
+// JSON, and the token and anything matching the default secret patterns
+// masked wherever they appear
+func (s *NetBirdService) redactBody(body []byte) string {
+	text := string(body)
+	var document any
+	if json.Unmarshal(body, &document) == nil {
+		if masked, err := json.Marshal(redactFields(document)); err == nil {
+			text = string(masked)
+		}
+	}
+	text, _ = lib.ScrubText(text, lib.ScrubConfig{Secrets: []string{s.apiToken}, Patterns: lib.DefaultSecretPatterns})
+	return text
+}
+
+// redactFields masks the values of secret fields in a decoded JSON document
+func redactFields(value any) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		for key, item := range typed {
+			if _, isString := item.(string); isString && secretFields[strings.ToLower(key)] {
+				typed[key] = redacted
+			} else {
+				typed[key] = redactFields(item)
+			}
+		}
+	case []any:
+		for i, item := range typed {
+			typed[i] = redactFields(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"netbird-terraformer/lib"
+	"netbird-terraformer/resources"
+)
+
+// reportFile is written to the output directory at the end of every run
+const reportFile = "report.json"
+
+// runReport is the machine-readable form of the run summary
+type runReport struct {
+	RunID           string                    `json:"run_id"`
+	Version         string                    `json:"version"`
+	Status          string                    `json:"status"`
+	ExitCode        int                       `json:"exit_code"`
+	ServerURL       string                    `json:"server_url"`
+	OutputDir       string                    `json:"output_dir"`
+	StartedAt       string                    `json:"started_at"`
+	FinishedAt      string                    `json:"finished_at"`
+	DurationSeconds float64                   `json:"duration_seconds"`
+	Resources       map[string]resourceReport `json:"resources"`
+	Skipped         []lib.SkippedResource     `json:"skipped"`
+	NameCollisions  []lib.NameCollision       `json:"name_collisions"`
+	Unresolved      []lib.UnresolvedReference `json:"unresolved_references"`
+	Pruned          []lib.PrunedResource      `json:"pruned"`
+	Reconciled      []string                  `json:"reconciled"`
+	Unreconcilable  []string                  `json:"unreconcilable"`
+	SkippedTypes    []string                  `json:"skipped_types"`
+	Imports         importReport              `json:"imports"`
+	SetupKeys       []resources.SetupKeyUsage `json:"setup_keys"`
+	Timings         []timingReport            `json:"timings"`
+	APIRequests     []endpointReport          `json:"api_requests"`
+	Warnings        []string                  `json:"warnings"`
+	SecretsRedacted []lib.ScrubFinding        `json:"secrets_redacted"`
+}
+
+type resourceReport struct {
+	Discovered int `json:"discovered"`
+	Generated  int `json:"generated"`
+	Skipped    int `json:"skipped"`
+}
+
+type importReport struct {
+	Mode      string                `json:"mode,omitempty"`
+	Terraform string                `json:"terraform_version,omitempty"`
+	Queued    int                   `json:"queued"`
+	Succeeded int                   `json:"succeeded"`
+	Failed    []importFailureReport `json:"failed"`
+	Durations []importTimingReport  `json:"durations"`
+	Slowest   []importTimingReport  `json:"slowest"`
+}
+
+type importTimingReport struct {
+	Address   string  `json:"address"`
+	Seconds   float64 `json:"seconds"`
+	Succeeded bool    `json:"succeeded"`
+}
+
+type importFailureReport struct {
+	Address string `json:"address"`
+	Error   string `json:"error"`
+}
+
+type endpointReport struct {
+	Endpoint    string  `json:"endpoint"`
+	Requests    int     `json:"requests"`
+	Retries     int     `json:"retries"`
+	Errors      int     `json:"errors"`
+	CacheHits   int     `json:"cache_hits"`
+	NotModified int     `json:"not_modified"`
+	Bytes       int64   `json:"bytes"`
+	Seconds     float64 `json:"seconds"`
+	MaxSeconds  float64 `json:"max_seconds"`
+}
+
+type timingReport struct {
+	Phase   string  `json:"phase"`
+	Seconds float64 `json:"seconds"`
+}
+
+// newRunReport converts a run summary into its report.json form
+func newRunReport(s *RunSummary) runReport {
+	status := "succeeded"
+	if s.Interrupted {
+		status = "interrupted"
+	} else if !s.Succeeded() {
+		status = "finished_with_warnings"
+	}
+
+	report := runReport{
+		RunID:           s.RunID,
+		Version:         s.Version,
+		Status:          status,
+		ExitCode:        s.ExitCode,
+		ServerURL:       s.ServerURL,
+		OutputDir:       s.OutputDir,
+		StartedAt:       s.StartedAt.UTC().Format(time.RFC3339),
+		FinishedAt:      s.FinishedAt.UTC().Format(time.RFC3339),
+		DurationSeconds: s.FinishedAt.Sub(s.StartedAt).Seconds(),
+		Resources:       make(map[string]resourceReport),
+		Skipped:         append([]lib.SkippedResource{}, s.Skipped...),
+		NameCollisions:  append([]lib.NameCollision{}, s.NameCollisions...),
+		Unresolved:      append([]lib.UnresolvedReference{}, s.Unresolved...),
+		Pruned:          append([]lib.PrunedResource{}, s.Pruned...),
+		Reconciled:      append([]string{}, s.Reconciled...),
+		Unreconcilable:  append([]string{}, s.Unreconcilable...),
+		SkippedTypes:    append([]string{}, s.SkippedTypes...),
+		Imports: importReport{
+			Mode:      s.ImportMode,
+			Terraform: s.TerraformVersion,
+			Queued:    s.ImportsQueued,
+			Succeeded: s.ImportsSucceeded,
+			Failed:    make([]importFailureReport, 0, len(s.ImportsFailed)),
+			Durations: make([]importTimingReport, 0, len(s.ImportTimings)),
+			Slowest:   make([]importTimingReport, 0, slowestImportsShown),
+		},
+		SetupKeys:   append([]resources.SetupKeyUsage{}, s.SetupKeys...),
+		Timings:     make([]timingReport, 0, len(s.Phases)),
+		APIRequests: make([]endpointReport, 0),
+		Warnings:    append([]string{}, s.Warnings...),
+
+		SecretsRedacted: append([]lib.ScrubFinding{}, s.SecretsRedacted...),
+	}
+
+	for resourceType, count := range s.Discovered {
+		entry := report.Resources[resourceType]
+		entry.Discovered = count
+		report.Resources[resourceType] = entry
+	}
+	for resourceType, count := range s.ResourceCounts {
+		entry := report.Resources[resourceType]
+		entry.Generated = count
+		report.Resources[resourceType] = entry
+	}
+	for _, skipped := range s.Skipped {
+		entry := report.Resources[skipped.Type]
+		entry.Skipped++
+		report.Resources[skipped.Type] = entry
+	}
+
+	sort.Slice(report.Skipped, func(i, j int) bool {
+		if report.Skipped[i].Type != report.Skipped[j].Type {
+			return report.Skipped[i].Type < report.Skipped[j].Type
+		}
+		return report.Skipped[i].Name < report.Skipped[j].Name
+	})
+
+	for _, failure := range s.ImportsFailed {
+		report.Imports.Failed = append(report.Imports.Failed, importFailureReport{Address: failure.Address, Error: failure.Error})
+	}
+	for _, timing := range s.ImportTimings {
+		report.Imports.Durations = append(report.Imports.Durations, newImportTimingReport(timing))
+	}
+	for _, timing := range s.SlowestImports(slowestImportsShown) {
+		report.Imports.Slowest = append(report.Imports.Slowest, newImportTimingReport(timing))
+	}
+	for _, phase := range s.Phases {
+		report.Timings = append(report.Timings, timingReport{Phase: phase.Name, Seconds: phase.Duration.Seconds()})
+	}
+	for _, stats := range s.API.Endpoints() {
+		report.APIRequests = append(report.APIRequests, endpointReport{
+			Endpoint:    stats.Endpoint,
+			Requests:    stats.Requests,
+			Retries:     stats.Retries,
+			Errors:      stats.Errors,
+			CacheHits:   stats.CacheHits,
+			NotModified: stats.NotModified,
+			Bytes:       stats.Bytes,
+			Seconds:     stats.Latency.Seconds(),
+			MaxSeconds:  stats.MaxLatency.Seconds(),
+		})
+	}
+
+	return report
+}
+
+func newImportTimingReport(timing ImportTiming) importTimingReport {
+	return importTimingReport{Address: timing.Address, Seconds: timing.Duration.Seconds(), Succeeded: timing.Succeeded}
+}
+
+// writeReport writes report.json to the output directory
+func writeReport(outputDir string, s *RunSummary) error {
+	data, err := json.MarshalIndent(newRunReport(s), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(outputDir, reportFile), append(data, '\n'), 0644)
+}
@@ -0,0 +1,217 @@
+package resources
+
+import (
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AccessReportFile is the file the HTML access report is written to
+const AccessReportFile = "access_report.html"
+
+// AccessFlow is traffic a policy rule allows, or drops, from one group to
+// another. Bidirectional rules yield a flow in each direction.
+type AccessFlow struct {
+	Source      string
+	Destination string
+	Action      string
+	Traffic     string // protocol and ports, e.g. tcp 22, 8000-8080
+	Policy      string
+	Rule        string
+	Enabled     bool // false if the policy or the rule is disabled
+}
+
+// AccessGroup is a group with its member peers
+type AccessGroup struct {
+	Name   string
+	Issued string
+	Peers  []Peer
+}
+
+// accessReport is the data of the report template
+type accessReport struct {
+	GeneratedAt   time.Time
+	ServerURL     string
+	Groups        []AccessGroup
+	Flows         []AccessFlow
+	DisabledFlows []AccessFlow
+	Peers         int
+	Policies      int
+}
+
+// AccessFlows derives the flows of the policies' rules. Groups are named by
+// the groups list, falling back to the name in the rule and then the ID.
+// Network resources a rule targets instead of a group are named by type and ID.
+func AccessFlows(policies []Policy, groups []Group) []AccessFlow {
+	groupNames := make(map[string]string, len(groups))
+	for _, group := range groups {
+		groupNames[group.ID] = group.Name
+	}
+	name := func(group GroupInfo) string {
+		if name := groupNames[group.ID]; name != "" {
+			return name
+		}
+		if group.Name != "" {
+			return group.Name
+		}
+		return group.ID
+	}
+	endpoints := func(groups []GroupInfo, resource *Resource) []string {
+		names := make([]string, 0, len(groups)+1)
+		for _, group := range groups {
+			names = append(names, name(group))
+		}
+		if resource != nil && resource.ID != "" {
+			names = append(names, fmt.Sprintf("%s resource %s", resource.Type, resource.ID))
+		}
+		return names
+	}
+
+	flows := make([]AccessFlow, 0)
+	for _, policy := range policies {
+		for _, rule := range policy.Rules {
+			traffic := ruleTraffic(rule)
+			for _, source := range endpoints(rule.Sources, rule.SourceResource) {
+				for _, destination := range endpoints(rule.Destinations, rule.DestinationResource) {
+					flow := AccessFlow{
+						Source:      source,
+						Destination: destination,
+						Action:      rule.Action,
+						Traffic:     traffic,
+						Policy:      policy.Name,
+						Rule:        rule.Name,
+						Enabled:     policy.Enabled && rule.Enabled,
+					}
+					flows = append(flows, flow)
+					if rule.Bidirectional && source != destination {
+						flow.Source, flow.Destination = destination, source
+						flows = append(flows, flow)
+					}
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(flows, func(i, j int) bool {
+		if flows[i].Source != flows[j].Source {
+			return flows[i].Source < flows[j].Source
+		}
+		return flows[i].Destination < flows[j].Destination
+	})
+	return flows
+}
+
+// ruleTraffic describes the protocol and ports a rule matches
+func ruleTraffic(rule PolicyRule) string {
+	ports := append([]string(nil), rule.Ports...)
+	for _, portRange := range rule.PortRanges {
+		ports = append(ports, fmt.Sprintf("%d-%d", portRange.Start, portRange.End))
+	}
+	protocol := rule.Protocol
+	if protocol == "" {
+		protocol = "all"
+	}
+	if len(ports) == 0 {
+		return protocol
+	}
+	return protocol + " " + strings.Join(ports, ", ")
+}
+
+// FormatAccessReport renders a self-contained HTML page with the groups and
+// their peers and the traffic the policies allow between the groups, for
+// security reviews of the generated configuration
+func FormatAccessReport(serverURL string, groups []Group, peers []Peer, policies []Policy) (string, error) {
+	peersByID := make(map[string]Peer, len(peers))
+	for _, peer := range peers {
+		peersByID[peer.ID] = peer
+	}
+
+	report := accessReport{
+		GeneratedAt: time.Now().UTC(),
+		ServerURL:   serverURL,
+		Groups:      make([]AccessGroup, 0, len(groups)),
+		Peers:       len(peers),
+		Policies:    len(policies),
+	}
+	for _, group := range groups {
+		members := make([]Peer, 0, len(group.Peers))
+		for _, member := range group.Peers {
+			id, _ := member.(string)
+			peerMap, _ := member.(map[string]any)
+			if peerMap != nil {
+				id, _ = peerMap["id"].(string)
+			}
+			peer, known := peersByID[id]
+			if !known {
+				peer.ID = id
+				peer.Name, _ = peerMap["name"].(string)
+			}
+			if peer.ID != "" {
+				members = append(members, peer)
+			}
+		}
+		sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+		report.Groups = append(report.Groups, AccessGroup{Name: group.Name, Issued: group.Issued, Peers: members})
+	}
+	sort.Slice(report.Groups, func(i, j int) bool { return report.Groups[i].Name < report.Groups[j].Name })
+
+	for _, flow := range AccessFlows(policies, groups) {
+		if flow.Enabled {
+			report.Flows = append(report.Flows, flow)
+		} else {
+			report.DisabledFlows = append(report.DisabledFlows, flow)
+		}
+	}
+
+	var builder strings.Builder
+	if err := accessReportTemplate.Execute(&builder, report); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+var accessReportTemplate = template.Must(template.New("access_report").Parse(`<!DOCTYPE html>
+<!-- Generated by NetBird terraformer Terraformer -->
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>NetBird access report</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem; color: #1f2933; }
+h1 { margin-bottom: 0; }
+.meta { color: #616e7c; margin-top: 0.25rem; }
+table { border-collapse: collapse; margin: 1rem 0 2rem; width: 100%; }
+th, td { border: 1px solid #d9e2ec; padding: 0.35rem 0.6rem; text-align: left; vertical-align: top; }
+th { background: #f0f4f8; }
+.accept { color: #0b6e4f; font-weight: 600; }
+.drop { color: #b42318; font-weight: 600; }
+.muted { color: #9aa5b1; }
+</style>
+</head>
+<body>
+<h1>NetBird access report</h1>
+<p class="meta">{{.ServerURL}} &middot; generated {{.GeneratedAt.Format "2006-01-02 15:04 MST"}} &middot; {{len .Groups}} groups, {{.Peers}} peers, {{.Policies}} policies</p>
+
+<h2>Allowed traffic</h2>
+{{if .Flows}}<table>
+<tr><th>Source</th><th>Destination</th><th>Action</th><th>Traffic</th><th>Policy</th><th>Rule</th></tr>
+{{range .Flows}}<tr><td>{{.Source}}</td><td>{{.Destination}}</td><td class="{{.Action}}">{{.Action}}</td><td>{{.Traffic}}</td><td>{{.Policy}}</td><td>{{.Rule}}</td></tr>
+{{end}}</table>
+{{else}}<p class="muted">No enabled policy rules.</p>
+{{end}}
+<h2>Groups</h2>
+<table>
+<tr><th>Group</th><th>Issued by</th><th>Peers</th></tr>
+{{range .Groups}}<tr><td>{{.Name}}</td><td>{{.Issued}}</td><td>{{range $i, $peer := .Peers}}{{if $i}}<br>{{end}}{{$peer.Name}}{{if $peer.IP}} <span class="muted">{{$peer.IP}}{{if $peer.OS}}, {{$peer.OS}}{{end}}</span>{{end}}{{else}}<span class="muted">none</span>{{end}}</td></tr>
+{{end}}</table>
+{{if .DisabledFlows}}
+<h2>Disabled rules</h2>
+<table>
+<tr><th>Source</th><th>Destination</th><th>Action</th><th>Traffic</th><th>Policy</th><th>Rule</th></tr>
+{{range .DisabledFlows}}<tr class="muted"><td>{{.Source}}</td><td>{{.Destination}}</td><td>{{.Action}}</td><td>{{.Traffic}}</td><td>{{.Policy}}</td><td>{{.Rule}}</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,45 @@
+package resources
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAccessFlows(t *testing.T) {
+	groups := []Group{{ID: "g1", Name: "Dev"}, {ID: "g2", Name: "Servers"}}
+	policies := []Policy{{
+		Name:    "Dev access",
+		Enabled: true,
+		Rules: []PolicyRule{{
+			Name: "ssh", Enabled: true, Action: "accept", Bidirectional: true, Protocol: "tcp", Ports: []string{"22"},
+			Sources: []GroupInfo{{ID: "g1"}}, Destinations: []GroupInfo{{ID: "g2"}},
+		}},
+	}}
+
+	flows := AccessFlows(policies, groups)
+	if len(flows) != 2 {
+		t.Fatalf("a bidirectional rule should yield a flow in each direction, got %+v", flows)
+	}
+	if flows[0].Source != "Dev" || flows[0].Destination != "Servers" || flows[1].Source != "Servers" || flows[1].Destination != "Dev" {
+		t.Errorf("unexpected flows %+v", flows)
+	}
+	if flows[0].Traffic != "tcp 22" {
+		t.Errorf("expected traffic tcp 22, got %q", flows[0].Traffic)
+	}
+}
+
+func TestFormatAccessReportEscapesNames(t *testing.T) {
+	groups := []Group{{ID: "g1", Name: "<script>", Peers: []any{"p1"}}}
+	peers := []Peer{{ID: "p1", Name: "laptop", IP: "100.64.0.1"}}
+
+	report, err := FormatAccessReport("https://api.netbird.io", groups, peers, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(report, "<script>") || !strings.Contains(report, "&lt;script&gt;") {
+		t.Errorf("group names should be escaped:\n%s", report)
+	}
+	if !strings.Contains(report, "laptop <span class=\"muted\">100.64.0.1</span>") {
+		t.Errorf("report should list the group's peers:\n%s", report)
+	}
+}
@@ -0,0 +1,93 @@
+package resources
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"netbird-terraformer/lib"
+)
+
+// AnsibleInventoryFile is the file the Ansible inventory is written to
+const AnsibleInventoryFile = "ansible_inventory.yaml"
+
+// FormatAnsibleInventory renders the peers as an Ansible YAML inventory with a
+// group per NetBird group. Hosts are named after their DNS label and reached
+// over their NetBird IP. Group names are sanitized like resource names; the
+// All group is Ansible's built-in all group and is left out.
+func FormatAnsibleInventory(peers []Peer) string {
+	sorted := make([]Peer, len(peers))
+	copy(sorted, peers)
+	sort.Slice(sorted, func(i, j int) bool { return ansibleHostName(sorted[i]) < ansibleHostName(sorted[j]) })
+
+	hostNames := make(map[string]string, len(sorted))
+	used := make(map[string]bool, len(sorted))
+	groups := make(map[string][]string)
+	for _, peer := range sorted {
+		name := ansibleHostName(peer)
+		if used[name] {
+			name = fmt.Sprintf("%s-%s", name, peer.ID)
+		}
+		used[name] = true
+		hostNames[peer.ID] = name
+
+		for _, group := range peer.Groups {
+			groupName := lib.SanitizeResourceName(group.Name)
+			if groupName == "all" || groupName == "ungrouped" {
+				continue
+			}
+			if members := groups[groupName]; len(members) == 0 || members[len(members)-1] != name {
+				groups[groupName] = append(members, name)
+			}
+		}
+	}
+
+	var builder strings.Builder
+	builder.WriteString("# NetBird peers as an Ansible inventory\n")
+	builder.WriteString("# Generated by NetBird terraformer Terraformer\n\n")
+	builder.WriteString("all:\n")
+	if len(sorted) == 0 {
+		builder.WriteString("  hosts: {}\n")
+		return builder.String()
+	}
+
+	builder.WriteString("  hosts:\n")
+	for _, peer := range sorted {
+		fmt.Fprintf(&builder, "    %s:\n", lib.QuoteYAML(hostNames[peer.ID]))
+		fmt.Fprintf(&builder, "      ansible_host: %s\n", lib.QuoteYAML(peer.IP))
+		fmt.Fprintf(&builder, "      netbird_ip: %s\n", lib.QuoteYAML(peer.IP))
+		fmt.Fprintf(&builder, "      netbird_os: %s\n", lib.QuoteYAML(peer.OS))
+		fmt.Fprintf(&builder, "      netbird_ssh_enabled: %t\n", peer.SSHEnabled)
+	}
+
+	if len(groups) > 0 {
+		groupNames := make([]string, 0, len(groups))
+		for name := range groups {
+			groupNames = append(groupNames, name)
+		}
+		sort.Strings(groupNames)
+
+		builder.WriteString("  children:\n")
+		for _, groupName := range groupNames {
+			fmt.Fprintf(&builder, "    %s:\n", groupName)
+			builder.WriteString("      hosts:\n")
+			for _, host := range groups[groupName] {
+				fmt.Fprintf(&builder, "        %s: {}\n", lib.QuoteYAML(host))
+			}
+		}
+	}
+
+	return builder.String()
+}
+
+// ansibleHostName returns the inventory name of a peer: its DNS label, or its
+// name for peers without one
+func ansibleHostName(peer Peer) string {
+	if peer.DNSLabel != "" {
+		return peer.DNSLabel
+	}
+	if peer.Name != "" {
+		return peer.Name
+	}
+	return peer.ID
+}
@@ -0,0 +1,27 @@
+package resources
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatAnsibleInventory(t *testing.T) {
+	peers := []Peer{
+		{ID: "p2", Name: "build", DNSLabel: "build-01", IP: "100.64.0.2", OS: "Linux", Groups: []GroupInfo{{Name: "All"}, {Name: "Dev Team"}}},
+		{ID: "p1", Name: "laptop", IP: "100.64.0.1", OS: "Darwin", SSHEnabled: true, Groups: []GroupInfo{{Name: "All"}}},
+	}
+
+	inventory := FormatAnsibleInventory(peers)
+	for _, want := range []string{
+		"    \"build-01\":\n      ansible_host: \"100.64.0.2\"\n",
+		"    \"laptop\":\n      ansible_host: \"100.64.0.1\"\n      netbird_ip: \"100.64.0.1\"\n      netbird_os: \"Darwin\"\n      netbird_ssh_enabled: true\n",
+		"  children:\n    dev_team:\n      hosts:\n        \"build-01\": {}\n",
+	} {
+		if !strings.Contains(inventory, want) {
+			t.Errorf("inventory should contain %q:\n%s", want, inventory)
+		}
+	}
+	if strings.Contains(inventory, "\n    all:") {
+		t.Errorf("the All group should not become a child group:\n%s", inventory)
+	}
+}
@@ -1,7 +1,9 @@
 package resources
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 
 	"netbird-terraformer/lib"
 )
@@ -10,6 +12,7 @@ import (
 type Group struct {
 	ID        string          `json:"id"`
 	Name      string          `json:"name"`
+	Issued    string          `json:"issued"`
 	Peers     []any           `json:"peers"`
 	Resources []GroupResource `json:"resources,omitempty"`
 }
@@ -25,6 +28,20 @@ type GroupsHandler struct {
 	service          lib.NetBirdAPI
 	terraformWriter  lib.TerraformWriter
 	idToResourceName map[string]string
+	groups           []Group
+	skipEmpty        bool
+}
+
+func init() {
+	Register(Handler{
+		Name:      "group",
+		Endpoints: []string{"/api/groups"},
+		New: func(deps Dependencies) lib.ResourceHandler {
+			handler := NewGroupsHandler(deps.Service, deps.Writer)
+			handler.SetSkipEmpty(deps.Options.SkipEmptyGroups)
+			return handler
+		},
+	})
 }
 
 // NewGroupsHandler creates a new groups handler
@@ -37,40 +54,67 @@ func NewGroupsHandler(service lib.NetBirdAPI, terraformWriter lib.TerraformWrite
 }
 
 // ImportAndGenerate imports groups from NetBird and generates Terraform resources
-func (h *GroupsHandler) ImportAndGenerate() error {
-	fmt.Printf("Importing groups...\n")
+func (h *GroupsHandler) ImportAndGenerate(ctx context.Context) error {
+	slog.Info("Importing groups")
 
 	var groups []Group
-	err := h.service.Get("/api/groups", &groups)
+	err := h.service.Get(ctx, "/api/groups", &groups)
 	if err != nil {
 		return fmt.Errorf("failed to fetch groups: %w", err)
 	}
+	h.terraformWriter.RecordDiscovered("group", len(groups))
 
+	h.groups = groups
+	progress := h.terraformWriter.StartProgress("Generating groups", len(groups))
 	for _, group := range groups {
+		progress.Increment()
+		if !h.terraformWriter.IncludeResource("group", group.ID, group.Name, group.Issued) {
+			continue
+		}
+
 		resourceName := h.generateGroupResource(group)
 		h.idToResourceName[group.ID] = resourceName
 	}
+	progress.Done()
 
-	fmt.Printf("Imported %d groups\n", len(groups))
+	slog.Info("Imported groups", "count", len(groups))
 	return nil
 }
 
+// SetSkipEmpty makes groups without peers and resources data sources that are
+// only generated if another resource references them
+func (h *GroupsHandler) SetSkipEmpty(skipEmpty bool) {
+	h.skipEmpty = skipEmpty
+}
+
 // GetResourceMapping returns the mapping from group IDs to resource names
 func (h *GroupsHandler) GetResourceMapping() map[string]string {
 	return h.idToResourceName
 }
 
+// GetGroups returns the groups fetched by the last import
+func (h *GroupsHandler) GetGroups() []Group {
+	return h.groups
+}
+
 // GetResourceType returns the resource type
 func (h *GroupsHandler) GetResourceType() string {
 	return "group"
 }
 
-// generateGroupResource generates a Terraform resource for a group
-func (h *GroupsHandler) generateGroupResource(group Group) string {
-	resourceName := lib.SanitizeResourceName(group.Name)
+// groupNameFor returns the resource name of a group, with a suffix if its name
+// collided with another's
+func groupNameFor(terraformWriter lib.TerraformWriter, id, name string) string {
+	resourceName := terraformWriter.ResourceName(lib.NameData{Type: "group", ID: id, Name: name})
 	if resourceName == "" {
-		resourceName = fmt.Sprintf("group_%s", group.ID)
+		resourceName = fmt.Sprintf("group_%s", id)
 	}
+	return terraformWriter.UniqueName("group", id, resourceName)
+}
+
+// generateGroupResource generates a Terraform resource for a group
+func (h *GroupsHandler) generateGroupResource(group Group) string {
+	resourceName := groupNameFor(h.terraformWriter, group.ID, group.Name)
 
 	// Extract peer IDs from the peers array
 	peerIDs := make([]string, 0)
@@ -91,6 +135,13 @@ func (h *GroupsHandler) generateGroupResource(group Group) string {
 		"name": group.Name,
 	}
 
+	// An empty group is left out unless something references it, in which case
+	// it is looked up by ID rather than managed
+	if h.skipEmpty && len(group.Peers) == 0 && len(group.Resources) == 0 {
+		h.terraformWriter.AddDataSourceIfReferenced("group", resourceName, group.Name, "empty group", map[string]any{"id": group.ID})
+		return resourceName
+	}
+
 	h.terraformWriter.AddResource("group", resourceName, attributes)
 	return resourceName
 }
@@ -0,0 +1,101 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"netbird-terraformer/lib"
+)
+
+// fakeAPI serves canned JSON responses per endpoint
+type fakeAPI map[string]string
+
+func (f fakeAPI) Get(ctx context.Context, endpoint string, result interface{}) error {
+	body, exists := f[endpoint]
+	if !exists {
+		return fmt.Errorf("unexpected request to %s", endpoint)
+	}
+	return json.Unmarshal([]byte(body), result)
+}
+
+var testAPI = fakeAPI{
+	"/api/groups": `[
+		{"id": "ch8i4ug6lnn4g9hqv7m0", "name": "All"},
+		{"id": "ch8i4ug6lnn4g9hqv7mg", "name": "Developers"}
+	]`,
+	"/api/users": `[
+		{"id": "google-oauth2|277474792786460067937", "email": "alice@example.com", "name": "Alice", "role": "admin", "auto_groups": ["ch8i4ug6lnn4g9hqv7mg"]},
+		{"id": "b6bdd3e1-e9d1-4c8c-a37c-3e3c4a1c7d34", "name": "ci", "role": "user", "is_service_user": true}
+	]`,
+	"/api/policies": `[
+		{"id": "cjcs2cs6lnnb2nqj9s3g", "name": "Developers to All", "enabled": true, "rules": [
+			{"name": "ssh", "enabled": true, "action": "accept", "protocol": "tcp", "ports": ["22"],
+			 "sources": [{"id": "ch8i4ug6lnn4g9hqv7mg", "name": "Developers"}],
+			 "destinations": [{"id": "ch8i4ug6lnn4g9hqv7m0", "name": "All"}]}
+		]}
+	]`,
+	"/api/routes": `[
+		{"id": "chacdk86lnnboviihd7g", "network_id": "office", "network": "10.0.0.0/24", "enabled": true,
+		 "peer_groups": ["ch8i4ug6lnn4g9hqv7m0"], "groups": ["ch8i4ug6lnn4g9hqv7mg"], "metric": 9999}
+	]`,
+	"/api/setup-keys": `[
+		{"id": "2531583362", "name": "default", "type": "reusable", "valid": true, "state": "valid", "used_times": 3,
+		 "auto_groups": ["ch8i4ug6lnn4g9hqv7m0"]}
+	]`,
+}
+
+// TestHandlersQueueProviderImportIDs checks that every handler queues the plain
+// object ID the provider expects, never an email or another attribute
+func TestHandlersQueueProviderImportIDs(t *testing.T) {
+	tests := []struct {
+		resourceType string
+		newHandler   func(api lib.NetBirdAPI, writer lib.TerraformWriter) lib.ResourceHandler
+		wantIDs      []string
+	}{
+		{"group", func(api lib.NetBirdAPI, writer lib.TerraformWriter) lib.ResourceHandler {
+			return NewGroupsHandler(api, writer)
+		}, []string{"ch8i4ug6lnn4g9hqv7m0", "ch8i4ug6lnn4g9hqv7mg"}},
+		{"user", func(api lib.NetBirdAPI, writer lib.TerraformWriter) lib.ResourceHandler {
+			return NewUsersHandler(api, writer)
+		}, []string{"google-oauth2|277474792786460067937", "b6bdd3e1-e9d1-4c8c-a37c-3e3c4a1c7d34"}},
+		{"policy", func(api lib.NetBirdAPI, writer lib.TerraformWriter) lib.ResourceHandler {
+			return NewPoliciesHandler(api, writer)
+		}, []string{"cjcs2cs6lnnb2nqj9s3g"}},
+		{"route", func(api lib.NetBirdAPI, writer lib.TerraformWriter) lib.ResourceHandler {
+			return NewRoutesHandler(api, writer)
+		}, []string{"chacdk86lnnboviihd7g"}},
+		{"setup_key", func(api lib.NetBirdAPI, writer lib.TerraformWriter) lib.ResourceHandler {
+			return NewSetupKeysHandler(api, writer)
+		}, []string{"2531583362"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.resourceType, func(t *testing.T) {
+			generator := lib.NewTerraformGenerator(t.TempDir(), &lib.Config{})
+			handler := test.newHandler(testAPI, generator)
+
+			err := handler.ImportAndGenerate(context.Background())
+			if err != nil {
+				t.Fatalf("ImportAndGenerate() = %v", err)
+			}
+
+			commands := generator.GetImportCommands()
+			if len(commands) != len(test.wantIDs) {
+				t.Fatalf("queued %d imports, want %d: %+v", len(commands), len(test.wantIDs), commands)
+			}
+			for i, cmd := range commands {
+				if cmd.ResourceType != test.resourceType {
+					t.Errorf("import %d has type %q, want %q", i, cmd.ResourceType, test.resourceType)
+				}
+				if cmd.ResourceID != test.wantIDs[i] {
+					t.Errorf("import %d has ID %q, want %q", i, cmd.ResourceID, test.wantIDs[i])
+				}
+				if err := lib.ValidateImportID(cmd.ResourceType, cmd.ResourceID); err != nil {
+					t.Errorf("import %d: %v", i, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"netbird-terraformer/lib"
+)
+
+// Peer represents a NetBird peer
+type Peer struct {
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	IP         string      `json:"ip"`
+	DNSLabel   string      `json:"dns_label"`
+	Hostname   string      `json:"hostname"`
+	OS         string      `json:"os"`
+	SSHEnabled bool        `json:"ssh_enabled"`
+	Connected  bool        `json:"connected"`
+	Groups     []GroupInfo `json:"groups"`
+}
+
+// PeersHandler implements ResourceHandler for peers. Peers are managed by the
+// NetBird client, so they are only generated as data sources.
+type PeersHandler struct {
+	service          lib.NetBirdAPI
+	terraformWriter  lib.TerraformWriter
+	idToResourceName map[string]string
+	peers            []Peer
+}
+
+func init() {
+	Register(Handler{
+		Name:      "peer",
+		Endpoints: []string{"/api/peers"},
+		New: func(deps Dependencies) lib.ResourceHandler {
+			return NewPeersHandler(deps.Service, deps.Writer)
+		},
+	})
+}
+
+// NewPeersHandler creates a new peers handler
+func NewPeersHandler(service lib.NetBirdAPI, terraformWriter lib.TerraformWriter) *PeersHandler {
+	return &PeersHandler{
+		service:          service,
+		terraformWriter:  terraformWriter,
+		idToResourceName: make(map[string]string),
+	}
+}
+
+// ImportAndGenerate imports peers from NetBird and generates Terraform data sources
+func (h *PeersHandler) ImportAndGenerate(ctx context.Context) error {
+	slog.Info("Importing peers")
+
+	var peers []Peer
+	err := h.service.Get(ctx, "/api/peers", &peers)
+	if err != nil {
+		return fmt.Errorf("failed to fetch peers: %w", err)
+	}
+	h.terraformWriter.RecordDiscovered("peer", len(peers))
+
+	h.peers = peers
+	for id, resourceName := range peerResourceNames(peers, h.terraformWriter) {
+		h.idToResourceName[id] = resourceName
+	}
+
+	progress := h.terraformWriter.StartProgress("Generating peers", len(peers))
+	for _, peer := range peers {
+		progress.Increment()
+		h.generatePeerDataSource(peer)
+	}
+	progress.Done()
+
+	slog.Info("Imported peers", "count", len(peers))
+	return nil
+}
+
+// GetResourceMapping returns the mapping from peer IDs to data source names
+func (h *PeersHandler) GetResourceMapping() map[string]string {
+	return h.idToResourceName
+}
+
+// GetPeers returns the peers fetched by the last import
+func (h *PeersHandler) GetPeers() []Peer {
+	return h.peers
+}
+
+// GetResourceType returns the resource type
+func (h *PeersHandler) GetResourceType() string {
+	return "peer"
+}
+
+// generatePeerDataSource generates a Terraform data source for a peer. The lookup
+// is always by ID, since several peers frequently share a hostname.
+func (h *PeersHandler) generatePeerDataSource(peer Peer) {
+	attributes := map[string]any{
+		"id": peer.ID,
+	}
+
+	h.terraformWriter.AddDataSource("peer", h.idToResourceName[peer.ID], attributes)
+}
+
+// peerResourceNames assigns a unique data source name to every peer. Peers sharing
+// a name are disambiguated by their NetBird IP, falling back to their ID.
+func peerResourceNames(peers []Peer, terraformWriter lib.TerraformWriter) map[string]string {
+	baseNames := make(map[string]string)
+	counts := make(map[string]int)
+	for _, peer := range peers {
+		baseName := terraformWriter.ResourceName(lib.NameData{Type: "peer", ID: peer.ID, Name: peer.Name})
+		if baseName == "" {
+			baseName = lib.SanitizeResourceName(fmt.Sprintf("peer_%s", peer.ID))
+		}
+		baseNames[peer.ID] = baseName
+		counts[baseName]++
+	}
+
+	names := make(map[string]string)
+	used := make(map[string]bool)
+	for _, peer := range peers {
+		name := baseNames[peer.ID]
+		if counts[name] > 1 && peer.IP != "" {
+			name = lib.SanitizeResourceName(fmt.Sprintf("%s_%s", name, peer.IP))
+		}
+		if used[name] {
+			name = lib.SanitizeResourceName(fmt.Sprintf("%s_%s", baseNames[peer.ID], peer.ID))
+		}
+
+		used[name] = true
+		names[peer.ID] = terraformWriter.UniqueName("peer", peer.ID, name)
+	}
+
+	return names
+}
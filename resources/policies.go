@@ -1,7 +1,9 @@
 package resources
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 
 	"netbird-terraformer/lib"
 )
@@ -58,7 +60,21 @@ type Resource struct {
 type PoliciesHandler struct {
 	service         lib.NetBirdAPI
 	terraformWriter lib.TerraformWriter
-	groupMapping    map[string]string
+	onlyEnabled     bool
+	policies        []Policy
+}
+
+func init() {
+	Register(Handler{
+		Name:      "policy",
+		DependsOn: []string{"group", "posture_check"},
+		Endpoints: []string{"/api/policies"},
+		New: func(deps Dependencies) lib.ResourceHandler {
+			handler := NewPoliciesHandler(deps.Service, deps.Writer)
+			handler.SetOnlyEnabled(deps.Options.OnlyEnabled)
+			return handler
+		},
+	})
 }
 
 // NewHandler creates a new policies handler
@@ -66,30 +82,42 @@ func NewPoliciesHandler(service lib.NetBirdAPI, terraformWriter lib.TerraformWri
 	return &PoliciesHandler{
 		service:         service,
 		terraformWriter: terraformWriter,
-		groupMapping:    make(map[string]string),
 	}
 }
 
-// SetGroupMapping sets the group ID to resource name mapping
-func (h *PoliciesHandler) SetGroupMapping(groupMapping map[string]string) {
-	h.groupMapping = groupMapping
+// SetOnlyEnabled makes the handler skip disabled policies
+func (h *PoliciesHandler) SetOnlyEnabled(onlyEnabled bool) {
+	h.onlyEnabled = onlyEnabled
 }
 
 // ImportAndGenerate imports policies from NetBird and generates Terraform resources
-func (h *PoliciesHandler) ImportAndGenerate() error {
-	fmt.Printf("Importing policies...\n")
+func (h *PoliciesHandler) ImportAndGenerate(ctx context.Context) error {
+	slog.Info("Importing policies")
 
 	var policies []Policy
-	err := h.service.Get("/api/policies", &policies)
+	err := h.service.Get(ctx, "/api/policies", &policies)
 	if err != nil {
 		return fmt.Errorf("failed to fetch policies: %w", err)
 	}
+	h.terraformWriter.RecordDiscovered("policy", len(policies))
+	h.policies = policies
 
+	progress := h.terraformWriter.StartProgress("Generating policies", len(policies))
 	for _, policy := range policies {
+		progress.Increment()
+		if h.onlyEnabled && !policy.Enabled {
+			h.terraformWriter.SkipResource("policy", policy.Name, "disabled")
+			continue
+		}
+		if !h.terraformWriter.IncludeResource("policy", policy.ID, policy.Name, "") {
+			continue
+		}
+
 		h.generatePolicyResource(policy)
 	}
+	progress.Done()
 
-	fmt.Printf("Imported %d policies\n", len(policies))
+	slog.Info("Imported policies", "count", len(policies))
 	return nil
 }
 
@@ -98,6 +126,11 @@ func (h *PoliciesHandler) GetResourceMapping() map[string]string {
 	return make(map[string]string)
 }
 
+// GetPolicies returns the policies fetched by the last import
+func (h *PoliciesHandler) GetPolicies() []Policy {
+	return h.policies
+}
+
 // GetResourceType returns the resource type
 func (h *PoliciesHandler) GetResourceType() string {
 	return "policy"
@@ -105,10 +138,11 @@ func (h *PoliciesHandler) GetResourceType() string {
 
 // generatePolicyResource generates a Terraform resource for a policy
 func (h *PoliciesHandler) generatePolicyResource(policy Policy) {
-	resourceName := lib.SanitizeResourceName(policy.Name)
+	resourceName := h.terraformWriter.ResourceName(lib.NameData{Type: "policy", ID: policy.ID, Name: policy.Name})
 	if resourceName == "" {
 		resourceName = fmt.Sprintf("policy_%s", policy.ID)
 	}
+	resourceName = h.terraformWriter.UniqueName("policy", policy.ID, resourceName)
 
 	attributes := map[string]any{
 		"id":          policy.ID,
@@ -118,7 +152,11 @@ func (h *PoliciesHandler) generatePolicyResource(policy Policy) {
 	}
 
 	if len(policy.SourcePostureChecks) > 0 {
-		attributes["source_posture_checks"] = policy.SourcePostureChecks
+		postureChecks := make([]string, 0, len(policy.SourcePostureChecks))
+		for _, id := range policy.SourcePostureChecks {
+			postureChecks = append(postureChecks, lib.Reference("posture_check", id, ""))
+		}
+		attributes["source_posture_checks"] = postureChecks
 	}
 
 	if len(policy.Rules) > 0 {
@@ -150,34 +188,19 @@ func (h *PoliciesHandler) generatePolicyResource(policy Policy) {
 				ruleMap["port_ranges"] = portRanges
 			}
 
-			// Handle sources - use proper group mapping
+			// Groups are referenced by ID, resolved once every handler ran
 			if len(rule.Sources) > 0 {
 				sources := make([]string, 0)
 				for _, source := range rule.Sources {
-					if groupResourceName, exists := h.groupMapping[source.ID]; exists {
-						terraformRef := lib.CreateTerraformReference("group", groupResourceName)
-						sources = append(sources, terraformRef)
-					} else {
-						groupResourceName := lib.SanitizeResourceName(source.Name)
-						terraformRef := lib.CreateTerraformReference("group", groupResourceName)
-						sources = append(sources, terraformRef)
-					}
+					sources = append(sources, lib.Reference("group", source.ID, source.Name))
 				}
 				ruleMap["sources"] = sources
 			}
 
-			// Handle destinations - use proper group mapping
 			if len(rule.Destinations) > 0 {
 				destinations := make([]string, 0)
 				for _, dest := range rule.Destinations {
-					if groupResourceName, exists := h.groupMapping[dest.ID]; exists {
-						terraformRef := lib.CreateTerraformReference("group", groupResourceName)
-						destinations = append(destinations, terraformRef)
-					} else {
-						groupResourceName := lib.SanitizeResourceName(dest.Name)
-						terraformRef := lib.CreateTerraformReference("group", groupResourceName)
-						destinations = append(destinations, terraformRef)
-					}
+					destinations = append(destinations, lib.Reference("group", dest.ID, dest.Name))
 				}
 				ruleMap["destinations"] = destinations
 			}
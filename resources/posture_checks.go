@@ -0,0 +1,197 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"netbird-terraformer/lib"
+)
+
+// PostureCheck represents a NetBird posture check
+type PostureCheck struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Checks      PostureTests `json:"checks"`
+}
+
+// PostureTests are the checks a posture check combines; each is optional
+type PostureTests struct {
+	NBVersionCheck *struct {
+		MinVersion string `json:"min_version"`
+	} `json:"nb_version_check,omitempty"`
+	OSVersionCheck *struct {
+		Android *OSVersion `json:"android,omitempty"`
+		Darwin  *OSVersion `json:"darwin,omitempty"`
+		IOS     *OSVersion `json:"ios,omitempty"`
+		Linux   *OSVersion `json:"linux,omitempty"`
+		Windows *OSVersion `json:"windows,omitempty"`
+	} `json:"os_version_check,omitempty"`
+	GeoLocationCheck *struct {
+		Locations []struct {
+			CountryCode string `json:"country_code"`
+			CityName    string `json:"city_name"`
+		} `json:"locations"`
+		Action string `json:"action"`
+	} `json:"geo_location_check,omitempty"`
+	PeerNetworkRangeCheck *struct {
+		Ranges []string `json:"ranges"`
+		Action string   `json:"action"`
+	} `json:"peer_network_range_check,omitempty"`
+	ProcessCheck *struct {
+		Processes []struct {
+			LinuxPath   string `json:"linux_path"`
+			MacPath     string `json:"mac_path"`
+			WindowsPath string `json:"windows_path"`
+		} `json:"processes"`
+	} `json:"process_check,omitempty"`
+}
+
+// OSVersion is the minimum version of one operating system
+type OSVersion struct {
+	MinVersion       string `json:"min_version,omitempty"`
+	MinKernelVersion string `json:"min_kernel_version,omitempty"`
+}
+
+// PostureChecksHandler implements ResourceHandler for posture checks
+type PostureChecksHandler struct {
+	service          lib.NetBirdAPI
+	terraformWriter  lib.TerraformWriter
+	idToResourceName map[string]string
+}
+
+func init() {
+	Register(Handler{
+		Name:             "posture_check",
+		Endpoints:        []string{"/api/posture-checks"},
+		MinServerVersion: "0.26.0",
+		New: func(deps Dependencies) lib.ResourceHandler {
+			return NewPostureChecksHandler(deps.Service, deps.Writer)
+		},
+	})
+}
+
+// NewPostureChecksHandler creates a new posture checks handler
+func NewPostureChecksHandler(service lib.NetBirdAPI, terraformWriter lib.TerraformWriter) *PostureChecksHandler {
+	return &PostureChecksHandler{
+		service:          service,
+		terraformWriter:  terraformWriter,
+		idToResourceName: make(map[string]string),
+	}
+}
+
+// ImportAndGenerate imports posture checks from NetBird and generates Terraform resources
+func (h *PostureChecksHandler) ImportAndGenerate(ctx context.Context) error {
+	slog.Info("Importing posture checks")
+
+	var postureChecks []PostureCheck
+	err := h.service.Get(ctx, "/api/posture-checks", &postureChecks)
+	if err != nil {
+		return fmt.Errorf("failed to fetch posture checks: %w", err)
+	}
+	h.terraformWriter.RecordDiscovered("posture_check", len(postureChecks))
+
+	progress := h.terraformWriter.StartProgress("Generating posture checks", len(postureChecks))
+	for _, postureCheck := range postureChecks {
+		progress.Increment()
+		if !h.terraformWriter.IncludeResource("posture_check", postureCheck.ID, postureCheck.Name, "") {
+			continue
+		}
+
+		h.idToResourceName[postureCheck.ID] = h.generatePostureCheckResource(postureCheck)
+	}
+	progress.Done()
+
+	slog.Info("Imported posture checks", "count", len(postureChecks))
+	return nil
+}
+
+// GetResourceMapping returns the mapping from posture check IDs to resource names
+func (h *PostureChecksHandler) GetResourceMapping() map[string]string {
+	return h.idToResourceName
+}
+
+// GetResourceType returns the resource type
+func (h *PostureChecksHandler) GetResourceType() string {
+	return "posture_check"
+}
+
+// generatePostureCheckResource generates a Terraform resource for a posture
+// check. The API nests the checks per operating system; the provider takes
+// them flattened into one block per check.
+func (h *PostureChecksHandler) generatePostureCheckResource(postureCheck PostureCheck) string {
+	resourceName := h.terraformWriter.ResourceName(lib.NameData{Type: "posture_check", ID: postureCheck.ID, Name: postureCheck.Name})
+	if resourceName == "" {
+		resourceName = fmt.Sprintf("posture_check_%s", postureCheck.ID)
+	}
+	resourceName = h.terraformWriter.UniqueName("posture_check", postureCheck.ID, resourceName)
+
+	attributes := map[string]any{
+		"id":          postureCheck.ID,
+		"name":        postureCheck.Name,
+		"description": postureCheck.Description,
+	}
+
+	checks := postureCheck.Checks
+	if checks.NBVersionCheck != nil {
+		attributes["netbird_version_check"] = map[string]any{"min_version": checks.NBVersionCheck.MinVersion}
+	}
+
+	if checks.OSVersionCheck != nil {
+		osVersions := make(map[string]any)
+		for key, version := range map[string]*OSVersion{
+			"android_min_version":        checks.OSVersionCheck.Android,
+			"darwin_min_version":         checks.OSVersionCheck.Darwin,
+			"ios_min_version":            checks.OSVersionCheck.IOS,
+			"linux_min_kernel_version":   checks.OSVersionCheck.Linux,
+			"windows_min_kernel_version": checks.OSVersionCheck.Windows,
+		} {
+			if version == nil {
+				continue
+			}
+			if version.MinKernelVersion != "" {
+				osVersions[key] = version.MinKernelVersion
+			} else {
+				osVersions[key] = version.MinVersion
+			}
+		}
+		attributes["os_version_check"] = osVersions
+	}
+
+	if checks.GeoLocationCheck != nil {
+		locations := make([]map[string]any, 0, len(checks.GeoLocationCheck.Locations))
+		for _, location := range checks.GeoLocationCheck.Locations {
+			locations = append(locations, map[string]any{
+				"country_code": location.CountryCode,
+				"city_name":    location.CityName,
+			})
+		}
+		attributes["geo_location_check"] = map[string]any{
+			"locations": locations,
+			"action":    checks.GeoLocationCheck.Action,
+		}
+	}
+
+	if checks.PeerNetworkRangeCheck != nil {
+		attributes["peer_network_range_check"] = map[string]any{
+			"ranges": checks.PeerNetworkRangeCheck.Ranges,
+			"action": checks.PeerNetworkRangeCheck.Action,
+		}
+	}
+
+	if checks.ProcessCheck != nil {
+		processes := make([]map[string]any, 0, len(checks.ProcessCheck.Processes))
+		for _, process := range checks.ProcessCheck.Processes {
+			processes = append(processes, map[string]any{
+				"linux_path":   process.LinuxPath,
+				"mac_path":     process.MacPath,
+				"windows_path": process.WindowsPath,
+			})
+		}
+		attributes["process_check"] = map[string]any{"processes": processes}
+	}
+
+	h.terraformWriter.AddResource("posture_check", resourceName, attributes)
+	return resourceName
+}
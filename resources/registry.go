@@ -0,0 +1,179 @@
+package resources
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"netbird-terraformer/lib"
+)
+
+// Handler registers a resource handler. Every handler registers from an init
+// function of its file; handlers for NetBird endpoints the importer does not
+// support yet do the same in their own package, which is linked in with a
+// build-tagged blank import, so no change to this package is needed.
+type Handler struct {
+	// Name is the resource type, e.g. "network" for netbird_network. It names
+	// the generated file and is accepted by exclude_resources and import_order.
+	Name string
+
+	// DependsOn lists the resource types the handler references, e.g. group,
+	// network or posture_check. They run first, whatever order the handlers
+	// registered in. References are written with lib.Reference, which resolves
+	// them once every handler ran.
+	DependsOn []string
+
+	// Endpoints lists the API endpoints the handler fetches, so bundles,
+	// fixtures and doctor cover them
+	Endpoints []string
+
+	// MinServerVersion is the first management server version serving the
+	// endpoints. On older servers the type is skipped instead of failing; on
+	// servers of unknown version it is fetched. Empty if every version does.
+	MinServerVersion string
+
+	// New creates the handler for a run. Its ImportAndGenerate fetches the
+	// objects and generates their resources through deps.Writer.
+	New func(deps Dependencies) lib.ResourceHandler
+}
+
+// Dependencies are passed to a registered handler when it is created
+type Dependencies struct {
+	Service lib.NetBirdAPI
+	Writer  lib.TerraformWriter
+	Options Options
+}
+
+// Options are the settings of a run that change what handlers generate
+type Options struct {
+	SkipEmptyGroups bool // leave out groups without peers or resources unless referenced
+	OnlyEnabled     bool // leave out disabled policies and routes
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []Handler
+)
+
+// Register adds a resource handler. It panics if the registration is invalid,
+// since it runs from init where a broken build should fail loudly. Dependencies
+// are checked by Waves, once every package registered its handlers.
+func Register(handler Handler) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if handler.Name == "" || handler.New == nil {
+		panic("resources: Register requires a Name and New")
+	}
+	if isRegisteredType(handler.Name) {
+		panic(fmt.Sprintf("resources: resource type %q is already registered", handler.Name))
+	}
+	registry = append(registry, handler)
+}
+
+// Registered returns the registered handlers in registration order
+func Registered() []Handler {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	return append([]Handler(nil), registry...)
+}
+
+// Lookup returns the registered handler of a resource type
+func Lookup(resourceType string) (Handler, bool) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, handler := range registry {
+		if handler.Name == resourceType {
+			return handler, true
+		}
+	}
+	return Handler{}, false
+}
+
+// Types returns the registered resource types in the order they run. If the
+// dependencies are invalid, which Waves reports, they are sorted by name.
+func Types() []string {
+	types := make([]string, 0)
+	waves, err := Waves()
+	if err != nil {
+		for _, handler := range Registered() {
+			types = append(types, handler.Name)
+		}
+		sort.Strings(types)
+		return types
+	}
+	for _, wave := range waves {
+		for _, handler := range wave {
+			types = append(types, handler.Name)
+		}
+	}
+	return types
+}
+
+// Waves orders the registered handlers for a run. The handlers of a wave only
+// depend on handlers of earlier waves, so they can run concurrently once those
+// finished; within a wave they are sorted by name. It fails if a handler
+// depends on an unregistered type or the dependencies form a cycle.
+func Waves() ([][]Handler, error) {
+	return orderHandlers(Registered())
+}
+
+// orderHandlers sorts handlers topologically into waves
+func orderHandlers(handlers []Handler) ([][]Handler, error) {
+	pending := make(map[string]Handler, len(handlers))
+	for _, handler := range handlers {
+		pending[handler.Name] = handler
+	}
+	for _, handler := range handlers {
+		for _, dependency := range handler.DependsOn {
+			if _, registered := pending[dependency]; !registered {
+				return nil, fmt.Errorf("handler %q depends on unknown resource type %q", handler.Name, dependency)
+			}
+		}
+	}
+
+	waves := make([][]Handler, 0)
+	for len(pending) > 0 {
+		wave := make([]Handler, 0)
+		for _, handler := range pending {
+			ready := true
+			for _, dependency := range handler.DependsOn {
+				if _, waiting := pending[dependency]; waiting {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				wave = append(wave, handler)
+			}
+		}
+
+		if len(wave) == 0 {
+			cycle := make([]string, 0, len(pending))
+			for name := range pending {
+				cycle = append(cycle, name)
+			}
+			sort.Strings(cycle)
+			return nil, fmt.Errorf("dependency cycle among handlers %s", strings.Join(cycle, ", "))
+		}
+
+		sort.Slice(wave, func(i, j int) bool { return wave[i].Name < wave[j].Name })
+		for _, handler := range wave {
+			delete(pending, handler.Name)
+		}
+		waves = append(waves, wave)
+	}
+	return waves, nil
+}
+
+// isRegisteredType reports whether a type is registered; the caller holds
+// registryMu
+func isRegisteredType(resourceType string) bool {
+	for _, handler := range registry {
+		if handler.Name == resourceType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"netbird-terraformer/lib"
+)
+
+// stubHandler is a registered handler that generates nothing
+type stubHandler struct{ resourceType string }
+
+func (h stubHandler) ImportAndGenerate(ctx context.Context) error { return nil }
+func (h stubHandler) GetResourceMapping() map[string]string       { return map[string]string{} }
+func (h stubHandler) GetResourceType() string                     { return h.resourceType }
+
+func TestRegister(t *testing.T) {
+	newStub := func(deps Dependencies) lib.ResourceHandler { return stubHandler{"test_widget"} }
+	Register(Handler{Name: "test_widget", DependsOn: []string{"group"}, Endpoints: []string{"/api/widgets"}, New: newStub})
+
+	if handler, registered := Lookup("test_widget"); !registered || handler.Endpoints[0] != "/api/widgets" {
+		t.Errorf("Lookup(test_widget) = %+v, %v", handler, registered)
+	}
+	types := Types()
+	position := make(map[string]int, len(types))
+	for i, resourceType := range types {
+		position[resourceType] = i
+	}
+	if len(types) != len(Registered()) || position["test_widget"] < position["group"] || position["policy"] < position["posture_check"] {
+		t.Errorf("Types() = %v, want every type after its dependencies", types)
+	}
+
+	invalid := map[string]Handler{
+		"duplicate built-in type": {Name: "group", New: newStub},
+		"duplicate registration":  {Name: "test_widget", New: newStub},
+		"missing constructor":     {Name: "test_gadget"},
+	}
+	for name, handler := range invalid {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%+v) did not panic", handler)
+				}
+			}()
+			Register(handler)
+		})
+	}
+}
+
+func TestOrderHandlers(t *testing.T) {
+	newStub := func(deps Dependencies) lib.ResourceHandler { return stubHandler{} }
+	handler := func(name string, dependsOn ...string) Handler {
+		return Handler{Name: name, DependsOn: dependsOn, New: newStub}
+	}
+
+	waves, err := orderHandlers([]Handler{handler("route", "group", "peer"), handler("peer"), handler("network", "group"), handler("group")})
+	if err != nil {
+		t.Fatalf("orderHandlers() error = %v", err)
+	}
+	names := make([][]string, 0, len(waves))
+	for _, wave := range waves {
+		waveNames := make([]string, 0, len(wave))
+		for _, handler := range wave {
+			waveNames = append(waveNames, handler.Name)
+		}
+		names = append(names, waveNames)
+	}
+	if fmt.Sprint(names) != "[[group peer] [network route]]" {
+		t.Errorf("orderHandlers() = %v, want [[group peer] [network route]]", names)
+	}
+
+	if _, err := orderHandlers([]Handler{handler("route", "gizmo")}); err == nil {
+		t.Error("orderHandlers() accepted a dependency on an unknown type")
+	}
+	if _, err := orderHandlers([]Handler{handler("group"), handler("a", "b"), handler("b", "a")}); err == nil || !strings.Contains(err.Error(), "a, b") {
+		t.Errorf("orderHandlers() error = %v, want a cycle between a and b", err)
+	}
+}
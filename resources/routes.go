@@ -1,7 +1,9 @@
 package resources
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 
 	"netbird-terraformer/lib"
 )
@@ -22,16 +24,24 @@ type Route struct {
 	KeepRoute   bool     `json:"keep_route"`
 }
 
-// RouteGroup represents a NetBird group (minimal struct for group fetching)
-type RouteGroup struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-}
-
 // Handler implements ResourceHandler for routes
 type RoutesHandler struct {
 	service         lib.NetBirdAPI
 	terraformWriter lib.TerraformWriter
+	onlyEnabled     bool
+}
+
+func init() {
+	Register(Handler{
+		Name:      "route",
+		DependsOn: []string{"group", "peer"},
+		Endpoints: []string{"/api/routes"},
+		New: func(deps Dependencies) lib.ResourceHandler {
+			handler := NewRoutesHandler(deps.Service, deps.Writer)
+			handler.SetOnlyEnabled(deps.Options.OnlyEnabled)
+			return handler
+		},
+	})
 }
 
 // NewHandler creates a new routes handler
@@ -42,35 +52,41 @@ func NewRoutesHandler(service lib.NetBirdAPI, terraformWriter lib.TerraformWrite
 	}
 }
 
-// ImportAndGenerate imports routes from NetBird and generates Terraform resources
-func (h *RoutesHandler) ImportAndGenerate() error {
-	fmt.Printf("Importing routes...\n")
-
-	// Fetch groups for group mapping
-	var groups []RouteGroup
-	err := h.service.Get("/api/groups", &groups)
-	if err != nil {
-		return fmt.Errorf("failed to fetch groups for route mapping: %w", err)
-	}
+// SetOnlyEnabled makes the handler skip disabled routes
+func (h *RoutesHandler) SetOnlyEnabled(onlyEnabled bool) {
+	h.onlyEnabled = onlyEnabled
+}
 
-	groupIDToResourceName := make(map[string]string)
-	for _, group := range groups {
-		resourceName := lib.SanitizeResourceName(group.Name)
-		groupIDToResourceName[group.ID] = resourceName
-	}
+// ImportAndGenerate imports routes from NetBird and generates Terraform resources
+func (h *RoutesHandler) ImportAndGenerate(ctx context.Context) error {
+	slog.Info("Importing routes")
 
-	// Fetch routes
 	var routes []Route
-	err = h.service.Get("/api/routes", &routes)
+	err := h.service.Get(ctx, "/api/routes", &routes)
 	if err != nil {
 		return fmt.Errorf("failed to fetch routes: %w", err)
 	}
+	h.terraformWriter.RecordDiscovered("route", len(routes))
 
+	progress := h.terraformWriter.StartProgress("Generating routes", len(routes))
 	for _, route := range routes {
-		h.generateRouteResource(route, groupIDToResourceName)
+		progress.Increment()
+		if h.onlyEnabled && !route.Enabled {
+			h.terraformWriter.SkipResource("route", route.NetworkID, "disabled")
+			continue
+		}
+		if !h.terraformWriter.IncludeResource("route", route.ID, route.NetworkID, "") {
+			continue
+		}
+
+		if err := h.generateRouteResource(route); err != nil {
+			slog.Warn("Skipping route", "id", route.ID, "network_id", route.NetworkID, "error", err)
+			h.terraformWriter.SkipResource("route", route.NetworkID, err.Error())
+		}
 	}
+	progress.Done()
 
-	fmt.Printf("Imported %d routes\n", len(routes))
+	slog.Info("Imported routes", "count", len(routes))
 	return nil
 }
 
@@ -85,37 +101,52 @@ func (h *RoutesHandler) GetResourceType() string {
 }
 
 // generateRouteResource generates a Terraform resource for a route
-func (h *RoutesHandler) generateRouteResource(route Route, groupIDToResourceName map[string]string) {
-	resourceName := lib.SanitizeResourceName(route.NetworkID)
-	if resourceName == "" {
+func (h *RoutesHandler) generateRouteResource(route Route) error {
+	// Domain routes have no network; everything else must be a valid prefix
+	network := route.Network
+	if network != "" {
+		normalized, err := lib.NormalizeNetwork(network)
+		if err != nil {
+			return err
+		}
+		if normalized != network {
+			slog.Debug("Normalized route network", "id", route.ID, "network", network, "normalized", normalized)
+		}
+		network = normalized
+	}
+
+	resourceName := h.terraformWriter.ResourceName(lib.NameData{Type: "route", ID: route.ID, Name: route.NetworkID})
+	if resourceName == "" && route.Network != "" {
 		resourceName = lib.SanitizeResourceName(route.Network)
 	}
 	if resourceName == "" {
 		resourceName = fmt.Sprintf("route_%s", route.ID)
 	}
+	resourceName = h.terraformWriter.UniqueName("route", route.ID, resourceName)
 
+	// A group or peer without a resource keeps its ID, since leaving it out
+	// would remove it from the route on the next apply
 	groupRefs := make([]string, 0)
 	for _, groupID := range route.Groups {
-		if resourceName, exists := groupIDToResourceName[groupID]; exists {
-			terraformRef := lib.CreateTerraformReference("group", resourceName)
-			groupRefs = append(groupRefs, terraformRef)
-		}
+		groupRefs = append(groupRefs, lib.Reference("group", groupID, ""))
 	}
 
 	peerGroupRefs := make([]string, 0)
 	for _, groupID := range route.PeerGroups {
-		if resourceName, exists := groupIDToResourceName[groupID]; exists {
-			terraformRef := lib.CreateTerraformReference("group", resourceName)
-			peerGroupRefs = append(peerGroupRefs, terraformRef)
-		}
+		peerGroupRefs = append(peerGroupRefs, lib.Reference("group", groupID, ""))
+	}
+
+	peer := ""
+	if route.Peer != "" {
+		peer = lib.Reference("peer", route.Peer, "")
 	}
 
 	attributes := map[string]any{
 		"id":          route.ID,
 		"description": route.Description,
 		"network_id":  route.NetworkID,
-		"network":     route.Network,
-		"peer":        route.Peer,
+		"network":     network,
+		"peer":        peer,
 		"peer_groups": peerGroupRefs,
 		"metric":      route.Metric,
 		"masquerade":  route.Masquerade,
@@ -125,4 +156,5 @@ func (h *RoutesHandler) generateRouteResource(route Route, groupIDToResourceName
 	}
 
 	h.terraformWriter.AddResource("route", resourceName, attributes)
+	return nil
 }
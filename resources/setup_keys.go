@@ -0,0 +1,172 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"netbird-terraformer/lib"
+)
+
+// SetupKey represents a NetBird setup key. The key itself is never returned in
+// full by the API and is not written anywhere.
+type SetupKey struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Type       string    `json:"type"`
+	Valid      bool      `json:"valid"`
+	Revoked    bool      `json:"revoked"`
+	State      string    `json:"state"`
+	Expires    time.Time `json:"expires"`
+	UsedTimes  int       `json:"used_times"`
+	LastUsed   time.Time `json:"last_used"`
+	UsageLimit int       `json:"usage_limit"`
+	Ephemeral  bool      `json:"ephemeral"`
+	AutoGroups []string  `json:"auto_groups"`
+}
+
+// SetupKeyUsage summarizes how a setup key has been used, for the run report
+type SetupKeyUsage struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	State     string `json:"state"`
+	Expires   string `json:"expires,omitempty"`
+	UsedTimes int    `json:"used_times"`
+	LastUsed  string `json:"last_used,omitempty"`
+	Ephemeral bool   `json:"ephemeral"`
+
+	// Unused is set for valid keys that never enrolled a peer; codifying them
+	// usually keeps a stale credential alive
+	Unused bool `json:"unused"`
+}
+
+// SetupKeysHandler implements ResourceHandler for setup keys
+type SetupKeysHandler struct {
+	service          lib.NetBirdAPI
+	terraformWriter  lib.TerraformWriter
+	idToResourceName map[string]string
+	setupKeys        []SetupKey
+}
+
+func init() {
+	Register(Handler{
+		Name:      "setup_key",
+		DependsOn: []string{"group"},
+		Endpoints: []string{"/api/setup-keys"},
+		New: func(deps Dependencies) lib.ResourceHandler {
+			return NewSetupKeysHandler(deps.Service, deps.Writer)
+		},
+	})
+}
+
+// NewSetupKeysHandler creates a new setup keys handler
+func NewSetupKeysHandler(service lib.NetBirdAPI, terraformWriter lib.TerraformWriter) *SetupKeysHandler {
+	return &SetupKeysHandler{
+		service:          service,
+		terraformWriter:  terraformWriter,
+		idToResourceName: make(map[string]string),
+	}
+}
+
+// ImportAndGenerate imports setup keys from NetBird and generates Terraform resources.
+// Revoked and expired keys are skipped.
+func (h *SetupKeysHandler) ImportAndGenerate(ctx context.Context) error {
+	slog.Info("Importing setup keys")
+
+	var setupKeys []SetupKey
+	err := h.service.Get(ctx, "/api/setup-keys", &setupKeys)
+	if err != nil {
+		return fmt.Errorf("failed to fetch setup keys: %w", err)
+	}
+	h.terraformWriter.RecordDiscovered("setup_key", len(setupKeys))
+
+	h.setupKeys = setupKeys
+
+	progress := h.terraformWriter.StartProgress("Generating setup keys", len(setupKeys))
+	for _, setupKey := range setupKeys {
+		progress.Increment()
+
+		if !setupKey.Valid {
+			slog.Info("Skipping invalid setup key", "name", setupKey.Name, "state", setupKey.State)
+			h.terraformWriter.SkipResource("setup_key", setupKey.Name, fmt.Sprintf("setup key is %s", setupKey.State))
+			continue
+		}
+
+		if !h.terraformWriter.IncludeResource("setup_key", setupKey.ID, setupKey.Name, "") {
+			continue
+		}
+
+		if setupKey.UsedTimes == 0 {
+			slog.Warn("Setup key is valid but was never used, consider revoking it instead of importing it", "name", setupKey.Name)
+		}
+
+		h.idToResourceName[setupKey.ID] = h.generateSetupKeyResource(setupKey)
+	}
+	progress.Done()
+
+	slog.Info("Imported setup keys", "count", len(setupKeys))
+	return nil
+}
+
+// GetResourceMapping returns the mapping from setup key IDs to resource names
+func (h *SetupKeysHandler) GetResourceMapping() map[string]string {
+	return h.idToResourceName
+}
+
+// GetResourceType returns the resource type
+func (h *SetupKeysHandler) GetResourceType() string {
+	return "setup_key"
+}
+
+// GetUsage returns usage analytics for the setup keys fetched by the last import
+func (h *SetupKeysHandler) GetUsage() []SetupKeyUsage {
+	usage := make([]SetupKeyUsage, 0, len(h.setupKeys))
+	for _, setupKey := range h.setupKeys {
+		entry := SetupKeyUsage{
+			ID:        setupKey.ID,
+			Name:      setupKey.Name,
+			Type:      setupKey.Type,
+			State:     setupKey.State,
+			UsedTimes: setupKey.UsedTimes,
+			Ephemeral: setupKey.Ephemeral,
+			Unused:    setupKey.Valid && setupKey.UsedTimes == 0,
+		}
+		if !setupKey.Expires.IsZero() {
+			entry.Expires = setupKey.Expires.UTC().Format(time.RFC3339)
+		}
+		if !setupKey.LastUsed.IsZero() {
+			entry.LastUsed = setupKey.LastUsed.UTC().Format(time.RFC3339)
+		}
+		usage = append(usage, entry)
+	}
+	return usage
+}
+
+// generateSetupKeyResource generates a Terraform resource for a setup key
+func (h *SetupKeysHandler) generateSetupKeyResource(setupKey SetupKey) string {
+	resourceName := h.terraformWriter.ResourceName(lib.NameData{Type: "setup_key", ID: setupKey.ID, Name: setupKey.Name})
+	if resourceName == "" {
+		resourceName = lib.SanitizeResourceName(fmt.Sprintf("setup_key_%s", setupKey.ID))
+	}
+	resourceName = h.terraformWriter.UniqueName("setup_key", setupKey.ID, resourceName)
+
+	autoGroupRefs := make([]string, 0)
+	for _, groupID := range setupKey.AutoGroups {
+		autoGroupRefs = append(autoGroupRefs, lib.Reference("group", groupID, ""))
+	}
+
+	attributes := map[string]any{
+		"id":          setupKey.ID,
+		"name":        setupKey.Name,
+		"type":        setupKey.Type,
+		"auto_groups": autoGroupRefs,
+		"usage_limit": setupKey.UsageLimit,
+		"ephemeral":   setupKey.Ephemeral,
+		"revoked":     setupKey.Revoked,
+	}
+
+	h.terraformWriter.AddResource("setup_key", resourceName, attributes)
+	return resourceName
+}
@@ -0,0 +1,131 @@
+package resources
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"netbird-terraformer/lib"
+)
+
+// GroupSuggestion represents a suggested group for all users sharing a role
+type GroupSuggestion struct {
+	GroupName    string
+	ResourceName string
+	Role         string
+	Exists       bool     // true when a group with this name already exists
+	Users        []string // user resource names missing the group in auto_groups
+}
+
+// SuggestRoleGroups derives one group per user role (e.g. all admins -> "admins")
+// and lists the users whose auto_groups don't include it yet. Regular users are
+// already covered by the built-in "All" group and get no suggestion.
+func SuggestRoleGroups(users []User, groups []Group, userNames, groupNames map[string]string) []GroupSuggestion {
+	// Several groups may share a name; membership in any of them counts
+	groupsByName := make(map[string][]Group)
+	for _, group := range groups {
+		name := strings.ToLower(group.Name)
+		groupsByName[name] = append(groupsByName[name], group)
+	}
+
+	suggestionsByRole := make(map[string]*GroupSuggestion)
+	for _, user := range users {
+		resourceName, exists := userNames[user.ID]
+		if !exists {
+			continue
+		}
+
+		role := user.Role
+		if user.IsServiceUser {
+			role = "service_user"
+		}
+		if role == "" || role == "user" {
+			continue
+		}
+
+		suggestion, exists := suggestionsByRole[role]
+		if !exists {
+			groupName := role + "s"
+			suggestion = &GroupSuggestion{
+				GroupName:    groupName,
+				ResourceName: lib.SanitizeResourceName(groupName),
+				Role:         role,
+			}
+			for _, group := range groupsByName[groupName] {
+				suggestion.Exists = true
+				if name, mapped := groupNames[group.ID]; mapped {
+					suggestion.ResourceName = name
+					break
+				}
+			}
+			suggestionsByRole[role] = suggestion
+		}
+
+		if suggestion.Exists && hasAnyAutoGroup(user, groupsByName[suggestion.GroupName]) {
+			continue
+		}
+		suggestion.Users = append(suggestion.Users, resourceName)
+	}
+
+	suggestions := make([]GroupSuggestion, 0, len(suggestionsByRole))
+	for _, suggestion := range suggestionsByRole {
+		if len(suggestion.Users) == 0 {
+			continue
+		}
+		sort.Strings(suggestion.Users)
+		suggestions = append(suggestions, *suggestion)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		return suggestions[i].Role < suggestions[j].Role
+	})
+
+	return suggestions
+}
+
+// FormatGroupSuggestions renders suggestions as commented HCL, so the file can sit
+// next to the generated configuration without changing what Terraform manages
+func FormatGroupSuggestions(suggestions []GroupSuggestion) string {
+	var builder strings.Builder
+
+	builder.WriteString("# NetBird group membership suggestions\n")
+	builder.WriteString("# Generated by NetBird terraformer Terraformer\n")
+	builder.WriteString("#\n")
+	builder.WriteString("# Everything in this file is commented out. Copy the parts you want into\n")
+	builder.WriteString("# group.tf and user.tf; nothing here is imported or applied.\n\n")
+
+	if len(suggestions) == 0 {
+		builder.WriteString("# No suggestions: every role is already covered by a matching group.\n")
+		return builder.String()
+	}
+
+	for _, suggestion := range suggestions {
+		reference := lib.CreateTerraformReference("group", suggestion.ResourceName)
+
+		fmt.Fprintf(&builder, "# Role %q: %d user(s) without a %q group\n", suggestion.Role, len(suggestion.Users), suggestion.GroupName)
+		if !suggestion.Exists {
+			fmt.Fprintf(&builder, "# resource \"netbird_group\" \"%s\" {\n", suggestion.ResourceName)
+			fmt.Fprintf(&builder, "#   name = \"%s\"\n", lib.EscapeString(suggestion.GroupName))
+			builder.WriteString("# }\n")
+		}
+		builder.WriteString("#\n")
+		for _, user := range suggestion.Users {
+			fmt.Fprintf(&builder, "# netbird_user.%s: add %s to auto_groups\n", user, reference)
+		}
+		builder.WriteString("\n")
+	}
+
+	return builder.String()
+}
+
+// hasAnyAutoGroup reports whether a user is already auto-assigned to one of
+// the groups
+func hasAnyAutoGroup(user User, groups []Group) bool {
+	for _, autoGroup := range user.AutoGroups {
+		for _, group := range groups {
+			if autoGroup == group.ID {
+				return true
+			}
+		}
+	}
+	return false
+}
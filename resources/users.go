@@ -1,7 +1,9 @@
 package resources
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 
 	"netbird-terraformer/lib"
 )
@@ -23,58 +25,88 @@ type User struct {
 
 // Handler implements ResourceHandler for users
 type UsersHandler struct {
-	service         lib.NetBirdAPI
-	terraformWriter lib.TerraformWriter
-	groupMapping    map[string]string
+	service          lib.NetBirdAPI
+	terraformWriter  lib.TerraformWriter
+	idToResourceName map[string]string
+	users            []User
+}
+
+func init() {
+	Register(Handler{
+		Name:      "user",
+		DependsOn: []string{"group"},
+		Endpoints: []string{"/api/users"},
+		New: func(deps Dependencies) lib.ResourceHandler {
+			return NewUsersHandler(deps.Service, deps.Writer)
+		},
+	})
 }
 
 // NewHandler creates a new users handler
 func NewUsersHandler(service lib.NetBirdAPI, terraformWriter lib.TerraformWriter) *UsersHandler {
 	return &UsersHandler{
-		service:         service,
-		terraformWriter: terraformWriter,
-		groupMapping:    make(map[string]string),
+		service:          service,
+		terraformWriter:  terraformWriter,
+		idToResourceName: make(map[string]string),
 	}
 }
 
-// SetGroupMapping sets the group ID to resource name mapping
-func (h *UsersHandler) SetGroupMapping(groupMapping map[string]string) {
-	h.groupMapping = groupMapping
-}
-
 // ImportAndGenerate imports users from NetBird and generates Terraform resources
-func (h *UsersHandler) ImportAndGenerate() error {
-	fmt.Printf("Importing users...\n")
+func (h *UsersHandler) ImportAndGenerate(ctx context.Context) error {
+	slog.Info("Importing users")
 
 	var users []User
-	err := h.service.Get("/api/users", &users)
+	err := h.service.Get(ctx, "/api/users", &users)
 	if err != nil {
 		return fmt.Errorf("failed to fetch users: %w", err)
 	}
+	h.terraformWriter.RecordDiscovered("user", len(users))
 
+	h.users = users
+
+	progress := h.terraformWriter.StartProgress("Generating users", len(users))
 	for _, user := range users {
+		progress.Increment()
+
 		// Skip users without email addresses, unless they are service users
 		if user.Email == "" && !user.IsServiceUser {
-			fmt.Printf("  Skipping user without email: ID=%s, Name=%s\n", user.ID, user.Name)
+			slog.Info("Skipping user without email", "id", user.ID, "name", user.Name)
+			h.terraformWriter.SkipResource("user", user.ID, "no email address")
 			continue
 		}
 
 		// Skip inactive service users without names
 		if user.IsServiceUser && user.Email == "" && user.Name == "" {
-			fmt.Printf("  Skipping unnamed service user: ID=%s\n", user.ID)
+			slog.Info("Skipping unnamed service user", "id", user.ID)
+			h.terraformWriter.SkipResource("user", user.ID, "unnamed service user")
+			continue
+		}
+
+		// Users are filtered by email, service users by name
+		displayName := user.Email
+		if displayName == "" {
+			displayName = user.Name
+		}
+		if !h.terraformWriter.IncludeResource("user", user.ID, displayName, user.Issued) {
 			continue
 		}
 
-		h.generateUserResource(user)
+		h.idToResourceName[user.ID] = h.generateUserResource(user)
 	}
+	progress.Done()
 
-	fmt.Printf("Imported %d users\n", len(users))
+	slog.Info("Imported users", "count", len(users))
 	return nil
 }
 
-// GetResourceMapping returns an empty mapping since users don't need to be referenced
+// GetResourceMapping returns the mapping from user IDs to resource names
 func (h *UsersHandler) GetResourceMapping() map[string]string {
-	return make(map[string]string)
+	return h.idToResourceName
+}
+
+// GetUsers returns the users fetched by the last import
+func (h *UsersHandler) GetUsers() []User {
+	return h.users
 }
 
 // GetResourceType returns the resource type
@@ -83,13 +115,8 @@ func (h *UsersHandler) GetResourceType() string {
 }
 
 // generateUserResource generates a Terraform resource for a user
-func (h *UsersHandler) generateUserResource(user User) {
-	// Generate a unique resource name
-	var resourceName string
-
-	if resourceName == "" && user.Name != "" {
-		resourceName = lib.SanitizeResourceName(user.Name)
-	}
+func (h *UsersHandler) generateUserResource(user User) string {
+	resourceName := h.terraformWriter.ResourceName(lib.NameData{Type: "user", ID: user.ID, Name: user.Name, Email: user.Email, Role: user.Role})
 
 	// If still no valid name, use ID with role prefix
 	if resourceName == "" {
@@ -99,6 +126,7 @@ func (h *UsersHandler) generateUserResource(user User) {
 			resourceName = lib.SanitizeResourceName(fmt.Sprintf("user_%s", user.ID))
 		}
 	}
+	resourceName = h.terraformWriter.UniqueName("user", user.ID, resourceName)
 
 	// Build attributes according to the schema
 	attributes := map[string]any{
@@ -134,16 +162,11 @@ func (h *UsersHandler) generateUserResource(user User) {
 	if len(user.AutoGroups) > 0 {
 		autoGroupRefs := make([]string, 0)
 		for _, groupID := range user.AutoGroups {
-			if groupResourceName, exists := h.groupMapping[groupID]; exists {
-				terraformRef := lib.CreateTerraformReference("group", groupResourceName)
-				autoGroupRefs = append(autoGroupRefs, terraformRef)
-			} else {
-				// Fallback to hardcoded ID if group not found in mapping
-				autoGroupRefs = append(autoGroupRefs, groupID)
-			}
+			autoGroupRefs = append(autoGroupRefs, lib.Reference("group", groupID, ""))
 		}
 		attributes["auto_groups"] = autoGroupRefs
 	}
 
 	h.terraformWriter.AddResource("user", resourceName, attributes)
+	return resourceName
 }
@@ -1,79 +1,428 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"math/rand"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 type NetBirdService struct {
-	apiEndpoint string
-	apiToken    string
-	client      *http.Client
-	debug       bool
+	apiEndpoint   string
+	apiToken      string
+	serverVersion string // selects the shims of decodeResponse, "" if unknown
+	client        *http.Client
+	debug         bool
+	traceHTTP     bool // log the connection and response of every request, see httptrace.go
+	maxRetries    int
+	retryDelay    time.Duration
+	timeout       time.Duration // per request, 0 for none
+	limiter       *rateLimiter
+	stats         *APIStats // nil outside a run
+
+	shapesMu sync.Mutex
+	shapes   map[string]string // response shape seen per endpoint, see decodeResponse
 }
 
-func NewNetBirdService(apiEndpoint, apiToken string, debug bool) *NetBirdService {
+// ServiceOptions configures the NetBird API client
+type ServiceOptions struct {
+	Debug       bool          // log requests and responses
+	TraceHTTP   bool          // log timings, TLS and redacted summaries of every request
+	MaxRetries  int           // retries for network errors, 429 and 5xx responses
+	RetryDelay  time.Duration // base delay, doubled on every retry and jittered
+	Timeout     time.Duration // per request, including reading the body; 0 for none
+	QPS         float64       // maximum requests per second, 0 for no client-side limit
+	Concurrency int           // requests in flight at once, sizes the connection pool
+
+	ServerVersion string    // management server version, "" if unknown, see compat.go
+	Stats         *APIStats // records every request, nil for none
+
+	RootCAs            *x509.CertPool   // trusted CAs, nil for the system roots
+	ClientCertificate  *tls.Certificate // presented to servers requiring mutual TLS
+	InsecureSkipVerify bool             // skip TLS certificate verification
+}
+
+// minIdleConnsPerHost is the fewest connections to the server kept open between
+// requests; the transport's default of 2 reconnects under concurrent fetches
+const minIdleConnsPerHost = 8
+
+// defaultRequestTimeout bounds a single API request, so a hung server fails
+// the request, which is retried, instead of stalling the run
+const defaultRequestTimeout = time.Minute
+
+// Retry defaults; the delay is capped so a long outage fails in reasonable time
+const (
+	defaultMaxRetries = 3
+	defaultRetryDelay = 500 * time.Millisecond
+	maxRetryDelay     = 30 * time.Second
+)
+
+func NewNetBirdService(apiEndpoint, apiToken string, options ServiceOptions) *NetBirdService {
+	client := &http.Client{Transport: newTransport(options), Timeout: options.Timeout}
+	if options.TraceHTTP {
+		client.CheckRedirect = traceRedirect
+	}
 	return &NetBirdService{
-		apiEndpoint: apiEndpoint,
-		apiToken:    apiToken,
-		client:      &http.Client{},
-		debug:       debug,
+		apiEndpoint:   apiEndpoint,
+		apiToken:      apiToken,
+		serverVersion: options.ServerVersion,
+		client:        client,
+		debug:         options.Debug,
+		traceHTTP:     options.TraceHTTP,
+		maxRetries:    options.MaxRetries,
+		retryDelay:    options.RetryDelay,
+		timeout:       options.Timeout,
+		limiter:       newRateLimiter(options.QPS),
+		stats:         options.Stats,
+		shapes:        make(map[string]string),
+	}
+}
+
+// newTransport returns the HTTP transport with the configured TLS verification
+// and client certificate. Connections are kept alive for every concurrent
+// fetch and HTTP/2 is negotiated when the server offers it, including with a
+// custom TLS configuration. Responses are requested gzip compressed and
+// decompressed by the transport itself, so Accept-Encoding must not be set on
+// requests.
+func newTransport(options ServiceOptions) http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ForceAttemptHTTP2 = true
+	transport.DisableCompression = false
+	transport.MaxIdleConnsPerHost = max(options.Concurrency, minIdleConnsPerHost)
+	if options.RootCAs == nil && options.ClientCertificate == nil && !options.InsecureSkipVerify {
+		return transport
+	}
+
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs:            options.RootCAs,
+		InsecureSkipVerify: options.InsecureSkipVerify,
+	}
+	if options.ClientCertificate != nil {
+		transport.TLSClientConfig.Certificates = []tls.Certificate{*options.ClientCertificate}
+	}
+	return transport
+}
+
+// makeRequest performs an API request with the given extra headers, retrying
+// transient failures with exponential backoff. It returns the body and the
+// headers of the response.
+func (s *NetBirdService) makeRequest(ctx context.Context, method, path string, header http.Header) ([]byte, http.Header, error) {
+	for attempt := 0; ; attempt++ {
+		body, responseHeader, retryAfter, err := s.doRequest(ctx, method, path, header)
+		if err == nil {
+			return body, responseHeader, nil
+		}
+
+		var transient *transientError
+		if !errors.As(err, &transient) || attempt >= s.maxRetries || ctx.Err() != nil {
+			if !errors.Is(err, errNotModified) {
+				s.stats.RecordError(path)
+			}
+			return nil, responseHeader, err
+		}
+		s.stats.RecordRetry(path)
+
+		// Hold back all requests, not just this one, so a throttled server
+		// gets the full delay
+		delay := s.backoff(attempt, retryAfter)
+		slog.Debug("Retrying API request", "component", "api", "method", method, "path", path,
+			"attempt", attempt+1, "max_retries", s.maxRetries, "delay", delay, "error", err)
+		s.limiter.PauseUntil(time.Now().Add(delay))
+	}
+}
+
+// transientError marks a failure worth retrying: network errors, rate limiting
+// and server errors
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transientError) Unwrap() error {
+	return e.err
+}
+
+// errNotModified is returned when the server answers a conditional request
+// with 304 Not Modified: the cached body is still current
+var errNotModified = errors.New("not modified")
+
+// statusError is an API response with an error status
+type statusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// backoff returns the delay before the given retry: the base delay doubled per
+// attempt with full jitter, or the server's Retry-After if it asked for longer
+func (s *NetBirdService) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	delay := s.retryDelay << attempt
+	if delay <= 0 || delay > maxRetryDelay {
+		delay = maxRetryDelay
 	}
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+
+	if retryAfter > delay {
+		delay = min(retryAfter, maxRetryDelay)
+	}
+	return delay
 }
 
-func (s *NetBirdService) makeRequest(method, path string) ([]byte, error) {
-	url := fmt.Sprintf("%s%s", s.apiEndpoint, path)
+// doRequest performs a single API request. It also returns the headers of the
+// response and the Retry-After delay requested by the server, if any.
+func (s *NetBirdService) doRequest(ctx context.Context, method, path string, header http.Header) ([]byte, http.Header, time.Duration, error) {
+	url, err := joinEndpoint(s.apiEndpoint, path)
+	if err != nil {
+		return nil, nil, 0, err
+	}
 
+	if err := s.limiter.Wait(ctx); err != nil {
+		return nil, nil, 0, err
+	}
 	if s.debug {
-		fmt.Printf("DEBUG: Making %s request to %s\n", method, url)
+		slog.Debug("API request", "component", "api", "method", method, "url", url)
 	}
 
-	req, err := http.NewRequest(method, url, nil)
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, 0, err
 	}
 
+	for name, values := range header {
+		req.Header[name] = values
+	}
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", s.apiToken))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent())
 
 	if s.debug {
-		fmt.Printf("DEBUG: Request headers: %v\n", req.Header)
+		slog.Debug("API request headers", "component", "api", "headers", redactHeaders(req.Header))
+	}
+
+	var trace *requestTrace
+	if s.traceHTTP {
+		var clientTrace *httptrace.ClientTrace
+		trace, clientTrace = newRequestTrace()
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), clientTrace))
 	}
 
+	sentAt := time.Now()
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return nil, err
+		s.stats.RecordRequest(path, time.Since(sentAt), 0)
+		if trace != nil {
+			trace.log(s, req, nil, nil, err)
+		}
+		// Retrying won't fix an untrusted certificate
+		var certificateErr *tls.CertificateVerificationError
+		if errors.As(err, &certificateErr) {
+			return nil, nil, 0, fmt.Errorf("%w (set NB_CA_CERT to trust a private CA)", err)
+		}
+		return nil, nil, 0, &transientError{err: s.timeoutError(ctx, method, path, err)}
 	}
 	defer resp.Body.Close()
 
+	s.limiter.Observe(resp.Header)
+
 	body, err := io.ReadAll(resp.Body)
+	s.stats.RecordRequest(path, time.Since(sentAt), len(body))
+	if trace != nil {
+		trace.log(s, req, resp, body, err)
+	}
 	if err != nil {
-		return nil, err
+		return nil, nil, 0, &transientError{err: s.timeoutError(ctx, method, path, err)}
 	}
 
 	if s.debug {
-		fmt.Printf("DEBUG: Response status: %d\n", resp.StatusCode)
-		fmt.Printf("DEBUG: Response headers: %v\n", resp.Header)
+		slog.Debug("API response", "component", "api", "status", resp.StatusCode, "proto", resp.Proto, "gzip", resp.Uncompressed, "headers", redactHeaders(resp.Header))
 		if resp.StatusCode >= 400 {
-			fmt.Printf("DEBUG: Response body: %s\n", string(body))
+			slog.Debug("API response body", "component", "api", "body", s.redactBody(body))
 		}
 	}
 
+	// The body of an error ends up in logs and the run report
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		err := &statusError{StatusCode: resp.StatusCode, Body: s.redactBody(body)}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			return nil, resp.Header, parseRetryAfter(resp.Header.Get("Retry-After")), &transientError{err: err}
+		}
+		return nil, resp.Header, 0, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, resp.Header, 0, errNotModified
 	}
 
-	return body, nil
+	return body, resp.Header, 0, nil
 }
 
-func (s *NetBirdService) Get(path string, result interface{}) error {
-	body, err := s.makeRequest("GET", path)
+// timeoutError explains a request that timed out: the error of the client
+// only names the URL and "Client.Timeout exceeded"
+func (s *NetBirdService) timeoutError(ctx context.Context, method, path string, err error) error {
+	var netErr net.Error
+	if ctx.Err() != nil || !errors.As(err, &netErr) || !netErr.Timeout() {
+		return err
+	}
+	return fmt.Errorf("%s %s got no complete response within %s, the management server or a proxy in front of it may be hung (raise --request-timeout for slow servers): %w", method, path, s.timeout, err)
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// GetRaw returns the undecoded response body of an API request
+func (s *NetBirdService) GetRaw(ctx context.Context, path string) ([]byte, error) {
+	body, _, err := s.makeRequest(ctx, "GET", path, nil)
+	return body, err
+}
+
+// GetConditional returns the undecoded response body of an API request and
+// its ETag. With the ETag of an earlier response the request is sent with
+// If-None-Match, and errNotModified is returned if that response is current.
+func (s *NetBirdService) GetConditional(ctx context.Context, path, etag string) ([]byte, string, error) {
+	header := make(http.Header)
+	if etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+	body, responseHeader, err := s.makeRequest(ctx, "GET", path, header)
+	return body, responseHeader.Get("ETag"), err
+}
+
+func (s *NetBirdService) Get(ctx context.Context, path string, result interface{}) error {
+	body, _, err := s.makeRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return err
 	}
 
-	return json.Unmarshal(body, result)
+	return s.decodeResponse(path, body, result)
+}
+
+// listWrapperKeys are the fields known to hold the items when a list endpoint
+// returns an object instead of a bare array
+var listWrapperKeys = []string{"data", "items", "results"}
+
+// decodeResponse decodes a response body into result. Depending on the server
+// version, list endpoints return either a bare array or an object wrapping it,
+// so an object is unwrapped when a slice is expected, and items of an older
+// shape are adapted by the shims of compat.go.
+func (s *NetBirdService) decodeResponse(path string, body []byte, result any) error {
+	shape, err := decodeTolerant(adaptResponse(path, s.serverVersion, body), result)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", path, err)
+	}
+	s.recordShape(path, shape)
+	return nil
+}
+
+// decodeTolerant decodes a response body, unwrapping list responses if needed,
+// and returns the shape it found: "plain" or "wrapped in <field>"
+func decodeTolerant(body []byte, result any) (string, error) {
+	err := json.Unmarshal(body, result)
+	if err == nil {
+		return "plain", nil
+	}
+
+	target := reflect.ValueOf(result)
+	if target.Kind() != reflect.Pointer || target.Elem().Kind() != reflect.Slice {
+		return "", err
+	}
+
+	var wrapper map[string]json.RawMessage
+	if json.Unmarshal(body, &wrapper) != nil {
+		return "", err
+	}
+
+	// Try the known wrapper fields first, then any field holding an array
+	others := make([]string, 0, len(wrapper))
+	for key, value := range wrapper {
+		if strings.HasPrefix(strings.TrimSpace(string(value)), "[") {
+			others = append(others, key)
+		}
+	}
+	sort.Strings(others)
+	keys := append(append([]string{}, listWrapperKeys...), others...)
+
+	for _, key := range keys {
+		items, exists := wrapper[key]
+		if !exists {
+			continue
+		}
+		if json.Unmarshal(items, result) == nil {
+			return "wrapped in " + key, nil
+		}
+	}
+
+	return "", err
+}
+
+// recordShape remembers which response shape an endpoint returned and logs it
+// the first time it is seen
+func (s *NetBirdService) recordShape(path, shape string) {
+	s.shapesMu.Lock()
+	defer s.shapesMu.Unlock()
+
+	if s.shapes[path] == shape {
+		return
+	}
+	s.shapes[path] = shape
+	if shape != "plain" {
+		slog.Debug("Decoded wrapped API response", "component", "api", "path", path, "shape", shape)
+	}
+}
+
+// joinEndpoint appends an API path to the management URL. Self-hosted deployments
+// may serve the API under a path prefix, either as the base of the API
+// (https://vpn.example.com/netbird) or including the api segment itself
+// (https://vpn.example.com/netbird/api); both resolve /api/groups correctly.
+func joinEndpoint(baseURL, path string) (string, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid management URL %q: %w", baseURL, err)
+	}
+
+	endpoint, err := url.Parse(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid API path %q: %w", path, err)
+	}
+
+	prefix := strings.TrimSuffix(base.Path, "/")
+	if strings.HasSuffix(prefix, "/api") && strings.HasPrefix(endpoint.Path, "/api/") {
+		prefix = strings.TrimSuffix(prefix, "/api")
+	}
+
+	base.Path = prefix + "/" + strings.TrimPrefix(endpoint.Path, "/")
+	base.RawPath = ""
+	base.RawQuery = endpoint.RawQuery
+	return base.String(), nil
 }
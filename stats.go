@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// statsSchemaVersion is bumped whenever a field of usageStats changes meaning
+const statsSchemaVersion = 1
+
+// statsTimeout bounds how long sending usage statistics may delay the exit
+const statsTimeout = 5 * time.Second
+
+// usageStats are the anonymous statistics of a run. They hold counts, durations
+// and error categories only; never names, IDs, URLs, emails or error messages.
+type usageStats struct {
+	SchemaVersion   int                       `json:"schema_version"`
+	ImporterVersion string                    `json:"importer_version"`
+	OS              string                    `json:"os"`
+	Arch            string                    `json:"arch"`
+	Format          string                    `json:"format"`
+	Features        []string                  `json:"features"`
+	Status          string                    `json:"status"`
+	ExitCode        int                       `json:"exit_code"`
+	DurationSeconds float64                   `json:"duration_seconds"`
+	Resources       map[string]resourceReport `json:"resources"`
+	Imports         importStats               `json:"imports"`
+	PhaseSeconds    map[string]float64        `json:"phase_seconds"`
+	ErrorCategories map[string]int            `json:"error_categories"`
+}
+
+type importStats struct {
+	Mode      string `json:"mode,omitempty"`
+	Queued    int    `json:"queued"`
+	Succeeded int    `json:"succeeded"`
+	Failed    int    `json:"failed"`
+}
+
+// newUsageStats derives the anonymous statistics from a run summary
+func newUsageStats(config *Config, s *RunSummary) usageStats {
+	report := newRunReport(s)
+
+	stats := usageStats{
+		SchemaVersion:   statsSchemaVersion,
+		ImporterVersion: version,
+		OS:              runtime.GOOS,
+		Arch:            runtime.GOARCH,
+		Format:          config.Format,
+		Features:        usedFeatures(config),
+		Status:          report.Status,
+		ExitCode:        s.ExitCode,
+		DurationSeconds: report.DurationSeconds,
+		Resources:       report.Resources,
+		Imports: importStats{
+			Mode:      s.ImportMode,
+			Queued:    s.ImportsQueued,
+			Succeeded: s.ImportsSucceeded,
+			Failed:    len(s.ImportsFailed),
+		},
+		PhaseSeconds:    make(map[string]float64),
+		ErrorCategories: make(map[string]int),
+	}
+
+	// Split state phases carry the module name, which is a resource type
+	for _, phase := range s.Phases {
+		stats.PhaseSeconds[phase.Name] += phase.Duration.Seconds()
+	}
+	for _, warning := range s.Warnings {
+		stats.ErrorCategories[errorCategory(warning)]++
+	}
+	for range s.ImportsFailed {
+		stats.ErrorCategories["terraform_import"]++
+	}
+
+	return stats
+}
+
+// usedFeatures lists the optional features enabled for the run
+func usedFeatures(config *Config) []string {
+	enabled := map[string]bool{
+		"account":         config.Account != "",
+		"auto_import":     config.AutoImport,
+		"bundle":          config.Bundle != nil,
+		"ca_cert":         config.RootCAs != nil,
+		"client_cert":     config.ClientCert != nil,
+		"config_file":     config.ConfigFile != "",
+		"ansible":         config.Ansible,
+		"dry_run":         config.DryRun,
+		"email_report":    len(config.EmailReport) > 0,
+		"exclude":         config.ExcludePattern != nil,
+		"exclude_types":   len(config.ExcludedTypes) > 0,
+		"fail_on_warning": config.FailOnWarning,
+		"graph":           config.Graph != "",
+		"html_report":     config.HTMLReport,
+		"include":         config.IncludePattern != nil,
+		"limit":           config.Limit > 0,
+		"profile":         config.Profile != "",
+		"pulumi_import":   config.PulumiImport,
+		"qps":             config.QPS > 0,
+		"resume":          config.Resume,
+		"rules":           len(config.Rules) > 0,
+		"split_state":     config.SplitState,
+		"suggest_groups":  config.SuggestGroups,
+		"tfc":             config.TFC != nil,
+		"tls_skip_verify": config.TLSSkipVerify,
+		"url_comments":    config.URLComments,
+	}
+
+	features := make([]string, 0, len(enabled))
+	for feature, on := range enabled {
+		if on {
+			features = append(features, feature)
+		}
+	}
+	sort.Strings(features)
+	return features
+}
+
+// errorCategory maps a warning to a coarse category, so that no part of the
+// message itself leaves the machine
+func errorCategory(message string) string {
+	switch {
+	case strings.Contains(message, "status 401"), strings.Contains(message, "status 403"):
+		return "auth"
+	case strings.Contains(message, "status 404"):
+		return "not_found"
+	case strings.Contains(message, "status 429"):
+		return "rate_limited"
+	case strings.Contains(message, "status 5"):
+		return "server_error"
+	case strings.Contains(message, "tls:"), strings.Contains(message, "x509:"):
+		return "tls"
+	case strings.Contains(message, "failed to decode"):
+		return "decode"
+	case strings.Contains(message, "dial tcp"), strings.Contains(message, "no such host"), strings.Contains(message, "timeout"):
+		return "network"
+	default:
+		return "other"
+	}
+}
+
+// recordUsageStats writes the run's anonymous statistics to the stats file and
+// sends them to the telemetry endpoint, when either was configured. Failures are
+// logged at debug level and never affect the run.
+func recordUsageStats(config *Config, s *RunSummary) {
+	if config.StatsFile == "" && config.TelemetryURL == "" {
+		return
+	}
+
+	data, err := json.MarshalIndent(newUsageStats(config, s), "", "  ")
+	if err != nil {
+		slog.Debug("Failed to encode usage statistics", "error", err)
+		return
+	}
+
+	if config.StatsFile != "" {
+		err := os.WriteFile(config.StatsFile, append(data, '\n'), 0644)
+		if err != nil {
+			slog.Warn("Failed to write stats file", "path", config.StatsFile, "error", err)
+		} else {
+			slog.Info("Wrote usage statistics", "path", config.StatsFile)
+		}
+	}
+
+	if config.TelemetryURL != "" {
+		err := sendUsageStats(config.TelemetryURL, data)
+		if err != nil {
+			slog.Debug("Failed to send usage statistics", "error", err)
+		}
+	}
+}
+
+// sendUsageStats posts the statistics to the telemetry endpoint. It runs after
+// Ctrl-C too, so it does not use the run's context.
+func sendUsageStats(endpoint string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), statsTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("telemetry endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"netbird-terraformer/lib"
+	"netbird-terraformer/resources"
+)
+
+// RunSummary collects the outcome of an importer run
+type RunSummary struct {
+	RunID      string
+	Version    string
+	ServerURL  string
+	OutputDir  string
+	StartedAt  time.Time
+	FinishedAt time.Time
+
+	ResourceCounts   map[string]int
+	Discovered       map[string]int
+	Skipped          []lib.SkippedResource
+	NameCollisions   []lib.NameCollision
+	Unresolved       []lib.UnresolvedReference
+	Pruned           []lib.PrunedResource
+	Reconciled       []string // attributes --reconcile adopted the state's value of
+	Unreconcilable   []string // changes the plan still makes after reconciling
+	SkippedTypes     []string
+	ImportMode       string // blocks, cli, state or tfc, empty without auto-import
+	TerraformVersion string
+	ImportsQueued    int
+	ImportsSucceeded int
+	ImportsFailed    []ImportFailure
+	ImportTimings    []ImportTiming
+	Phases           []PhaseTiming
+	API              *APIStats // requests per endpoint, see apistats.go
+	SetupKeys        []resources.SetupKeyUsage
+	Warnings         []string
+	SecretsRedacted  []lib.ScrubFinding
+	ExitCode         int
+	Interrupted      bool
+}
+
+// ImportFailure records a terraform import that did not succeed
+type ImportFailure struct {
+	Address string
+	Error   string
+}
+
+// ImportTiming records how long the terraform import of one resource took
+type ImportTiming struct {
+	Address   string
+	Duration  time.Duration
+	Succeeded bool
+}
+
+// slowestImportsShown is how many of the slowest imports the summary lists
+const slowestImportsShown = 5
+
+// SlowestImports returns up to n imports ordered by decreasing duration
+func (s *RunSummary) SlowestImports(n int) []ImportTiming {
+	slowest := append([]ImportTiming{}, s.ImportTimings...)
+	sort.SliceStable(slowest, func(i, j int) bool {
+		return slowest[i].Duration > slowest[j].Duration
+	})
+	if len(slowest) > n {
+		slowest = slowest[:n]
+	}
+	return slowest
+}
+
+// PhaseTiming records how long one phase of the run took
+type PhaseTiming struct {
+	Name     string
+	Duration time.Duration
+}
+
+// NewRunSummary creates a summary for a run starting now
+func NewRunSummary(runID string, config *Config) *RunSummary {
+	return &RunSummary{
+		RunID:          runID,
+		Version:        version,
+		ServerURL:      config.ServerURL,
+		OutputDir:      config.OutputDir,
+		StartedAt:      time.Now(),
+		ResourceCounts: make(map[string]int),
+		Discovered:     make(map[string]int),
+		API:            newAPIStats(),
+	}
+}
+
+// AddWarning records a non-fatal problem and prints it
+func (s *RunSummary) AddWarning(format string, args ...any) {
+	warning := fmt.Sprintf(format, args...)
+	s.Warnings = append(s.Warnings, warning)
+	slog.Warn(warning)
+}
+
+// RecordResources counts the generated, discovered and skipped resources per
+// type and collects the objects renamed because of a name collision and the
+// references that could not be resolved
+func (s *RunSummary) RecordResources(terraformGen *lib.TerraformGenerator) {
+	for _, resource := range terraformGen.GetResources() {
+		s.ResourceCounts[resource.Type]++
+	}
+	for resourceType, count := range terraformGen.GetDiscovered() {
+		s.Discovered[resourceType] += count
+	}
+	s.Skipped = append(s.Skipped, terraformGen.GetSkipped()...)
+	s.NameCollisions = append(s.NameCollisions, terraformGen.GetNameCollisions()...)
+	s.Unresolved = append(s.Unresolved, terraformGen.GetUnresolvedReferences()...)
+}
+
+// TrackPhase records the duration of a phase that started at the given time
+func (s *RunSummary) TrackPhase(name string, startedAt time.Time) {
+	s.Phases = append(s.Phases, PhaseTiming{Name: name, Duration: time.Since(startedAt)})
+}
+
+// Succeeded reports whether the run finished without warnings or failed imports
+func (s *RunSummary) Succeeded() bool {
+	return len(s.Warnings) == 0 && len(s.ImportsFailed) == 0 && len(s.SecretsRedacted) == 0 && !s.Interrupted
+}
+
+// Text renders the summary as plain text
+func (s *RunSummary) Text() string {
+	var builder strings.Builder
+
+	status := "succeeded"
+	if s.Interrupted {
+		status = "was interrupted"
+	} else if !s.Succeeded() {
+		status = "finished with warnings"
+	}
+
+	fmt.Fprintf(&builder, "NetBird Terraformer run %s %s\n\n", s.RunID, status)
+	fmt.Fprintf(&builder, "Importer version: %s\n", s.Version)
+	fmt.Fprintf(&builder, "Server URL:       %s\n", s.ServerURL)
+	fmt.Fprintf(&builder, "Output directory: %s\n", s.OutputDir)
+	fmt.Fprintf(&builder, "Started:          %s\n", s.StartedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&builder, "Duration:         %s\n\n", s.FinishedAt.Sub(s.StartedAt).Round(time.Second))
+
+	resourceTypes := make([]string, 0, len(s.ResourceCounts))
+	for resourceType := range s.ResourceCounts {
+		resourceTypes = append(resourceTypes, resourceType)
+	}
+	sort.Strings(resourceTypes)
+
+	builder.WriteString("Generated resources:\n")
+	if len(resourceTypes) == 0 {
+		builder.WriteString("  (none, nothing to import: only the provider configuration was written)\n")
+	}
+	for _, resourceType := range resourceTypes {
+		fmt.Fprintf(&builder, "  %-10s %d\n", resourceType, s.ResourceCounts[resourceType])
+	}
+
+	importMode := ""
+	if s.ImportMode != "" {
+		importMode = " (" + s.ImportMode + ")"
+	}
+	fmt.Fprintf(&builder, "\nTerraform imports%s: %d queued, %d succeeded, %d failed\n", importMode, s.ImportsQueued, s.ImportsSucceeded, len(s.ImportsFailed))
+	for _, failure := range s.ImportsFailed {
+		fmt.Fprintf(&builder, "  failed: %s (%s)\n", failure.Address, failure.Error)
+	}
+
+	if len(s.ImportTimings) > 0 {
+		var total time.Duration
+		for _, timing := range s.ImportTimings {
+			total += timing.Duration
+		}
+		fmt.Fprintf(&builder, "\nImport time: %s total, %s per resource on average\n",
+			total.Round(time.Millisecond), (total / time.Duration(len(s.ImportTimings))).Round(time.Millisecond))
+		builder.WriteString("Slowest imports:\n")
+		for _, timing := range s.SlowestImports(slowestImportsShown) {
+			fmt.Fprintf(&builder, "  %-40s %s\n", timing.Address, timing.Duration.Round(time.Millisecond))
+		}
+	}
+
+	unusedKeys := 0
+	for _, setupKey := range s.SetupKeys {
+		if setupKey.Unused {
+			unusedKeys++
+		}
+	}
+	if unusedKeys > 0 {
+		fmt.Fprintf(&builder, "\nSetup keys: %d valid but never used\n", unusedKeys)
+		for _, setupKey := range s.SetupKeys {
+			if setupKey.Unused {
+				fmt.Fprintf(&builder, "  - %s (%s, expires %s)\n", setupKey.Name, setupKey.Type, setupKey.Expires)
+			}
+		}
+	}
+
+	if len(s.Skipped) > 0 {
+		fmt.Fprintf(&builder, "\nSkipped objects: %d (see report.json for details)\n", len(s.Skipped))
+	}
+
+	if len(s.NameCollisions) > 0 {
+		builder.WriteString("\nRenamed because of a name collision:\n")
+		for _, collision := range s.NameCollisions {
+			fmt.Fprintf(&builder, "  %s %s: %s (%s keeps %s)\n", collision.Type, collision.ID, collision.Assigned, collision.TakenBy, collision.Name)
+		}
+	}
+
+	if len(s.Unresolved) > 0 {
+		builder.WriteString("\nUnresolved references, written as IDs:\n")
+		for _, reference := range s.Unresolved {
+			fmt.Fprintf(&builder, "  %s.%s %s: %s %s\n", reference.Type, reference.Resource, reference.Attribute, reference.TargetType, reference.Target())
+		}
+	}
+
+	if len(s.Pruned) > 0 {
+		builder.WriteString("\nNo longer generated, removed blocks written:\n")
+		for _, resource := range s.Pruned {
+			fmt.Fprintf(&builder, "  %s (%s)\n", resource.Address, resource.ID)
+		}
+	}
+
+	if len(s.Reconciled) > 0 {
+		builder.WriteString("\nAdopted the state's values:\n")
+		for _, attribute := range s.Reconciled {
+			fmt.Fprintf(&builder, "  %s\n", attribute)
+		}
+	}
+
+	if len(s.Unreconcilable) > 0 {
+		builder.WriteString("\nChanges left in the plan, not reconcilable:\n")
+		for _, change := range s.Unreconcilable {
+			fmt.Fprintf(&builder, "  %s\n", change)
+		}
+	}
+
+	if len(s.SecretsRedacted) > 0 {
+		builder.WriteString("\nSecrets redacted from generated files:\n")
+		for _, finding := range s.SecretsRedacted {
+			fmt.Fprintf(&builder, "  %s:%d (%s)\n", finding.File, finding.Line, finding.Rule)
+		}
+	}
+
+	if len(s.Warnings) > 0 {
+		builder.WriteString("\nWarnings:\n")
+		for _, warning := range s.Warnings {
+			fmt.Fprintf(&builder, "  - %s\n", warning)
+		}
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"netbird-terraformer/lib"
+)
+
+// TFCConfig selects the HCP Terraform (Terraform Cloud) or Terraform Enterprise
+// workspace the import runs in instead of running terraform locally
+type TFCConfig struct {
+	Hostname     string `json:"hostname"` // defaults to app.terraform.io
+	Organization string `json:"organization"`
+	Workspace    string `json:"workspace"`
+
+	Token string `json:"-"` // TFE_TOKEN, never read from the config file
+}
+
+// importModeTFC is the import mode reported for runs in HCP Terraform
+const importModeTFC = "tfc"
+
+// defaultTFCHostname is the hostname of HCP Terraform
+const defaultTFCHostname = "app.terraform.io"
+
+// tfcUploadTimeout bounds the wait for an uploaded configuration to be processed
+const tfcUploadTimeout = 2 * time.Minute
+
+// tfcPollInterval is the time between configuration version status checks
+var tfcPollInterval = 2 * time.Second
+
+// tfcMediaType is the JSON:API media type of the HCP Terraform API
+const tfcMediaType = "application/vnd.api+json"
+
+// tfcClient calls the HCP Terraform API
+type tfcClient struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// newTFCClient returns a client for the API of a hostname. A hostname with a
+// scheme, e.g. http://127.0.0.1:8080, is used as the base URL as is.
+func newTFCClient(tfc *TFCConfig) *tfcClient {
+	baseURL := tfc.Hostname
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "https://" + baseURL
+	}
+	return &tfcClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   tfc.Token,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// tfcDocument is a JSON:API document with a single resource
+type tfcDocument struct {
+	Data tfcResource `json:"data"`
+}
+
+// tfcResource is a JSON:API resource object
+type tfcResource struct {
+	ID            string         `json:"id,omitempty"`
+	Type          string         `json:"type"`
+	Attributes    map[string]any `json:"attributes,omitempty"`
+	Relationships map[string]any `json:"relationships,omitempty"`
+}
+
+// do sends a request to the API and decodes the response into result. It
+// returns the status code, which is also set when the request failed with one.
+func (c *tfcClient) do(ctx context.Context, method, path string, body, result any) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+"/api/v2"+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", tfcMediaType)
+	req.Header.Set("User-Agent", userAgent())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return resp.StatusCode, fmt.Errorf("%s %s returned status %d", method, path, resp.StatusCode)
+	}
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode %s response: %w", path, err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// ensureWorkspace returns the ID of the workspace, creating it if it does not
+// exist. A created workspace does not apply automatically.
+func (c *tfcClient) ensureWorkspace(ctx context.Context, organization, name string) (string, error) {
+	var workspace tfcDocument
+	path := fmt.Sprintf("/organizations/%s/workspaces/%s", url.PathEscape(organization), url.PathEscape(name))
+	status, err := c.do(ctx, http.MethodGet, path, nil, &workspace)
+	if err == nil {
+		return workspace.Data.ID, nil
+	}
+	if status != http.StatusNotFound {
+		return "", err
+	}
+
+	slog.Info("Creating HCP Terraform workspace", "organization", organization, "workspace", name)
+	create := tfcDocument{Data: tfcResource{Type: "workspaces", Attributes: map[string]any{
+		"name":        name,
+		"auto-apply":  false,
+		"description": "NetBird configuration adopted by netbird-importer",
+	}}}
+	_, err = c.do(ctx, http.MethodPost, fmt.Sprintf("/organizations/%s/workspaces", url.PathEscape(organization)), create, &workspace)
+	if err != nil {
+		return "", fmt.Errorf("failed to create workspace: %w", err)
+	}
+	return workspace.Data.ID, nil
+}
+
+// setSensitiveVariable sets a sensitive environment variable of a workspace,
+// replacing its value if it exists
+func (c *tfcClient) setSensitiveVariable(ctx context.Context, workspaceID, key, value string) error {
+	var variables struct {
+		Data []tfcResource `json:"data"`
+	}
+	_, err := c.do(ctx, http.MethodGet, "/workspaces/"+workspaceID+"/vars", nil, &variables)
+	if err != nil {
+		return err
+	}
+
+	variable := tfcDocument{Data: tfcResource{Type: "vars", Attributes: map[string]any{
+		"key":       key,
+		"value":     value,
+		"category":  "env",
+		"sensitive": true,
+	}}}
+	for _, existing := range variables.Data {
+		if existing.Attributes["key"] == key && existing.Attributes["category"] == "env" {
+			variable.Data.ID = existing.ID
+			_, err = c.do(ctx, http.MethodPatch, "/workspaces/"+workspaceID+"/vars/"+existing.ID, variable, nil)
+			return err
+		}
+	}
+	_, err = c.do(ctx, http.MethodPost, "/workspaces/"+workspaceID+"/vars", variable, nil)
+	return err
+}
+
+// uploadConfiguration creates a configuration version from a directory and
+// waits until HCP Terraform processed it
+func (c *tfcClient) uploadConfiguration(ctx context.Context, workspaceID, dir string) (string, error) {
+	archive, err := archiveConfiguration(dir)
+	if err != nil {
+		return "", err
+	}
+
+	var version tfcDocument
+	create := tfcDocument{Data: tfcResource{Type: "configuration-versions", Attributes: map[string]any{"auto-queue-runs": false}}}
+	_, err = c.do(ctx, http.MethodPost, "/workspaces/"+workspaceID+"/configuration-versions", create, &version)
+	if err != nil {
+		return "", fmt.Errorf("failed to create configuration version: %w", err)
+	}
+
+	uploadURL, _ := version.Data.Attributes["upload-url"].(string)
+	if uploadURL == "" {
+		return "", fmt.Errorf("configuration version %s has no upload URL", version.Data.ID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, uploadURL, bytes.NewReader(archive))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("User-Agent", userAgent())
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload configuration: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("failed to upload configuration: status %d", resp.StatusCode)
+	}
+
+	deadline := time.Now().Add(tfcUploadTimeout)
+	for {
+		_, err := c.do(ctx, http.MethodGet, "/configuration-versions/"+version.Data.ID, nil, &version)
+		if err != nil {
+			return "", err
+		}
+		switch status, _ := version.Data.Attributes["status"].(string); status {
+		case "uploaded":
+			return version.Data.ID, nil
+		case "errored":
+			return "", fmt.Errorf("configuration version %s errored: %v", version.Data.ID, version.Data.Attributes["error-message"])
+		}
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("configuration version %s was not processed within %s", version.Data.ID, tfcUploadTimeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(tfcPollInterval):
+		}
+	}
+}
+
+// createRun queues a run of a configuration version and returns its ID
+func (c *tfcClient) createRun(ctx context.Context, workspaceID, configurationVersionID, message string) (string, error) {
+	run := tfcDocument{Data: tfcResource{
+		Type:       "runs",
+		Attributes: map[string]any{"message": message},
+		Relationships: map[string]any{
+			"workspace":             map[string]any{"data": map[string]string{"type": "workspaces", "id": workspaceID}},
+			"configuration-version": map[string]any{"data": map[string]string{"type": "configuration-versions", "id": configurationVersionID}},
+		},
+	}}
+	var created tfcDocument
+	_, err := c.do(ctx, http.MethodPost, "/runs", run, &created)
+	if err != nil {
+		return "", fmt.Errorf("failed to create run: %w", err)
+	}
+	return created.Data.ID, nil
+}
+
+// archiveConfiguration packs the regular files of a directory into a tar.gz
+// archive. Local state never leaves the machine; the workspace keeps its own.
+func archiveConfiguration(dir string) ([]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	gz := gzip.NewWriter(&buffer)
+	archive := tar.NewWriter(gz)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), "terraform.tfstate") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		header := &tar.Header{Name: entry.Name(), Mode: 0644, Size: int64(len(data)), ModTime: time.Now()}
+		if err := archive.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := archive.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// runTFCImport runs the import in an HCP Terraform workspace instead of
+// locally: it creates the workspace if needed, stores NB_PAT as a sensitive
+// environment variable, uploads the generated configuration with an import
+// block per resource and queues a run. Applying the run adopts the objects
+// into the workspace's state. It returns the URL of the run.
+func runTFCImport(ctx context.Context, config *Config, terraformGen *lib.TerraformGenerator, runID string, summary *RunSummary) (string, error) {
+	importCommands := terraformGen.GetImportCommands()
+	summary.ImportsQueued = len(importCommands)
+	if len(importCommands) == 0 {
+		slog.Info("No terraform imports to run")
+		return "", nil
+	}
+
+	tfc := config.TFC
+	client := newTFCClient(tfc)
+	startedAt := time.Now()
+	workspaceID, err := client.ensureWorkspace(ctx, tfc.Organization, tfc.Workspace)
+	if err != nil {
+		return "", err
+	}
+	err = client.setSensitiveVariable(ctx, workspaceID, "NB_PAT", config.APIToken)
+	if err != nil {
+		return "", fmt.Errorf("failed to set NB_PAT: %w", err)
+	}
+
+	// Import blocks go into a copy, as for a local import
+	workspace, err := lib.NewWorkspace(config.OutputDir)
+	if err != nil {
+		return "", err
+	}
+	defer workspace.Close()
+	err = terraformGen.WriteImportBlocks(workspace.Dir(), importCommands)
+	if err != nil {
+		return "", fmt.Errorf("failed to write import blocks: %w", err)
+	}
+
+	slog.Info("Uploading the configuration to HCP Terraform", "organization", tfc.Organization, "workspace", tfc.Workspace, "imports", len(importCommands))
+	configurationVersionID, err := client.uploadConfiguration(ctx, workspaceID, workspace.Dir())
+	if err != nil {
+		return "", err
+	}
+	tfcRunID, err := client.createRun(ctx, workspaceID, configurationVersionID, "Import NetBird objects (netbird-importer run "+runID+")")
+	if err != nil {
+		return "", err
+	}
+	summary.TrackPhase("tfc_run", startedAt)
+
+	hostname := strings.TrimSuffix(strings.SplitN(client.baseURL, "://", 2)[1], "/")
+	runURL := fmt.Sprintf("https://%s/app/%s/workspaces/%s/runs/%s", hostname, tfc.Organization, tfc.Workspace, tfcRunID)
+	slog.Info("Queued the import run in HCP Terraform; confirm it there to adopt the objects", "run", tfcRunID, "url", runURL)
+	return runURL, nil
+}
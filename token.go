@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// keyringService is the service name tokens are stored under in the OS keyring.
+// Entries are keyed by the management URL they authenticate against.
+const keyringService = "netbird-terraformer"
+
+// commandToken manages the token stored in the OS keyring
+const commandToken = "token"
+
+// tokenSources are the places the API token is read from besides NB_PAT, so it
+// never has to appear in shell history or environment dumps
+type tokenSources struct {
+	File    string // --token-file or NB_PAT_FILE
+	Stdin   bool   // --token-stdin: the first line of stdin
+	Vault   string // NB_PAT_VAULT_PATH: a Vault KV path, see vault.go
+	Keyring bool   // --keyring: the OS keyring entry of the management URL
+}
+
+// token returns the API token of a management URL from the first source that
+// has one: stdin, the token file, Vault, the environment variable, then the
+// keyring.
+// An empty token without an error means no source is configured.
+func (s tokenSources) token(envName, serverURL string) (string, error) {
+	if s.Stdin {
+		return readToken(os.Stdin, "stdin")
+	}
+	if s.File != "" {
+		file, err := os.Open(s.File)
+		if err != nil {
+			return "", fmt.Errorf("failed to read the token file: %w", err)
+		}
+		defer file.Close()
+		return readToken(file, s.File)
+	}
+	if s.Vault != "" {
+		return readVaultToken(context.Background(), s.Vault)
+	}
+	if token := os.Getenv(envName); token != "" {
+		return token, nil
+	}
+	if s.Keyring {
+		token, err := keyringGet(serverURL)
+		if err != nil {
+			return "", fmt.Errorf("failed to read the token of %s from the keyring: %w", serverURL, err)
+		}
+		return token, nil
+	}
+	return "", nil
+}
+
+// readToken reads a token from the first line of r
+func readToken(r io.Reader, source string) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read the token from %s: %w", source, err)
+	}
+	token := strings.TrimSpace(line)
+	if token == "" {
+		return "", fmt.Errorf("no token in %s", source)
+	}
+	return token, nil
+}
+
+// runToken stores the token of the management URL in the OS keyring, read
+// from stdin, or deletes it:
+//
+//	netbird-importer token store < token.txt
+//	netbird-importer token delete
+func runToken(arguments []string) int {
+	flags := flag.NewFlagSet(os.Args[0]+" "+commandToken, flag.ExitOnError)
+	configPath := flags.String("config", "", "Path to a netbird-terraformer.yaml config file")
+	flags.Parse(arguments)
+
+	fileConfig, _, err := loadConfigFile(*configPath)
+	if err != nil {
+		slog.Error("Failed to read the config file", "error", err)
+		return exitFatal
+	}
+	serverURL := strings.TrimSuffix(stringSetting(false, "", "NB_MANAGEMENT_URL", fileConfig.ServerURL, "https://netbird.api.com:33073"), "/")
+
+	switch flags.Arg(0) {
+	case "store":
+		token, err := readToken(os.Stdin, "stdin")
+		if err == nil {
+			err = keyringSet(serverURL, token)
+		}
+		if err != nil {
+			slog.Error("Failed to store the token", "server_url", serverURL, "error", err)
+			return exitFatal
+		}
+		slog.Info("Stored the token in the keyring, use it with --keyring", "server_url", serverURL)
+	case "delete":
+		if err := keyringDelete(serverURL); err != nil {
+			slog.Error("Failed to delete the token", "server_url", serverURL, "error", err)
+			return exitFatal
+		}
+		slog.Info("Deleted the token from the keyring", "server_url", serverURL)
+	default:
+		fmt.Fprintf(os.Stderr, "Usage: %s token store|delete [--config <path>]\n", os.Args[0])
+		return exitFatal
+	}
+	return exitOK
+}
+
+// errKeyringUnsupported is returned on platforms without a keyring command
+var errKeyringUnsupported = errors.New("the OS keyring is only supported on macOS (security) and Linux (secret-tool); use --token-file instead")
+
+// keyringGet returns the token stored for a management URL. It uses the
+// keychain through security on macOS and the Secret Service through
+// secret-tool on Linux, so no keyring library is linked in.
+func keyringGet(serverURL string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keyringService, "-a", serverURL, "-w")
+	case "linux", "freebsd", "openbsd":
+		cmd = exec.Command("secret-tool", "lookup", "service", keyringService, "account", serverURL)
+	default:
+		return "", errKeyringUnsupported
+	}
+	output, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return "", err
+	}
+	if err != nil {
+		return "", fmt.Errorf("no token stored, run the token store command first (%w)", keyringError(err))
+	}
+	token := strings.TrimSpace(string(output))
+	if token == "" {
+		return "", errors.New("no token stored, run the token store command first")
+	}
+	return token, nil
+}
+
+// keyringSet stores the token of a management URL, replacing a stored one.
+// The token is passed on stdin, never as an argument other users could see.
+func keyringSet(serverURL, token string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		// security -i reads its commands from stdin
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %q -a %q -w %q\n", keyringService, serverURL, token))
+	case "linux", "freebsd", "openbsd":
+		cmd = exec.Command("secret-tool", "store", "--label", "NetBird token for "+serverURL, "service", keyringService, "account", serverURL)
+		cmd.Stdin = strings.NewReader(token)
+	default:
+		return errKeyringUnsupported
+	}
+	return keyringError(cmd.Run())
+}
+
+// keyringDelete deletes the token stored for a management URL
+func keyringDelete(serverURL string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "delete-generic-password", "-s", keyringService, "-a", serverURL)
+	case "linux", "freebsd", "openbsd":
+		cmd = exec.Command("secret-tool", "clear", "service", keyringService, "account", serverURL)
+	default:
+		return errKeyringUnsupported
+	}
+	return keyringError(cmd.Run())
+}
+
+// keyringError adds the output of a failed keyring command to its error
+func keyringError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultVaultField is the field of the Vault secret holding the token when
+// the path does not name one
+const defaultVaultField = "token"
+
+// vaultSecret is the response of a Vault read. KV version 2 nests the secret's
+// fields in data.data, next to data.metadata; version 1 returns them in data.
+type vaultSecret struct {
+	Data   map[string]any `json:"data"`
+	Errors []string       `json:"errors"`
+}
+
+// readVaultToken reads the token from a Vault KV path, as path or
+// path#field. KV version 2 paths include the data segment, e.g.
+// secret/data/netbird#pat. The address, token, namespace and TLS settings come
+// from Vault's standard environment variables.
+func readVaultToken(ctx context.Context, secretPath string) (string, error) {
+	path, field, _ := strings.Cut(secretPath, "#")
+	if field == "" {
+		field = defaultVaultField
+	}
+
+	address := os.Getenv("VAULT_ADDR")
+	if address == "" {
+		return "", errors.New("VAULT_ADDR is required to read the token from Vault")
+	}
+	vaultToken, err := vaultClientToken()
+	if err != nil {
+		return "", err
+	}
+
+	rootCAs, err := loadCACertPool(os.Getenv("VAULT_CACERT"))
+	if err != nil {
+		return "", err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs, InsecureSkipVerify: os.Getenv("VAULT_SKIP_VERIFY") == "true"}
+	client := &http.Client{Transport: transport, Timeout: 30 * time.Second}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(address, "/")+"/v1/"+strings.Trim(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", vaultToken)
+	req.Header.Set("User-Agent", userAgent())
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s from Vault: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	var secret vaultSecret
+	decodeErr := json.NewDecoder(resp.Body).Decode(&secret)
+	if resp.StatusCode != http.StatusOK {
+		if len(secret.Errors) > 0 {
+			return "", fmt.Errorf("failed to read %s from Vault: status %d: %s", path, resp.StatusCode, strings.Join(secret.Errors, "; "))
+		}
+		return "", fmt.Errorf("failed to read %s from Vault: status %d", path, resp.StatusCode)
+	}
+	if decodeErr != nil {
+		return "", fmt.Errorf("failed to decode the Vault response for %s: %w", path, decodeErr)
+	}
+	return vaultField(secret, path, field)
+}
+
+// vaultField returns a string field of a KV version 1 or 2 secret
+func vaultField(secret vaultSecret, path, field string) (string, error) {
+	fields := secret.Data
+	if nested, ok := secret.Data["data"].(map[string]any); ok {
+		if _, versioned := secret.Data["metadata"]; versioned {
+			fields = nested
+		}
+	}
+	token, _ := fields[field].(string)
+	if token == "" {
+		return "", fmt.Errorf("the Vault secret %s has no field %q (name the field as %s#<field>)", path, field, path)
+	}
+	return token, nil
+}
+
+// vaultClientToken returns VAULT_TOKEN, or the token vault login saved in
+// ~/.vault-token
+func vaultClientToken() (string, error) {
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+	home, err := os.UserHomeDir()
+	if err == nil {
+		if data, err := os.ReadFile(filepath.Join(home, ".vault-token")); err == nil && strings.TrimSpace(string(data)) != "" {
+			return strings.TrimSpace(string(data)), nil
+		}
+	}
+	return "", errors.New("VAULT_TOKEN is required to read the token from Vault (or log in with vault login)")
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// version is the importer version, set at build time with
+// -ldflags "-X main.version=<version>"
+var version = "dev"
+
+// importerBuild identifies the exact importer build: the version, followed by
+// the commit it was built from when the binary records one, e.g.
+// "1.4.0+3f2a9c1e5b7d" or "dev+3f2a9c1e5b7d-dirty"
+func importerBuild() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return version
+	}
+	var revision, modified string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value
+		}
+	}
+	if revision == "" {
+		return version
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified == "true" {
+		revision += "-dirty"
+	}
+	return version + "+" + revision
+}
+
+// userAgent identifies the importer in the logs of the management server, and
+// of anything else it talks to, e.g. "netbird-terraformer/1.4.0 (linux/amd64)"
+func userAgent() string {
+	return fmt.Sprintf("netbird-terraformer/%s (%s/%s)", version, runtime.GOOS, runtime.GOARCH)
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"netbird-terraformer/lib"
+)
+
+// Watch defaults. The admin endpoint listens on loopback unless configured
+// otherwise, since it can trigger runs without authentication.
+const (
+	defaultWatchInterval = time.Hour
+	defaultAdminAddr     = "127.0.0.1:9090"
+
+	// childStopTimeout is how long an interrupted run may take to release its
+	// state lock and write its report before it is killed
+	childStopTimeout = 30 * time.Second
+)
+
+// watchRun is the status of one run of the watch loop, served at /status
+type watchRun struct {
+	Trigger    string          `json:"trigger"` // startup, interval or admin
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+	ExitCode   *int            `json:"exit_code,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Report     json.RawMessage `json:"report,omitempty"` // report.json of the run
+}
+
+// watcher runs generate repeatedly and serves the admin endpoint
+type watcher struct {
+	config  *Config
+	args    []string
+	trigger chan string
+
+	mu      sync.Mutex
+	current *watchRun
+	last    *watchRun
+}
+
+// runWatch runs generate now, then every WatchInterval and whenever the admin
+// endpoint asks for it, until ctx is cancelled. Each run is a child process
+// with the same arguments, so a run's fatal error or exit never stops the
+// loop. Cancelling ctx interrupts the running child, which stops at its next
+// safe point like after Ctrl-C.
+func runWatch(ctx context.Context, config *Config, args []string) int {
+	w := &watcher{config: config, args: args, trigger: make(chan string, 1)}
+
+	listener, err := net.Listen("tcp", config.AdminAddr)
+	if err != nil {
+		slog.Error("Failed to start admin endpoint", "addr", config.AdminAddr, "error", err)
+		return exitFatal
+	}
+	server := &http.Server{Handler: w.adminHandler(), ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(listener)
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("Watching NetBird account", "interval", config.WatchInterval, "admin", "http://"+listener.Addr().String())
+
+	trigger := "startup"
+	for {
+		w.run(ctx, trigger)
+
+		timer := time.NewTimer(config.WatchInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			slog.Info("Stopped watching")
+			return exitOK
+		case <-timer.C:
+			trigger = "interval"
+		case trigger = <-w.trigger:
+			timer.Stop()
+		}
+	}
+}
+
+// run runs generate once as a child process and records its outcome
+func (w *watcher) run(ctx context.Context, trigger string) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	status := &watchRun{Trigger: trigger, StartedAt: time.Now().UTC()}
+	w.mu.Lock()
+	w.current = status
+	w.mu.Unlock()
+
+	slog.Info("Starting run", "trigger", trigger)
+	exitCode, err := runChild(ctx, append([]string{commandGenerate}, w.args...), nil)
+	finishedAt := time.Now().UTC()
+	slog.Info("Finished run", "trigger", trigger, "exit_code", exitCode, "duration", finishedAt.Sub(status.StartedAt).Round(time.Millisecond))
+
+	report, readErr := os.ReadFile(filepath.Join(w.config.OutputDir, reportFile))
+
+	// The status may be served concurrently until it is complete
+	w.mu.Lock()
+	status.FinishedAt = &finishedAt
+	status.ExitCode = &exitCode
+	if err != nil {
+		status.Error = err.Error()
+	}
+	if readErr == nil && json.Valid(report) {
+		status.Report = report
+	}
+	w.current = nil
+	w.last = status
+	w.mu.Unlock()
+}
+
+// runChild runs this binary with the given arguments, and environment
+// variables added to this process's, and returns its exit code. On
+// cancellation the child is interrupted rather than killed, so terraform can
+// release its state lock.
+func runChild(ctx context.Context, args []string, env []string) (int, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return exitFatal, err
+	}
+
+	cmd := exec.CommandContext(ctx, executable, args...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Cancel = func() error {
+		return lib.InterruptProcess(cmd.Process)
+	}
+	cmd.WaitDelay = childStopTimeout
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return exitOK, nil
+	case errors.As(err, &exitErr) && exitErr.ExitCode() >= 0:
+		return exitErr.ExitCode(), nil
+	case ctx.Err() != nil:
+		return exitInterrupted, nil
+	default:
+		return exitFatal, err
+	}
+}
+
+// adminHandler serves the admin endpoint:
+//
+//	GET  /healthz  200 while the watch loop runs (liveness)
+//	GET  /readyz   200 once the last finished run succeeded (readiness)
+//	GET  /status   the running and the last finished run, with its report
+//	POST /sync     start a run now, or right after the running one
+func (w *watcher) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(rw http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(rw, http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	mux.HandleFunc("/readyz", func(rw http.ResponseWriter, r *http.Request) {
+		w.mu.Lock()
+		last := w.last
+		w.mu.Unlock()
+
+		switch {
+		case last == nil:
+			writeAdminJSON(rw, http.StatusServiceUnavailable, map[string]string{"status": "no run finished yet"})
+		case *last.ExitCode != exitOK:
+			writeAdminJSON(rw, http.StatusServiceUnavailable, map[string]any{"status": "last run failed", "exit_code": *last.ExitCode})
+		default:
+			writeAdminJSON(rw, http.StatusOK, map[string]string{"status": "ok"})
+		}
+	})
+
+	mux.HandleFunc("/status", func(rw http.ResponseWriter, r *http.Request) {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		writeAdminJSON(rw, http.StatusOK, map[string]any{
+			"interval": w.config.WatchInterval.String(),
+			"running":  w.current,
+			"last_run": w.last,
+		})
+	})
+
+	mux.HandleFunc("/sync", func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			rw.Header().Set("Allow", http.MethodPost)
+			writeAdminJSON(rw, http.StatusMethodNotAllowed, map[string]string{"status": "use POST"})
+			return
+		}
+
+		// At most one run is queued; further requests join it
+		select {
+		case w.trigger <- "admin":
+			writeAdminJSON(rw, http.StatusAccepted, map[string]string{"status": "sync queued"})
+		default:
+			writeAdminJSON(rw, http.StatusAccepted, map[string]string{"status": "sync already queued"})
+		}
+	})
+
+	return mux
+}
+
+// writeAdminJSON writes an admin endpoint response
+func writeAdminJSON(rw http.ResponseWriter, status int, body any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(body)
+}